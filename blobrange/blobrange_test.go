@@ -0,0 +1,126 @@
+package blobrange_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/blobrange"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/memblob"
+)
+
+func setupBucket(t *testing.T, key string, data []byte) *blob.Bucket {
+	t.Helper()
+
+	bucket := memblob.OpenBucket(nil)
+	t.Cleanup(func() { bucket.Close() })
+
+	if err := bucket.WriteAll(t.Context(), key, data, nil); err != nil {
+		t.Fatalf("writing blob should not fail: %s", err)
+	}
+	return bucket
+}
+
+func TestRangeReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	bucket := setupBucket(t, "archive.pmtiles", data)
+
+	tests := []struct {
+		name          string
+		key           string
+		offset        uint64
+		length        uint64
+		expectedData  []byte
+		expectedError string
+	}{
+		{
+			name:         "range",
+			key:          "archive.pmtiles",
+			offset:       5,
+			length:       10,
+			expectedData: data[5:15],
+		},
+		{
+			name:         "range past the end is truncated",
+			key:          "archive.pmtiles",
+			offset:       990,
+			length:       20,
+			expectedData: data[990:],
+		},
+		{
+			name:          "missing blob",
+			key:           "missing.pmtiles",
+			offset:        0,
+			length:        10,
+			expectedError: `reading blob "missing.pmtiles"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := blobrange.NewRangeReader(bucket, tt.key)
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(tt.offset, tt.length))
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer rc.Close()
+
+			result, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(tt.expectedData, result) {
+				t.Fatalf("expected %q, got %q", tt.expectedData, result)
+			}
+		})
+	}
+}
+
+func TestRangeReaderSizeAndVersion(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1234)
+	reader := blobrange.NewRangeReader(setupBucket(t, "archive.pmtiles", data), "archive.pmtiles")
+
+	size, err := reader.Size(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if size != uint64(len(data)) {
+		t.Fatalf("expected size %d, got %d", len(data), size)
+	}
+
+	version, err := reader.Version(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if version == "" {
+		t.Fatal("expected a version")
+	}
+}
+
+func TestRangeReaderSource(t *testing.T) {
+	bucket := setupBucket(t, "archive.pmtiles", pmtilrtest.FixtureArchive(2).Bytes())
+
+	source, err := pmtilr.NewSource(
+		t.Context(),
+		"",
+		pmtilr.WithRangeReader(blobrange.NewRangeReader(bucket, "archive.pmtiles")),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	if _, err := source.Tile(t.Context(), 1, 0, 0); err != nil {
+		t.Fatalf("reading tile should not fail: %s", err)
+	}
+}
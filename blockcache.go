@@ -0,0 +1,280 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	singleflight "github.com/iwpnd/singleflightx"
+	"github.com/maypok86/otter/v2"
+)
+
+const (
+	DefaultBlockSize          = 256 << 10
+	DefaultBlockCacheMaxBytes = 64 << 20
+)
+
+type blockCacheConfig struct {
+	blockSize uint64
+	maxBytes  uint64
+}
+
+// BlockCacheOption is a functional option for configuring a
+// BlockCacheRangeReader.
+type BlockCacheOption = func(config *blockCacheConfig)
+
+// WithBlockSize sets the size of the aligned blocks fetched from the inner
+// RangeReader, defaults to 256 KiB.
+func WithBlockSize(size uint64) BlockCacheOption {
+	return func(config *blockCacheConfig) {
+		config.blockSize = size
+	}
+}
+
+// WithBlockCacheMaxBytes bounds the memory used by cached blocks, defaults
+// to 64 MiB.
+func WithBlockCacheMaxBytes(maxBytes uint64) BlockCacheOption {
+	return func(config *blockCacheConfig) {
+		config.maxBytes = maxBytes
+	}
+}
+
+// blockKey identifies a block of an archive generation.
+type blockKey struct {
+	etag  string
+	index uint64
+}
+
+// BlockCacheRangeReader is an in-memory read-through cache fetching fixed-size
+// aligned blocks from the inner RangeReader and serving arbitrary ranges from
+// them. Neighboring tiles are usually stored next to each other, so dense
+// tile traffic is served by a few block reads instead of one read per tile.
+//
+// Blocks are cached by the ETag of the inner reader if it implements
+// ETagger, so blocks of a replaced archive are not served. Consecutive
+// missing blocks of a range are fetched with a single read and concurrent
+// reads of the same blocks are deduplicated.
+type BlockCacheRangeReader struct {
+	inner RangeReader
+	cfg   blockCacheConfig
+	cache *otter.Cache[blockKey, []byte]
+	sg    *singleflight.ShardedGroup[string, [][]byte]
+}
+
+// NewBlockCacheRangeReader creates a BlockCacheRangeReader caching blocks
+// read from inner.
+func NewBlockCacheRangeReader(inner RangeReader, options ...BlockCacheOption) (*BlockCacheRangeReader, error) {
+	cfg := blockCacheConfig{
+		blockSize: DefaultBlockSize,
+		maxBytes:  DefaultBlockCacheMaxBytes,
+	}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+	if cfg.blockSize == 0 {
+		return nil, fmt.Errorf("invalid block size: %d", cfg.blockSize)
+	}
+
+	cache, err := otter.New(&otter.Options[blockKey, []byte]{
+		MaximumWeight: cfg.maxBytes,
+		Weigher: func(_ blockKey, block []byte) uint32 {
+			return uint32(min(uint64(len(block))+1, math.MaxUint32)) //nolint:gosec // clamped
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating block cache: %w", err)
+	}
+
+	return &BlockCacheRangeReader{
+		inner: inner,
+		cfg:   cfg,
+		cache: cache,
+		sg:    singleflight.NewShardedGroup[string, [][]byte](),
+	}, nil
+}
+
+// ReadRange serves the range from cached blocks, reading missing blocks from
+// the inner RangeReader. Like files, ranges past the end of the archive are
+// truncated.
+func (b *BlockCacheRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	etag := readerETag(b.inner)
+	offset, end := ranger.Offset(), ranger.Offset()+ranger.Length()
+	first, last := offset/b.cfg.blockSize, (end-1)/b.cfg.blockSize
+
+	blocks := make([][]byte, 0, last-first+1)
+	for index := first; index <= last; {
+		if block, ok := b.cache.GetIfPresent(blockKey{etag: etag, index: index}); ok {
+			blocks = append(blocks, block)
+			index++
+			continue
+		}
+
+		missing := index + 1
+		for missing <= last {
+			if _, ok := b.cache.GetIfPresent(blockKey{etag: etag, index: missing}); ok {
+				break
+			}
+			missing++
+		}
+
+		fetched, err := b.fetch(ctx, etag, index, missing-index)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, fetched...)
+		index = missing
+	}
+
+	readers := make([]io.Reader, 0, len(blocks))
+	for i, block := range blocks {
+		start := first*b.cfg.blockSize + uint64(i)*b.cfg.blockSize
+		lo := max(offset, start) - start
+		hi := min(end-start, uint64(len(block)))
+		if lo >= hi {
+			break
+		}
+		readers = append(readers, bytes.NewReader(block[lo:hi]))
+		if uint64(len(block)) < b.cfg.blockSize {
+			// a short block is the last block of the archive.
+			break
+		}
+	}
+
+	return io.NopCloser(io.MultiReader(readers...)), nil
+}
+
+// fetch reads count blocks starting at index with a single read and caches
+// them. Blocks past the end of the archive are returned empty.
+func (b *BlockCacheRangeReader) fetch(
+	ctx context.Context,
+	etag string,
+	index, count uint64,
+) ([][]byte, error) {
+	key := etag + ":" + strconv.FormatUint(index, 10) + ":" + strconv.FormatUint(count, 10)
+
+	for {
+		blocks, err, _ := b.sg.Do(key, func() ([][]byte, error) {
+			blocks, err := b.readBlocks(ctx, etag, index, count)
+			if err != nil && ctx.Err() != nil {
+				return nil, &leaderCanceledError{err: err}
+			}
+			return blocks, err
+		})
+		// the read is shared with the context of the caller that started it, so
+		// the other callers retry if that one was canceled.
+		var canceled *leaderCanceledError
+		if errors.As(err, &canceled) && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fetching blocks %d-%d: %w", index, index+count-1, err)
+		}
+		return blocks, nil
+	}
+}
+
+// leaderCanceledError is returned by a shared fetch whose context ended
+// before the read completed.
+type leaderCanceledError struct {
+	err error
+}
+
+func (e *leaderCanceledError) Error() string { return e.err.Error() }
+
+func (e *leaderCanceledError) Unwrap() error { return e.err }
+
+// readBlocks reads count blocks starting at index into the cache.
+func (b *BlockCacheRangeReader) readBlocks(
+	ctx context.Context,
+	etag string,
+	index, count uint64,
+) ([][]byte, error) {
+	// a read of the same blocks may have completed since the cache miss.
+	if blocks, ok := b.cached(etag, index, count); ok {
+		return blocks, nil
+	}
+
+	rc, err := b.inner.ReadRange(ctx, NewRange(index*b.cfg.blockSize, count*b.cfg.blockSize))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	blockLen, err := bufferLen(b.cfg.blockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// blocks get their own buffers, so evicting one frees its memory.
+	blocks := make([][]byte, count)
+	eof := false
+	for i := range count {
+		var block []byte
+		if !eof {
+			block = make([]byte, blockLen)
+			n, err := readBlock(rc, block)
+			switch {
+			case errors.Is(err, io.EOF):
+				block, eof = bytes.Clone(block[:n]), true
+			case err != nil:
+				return nil, fmt.Errorf("reading blocks: %w", err)
+			}
+		}
+		blocks[i] = block
+		b.cache.Set(blockKey{etag: etag, index: index + i}, block)
+	}
+	return blocks, nil
+}
+
+// cached returns count blocks starting at index if all are cached.
+func (b *BlockCacheRangeReader) cached(etag string, index, count uint64) ([][]byte, bool) {
+	blocks := make([][]byte, count)
+	for i := range count {
+		block, ok := b.cache.GetIfPresent(blockKey{etag: etag, index: index + i})
+		if !ok {
+			return nil, false
+		}
+		blocks[i] = block
+	}
+	return blocks, true
+}
+
+// readBlock fills block from r. Unlike io.ReadFull it only reports io.EOF
+// if r ended, not if r failed with io.ErrUnexpectedEOF, e.g. for a truncated
+// HTTP response, which must not be cached as the end of the archive.
+func readBlock(r io.Reader, block []byte) (int, error) {
+	var n int
+	for n < len(block) {
+		m, err := r.Read(block[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Clear drops all cached blocks.
+func (b *BlockCacheRangeReader) Clear() {
+	b.cache.InvalidateAll()
+}
+
+// Unwrap returns the inner reader, see Unwrapper.
+func (b *BlockCacheRangeReader) Unwrap() RangeReader {
+	return b.inner
+}
+
+// Close drops all cached blocks and closes the inner reader if it
+// implements io.Closer.
+func (b *BlockCacheRangeReader) Close() error {
+	b.Clear()
+	return CloseReader(b.inner)
+}
@@ -0,0 +1,164 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+// countingRangeReader counts the reads of a RangeReader that truncates
+// ranges past the end of the data, like S3 and HTTP servers do.
+type countingRangeReader struct {
+	*pmtilr.BytesRangeReader
+	calls atomic.Int64
+}
+
+func (c *countingRangeReader) ReadRange(ctx context.Context, ranger pmtilr.Ranger) (io.ReadCloser, error) {
+	c.calls.Add(1)
+	return c.BytesRangeReader.ReadRange(ctx, ranger)
+}
+
+func TestBlockCacheRangeReader(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz") // 36 bytes, 5 blocks of 8
+
+	tests := []struct {
+		name   string
+		ranges []pmtilr.Range
+		want   []string
+		calls  int64
+	}{
+		{
+			name:   "within a block",
+			ranges: []pmtilr.Range{pmtilr.NewRange(1, 3), pmtilr.NewRange(4, 4)},
+			want:   []string{"123", "4567"},
+			calls:  1,
+		},
+		{
+			name:   "across blocks in a single read",
+			ranges: []pmtilr.Range{pmtilr.NewRange(6, 12)},
+			want:   []string{"6789abcdefgh"},
+			calls:  1,
+		},
+		{
+			name:   "only missing blocks",
+			ranges: []pmtilr.Range{pmtilr.NewRange(8, 2), pmtilr.NewRange(0, 20)},
+			want:   []string{"89", "0123456789abcdefghij"},
+			calls:  3,
+		},
+		{
+			name:   "past the end",
+			ranges: []pmtilr.Range{pmtilr.NewRange(30, 20), pmtilr.NewRange(34, 1)},
+			want:   []string{"uvwxyz", "y"},
+			calls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingRangeReader{BytesRangeReader: pmtilr.NewBytesRangeReader(data)}
+			reader, err := pmtilr.NewBlockCacheRangeReader(inner, pmtilr.WithBlockSize(8))
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+
+			for i, rng := range tt.ranges {
+				rc, err := reader.ReadRange(t.Context(), rng)
+				if err != nil {
+					t.Fatalf("reading range: %v", err)
+				}
+				got, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil || string(got) != tt.want[i] {
+					t.Errorf("got %q, %v, want %q", got, err, tt.want[i])
+				}
+			}
+			if got := inner.calls.Load(); got != tt.calls {
+				t.Errorf("got %d inner reads, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestBlockCacheRangeReaderConcurrent(t *testing.T) {
+	data := bytes.Repeat([]byte("pmtiles!"), 1024)
+	mock := pmtilrtest.NewRangeReader(data).WithETag("v1")
+	reader, err := pmtilr.NewBlockCacheRangeReader(mock, pmtilr.WithBlockSize(1024))
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 64 {
+		wg.Go(func() {
+			offset := uint64(i * 16)
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(offset, 16))
+			if err != nil {
+				t.Errorf("reading range: %v", err)
+				return
+			}
+			defer rc.Close()
+			got, _ := io.ReadAll(rc)
+			if !bytes.Equal(got, data[offset:offset+16]) {
+				t.Errorf("got %q at %d", got, offset)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got := len(mock.Calls()); got != 1 {
+		t.Errorf("got %d inner reads, want 1", got)
+	}
+
+	mock.WithETag("v2")
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 8))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	rc.Close()
+	if got := len(mock.Calls()); got != 2 {
+		t.Errorf("expected a changed etag to bypass cached blocks, got %d reads", got)
+	}
+}
+
+func TestBlockCacheRangeReaderCanceledLeader(t *testing.T) {
+	data := []byte("0123456789abcdef")
+	mock := pmtilrtest.NewRangeReader(data).WithLatency(100 * time.Millisecond)
+	reader, err := pmtilr.NewBlockCacheRangeReader(mock, pmtilr.WithBlockSize(8))
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := reader.ReadRange(ctx, pmtilr.NewRange(0, 4))
+		leader <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	waiter := make(chan error, 1)
+	go func() {
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+		if err == nil {
+			rc.Close()
+		}
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled caller to fail, got %v", err)
+	}
+	if err := <-waiter; err != nil {
+		t.Errorf("expected the waiting caller to retry the read, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package pmtilr
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	indexMinLon = 0
+	indexMinLat = 1
+	indexMaxLon = 2
+	indexMaxLat = 3
+
+	// maxMercatorLat is the latitude limit of the web mercator projection.
+	maxMercatorLat = 85.0511287798066
+
+	// mercatorExtent is half the width of the web mercator projection in
+	// meters.
+	mercatorExtent = 20037508.342789244
+)
+
+// Bounds defines a WGS84 bounding box [MinLon, MinLat, MaxLon, MaxLat]
+// in degrees.
+type Bounds [4]float64
+
+// NewBounds constructs Bounds from its corners.
+func NewBounds(minLon, minLat, maxLon, maxLat float64) Bounds {
+	return Bounds{minLon, minLat, maxLon, maxLat}
+}
+
+// MinLon returns the western edge of the bounds.
+func (b Bounds) MinLon() float64 {
+	return b[indexMinLon]
+}
+
+// MinLat returns the southern edge of the bounds.
+func (b Bounds) MinLat() float64 {
+	return b[indexMinLat]
+}
+
+// MaxLon returns the eastern edge of the bounds.
+func (b Bounds) MaxLon() float64 {
+	return b[indexMaxLon]
+}
+
+// MaxLat returns the northern edge of the bounds.
+func (b Bounds) MaxLat() float64 {
+	return b[indexMaxLat]
+}
+
+// Validate ensures that the minimum corner is not greater than the maximum.
+func (b Bounds) Validate() error {
+	if b.MinLon() > b.MaxLon() || b.MinLat() > b.MaxLat() {
+		return fmt.Errorf(
+			"min corner %f,%f cannot be greater than max corner %f,%f",
+			b.MinLon(), b.MinLat(), b.MaxLon(), b.MaxLat(),
+		)
+	}
+	return nil
+}
+
+// TileRange returns the inclusive x/y range of tiles at zoom intersecting
+// the bounds.
+func (b Bounds) TileRange(zoom uint8) (minX, minY, maxX, maxY uint64) {
+	minX, maxY = lonLatToTile(b.MinLon(), b.MinLat(), zoom)
+	maxX, minY = lonLatToTile(b.MaxLon(), b.MaxLat(), zoom)
+	return minX, minY, maxX, maxY
+}
+
+// TileBounds returns the WGS84 bounds of the tile at z/x/y.
+func TileBounds(z, x, y uint64) (Bounds, error) {
+	if err := validateTile(z, x, y); err != nil {
+		return Bounds{}, err
+	}
+
+	n := float64(uint64(1) << z)
+	lon := func(x uint64) float64 { return float64(x)/n*360 - 180 }
+	lat := func(y uint64) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi
+	}
+	return NewBounds(lon(x), lat(y+1), lon(x+1), lat(y)), nil
+}
+
+// MercatorBounds defines a web mercator (EPSG:3857) bounding box
+// [MinX, MinY, MaxX, MaxY] in meters.
+type MercatorBounds [4]float64
+
+// TileMercatorBounds returns the EPSG:3857 bounds of the tile at z/x/y,
+// e.g. to place decoded tiles in a rendering pipeline.
+func TileMercatorBounds(z, x, y uint64) (MercatorBounds, error) {
+	if err := validateTile(z, x, y); err != nil {
+		return MercatorBounds{}, err
+	}
+
+	size := 2 * mercatorExtent / float64(uint64(1)<<z)
+	return MercatorBounds{
+		-mercatorExtent + float64(x)*size,
+		mercatorExtent - float64(y+1)*size,
+		-mercatorExtent + float64(x+1)*size,
+		mercatorExtent - float64(y)*size,
+	}, nil
+}
+
+// validateTile ensures that z/x/y addresses a tile up to MaxZ.
+func validateTile(z, x, y uint64) error {
+	if z > MaxZ {
+		return fmt.Errorf("zoom %d exceeds limit of %d", z, MaxZ)
+	}
+	if x >= 1<<z || y >= 1<<z {
+		return fmt.Errorf("tile coordinates x/y (%d/%d) outside of bounds for zoom %d", x, y, z)
+	}
+	return nil
+}
+
+// lonLatToTile returns the x/y of the web mercator tile containing lon/lat
+// at zoom, clamped to the valid tile range.
+func lonLatToTile(lon, lat float64, zoom uint8) (uint64, uint64) {
+	n := float64(uint64(1) << zoom)
+	p := lonLatToTileFloat(lon, lat, zoom)
+
+	clamp := func(v float64) uint64 {
+		return uint64(min(max(math.Floor(v), 0), n-1))
+	}
+	return clamp(p[0]), clamp(p[1])
+}
+
+// lonLatToTileFloat returns the fractional web mercator tile coordinates of
+// lon/lat at zoom.
+func lonLatToTileFloat(lon, lat float64, zoom uint8) [2]float64 {
+	n := float64(uint64(1) << zoom)
+	lat = min(max(lat, -maxMercatorLat), maxMercatorLat)
+	lon = min(max(lon, -180), 180)
+
+	rad := lat * math.Pi / 180
+	return [2]float64{
+		(lon + 180) / 360 * n,
+		(1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2 * n,
+	}
+}
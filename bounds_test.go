@@ -0,0 +1,63 @@
+package pmtilr_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+const epsilon = 1e-6
+
+func TestTileBounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		z, x, y   uint64
+		wgs84     pmtilr.Bounds
+		mercator  pmtilr.MercatorBounds
+		wantError bool
+	}{
+		{
+			name: "root", z: 0, x: 0, y: 0,
+			wgs84:    pmtilr.NewBounds(-180, -85.0511287798066, 180, 85.0511287798066),
+			mercator: pmtilr.MercatorBounds{-20037508.342789244, -20037508.342789244, 20037508.342789244, 20037508.342789244},
+		},
+		{
+			name: "north east z1", z: 1, x: 1, y: 0,
+			wgs84:    pmtilr.NewBounds(0, 0, 180, 85.0511287798066),
+			mercator: pmtilr.MercatorBounds{0, 0, 20037508.342789244, 20037508.342789244},
+		},
+		{
+			name: "south west z2", z: 2, x: 0, y: 3,
+			wgs84:    pmtilr.NewBounds(-180, -85.0511287798066, -90, -66.51326044311186),
+			mercator: pmtilr.MercatorBounds{-20037508.342789244, -20037508.342789244, -10018754.171394622, -10018754.171394622},
+		},
+		{name: "beyond max zoom", z: pmtilr.MaxZ + 1, wantError: true},
+		{name: "outside zoom", z: 1, x: 0, y: 2, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wgs84, err := pmtilr.TileBounds(tt.z, tt.x, tt.y)
+			if tt.wantError != (err != nil) {
+				t.Fatalf("expected error %t, got: %v", tt.wantError, err)
+			}
+			mercator, err := pmtilr.TileMercatorBounds(tt.z, tt.x, tt.y)
+			if tt.wantError != (err != nil) {
+				t.Fatalf("expected error %t, got: %v", tt.wantError, err)
+			}
+			if tt.wantError {
+				return
+			}
+
+			for i := range wgs84 {
+				if math.Abs(wgs84[i]-tt.wgs84[i]) > epsilon {
+					t.Fatalf("expected %v, got: %v", tt.wgs84, wgs84)
+				}
+				if math.Abs(mercator[i]-tt.mercator[i]) > epsilon {
+					t.Fatalf("expected %v, got: %v", tt.mercator, mercator)
+				}
+			}
+		})
+	}
+}
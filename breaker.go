@@ -0,0 +1,184 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a CircuitBreakerRangeReader.
+type BreakerState uint8
+
+const (
+	// BreakerClosed passes reads to the backend.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails reads with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen passes a single probe read to the backend.
+	BreakerHalfOpen
+)
+
+var breakerStateStrings = map[BreakerState]string{
+	BreakerClosed:   "closed",
+	BreakerOpen:     "open",
+	BreakerHalfOpen: "half-open",
+}
+
+func (s BreakerState) String() string {
+	return breakerStateStrings[s]
+}
+
+type breakerConfig struct {
+	failureThreshold int
+	cooldown         time.Duration
+	isFailure        func(error) bool
+}
+
+// BreakerOption is a functional option for configuring a
+// CircuitBreakerRangeReader.
+type BreakerOption = func(config *breakerConfig)
+
+// WithBreakerFailureThreshold sets the number of consecutive failures that
+// open the breaker. Defaults to 5.
+func WithBreakerFailureThreshold(n int) BreakerOption {
+	return func(config *breakerConfig) {
+		config.failureThreshold = max(n, 1)
+	}
+}
+
+// WithBreakerCooldown sets how long the breaker stays open before a probe
+// read is let through. Defaults to 30s.
+func WithBreakerCooldown(d time.Duration) BreakerOption {
+	return func(config *breakerConfig) {
+		config.cooldown = d
+	}
+}
+
+// WithBreakerFailure classifies the errors counting as backend failures,
+// defaults to IsRetryable, so e.g. ErrArchiveChanged or a 404 do not open
+// the breaker.
+func WithBreakerFailure(isFailure func(error) bool) BreakerOption {
+	return func(config *breakerConfig) {
+		config.isFailure = isFailure
+	}
+}
+
+// CircuitBreakerRangeReader stops reading from a backend that keeps
+// failing, so tile requests fail fast with ErrCircuitOpen instead of piling
+// up against a dead origin. After a number of consecutive failures the
+// breaker opens; once the cooldown has passed a single probe read is let
+// through, which closes the breaker on success or opens it again.
+//
+// Wrap it in a BlockCacheRangeReader or DiskCacheRangeReader to keep
+// serving cached ranges while the breaker is open, or in a
+// FallbackRangeReader to read from a mirror instead.
+type CircuitBreakerRangeReader struct {
+	reader RangeReader
+	cfg    breakerConfig
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+}
+
+// NewCircuitBreakerRangeReader wraps reader with a circuit breaker.
+func NewCircuitBreakerRangeReader(reader RangeReader, options ...BreakerOption) *CircuitBreakerRangeReader {
+	cfg := breakerConfig{
+		failureThreshold: defaultBreakerFailureThreshold,
+		cooldown:         defaultBreakerCooldown,
+		isFailure:        IsRetryable,
+	}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+	return &CircuitBreakerRangeReader{reader: reader, cfg: cfg}
+}
+
+// State returns the current state of the breaker.
+func (c *CircuitBreakerRangeReader) State() BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == BreakerOpen && !time.Now().Before(c.openUntil) {
+		return BreakerHalfOpen
+	}
+	return c.state
+}
+
+// ReadRange reads the range unless the breaker is open.
+func (c *CircuitBreakerRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := c.allow(); err != nil {
+		return nil, err
+	}
+
+	rc, err := c.reader.ReadRange(ctx, ranger)
+	c.record(ctx, err)
+	return rc, err
+}
+
+// allow admits a read, or the probe read once the cooldown has passed.
+func (c *CircuitBreakerRangeReader) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case BreakerClosed:
+		return nil
+	case BreakerOpen:
+		if time.Now().Before(c.openUntil) {
+			return fmt.Errorf("%w until %s", ErrCircuitOpen, c.openUntil.Format(time.RFC3339))
+		}
+		c.state = BreakerHalfOpen
+		return nil
+	default:
+		// a probe read is in flight.
+		return fmt.Errorf("%w: probing backend", ErrCircuitOpen)
+	}
+}
+
+// record updates the breaker with the outcome of a read.
+func (c *CircuitBreakerRangeReader) record(ctx context.Context, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil && ctx.Err() != nil {
+		// a cancelled probe is inconclusive, let the next read probe.
+		if c.state == BreakerHalfOpen {
+			c.state = BreakerOpen
+		}
+		return
+	}
+
+	switch {
+	case err == nil || !c.cfg.isFailure(err):
+		c.state, c.failures = BreakerClosed, 0
+	case c.state == BreakerHalfOpen:
+		c.open()
+	default:
+		c.failures++
+		if c.failures >= c.cfg.failureThreshold {
+			c.open()
+		}
+	}
+}
+
+func (c *CircuitBreakerRangeReader) open() {
+	c.state, c.failures = BreakerOpen, 0
+	c.openUntil = time.Now().Add(c.cfg.cooldown)
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (c *CircuitBreakerRangeReader) Unwrap() RangeReader {
+	return c.reader
+}
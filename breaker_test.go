@@ -0,0 +1,126 @@
+package pmtilr_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestCircuitBreakerRangeReader(t *testing.T) {
+	errDown := fmt.Errorf("dial: %w", &pmtilr.UpstreamStatusError{StatusCode: 503})
+	errNotFound := &pmtilr.UpstreamStatusError{StatusCode: 404}
+	ok := pmtilrtest.Response{}
+
+	tests := []struct {
+		name           string
+		responses      []pmtilrtest.Response
+		reads          int
+		expectedErrs   []error
+		expectedCalls  int
+		expectedState  pmtilr.BreakerState
+		waitAndProbe   bool
+		expectedProbed pmtilr.BreakerState
+	}{
+		{
+			name:          "successful reads keep the breaker closed",
+			responses:     []pmtilrtest.Response{ok, ok, ok},
+			reads:         3,
+			expectedErrs:  []error{nil, nil, nil},
+			expectedCalls: 3,
+			expectedState: pmtilr.BreakerClosed,
+		},
+		{
+			name:          "consecutive failures open the breaker",
+			responses:     []pmtilrtest.Response{{Err: errDown}, {Err: errDown}},
+			reads:         3,
+			expectedErrs:  []error{errDown, errDown, pmtilr.ErrCircuitOpen},
+			expectedCalls: 2,
+			expectedState: pmtilr.BreakerOpen,
+		},
+		{
+			name:          "success resets the failures",
+			responses:     []pmtilrtest.Response{{Err: errDown}, ok, {Err: errDown}, ok},
+			reads:         4,
+			expectedErrs:  []error{errDown, nil, errDown, nil},
+			expectedCalls: 4,
+			expectedState: pmtilr.BreakerClosed,
+		},
+		{
+			name:          "non transient errors do not count",
+			responses:     []pmtilrtest.Response{{Err: errNotFound}, {Err: errNotFound}, {Err: errNotFound}},
+			reads:         3,
+			expectedErrs:  []error{errNotFound, errNotFound, errNotFound},
+			expectedCalls: 3,
+			expectedState: pmtilr.BreakerClosed,
+		},
+		{
+			name:           "successful probe closes the breaker",
+			responses:      []pmtilrtest.Response{{Err: errDown}, {Err: errDown}, ok},
+			reads:          2,
+			expectedErrs:   []error{errDown, errDown},
+			expectedCalls:  3,
+			expectedState:  pmtilr.BreakerOpen,
+			waitAndProbe:   true,
+			expectedProbed: pmtilr.BreakerClosed,
+		},
+		{
+			name:           "failed probe opens the breaker again",
+			responses:      []pmtilrtest.Response{{Err: errDown}, {Err: errDown}, {Err: errDown}},
+			reads:          2,
+			expectedErrs:   []error{errDown, errDown},
+			expectedCalls:  3,
+			expectedState:  pmtilr.BreakerOpen,
+			waitAndProbe:   true,
+			expectedProbed: pmtilr.BreakerOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pmtilrtest.NewRangeReader([]byte("0123456789")).WithResponses(tt.responses...)
+			reader := pmtilr.NewCircuitBreakerRangeReader(
+				mock,
+				pmtilr.WithBreakerFailureThreshold(2),
+				pmtilr.WithBreakerCooldown(20*time.Millisecond),
+			)
+
+			read := func() error {
+				rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+				if err == nil {
+					io.ReadAll(rc)
+					rc.Close()
+				}
+				return err
+			}
+
+			for i := range tt.reads {
+				if err := read(); !errors.Is(err, tt.expectedErrs[i]) {
+					t.Fatalf("read %d: expected %v, got %v", i, tt.expectedErrs[i], err)
+				}
+			}
+			if got := reader.State(); got != tt.expectedState {
+				t.Errorf("expected state %s, got %s", tt.expectedState, got)
+			}
+
+			if tt.waitAndProbe {
+				time.Sleep(30 * time.Millisecond)
+				if got := reader.State(); got != pmtilr.BreakerHalfOpen {
+					t.Errorf("expected state %s after the cooldown, got %s", pmtilr.BreakerHalfOpen, got)
+				}
+				read()
+				if got := reader.State(); got != tt.expectedProbed {
+					t.Errorf("expected state %s after the probe, got %s", tt.expectedProbed, got)
+				}
+			}
+
+			if got := len(mock.Calls()); got != tt.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tt.expectedCalls, got)
+			}
+		})
+	}
+}
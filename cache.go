@@ -2,8 +2,11 @@ package pmtilr
 
 import (
 	"context"
+	"encoding/binary"
+	"iter"
 	"strconv"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/maypok86/otter/v2"
 )
 
@@ -14,10 +17,32 @@ type Cacher interface {
 	Clear()
 }
 
+// KeyFunc derives the directory cache and singleflight key of the directory
+// at offset and length of an archive generation identified by etag.
+type KeyFunc = func(etag string, offset, length uint64) string
+
+// HashKey is the default KeyFunc. It returns a fixed-size 24 byte key: the
+// xxhash of the etag followed by offset and length. Directories of the same
+// archive never collide, directories of different archives only if the
+// 64-bit hashes of their etags do. Use ConcatKey to rule that out.
+func HashKey(etag string, offset, length uint64) string {
+	var buf [24]byte
+	binary.LittleEndian.PutUint64(buf[0:], xxhash.Sum64String(etag))
+	binary.LittleEndian.PutUint64(buf[8:], offset)
+	binary.LittleEndian.PutUint64(buf[16:], length)
+	return string(buf[:])
+}
+
+// ConcatKey is a KeyFunc returning exact "etag:offset:length" keys, which
+// grow with the etag.
+func ConcatKey(etag string, offset, length uint64) string {
+	return buildCacheKey(etag, offset, length)
+}
+
 // buildCacheKey efficiently builds a singleflight key using a shared buffer pool
 func buildCacheKey(etag string, offset, length uint64) string {
-	bufPtr, _ := keyBufPool.Get().(*[]byte) //nolint:errcheck
-	buf := (*bufPtr)[:0]                    // Reset length but keep capacity
+	bufPtr := keyBufPool.Get()
+	buf := (*bufPtr)[:0] // Reset length but keep capacity
 	defer keyBufPool.Put(bufPtr)
 
 	buf = append(buf, etag...)
@@ -59,6 +84,11 @@ func (oc *OtterCache) Set(_ context.Context, key string, value Directory) bool {
 	return ok
 }
 
+// All yields the cached directories by key, see IterableCacher.
+func (oc *OtterCache) All(_ context.Context) iter.Seq2[string, Directory] {
+	return oc.cache.All()
+}
+
 func (oc *OtterCache) Close() {}
 
 func (oc *OtterCache) Clear() {}
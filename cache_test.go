@@ -26,3 +26,44 @@ func TestBuildCacheKey(t *testing.T) {
 		})
 	}
 }
+
+func TestHashKey(t *testing.T) {
+	keys := map[string]struct{}{}
+	for _, args := range []struct {
+		etag           string
+		offset, length uint64
+	}{
+		{"v1", 127, 300},
+		{"v1", 127, 301},
+		{"v1", 128, 300},
+		{"v2", 127, 300},
+		{"a-much-longer-etag-of-a-remote-archive", 127, 300},
+	} {
+		key := HashKey(args.etag, args.offset, args.length)
+		if len(key) != 24 {
+			t.Errorf("expected 24 byte key, got %d", len(key))
+		}
+		if key != HashKey(args.etag, args.offset, args.length) {
+			t.Errorf("expected stable key for %v", args)
+		}
+		keys[key] = struct{}{}
+	}
+	if len(keys) != 5 {
+		t.Errorf("expected 5 distinct keys, got %d", len(keys))
+	}
+}
+
+func BenchmarkKeyFunc(b *testing.B) {
+	const etag = `"d41d8cd98f00b204e9800998ecf8427e-12"`
+
+	for name, keyFunc := range map[string]KeyFunc{"hash": HashKey, "concat": ConcatKey} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var i uint64
+			for b.Loop() {
+				_ = keyFunc(etag, 127+i, 16384)
+				i++
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package pmtilr
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// surrogateKeyPrefix prefixes all surrogate keys generated by pmtilr.
+const surrogateKeyPrefix = "pmtilr-"
+
+// ArchiveKey returns a compact, stable key for an archive generation derived
+// from its etag (HeaderV3.Etag). It is safe to use in URLs, cache keys and
+// surrogate key headers.
+func ArchiveKey(etag string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(etag)) //nolint:errcheck
+	return surrogateKeyPrefix + strconv.FormatUint(h.Sum64(), 36)
+}
+
+// TileCacheKey returns a stable cache key for a tile of an archive generation,
+// e.g. "pmtilr-1x2y3z/14/8943/5372". The key changes whenever the archive
+// etag changes.
+func TileCacheKey(etag string, z, x, y uint64) string {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, ArchiveKey(etag)...)
+	buf = append(buf, '/')
+	buf = strconv.AppendUint(buf, z, 10)
+	buf = append(buf, '/')
+	buf = strconv.AppendUint(buf, x, 10)
+	buf = append(buf, '/')
+	buf = strconv.AppendUint(buf, y, 10)
+	return string(buf)
+}
+
+// TileETag returns a strong HTTP ETag for a tile of an archive generation.
+func TileETag(etag string, z, x, y uint64) string {
+	return `"` + TileCacheKey(etag, z, x, y) + `"`
+}
+
+// SurrogateKeys returns the surrogate keys (Fastly Surrogate-Key, Akamai
+// Edge-Cache-Tag) of a tile: the archive key and the archive zoom level key,
+// so all tiles of an archive or of a single zoom level can be purged at once.
+func SurrogateKeys(etag string, z uint64) []string {
+	archive := ArchiveKey(etag)
+	return []string{archive, archive + "-z" + strconv.FormatUint(z, 10)}
+}
+
+// PurgeKey returns the surrogate key that purges all tiles of an archive
+// generation from a CDN.
+func PurgeKey(etag string) string {
+	return ArchiveKey(etag)
+}
+
+// CacheControl returns a Cache-Control value for publicly cacheable tiles.
+// Set immutable only if the tile URL changes with the archive, e.g. when it
+// contains ArchiveKey.
+func CacheControl(maxAge time.Duration, immutable bool) string {
+	v := "public, max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	if immutable {
+		v += ", immutable"
+	}
+	return v
+}
+
+// SetTileCacheHeaders sets CDN friendly caching headers for a tile response:
+// Cache-Control, ETag, Vary and Surrogate-Key.
+func SetTileCacheHeaders(
+	h http.Header,
+	etag string,
+	z, x, y uint64,
+	maxAge time.Duration,
+	immutable bool,
+) {
+	h.Set("Cache-Control", CacheControl(maxAge, immutable))
+	h.Set("ETag", TileETag(etag, z, x, y))
+	h.Set("Vary", "Accept-Encoding")
+	h.Set("Surrogate-Key", strings.Join(SurrogateKeys(etag, z), " "))
+}
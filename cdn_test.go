@@ -0,0 +1,79 @@
+package pmtilr_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestSetTileCacheHeaders(t *testing.T) {
+	h := http.Header{}
+	pmtilr.SetTileCacheHeaders(h, `"v1"`, 14, 8943, 5372, 24*time.Hour, true)
+
+	if got := h.Get("Cache-Control"); got != "public, max-age=86400, immutable" {
+		t.Errorf("unexpected Cache-Control: %s", got)
+	}
+	if got := h.Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("unexpected Vary: %s", got)
+	}
+
+	archiveKey := pmtilr.ArchiveKey(`"v1"`)
+	if got := h.Get("ETag"); got != `"`+archiveKey+`/14/8943/5372"` {
+		t.Errorf("unexpected ETag: %s", got)
+	}
+	if got := h.Get("Surrogate-Key"); got != archiveKey+" "+archiveKey+"-z14" {
+		t.Errorf("unexpected Surrogate-Key: %s", got)
+	}
+	if !strings.Contains(h.Get("Surrogate-Key"), pmtilr.PurgeKey(`"v1"`)) {
+		t.Errorf("expected purge key to be a surrogate key of the tile")
+	}
+
+	if pmtilr.ArchiveKey(`"v1"`) == pmtilr.ArchiveKey(`"v2"`) {
+		t.Errorf("expected archive keys of different etags to differ")
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	if got := pmtilr.CacheControl(time.Minute, false); got != "public, max-age=60" {
+		t.Errorf("unexpected Cache-Control: %s", got)
+	}
+}
+
+func TestMetadataTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		want     time.Duration
+	}{
+		{name: "unset", metadata: map[string]any{}, want: time.Hour},
+		{name: "string", metadata: map[string]any{"pmtilr:ttl": "86400"}, want: 24 * time.Hour},
+		{name: "number", metadata: map[string]any{"pmtilr:ttl": 60}, want: time.Minute},
+		{name: "invalid", metadata: map[string]any{"pmtilr:ttl": "1d"}, want: time.Hour},
+		{name: "negative", metadata: map[string]any{"pmtilr:ttl": -1}, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := pmtilrtest.NewArchive().
+				WithTile(0, 0, 0, []byte("tile")).
+				WithMetadata(tt.metadata)
+
+			source, err := pmtilr.NewSource(
+				t.Context(),
+				"memory",
+				pmtilr.WithRangeReader(archive.RangeReader()),
+			)
+			if err != nil {
+				t.Fatalf("creating source: %v", err)
+			}
+
+			if got := source.Meta().CacheMaxAge(time.Hour); got != tt.want {
+				t.Errorf("cache max age = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
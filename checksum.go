@@ -0,0 +1,277 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const DefaultChecksumChunkSize = 1 << 20
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError is returned by a ChecksumRangeReader when the SHA-256 of the
+// archive or of a chunk differs from the expected one. It matches
+// ErrChecksumMismatch.
+type ChecksumError struct {
+	Offset   uint64
+	Length   uint64
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf(
+		"%s: sha256 of %d+%d is %s, expected %s",
+		ErrChecksumMismatch, e.Offset, e.Length, e.Actual, e.Expected,
+	)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// ChecksumManifest is a sidecar of the SHA-256 of every fixed-size chunk of
+// an archive, so ranges can be verified without reading the whole archive.
+// It is stored as JSON next to the archive.
+type ChecksumManifest struct {
+	Size      uint64 `json:"size"`
+	ChunkSize uint64 `json:"chunk_size"`
+	// SHA256 holds the hex encoded checksums of the chunks in order. The
+	// last chunk may be shorter than ChunkSize.
+	SHA256 []string `json:"sha256"`
+}
+
+// NewChecksumManifest computes the manifest of the archive behind reader,
+// which must implement Sizer, in chunks of chunkSize bytes, defaults to
+// 1 MiB if zero. Compute it from a trusted copy of the archive.
+func NewChecksumManifest(ctx context.Context, reader RangeReader, chunkSize uint64) (*ChecksumManifest, error) {
+	if chunkSize == 0 {
+		chunkSize = DefaultChecksumChunkSize
+	}
+	size, err := readerSize(ctx, reader)
+	if err != nil {
+		return nil, fmt.Errorf("computing checksum manifest: %w", err)
+	}
+
+	m := &ChecksumManifest{Size: size, ChunkSize: chunkSize}
+	for offset := uint64(0); offset < size; offset += chunkSize {
+		length := min(chunkSize, size-offset)
+		sum, err := rangeSHA256(ctx, reader, NewRange(offset, length))
+		if err != nil {
+			return nil, fmt.Errorf("computing checksum manifest: %w", err)
+		}
+		m.SHA256 = append(m.SHA256, sum)
+	}
+	return m, nil
+}
+
+// ReadChecksumManifest decodes and validates a JSON manifest.
+func ReadChecksumManifest(r io.Reader) (*ChecksumManifest, error) {
+	var m ChecksumManifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decoding checksum manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks that the manifest holds a checksum for every chunk.
+func (m *ChecksumManifest) Validate() error {
+	if m.ChunkSize == 0 {
+		return errors.New("invalid checksum manifest: chunk size must be positive")
+	}
+	if chunks := (m.Size + m.ChunkSize - 1) / m.ChunkSize; uint64(len(m.SHA256)) != chunks {
+		return fmt.Errorf(
+			"invalid checksum manifest: %d checksums for %d chunks", len(m.SHA256), chunks,
+		)
+	}
+	for i, sum := range m.SHA256 {
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid checksum manifest: chunk %d: invalid sha256 %q", i, sum)
+		}
+	}
+	return nil
+}
+
+type checksumConfig struct {
+	archiveSHA256 string
+	manifest      *ChecksumManifest
+}
+
+// ChecksumOption is a functional option for configuring a
+// ChecksumRangeReader.
+type ChecksumOption = func(config *checksumConfig)
+
+// WithArchiveSHA256 verifies the complete archive against the hex encoded
+// SHA-256 sum once, when the ChecksumRangeReader is created.
+func WithArchiveSHA256(sum string) ChecksumOption {
+	return func(config *checksumConfig) {
+		config.archiveSHA256 = sum
+	}
+}
+
+// WithChecksumManifest verifies every read against the checksums of the
+// chunks it covers.
+func WithChecksumManifest(manifest *ChecksumManifest) ChecksumOption {
+	return func(config *checksumConfig) {
+		config.manifest = manifest
+	}
+}
+
+// ChecksumRangeReader verifies an archive against user-supplied SHA-256
+// checksums, e.g. for archives synced through untrusted mirrors. The
+// complete archive is verified on creation with WithArchiveSHA256, and
+// individual reads with WithChecksumManifest.
+//
+// With a manifest, every read is expanded to the chunks it covers, which
+// are verified before the range is returned. Wrap it in a
+// BlockCacheRangeReader with a block size of the chunk size to read every
+// chunk once.
+type ChecksumRangeReader struct {
+	reader   RangeReader
+	manifest *ChecksumManifest
+}
+
+// NewChecksumRangeReader wraps reader to verify it. If the archive checksum
+// is set, the archive is read and verified before returning, failing with a
+// ChecksumError on mismatch.
+func NewChecksumRangeReader(
+	ctx context.Context,
+	reader RangeReader,
+	options ...ChecksumOption,
+) (*ChecksumRangeReader, error) {
+	cfg := checksumConfig{}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+	if cfg.archiveSHA256 == "" && cfg.manifest == nil {
+		return nil, errors.New("checksum reader requires an archive checksum or a manifest")
+	}
+	if cfg.manifest != nil {
+		if err := cfg.manifest.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.archiveSHA256 != "" {
+		if err := verifyArchive(ctx, reader, cfg.archiveSHA256); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ChecksumRangeReader{reader: reader, manifest: cfg.manifest}, nil
+}
+
+// verifyArchive reads the complete archive and compares its SHA-256 to sum.
+func verifyArchive(ctx context.Context, reader RangeReader, sum string) error {
+	size, err := readerSize(ctx, reader)
+	if err != nil {
+		return fmt.Errorf("verifying archive: %w", err)
+	}
+
+	actual, err := rangeSHA256(ctx, reader, NewRange(0, size))
+	if err != nil {
+		return fmt.Errorf("verifying archive: %w", err)
+	}
+	if !hexEqual(actual, sum) {
+		return &ChecksumError{Length: size, Expected: sum, Actual: actual}
+	}
+	return nil
+}
+
+// ReadRange reads and verifies the chunks covering the range. Without a
+// manifest, reads are passed through.
+func (c *ChecksumRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if c.manifest == nil {
+		return c.reader.ReadRange(ctx, ranger)
+	}
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	m := c.manifest
+	if ranger.Offset() >= m.Size {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	end := min(ranger.Offset()+ranger.Length(), m.Size)
+	first, last := ranger.Offset()/m.ChunkSize, (end-1)/m.ChunkSize
+	start := first * m.ChunkSize
+	stop := min((last+1)*m.ChunkSize, m.Size)
+
+	data, err := readFull(ctx, c.reader, NewRange(start, stop-start))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := first; i <= last; i++ {
+		lo := (i - first) * m.ChunkSize
+		hi := min(lo+m.ChunkSize, uint64(len(data)))
+		sum := sha256.Sum256(data[lo:hi])
+		if actual := hex.EncodeToString(sum[:]); !hexEqual(actual, m.SHA256[i]) {
+			return nil, &ChecksumError{
+				Offset: i * m.ChunkSize, Length: hi - lo, Expected: m.SHA256[i], Actual: actual,
+			}
+		}
+	}
+
+	return io.NopCloser(bytes.NewReader(data[ranger.Offset()-start : end-start])), nil
+}
+
+// readFull reads the complete range, failing if it is truncated.
+func readFull(ctx context.Context, reader RangeReader, rng Range) ([]byte, error) {
+	n, err := bufferLen(rng.Length())
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := reader.ReadRange(ctx, rng)
+	if err != nil {
+		return nil, fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return nil, fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), err)
+	}
+	return data, nil
+}
+
+// rangeSHA256 returns the hex encoded SHA-256 of a range, failing if it is
+// truncated.
+func rangeSHA256(ctx context.Context, reader RangeReader, rng Range) (string, error) {
+	rc, err := reader.ReadRange(ctx, rng)
+	if err != nil {
+		return "", fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	h := sha256.New()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return "", fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), err)
+	}
+	if uint64(n) != rng.Length() { //nolint:gosec // n is not negative
+		return "", fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), io.ErrUnexpectedEOF)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hexEqual compares hex encoded checksums case-insensitively.
+func hexEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (c *ChecksumRangeReader) Unwrap() RangeReader {
+	return c.reader
+}
@@ -0,0 +1,128 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestChecksumRangeReaderArchive(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).Bytes()
+	sum := sha256.Sum256(data)
+
+	tests := []struct {
+		name    string
+		sum     string
+		wantErr error
+	}{
+		{name: "matching", sum: hex.EncodeToString(sum[:])},
+		{name: "matching upper case", sum: strings.ToUpper(hex.EncodeToString(sum[:]))},
+		{name: "mismatch", sum: strings.Repeat("0", 64), wantErr: pmtilr.ErrChecksumMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pmtilr.NewChecksumRangeReader(
+				t.Context(), pmtilr.NewBytesRangeReader(data), pmtilr.WithArchiveSHA256(tt.sum),
+			)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChecksumRangeReaderManifest(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).Bytes()
+
+	manifest, err := pmtilr.NewChecksumManifest(t.Context(), pmtilr.NewBytesRangeReader(data), 64)
+	if err != nil {
+		t.Fatalf("computing manifest: %v", err)
+	}
+
+	// the manifest survives a round trip as sidecar file.
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(manifest); err != nil {
+		t.Fatalf("encoding manifest: %v", err)
+	}
+	manifest, err = pmtilr.ReadChecksumManifest(&buf)
+	if err != nil {
+		t.Fatalf("reading manifest: %v", err)
+	}
+
+	tampered := bytes.Clone(data)
+	tampered[100] ^= 0xff
+
+	tests := []struct {
+		name    string
+		data    []byte
+		ranger  pmtilr.Range
+		wantErr error
+	}{
+		{name: "within a chunk", data: data, ranger: pmtilr.NewRange(10, 20)},
+		{name: "across chunks", data: data, ranger: pmtilr.NewRange(50, 100)},
+		{name: "past the end", data: data, ranger: pmtilr.NewRange(uint64(len(data))-10, 100)},
+		{name: "tampered chunk", data: tampered, ranger: pmtilr.NewRange(90, 20), wantErr: pmtilr.ErrChecksumMismatch},
+		{name: "other chunk of tampered archive", data: tampered, ranger: pmtilr.NewRange(0, 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := pmtilr.NewChecksumRangeReader(
+				t.Context(), pmtilr.NewBytesRangeReader(tt.data), pmtilr.WithChecksumManifest(manifest),
+			)
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+
+			rc, err := reader.ReadRange(t.Context(), tt.ranger)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				var checksumErr *pmtilr.ChecksumError
+				if !errors.As(err, &checksumErr) || checksumErr.Offset != 64 {
+					t.Fatalf("expected a ChecksumError of the chunk at 64, got %v", err)
+				}
+				return
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			end := min(tt.ranger.Offset()+tt.ranger.Length(), uint64(len(data)))
+			if want := data[tt.ranger.Offset():end]; !bytes.Equal(got, want) {
+				t.Fatalf("expected %d bytes of the archive, got %d", len(want), len(got))
+			}
+		})
+	}
+}
+
+func TestReadChecksumManifestInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{name: "zero chunk size", manifest: `{"size": 10, "chunk_size": 0, "sha256": []}`},
+		{name: "missing checksums", manifest: `{"size": 10, "chunk_size": 4, "sha256": []}`},
+		{name: "invalid checksum", manifest: `{"size": 4, "chunk_size": 4, "sha256": ["abc"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pmtilr.ReadChecksumManifest(strings.NewReader(tt.manifest)); err == nil {
+				t.Fatal("expected error for invalid manifest")
+			}
+		})
+	}
+}
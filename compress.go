@@ -0,0 +1,126 @@
+package pmtilr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressFunc wraps an io.Writer with the compressor for the given
+// Compression, the counterpart of DecompressFunc for write paths. The
+// returned io.WriteCloser must be closed to flush the compressed stream; it
+// does not close w.
+type CompressFunc = func(w io.Writer, compression Compression) (io.WriteCloser, error)
+
+var (
+	gzWriterPool = newPool(func() *gzip.Writer { return gzip.NewWriter(nil) })
+	brWriterPool = newPool(func() *brotli.Writer { return brotli.NewWriter(nil) })
+	// zstdWriterPool holds encoders without concurrency, so they encode
+	// synchronously and hold no goroutines while pooled.
+	zstdWriterPool = newPool(func() *zstd.Encoder {
+		e, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)) //nolint:errcheck // options are valid
+		return e
+	})
+)
+
+// Compress wraps w with a pooled compressor based on the provided
+// Compression, e.g. to re-encode tiles in the compression of an archive.
+//
+// Behavior:
+//   - CompressionNone: writes are passed through to w.
+//   - CompressionGZIP, CompressionBrotli, CompressionZstd: returns a pooled
+//     compressor. Close flushes the stream and returns the compressor to
+//     the pool.
+//   - CompressionUnknown and other codecs: returns an error.
+func Compress(w io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+
+	case CompressionGZIP:
+		zw := gzWriterPool.Get()
+		zw.Reset(w)
+		return newPooledWriter(zw, func() {
+			zw.Reset(nil)
+			gzWriterPool.Put(zw)
+		}), nil
+
+	case CompressionBrotli:
+		bw := brWriterPool.Get()
+		bw.Reset(w)
+		return newPooledWriter(bw, func() {
+			bw.Reset(nil)
+			brWriterPool.Put(bw)
+		}), nil
+
+	case CompressionZstd:
+		ze := zstdWriterPool.Get()
+		ze.Reset(w)
+		return newPooledWriter(ze, func() {
+			ze.Reset(nil)
+			zstdWriterPool.Put(ze)
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported compression: %v", compression)
+	}
+}
+
+// CompressBytes returns data compressed with compression.
+func CompressBytes(data []byte, compression Compression) ([]byte, error) {
+	if compression == CompressionNone {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	zw, err := Compress(&buf, compression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, errors.Join(fmt.Errorf("compressing: %w", err), zw.Close())
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pooledWriter closes a compressor and releases it once.
+type pooledWriter struct {
+	wc      io.WriteCloser
+	release func()
+}
+
+func newPooledWriter(wc io.WriteCloser, release func()) *pooledWriter {
+	return &pooledWriter{wc: wc, release: release}
+}
+
+func (p *pooledWriter) Write(b []byte) (int, error) {
+	if p.release == nil {
+		return 0, errors.New("write to closed compressor")
+	}
+	return p.wc.Write(b)
+}
+
+func (p *pooledWriter) Close() error {
+	if p.release == nil {
+		return nil
+	}
+	err := p.wc.Close()
+	p.release()
+	p.release = nil
+	return err
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,89 @@
+package pmtilr
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	input := strings.Repeat("pmtiles ", 1024)
+
+	tests := []struct {
+		name        string
+		compression Compression
+		expectError bool
+	}{
+		{name: "none", compression: CompressionNone},
+		{name: "gzip", compression: CompressionGZIP},
+		{name: "brotli", compression: CompressionBrotli},
+		{name: "zstd", compression: CompressionZstd},
+		{name: "unknown", compression: CompressionUnknown, expectError: true},
+		{name: "unsupported", compression: Compression(42), expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// twice, to reuse pooled compressors
+			for range 2 {
+				var buf bytes.Buffer
+				zw, err := Compress(&buf, tc.compression)
+				if tc.expectError {
+					if err == nil {
+						t.Fatal("expected error, got none")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, err := io.WriteString(zw, input); err != nil {
+					t.Fatalf("writing: %v", err)
+				}
+				if err := zw.Close(); err != nil {
+					t.Fatalf("closing: %v", err)
+				}
+				if err := zw.Close(); err != nil {
+					t.Errorf("closing twice: %v", err)
+				}
+
+				if tc.compression != CompressionNone && buf.Len() >= len(input) {
+					t.Errorf("expected compressed output, got %d of %d bytes", buf.Len(), len(input))
+				}
+
+				dr, err := Decompress(io.NopCloser(&buf), tc.compression)
+				if err != nil {
+					t.Fatalf("decompressing: %v", err)
+				}
+				out, err := io.ReadAll(dr)
+				_ = dr.Close()
+				if err != nil {
+					t.Fatalf("reading decompressed data: %v", err)
+				}
+				if string(out) != input {
+					t.Errorf("round trip mismatch: got %d bytes, want %d", len(out), len(input))
+				}
+			}
+		})
+	}
+}
+
+func TestCompressBytes(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionGZIP, CompressionBrotli, CompressionZstd} {
+		t.Run(c.String(), func(t *testing.T) {
+			data, err := CompressBytes([]byte("tile"), c)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dr, err := Decompress(io.NopCloser(bytes.NewReader(data)), c)
+			if err != nil {
+				t.Fatalf("decompressing: %v", err)
+			}
+			defer dr.Close()
+			if out, _ := io.ReadAll(dr); string(out) != "tile" {
+				t.Errorf("got %q, want %q", out, "tile")
+			}
+		})
+	}
+}
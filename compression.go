@@ -1,12 +1,16 @@
 package pmtilr
 
 import (
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"sync"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
 // Compression enumerates supported compression codecs for PMTiles content.
@@ -26,6 +30,28 @@ const (
 	CompressionZstd
 )
 
+// DefaultMaxDecompressedSize is the maximum number of bytes Decompress
+// returns for a single directory, metadata document or tile.
+const DefaultMaxDecompressedSize = 256 << 20
+
+var ErrDecompressedSizeExceeded = errors.New("decompressed size exceeds limit")
+
+// DecompressedSizeError is returned by decompressed readers once they
+// produce more than the configured maximum, e.g. for decompression bombs in
+// hostile archives. It matches ErrDecompressedSizeExceeded.
+type DecompressedSizeError struct {
+	Compression Compression
+	Limit       uint64
+}
+
+func (e *DecompressedSizeError) Error() string {
+	return fmt.Sprintf("%s: %s payload larger than %d bytes", ErrDecompressedSizeExceeded, e.Compression, e.Limit)
+}
+
+func (e *DecompressedSizeError) Unwrap() error {
+	return ErrDecompressedSizeExceeded
+}
+
 // compressionOptions maps Compression to a human-readable name.
 // String() and MarshalJSON use this table.
 var compressionOptions = map[Compression]string{
@@ -52,6 +78,33 @@ func (c Compression) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+// ParseCompression parses the name of a compression codec as returned by
+// String, case-insensitively.
+func ParseCompression(s string) (Compression, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for c, str := range compressionOptions {
+		if str == name {
+			return c, nil
+		}
+	}
+	return CompressionUnknown, fmt.Errorf("unknown compression %q", s)
+}
+
+// UnmarshalJSON unmarshals a Compression from a JSON string (e.g. "gzip"),
+// the inverse of MarshalJSON.
+func (c *Compression) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("unmarshaling compression: %w", err)
+	}
+	parsed, err := ParseCompression(str)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
 // DecompressFunc is a function that wraps an io.ReadCloser with the
 // appropriate decompressor for the given Compression. The returned
 // io.ReadCloser must be closed by the caller to release resources.
@@ -60,7 +113,7 @@ type DecompressFunc = func(r io.ReadCloser, compression Compression) (io.ReadClo
 // gzPool stores reusable *gzip.Reader instances to reduce allocations.
 // gzip.Reader is not safe for concurrent use, but sync.Pool access is
 // concurrency-safe and returns a fresh instance per caller.
-var gzPool = sync.Pool{New: func() any { return new(gzip.Reader) }}
+var gzPool = newPool(func() *gzip.Reader { return new(gzip.Reader) })
 
 // GZIPReadCloser wraps a gzip reader together with a Closer. Closing the
 // GZIPReadCloser closes the gzip reader first and then the underlying
@@ -86,7 +139,7 @@ func (f closeFunc) Close() error { return f() }
 //   - If the gzip reader cannot be initialized (Reset fails), rc is closed
 //     and the error is returned.
 func NewGZIPReadCloser(rc io.ReadCloser) (io.ReadCloser, error) {
-	zr, _ := gzPool.Get().(*gzip.Reader) //nolint:errcheck
+	zr := gzPool.Get()
 	if err := zr.Reset(rc); err != nil {
 		gzPool.Put(zr)
 		_ = rc.Close() //nolint:errcheck // ensure underlying is closed on init failure
@@ -102,15 +155,92 @@ func NewGZIPReadCloser(rc io.ReadCloser) (io.ReadCloser, error) {
 	}, nil
 }
 
+// brPool stores reusable *brotli.Reader instances, which keep their window
+// buffer across resets.
+var brPool = newPool(func() *brotli.Reader { return brotli.NewReader(nil) })
+
+// BrotliReadCloser wraps a brotli reader together with a Closer, like
+// GZIPReadCloser.
+type BrotliReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewBrotliReadCloser returns a pooled brotli reader that reads from rc.
+// The returned ReadCloser must be closed; on Close it returns the brotli
+// reader to the pool and closes the underlying rc.
+func NewBrotliReadCloser(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := brPool.Get()
+	if err := br.Reset(rc); err != nil {
+		brPool.Put(br)
+		_ = rc.Close() //nolint:errcheck // ensure underlying is closed on init failure
+		return nil, err
+	}
+	return BrotliReadCloser{
+		Reader: br,
+		Closer: closeFunc(func() error {
+			_ = br.Reset(nil) //nolint:errcheck // drop the reference to rc
+			brPool.Put(br)
+			return rc.Close()
+		}),
+	}, nil
+}
+
+// zstdPool stores reusable *zstd.Decoder instances. Decoders are created
+// without concurrency, so they decode synchronously and hold no goroutines
+// while pooled.
+var zstdPool = newPool(func() *zstd.Decoder {
+	d, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1)) //nolint:errcheck // options are valid
+	return d
+})
+
+// ZstdReadCloser wraps a zstd decoder together with a Closer, like
+// GZIPReadCloser.
+type ZstdReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewZstdReadCloser returns a pooled zstd decoder that reads from rc.
+// The returned ReadCloser must be closed; on Close it returns the decoder to
+// the pool and closes the underlying rc.
+func NewZstdReadCloser(rc io.ReadCloser) (io.ReadCloser, error) {
+	zd := zstdPool.Get()
+	if err := zd.Reset(rc); err != nil {
+		_ = rc.Close() //nolint:errcheck // ensure underlying is closed on init failure
+		return nil, err
+	}
+	return ZstdReadCloser{
+		Reader: zd,
+		Closer: closeFunc(func() error {
+			_ = zd.Reset(nil) //nolint:errcheck // drop the reference to rc
+			zstdPool.Put(zd)
+			return rc.Close()
+		}),
+	}, nil
+}
+
 // Decompress wraps r with a decompressor based on the provided Compression.
+// Reading more than DefaultMaxDecompressedSize bytes fails with a
+// DecompressedSizeError, see LimitDecompressFunc for other limits.
 //
 // Behavior:
 //   - CompressionNone, CompressionUnknown: r is returned unchanged. The caller
 //     is still responsible for calling Close on the returned ReadCloser.
 //   - CompressionGZIP: returns a pooled gzip ReadCloser that owns r and must
 //     be closed by the caller (which will, in turn, close r).
-//   - Other codecs: currently unsupported; returns an error.
+//   - CompressionBrotli, CompressionZstd: return a pooled brotli or zstd
+//     ReadCloser that owns r and must be closed by the caller (which will,
+//     in turn, close r).
+//   - Other codecs: unsupported; returns an error.
 func Decompress(r io.ReadCloser, compression Compression) (io.ReadCloser, error) {
+	return defaultDecompress(r, compression)
+}
+
+var defaultDecompress = LimitDecompressFunc(decompress, DefaultMaxDecompressedSize)
+
+// decompress wraps r with a decompressor without limiting its output.
+func decompress(r io.ReadCloser, compression Compression) (io.ReadCloser, error) {
 	switch compression {
 	case CompressionNone, CompressionUnknown:
 		return r, nil
@@ -122,13 +252,120 @@ func Decompress(r io.ReadCloser, compression Compression) (io.ReadCloser, error)
 		}
 		return gr, nil
 
-	// TODO: extend
-	// case CompressionBrotli:
-	//   return NewBrotliReadCloser(r)
-	// case CompressionZstd:
-	//   return NewZstdReadCloser(r)
+	case CompressionBrotli:
+		br, err := NewBrotliReadCloser(r)
+		if err != nil {
+			return nil, fmt.Errorf("brotli.NewReader: %w", err)
+		}
+		return br, nil
+
+	case CompressionZstd:
+		zr, err := NewZstdReadCloser(r)
+		if err != nil {
+			return nil, fmt.Errorf("zstd.NewReader: %w", err)
+		}
+		return zr, nil
 
 	default:
 		return nil, fmt.Errorf("unsupported compression: %v", compression)
 	}
 }
+
+// LimitDecompressFunc wraps fn so the returned readers fail with a
+// DecompressedSizeError once they produce more than limit bytes. A limit of
+// zero disables the check.
+func LimitDecompressFunc(fn DecompressFunc, limit uint64) DecompressFunc {
+	if limit == 0 {
+		return fn
+	}
+	return func(r io.ReadCloser, compression Compression) (io.ReadCloser, error) {
+		rc, err := fn(r, compression)
+		if err != nil {
+			return nil, err
+		}
+		return &limitedReadCloser{rc: rc, compression: compression, limit: limit, remaining: limit}, nil
+	}
+}
+
+// limitedReadCloser reads up to limit bytes and fails if rc holds more.
+type limitedReadCloser struct {
+	rc          io.ReadCloser
+	compression Compression
+	limit       uint64
+	remaining   uint64
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if l.remaining == 0 {
+		// the limit is reached, any further byte exceeds it
+		var b [1]byte
+		n, err := l.rc.Read(b[:])
+		if n > 0 {
+			return 0, &DecompressedSizeError{Compression: l.compression, Limit: l.limit}
+		}
+		return 0, err
+	}
+
+	if uint64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= uint64(n) //nolint:gosec // n is not negative
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.rc.Close()
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// SniffCompression detects the compression of data by its magic number.
+// Only gzip and zstd can be detected, other data is CompressionUnknown.
+func SniffCompression(data []byte) Compression {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return CompressionGZIP
+	case bytes.HasPrefix(data, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionUnknown
+	}
+}
+
+// SniffingDecompressFunc wraps fn to detect the compression of payloads
+// declared as CompressionUnknown with SniffCompression, as some older
+// tooling writes incorrect compression bytes to the header. Payloads
+// without a known magic number are passed to fn as CompressionUnknown.
+func SniffingDecompressFunc(fn DecompressFunc) DecompressFunc {
+	return func(r io.ReadCloser, compression Compression) (io.ReadCloser, error) {
+		if compression != CompressionUnknown {
+			return fn(r, compression)
+		}
+
+		var magic [4]byte
+		n, err := io.ReadFull(r, magic[:])
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			_ = r.Close() //nolint:errcheck // ensure underlying is closed on init failure
+			return nil, fmt.Errorf("sniffing compression: %w", err)
+		}
+
+		rc := readCloser{
+			Reader: io.MultiReader(bytes.NewReader(magic[:n]), r),
+			Closer: r,
+		}
+		return fn(rc, SniffCompression(magic[:n]))
+	}
+}
+
+// readCloser combines a Reader and a Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
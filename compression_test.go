@@ -3,8 +3,12 @@ package pmtilr
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"io"
 	"testing"
+
+	"github.com/andybalholm/brotli"
 )
 
 func TestDecompress(t *testing.T) {
@@ -33,9 +37,15 @@ func TestDecompress(t *testing.T) {
 			expectError: false,
 		},
 		{
-			name:        "Unsupported compression",
+			name:        "Brotli compression",
 			compression: CompressionBrotli,
 			input:       "test-data",
+			expectError: false,
+		},
+		{
+			name:        "Unsupported compression",
+			compression: Compression(42),
+			input:       "test-data",
 			expectError: true,
 		},
 	}
@@ -45,12 +55,18 @@ func TestDecompress(t *testing.T) {
 			var buf bytes.Buffer
 			var r io.Reader
 
-			if tc.compression == CompressionGZIP {
+			switch tc.compression {
+			case CompressionGZIP:
 				gw := gzip.NewWriter(&buf)
 				_, _ = gw.Write([]byte(tc.input))
 				_ = gw.Close()
 				r = &buf
-			} else {
+			case CompressionBrotli:
+				bw := brotli.NewWriter(&buf)
+				_, _ = bw.Write([]byte(tc.input))
+				_ = bw.Close()
+				r = &buf
+			default:
 				r = bytes.NewReader([]byte(tc.input))
 			}
 
@@ -76,3 +92,155 @@ func TestDecompress(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Compression
+		expectError bool
+	}{
+		{input: "gzip", expected: CompressionGZIP},
+		{input: " Brotli ", expected: CompressionBrotli},
+		{input: "ZSTD", expected: CompressionZstd},
+		{input: "none", expected: CompressionNone},
+		{input: "unknown", expected: CompressionUnknown},
+		{input: "lz4", expectError: true},
+		{input: "", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseCompression(tc.input)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("got %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCompressionJSONRoundTrip(t *testing.T) {
+	for c := range compressionOptions {
+		t.Run(c.String(), func(t *testing.T) {
+			data, err := json.Marshal(c)
+			if err != nil {
+				t.Fatalf("marshaling: %v", err)
+			}
+			var got Compression
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshaling %s: %v", data, err)
+			}
+			if got != c {
+				t.Errorf("got %v, want %v", got, c)
+			}
+		})
+	}
+
+	var c Compression
+	if err := json.Unmarshal([]byte(`2`), &c); err == nil {
+		t.Error("expected error for non-string JSON")
+	}
+	if err := json.Unmarshal([]byte(`"lz4"`), &c); err == nil {
+		t.Error("expected error for unknown compression")
+	}
+}
+
+func TestLimitDecompressFunc(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write(make([]byte, 1<<16))
+	_ = gw.Close()
+
+	tests := []struct {
+		name        string
+		limit       uint64
+		expectError bool
+	}{
+		{name: "below limit", limit: 1 << 17},
+		{name: "at limit", limit: 1 << 16},
+		{name: "above limit", limit: 1<<16 - 1, expectError: true},
+		{name: "disabled", limit: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fn := LimitDecompressFunc(Decompress, tc.limit)
+			dr, err := fn(io.NopCloser(bytes.NewReader(buf.Bytes())), CompressionGZIP)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer dr.Close()
+
+			out, err := io.ReadAll(dr)
+			if tc.expectError {
+				var sizeErr *DecompressedSizeError
+				if !errors.As(err, &sizeErr) || !errors.Is(err, ErrDecompressedSizeExceeded) {
+					t.Fatalf("expected DecompressedSizeError, got %v", err)
+				}
+				if sizeErr.Limit != tc.limit {
+					t.Errorf("got limit %d, want %d", sizeErr.Limit, tc.limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(out) != 1<<16 {
+				t.Errorf("got %d bytes, want %d", len(out), 1<<16)
+			}
+		})
+	}
+}
+
+func TestSniffingDecompressFunc(t *testing.T) {
+	input := "test-data"
+	gz, _ := CompressBytes([]byte(input), CompressionGZIP)
+	zs, _ := CompressBytes([]byte(input), CompressionZstd)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		declared    Compression
+		wantSniffed Compression
+		want        string
+	}{
+		{name: "gzip", data: gz, declared: CompressionUnknown, wantSniffed: CompressionGZIP, want: input},
+		{name: "zstd", data: zs, declared: CompressionUnknown, wantSniffed: CompressionZstd, want: input},
+		{name: "plain", data: []byte(input), declared: CompressionUnknown, wantSniffed: CompressionUnknown, want: input},
+		{name: "short", data: []byte{0x1f}, declared: CompressionUnknown, wantSniffed: CompressionUnknown, want: "\x1f"},
+		{name: "empty", declared: CompressionUnknown, wantSniffed: CompressionUnknown},
+		{name: "declared compression", data: gz, declared: CompressionGZIP, want: input},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.declared == CompressionUnknown {
+				if got := SniffCompression(tc.data); got != tc.wantSniffed {
+					t.Errorf("sniffed %v, want %v", got, tc.wantSniffed)
+				}
+			}
+
+			dr, err := SniffingDecompressFunc(Decompress)(io.NopCloser(bytes.NewReader(tc.data)), tc.declared)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer dr.Close()
+
+			out, err := io.ReadAll(dr)
+			if err != nil {
+				t.Fatalf("reading decompressed data: %v", err)
+			}
+			if string(out) != tc.want {
+				t.Errorf("got %q, want %q", out, tc.want)
+			}
+		})
+	}
+}
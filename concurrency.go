@@ -0,0 +1,54 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ConcurrencyLimitedRangeReader limits the number of reads in flight against
+// the backing store, protecting slow backends, e.g. NFS or throttled S3,
+// from bursts of concurrent tile requests. A read is in flight until its
+// body is closed; reads over the limit wait until a slot is free or their
+// context is done.
+type ConcurrencyLimitedRangeReader struct {
+	reader RangeReader
+	slots  chan struct{}
+}
+
+// NewConcurrencyLimitedRangeReader wraps reader to allow at most limit reads
+// in flight. A limit below 1 is treated as 1.
+func NewConcurrencyLimitedRangeReader(reader RangeReader, limit int) *ConcurrencyLimitedRangeReader {
+	return &ConcurrencyLimitedRangeReader{
+		reader: reader,
+		slots:  make(chan struct{}, max(limit, 1)),
+	}
+}
+
+// ReadRange waits for a free slot and reads the range. The slot is released
+// once the body is closed.
+func (c *ConcurrencyLimitedRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for concurrency limit: %w", ctx.Err())
+	}
+
+	rc, err := c.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		<-c.slots
+		return nil, err
+	}
+
+	return newObservedBody(rc, func(int64, error) { <-c.slots }), nil
+}
+
+// InFlight returns the number of reads currently in flight.
+func (c *ConcurrencyLimitedRangeReader) InFlight() int {
+	return len(c.slots)
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (c *ConcurrencyLimitedRangeReader) Unwrap() RangeReader {
+	return c.reader
+}
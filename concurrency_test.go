@@ -0,0 +1,78 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestConcurrencyLimitedRangeReader(t *testing.T) {
+	t.Run("limits calls in flight", func(t *testing.T) {
+		mock := pmtilrtest.NewRangeReader([]byte("0123456789")).WithLatency(10 * time.Millisecond)
+		reader := pmtilr.NewConcurrencyLimitedRangeReader(mock, 2)
+
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+				if err != nil {
+					t.Errorf("reading range: %v", err)
+					return
+				}
+				io.ReadAll(rc)
+				rc.Close()
+			})
+		}
+		wg.Wait()
+
+		if got := mock.MaxConcurrency(); got != 2 {
+			t.Errorf("got %d calls in flight, want 2", got)
+		}
+		if got := reader.InFlight(); got != 0 {
+			t.Errorf("expected all slots to be released, got %d in flight", got)
+		}
+	})
+
+	t.Run("open bodies hold slots", func(t *testing.T) {
+		reader := pmtilr.NewConcurrencyLimitedRangeReader(pmtilrtest.NewRangeReader([]byte("0123456789")), 1)
+
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+		if err != nil {
+			t.Fatalf("reading range: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := reader.ReadRange(ctx, pmtilr.NewRange(0, 4)); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected to wait for the open body, got %v", err)
+		}
+
+		rc.Close()
+		rc.Close()
+		rc, err = reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+		if err != nil {
+			t.Fatalf("expected closed body to release its slot: %v", err)
+		}
+		rc.Close()
+	})
+
+	t.Run("failed reads release slots", func(t *testing.T) {
+		mock := pmtilrtest.NewRangeReader(nil).WithError(errors.New("backend unavailable"))
+		reader := pmtilr.NewConcurrencyLimitedRangeReader(mock, 1)
+
+		for range 3 {
+			if _, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4)); err == nil {
+				t.Fatal("expected error")
+			}
+		}
+		if got := reader.InFlight(); got != 0 {
+			t.Errorf("got %d in flight, want 0", got)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+)
+
+// Bitmap is a compact set of the tiles present at a single zoom level. It
+// stores runs of consecutive positions on the Hilbert curve, like the
+// entries of the archive, so its size follows the number of runs rather
+// than the 4^z tiles of the zoom.
+type Bitmap struct {
+	zoom uint8
+	runs []tileRun // sorted, disjoint and not adjacent
+}
+
+// tileRun is the half-open range [from, to) of positions on the Hilbert
+// curve of a zoom level.
+type tileRun struct {
+	from, to uint64
+}
+
+// NewBitmap returns an empty Bitmap for zoom.
+func NewBitmap(zoom uint8) (*Bitmap, error) {
+	if zoom > MaxZ {
+		return nil, fmt.Errorf("zoom %d exceeds limit of %d", zoom, MaxZ)
+	}
+	return &Bitmap{zoom: zoom}, nil
+}
+
+// Zoom returns the zoom level of the bitmap.
+func (b *Bitmap) Zoom() uint8 {
+	return b.zoom
+}
+
+// Len returns the number of tiles at the bitmap's zoom level.
+func (b *Bitmap) Len() uint64 {
+	return uint64(1) << (2 * uint64(b.zoom))
+}
+
+// Count returns the number of tiles present.
+func (b *Bitmap) Count() uint64 {
+	var n uint64
+	for _, r := range b.runs {
+		n += r.to - r.from
+	}
+	return n
+}
+
+// Contains reports whether tile x/y is present.
+func (b *Bitmap) Contains(x, y uint64) bool {
+	id, err := FastZXYToHilbertTileID(uint64(b.zoom), x, y)
+	if err != nil {
+		return false
+	}
+	i := id - zoomBaseTileID(b.zoom)
+	k := sort.Search(len(b.runs), func(k int) bool { return b.runs[k].to > i })
+	return k < len(b.runs) && b.runs[k].from <= i
+}
+
+// Set marks tile x/y as present.
+func (b *Bitmap) Set(x, y uint64) error {
+	id, err := FastZXYToHilbertTileID(uint64(b.zoom), x, y)
+	if err != nil {
+		return err
+	}
+	i := id - zoomBaseTileID(b.zoom)
+	b.setRange(i, i+1)
+	return nil
+}
+
+// setRange marks the positions [from, to) as present. Ranges set in
+// ascending order, as by Coverage, are appended.
+func (b *Bitmap) setRange(from, to uint64) {
+	if from >= to {
+		return
+	}
+
+	// runs i to j overlap or touch [from, to) and are merged with it.
+	i := sort.Search(len(b.runs), func(k int) bool { return b.runs[k].to >= from })
+	j := sort.Search(len(b.runs), func(k int) bool { return b.runs[k].from > to })
+	if i < j {
+		from = min(from, b.runs[i].from)
+		to = max(to, b.runs[j-1].to)
+	}
+	b.runs = slices.Replace(b.runs, i, j, tileRun{from: from, to: to})
+}
+
+// Tiles iterates the x/y coordinates of present tiles in Hilbert order.
+func (b *Bitmap) Tiles() iter.Seq2[uint64, uint64] {
+	return func(yield func(uint64, uint64) bool) {
+		base := zoomBaseTileID(b.zoom)
+		for _, r := range b.runs {
+			for i := r.from; i < r.to; i++ {
+				zxy, err := FastZXYfromHilbertTileID(base + i)
+				if err != nil {
+					return
+				}
+				if !yield(zxy[1], zxy[2]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AndNot returns the tiles present in b but not in other, e.g. to report
+// tiles missing from a renderer run. Both bitmaps must share a zoom level.
+func (b *Bitmap) AndNot(other *Bitmap) (*Bitmap, error) {
+	if b.zoom != other.zoom {
+		return nil, fmt.Errorf("zoom mismatch: %d and %d", b.zoom, other.zoom)
+	}
+
+	out := &Bitmap{zoom: b.zoom}
+	j := 0
+	for _, r := range b.runs {
+		for j < len(other.runs) && other.runs[j].to <= r.from {
+			j++
+		}
+
+		// runs of other may extend beyond r, so they are kept for the next.
+		from := r.from
+		for k := j; k < len(other.runs) && other.runs[k].from < r.to; k++ {
+			if other.runs[k].from > from {
+				out.runs = append(out.runs, tileRun{from: from, to: other.runs[k].from})
+			}
+			from = max(from, other.runs[k].to)
+		}
+		if from < r.to {
+			out.runs = append(out.runs, tileRun{from: from, to: r.to})
+		}
+	}
+	return out, nil
+}
+
+// Coverager is implemented by Sources that report the tiles present in
+// their archive, such as TileSource.
+type Coverager interface {
+	Coverage(ctx context.Context, zoom uint8) (*Bitmap, error)
+}
+
+// sourceCoverage returns the coverage of source at zoom, see Coverager.
+func sourceCoverage(ctx context.Context, source Source, zoom uint8) (*Bitmap, error) {
+	c, ok := source.(Coverager)
+	if !ok {
+		return nil, fmt.Errorf("computing coverage: %T does not report coverage", source)
+	}
+	return c.Coverage(ctx, zoom)
+}
+
+// zoomBaseTileID returns the Hilbert tile ID of the first tile at zoom.
+func zoomBaseTileID(zoom uint8) uint64 {
+	return ((uint64(1) << (2 * uint64(zoom))) - 1) / 3
+}
+
+// Coverage walks the directories of the archive and returns a Bitmap of the
+// tiles present at zoom. Only leaf directories overlapping zoom are read.
+func Coverage(
+	ctx context.Context,
+	repo Repository,
+	header HeaderV3,
+	reader RangeReader,
+	decompress DecompressFunc,
+	zoom uint8,
+) (*Bitmap, error) {
+	b, err := NewBitmap(zoom)
+	if err != nil {
+		return nil, err
+	}
+
+	lo := zoomBaseTileID(zoom)
+	hi := lo + b.Len()
+
+	var walk func(ranger Ranger, end uint64, depth uint64) error
+	walk = func(ranger Ranger, end uint64, depth uint64) error {
+		if depth >= directoryMaxDepth {
+			return fmt.Errorf("maximum directory depth exceeded")
+		}
+
+		dir, _, err := repo.DirectoryAt(ctx, header, reader, ranger, decompress)
+		if err != nil {
+			return err
+		}
+
+		for i, e := range dir.entries {
+			id := uint64(e.TileID)
+			if id >= hi {
+				break
+			}
+
+			if !e.IsDirectory() {
+				from, to := max(id, lo), min(id+uint64(e.RunLength), hi)
+				if from < to {
+					b.setRange(from-lo, to-lo)
+				}
+				continue
+			}
+
+			// a leaf directory covers tile IDs up to the next entry
+			next := end
+			if i+1 < len(dir.entries) {
+				next = uint64(dir.entries[i+1].TileID)
+			}
+			if next <= lo {
+				continue
+			}
+
+			leaf := NewRange(header.LeafDirectoryOffset+e.Offset, e.Length)
+			if err := walk(leaf, next, depth+1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(NewRange(header.RootOffset, header.RootLength), invalidTileID, 0); err != nil {
+		return nil, fmt.Errorf("computing coverage: %w", err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,189 @@
+package pmtilr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestCoverage(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	for zoom := range uint8(6) {
+		bitmap, err := src.(pmtilr.Coverager).Coverage(t.Context(), zoom)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		var count uint64
+		for x := range uint64(1) << zoom {
+			for y := range uint64(1) << zoom {
+				_, err := src.Tile(t.Context(), uint64(zoom), x, y)
+				exists := err == nil
+				if err != nil && !errors.Is(err, pmtilr.ErrTileNotFound) {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if exists {
+					count++
+				}
+				if bitmap.Contains(x, y) != exists {
+					t.Fatalf("z%d %d/%d: expected contains=%t", zoom, x, y, exists)
+				}
+			}
+		}
+
+		if bitmap.Count() != count {
+			t.Fatalf("z%d: expected count %d, got: %d", zoom, count, bitmap.Count())
+		}
+		if count == 0 {
+			t.Fatalf("z%d: expected tiles in archive", zoom)
+		}
+	}
+}
+
+func TestBitmap(t *testing.T) {
+	a, err := pmtilr.NewBitmap(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, _ := pmtilr.NewBitmap(3)
+
+	if a.Len() != 64 {
+		t.Fatalf("expected 64 tiles at z3, got: %d", a.Len())
+	}
+
+	for _, xy := range [][2]uint64{{0, 0}, {7, 7}, {3, 5}} {
+		if err := a.Set(xy[0], xy[1]); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	_ = b.Set(7, 7)
+
+	if err := a.Set(8, 0); err == nil {
+		t.Fatal("expected error for tile outside of zoom")
+	}
+
+	diff, err := a.AndNot(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff.Count() != 2 || diff.Contains(7, 7) || !diff.Contains(3, 5) {
+		t.Fatalf("unexpected difference with count %d", diff.Count())
+	}
+
+	seen := map[[2]uint64]bool{}
+	for x, y := range diff.Tiles() {
+		seen[[2]uint64{x, y}] = true
+	}
+	if len(seen) != 2 || !seen[[2]uint64{0, 0}] || !seen[[2]uint64{3, 5}] {
+		t.Fatalf("unexpected tiles: %v", seen)
+	}
+
+	other, _ := pmtilr.NewBitmap(4)
+	if _, err := a.AndNot(other); err == nil {
+		t.Fatal("expected error for zoom mismatch")
+	}
+}
+
+func TestBitmapRuns(t *testing.T) {
+	a, _ := pmtilr.NewBitmap(2)
+
+	// every tile of z2 in an order that creates, extends and merges runs.
+	var ids []pmtilr.TileID
+	for _, i := range []uint64{5, 0, 15, 6, 1, 3, 2, 4, 7, 14, 8, 9, 13, 10, 12, 11} {
+		id := pmtilr.TileID(5 + i)
+		z, x, y := id.ZXY()
+		if z != 2 {
+			t.Fatalf("expected zoom 2, got: %d", z)
+		}
+		if err := a.Set(x, y); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_ = a.Set(x, y)
+		ids = append(ids, id)
+		if a.Count() != uint64(len(ids)) {
+			t.Fatalf("expected count %d, got: %d", len(ids), a.Count())
+		}
+	}
+
+	var last pmtilr.TileID
+	for x, y := range a.Tiles() {
+		id, _ := pmtilr.NewTileID(2, x, y)
+		if id < last {
+			t.Fatalf("expected tiles in Hilbert order, got %s after %s", id, last)
+		}
+		last = id
+	}
+
+	b, _ := pmtilr.NewBitmap(2)
+	for _, id := range []pmtilr.TileID{5, 6, 12, 13, 14, 20} {
+		_, x, y := id.ZXY()
+		_ = b.Set(x, y)
+	}
+	diff, _ := a.AndNot(b)
+	if diff.Count() != 10 {
+		t.Fatalf("expected 10 tiles, got: %d", diff.Count())
+	}
+	for _, id := range ids {
+		_, x, y := id.ZXY()
+		if diff.Contains(x, y) == b.Contains(x, y) {
+			t.Fatalf("%s: expected contains=%t", id, !b.Contains(x, y))
+		}
+	}
+}
+
+func TestBitmapHighZoom(t *testing.T) {
+	b, err := pmtilr.NewBitmap(pmtilr.MaxZ)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	n := uint64(1) << pmtilr.MaxZ
+	for _, xy := range [][2]uint64{{0, 0}, {n - 1, n - 1}, {n / 2, 12345}} {
+		if err := b.Set(xy[0], xy[1]); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if b.Count() != 3 || !b.Contains(n-1, n-1) || b.Contains(1, 0) {
+		t.Fatalf("unexpected bitmap with count %d", b.Count())
+	}
+
+	if _, err := pmtilr.NewBitmap(pmtilr.MaxZ + 1); err == nil {
+		t.Fatal("expected error beyond max zoom")
+	}
+}
+
+func TestCoverageHighZoom(t *testing.T) {
+	src, err := pmtilr.NewSource(
+		t.Context(), "",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	bitmap, err := src.(pmtilr.Coverager).Coverage(t.Context(), 22)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if bitmap.Count() != 0 {
+		t.Fatalf("expected no tiles at z22, got: %d", bitmap.Count())
+	}
+}
+
+func TestCoverageUnsupported(t *testing.T) {
+	var failed bool
+	for _, err := range pmtilr.MissingTiles(
+		t.Context(), rasterSource{}, pmtilr.NewBounds(0, 0, 1, 1), pmtilr.NewZoomRange(0, 1),
+	) {
+		failed = err != nil
+	}
+	if !failed {
+		t.Fatal("expected error for source without coverage")
+	}
+}
@@ -0,0 +1,172 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestSourceMaxDecompressedSize(t *testing.T) {
+	archive := pmtilrtest.FixtureArchive(3).WithCompression(pmtilr.CompressionGZIP)
+
+	tests := []struct {
+		name    string
+		opts    []pmtilr.SourceOption
+		wantErr error
+	}{
+		{name: "default limit"},
+		{
+			name:    "limit exceeded",
+			opts:    []pmtilr.SourceOption{pmtilr.WithMaxDecompressedSize(16)},
+			wantErr: pmtilr.ErrDecompressedSizeExceeded,
+		},
+		{
+			name:    "custom decompress func is limited",
+			opts:    []pmtilr.SourceOption{pmtilr.WithDecompressFunc(pmtilr.Decompress), pmtilr.WithMaxDecompressedSize(16)},
+			wantErr: pmtilr.ErrDecompressedSizeExceeded,
+		},
+		{name: "limit disabled", opts: []pmtilr.SourceOption{pmtilr.WithMaxDecompressedSize(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]pmtilr.SourceOption{
+				pmtilr.WithRangeReader(archive.RangeReader()),
+				pmtilr.WithDisableInstrumentation(),
+			}, tt.opts...)
+
+			source, err := pmtilr.NewSource(t.Context(), "", opts...)
+			if err != nil {
+				t.Fatalf("creating source: %s", err)
+			}
+
+			_, err = source.Tile(t.Context(), 3, 1, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSourceOversizedMetadataLength(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).WithCompression(pmtilr.CompressionGZIP).Bytes()
+	// MetadataLength of the header, the read range is truncated at the end
+	// of the archive and runs into the leaves and tile data.
+	binary.LittleEndian.PutUint64(data[32:40], 1<<50)
+
+	_, err := pmtilr.NewSource(
+		t.Context(), "",
+		pmtilr.WithRangeReader(pmtilr.NewBytesRangeReader(data)),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestSourceTileDecompression(t *testing.T) {
+	tests := []struct {
+		name            string
+		compression     pmtilr.Compression
+		opts            []pmtilr.SourceOption
+		wantCompression pmtilr.Compression
+		wantRaw         bool
+	}{
+		{
+			name:            "gzip decompressed",
+			compression:     pmtilr.CompressionGZIP,
+			opts:            []pmtilr.SourceOption{pmtilr.WithTileDecompression()},
+			wantCompression: pmtilr.CompressionNone,
+			wantRaw:         true,
+		},
+		{
+			name:            "gzip passed through",
+			compression:     pmtilr.CompressionGZIP,
+			wantCompression: pmtilr.CompressionGZIP,
+		},
+		{
+			name:            "uncompressed archive",
+			compression:     pmtilr.CompressionNone,
+			opts:            []pmtilr.SourceOption{pmtilr.WithTileDecompression()},
+			wantCompression: pmtilr.CompressionNone,
+			wantRaw:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := pmtilrtest.FixtureArchive(2).WithCompression(tt.compression)
+			opts := append([]pmtilr.SourceOption{
+				pmtilr.WithRangeReader(archive.RangeReader()),
+				pmtilr.WithDisableInstrumentation(),
+			}, tt.opts...)
+
+			source, err := pmtilr.NewSource(t.Context(), "", opts...)
+			if err != nil {
+				t.Fatalf("creating source: %s", err)
+			}
+			if got := source.Header().TileCompression; got != tt.wantCompression {
+				t.Errorf("expected tile compression %s, got %s", tt.wantCompression, got)
+			}
+
+			data, err := source.Tile(t.Context(), 2, 1, 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := string(data) == "2/1/3"; got != tt.wantRaw {
+				t.Errorf("expected raw tile %t, got %q", tt.wantRaw, data)
+			}
+
+			var buf bytes.Buffer
+			if _, err := pmtilr.TileTo(t.Context(), source, &buf, 2, 1, 3); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("expected TileTo to write %q, got %q", data, buf.Bytes())
+			}
+		})
+	}
+}
+
+func TestSourceCompressionSniffing(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).WithCompression(pmtilr.CompressionGZIP).Bytes()
+	// older tooling writing an unknown internal and tile compression
+	data[97], data[98] = byte(pmtilr.CompressionUnknown), byte(pmtilr.CompressionUnknown)
+
+	tests := []struct {
+		name    string
+		opts    []pmtilr.SourceOption
+		wantErr bool
+	}{
+		{name: "without sniffing", wantErr: true},
+		{
+			name: "with sniffing",
+			opts: []pmtilr.SourceOption{pmtilr.WithCompressionSniffing(), pmtilr.WithTileDecompression()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]pmtilr.SourceOption{
+				pmtilr.WithRangeReader(pmtilrtest.NewRangeReader(data)),
+				pmtilr.WithDisableInstrumentation(),
+			}, tt.opts...)
+
+			source, err := pmtilr.NewSource(t.Context(), "", opts...)
+			if err == nil {
+				var tile []byte
+				tile, err = source.Tile(t.Context(), 2, 1, 3)
+				if err == nil && string(tile) != "2/1/3" {
+					t.Errorf("expected tile %q, got %q", "2/1/3", tile)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
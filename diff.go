@@ -0,0 +1,180 @@
+package pmtilr
+
+import (
+	"context"
+	"iter"
+)
+
+// DiffKind classifies a TileDiff.
+type DiffKind uint8
+
+const (
+	// DiffUnchanged indicates the tile is present in both archives with equal content.
+	DiffUnchanged DiffKind = iota
+	// DiffAdded indicates the tile is only present in the second archive.
+	DiffAdded
+	// DiffRemoved indicates the tile is only present in the first archive.
+	DiffRemoved
+	// DiffChanged indicates the tile is present in both archives with different content.
+	DiffChanged
+)
+
+var diffKindOptions = map[DiffKind]string{
+	DiffUnchanged: "unchanged",
+	DiffAdded:     "added",
+	DiffRemoved:   "removed",
+	DiffChanged:   "changed",
+}
+
+// String returns a human-readable name for the diff kind.
+func (k DiffKind) String() string {
+	return diffKindOptions[k]
+}
+
+// MarshalText marshals the DiffKind as its name, e.g. "added".
+func (k DiffKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// TileDiff describes the difference of a single tile between two archives.
+// Hashes are the hex encoded SHA-256 of the tile bytes as read by
+// EntryReader.EntryBytes, empty if the tile is absent from that archive.
+type TileDiff struct {
+	TileID TileID   `json:"tile_id"`
+	Z      uint64   `json:"z"`
+	X      uint64   `json:"x"`
+	Y      uint64   `json:"y"`
+	Kind   DiffKind `json:"kind"`
+	HashA  string   `json:"hash_a,omitempty"`
+	HashB  string   `json:"hash_b,omitempty"`
+}
+
+type diffConfig struct {
+	unchanged bool
+}
+
+// DiffOption configures DiffArchives.
+type DiffOption = func(*diffConfig)
+
+// WithDiffUnchanged includes tiles with equal content in the diff.
+func WithDiffUnchanged() DiffOption {
+	return func(cfg *diffConfig) {
+		cfg.unchanged = true
+	}
+}
+
+// tileHash is a tile ID with the content hash of its bytes.
+type tileHash struct {
+	id   TileID
+	hash string
+}
+
+// DiffArchives compares tile presence and content of a and b and yields a
+// TileDiff per differing tile in ascending TileID order. Both archives are
+// streamed entry by entry; run-length encoded tiles are read once per run.
+// Errors are yielded once, after which iteration stops.
+func DiffArchives(
+	ctx context.Context,
+	a, b Source,
+	options ...DiffOption,
+) iter.Seq2[TileDiff, error] {
+	cfg := diffConfig{}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	return func(yield func(TileDiff, error) bool) {
+		nextA, stopA := iter.Pull2(tileHashes(ctx, a))
+		defer stopA()
+		nextB, stopB := iter.Pull2(tileHashes(ctx, b))
+		defer stopB()
+
+		emit := func(id TileID, kind DiffKind, hashA, hashB string) bool {
+			if kind == DiffUnchanged && !cfg.unchanged {
+				return true
+			}
+			zxy, err := FastZXYfromHilbertTileID(uint64(id))
+			if err != nil {
+				yield(TileDiff{}, err)
+				return false
+			}
+			return yield(TileDiff{
+				TileID: id,
+				Z:      zxy[0],
+				X:      zxy[1],
+				Y:      zxy[2],
+				Kind:   kind,
+				HashA:  hashA,
+				HashB:  hashB,
+			}, nil)
+		}
+
+		ta, errA, okA := nextA()
+		tb, errB, okB := nextB()
+		for okA || okB {
+			if errA != nil {
+				yield(TileDiff{}, errA)
+				return
+			}
+			if errB != nil {
+				yield(TileDiff{}, errB)
+				return
+			}
+
+			switch {
+			case !okB || (okA && ta.id < tb.id):
+				if !emit(ta.id, DiffRemoved, ta.hash, "") {
+					return
+				}
+				ta, errA, okA = nextA()
+			case !okA || tb.id < ta.id:
+				if !emit(tb.id, DiffAdded, "", tb.hash) {
+					return
+				}
+				tb, errB, okB = nextB()
+			default:
+				kind := DiffUnchanged
+				if ta.hash != tb.hash {
+					kind = DiffChanged
+				}
+				if !emit(ta.id, kind, ta.hash, tb.hash) {
+					return
+				}
+				ta, errA, okA = nextA()
+				tb, errB, okB = nextB()
+			}
+		}
+	}
+}
+
+// tileHashes yields the content hash of every tile of source in ascending
+// TileID order, expanding run-length encoded entries.
+func tileHashes(ctx context.Context, source Source) iter.Seq2[tileHash, error] {
+	return func(yield func(tileHash, error) bool) {
+		er, err := sourceEntryReader(source)
+		if err != nil {
+			yield(tileHash{}, err)
+			return
+		}
+
+		for e, err := range er.Entries(ctx) {
+			if err != nil {
+				yield(tileHash{}, err)
+				return
+			}
+
+			data, err := er.EntryBytes(ctx, e)
+			if err != nil {
+				yield(tileHash{}, err)
+				return
+			}
+			hash := hashTile(data).String()
+
+			for i := range uint64(e.RunLength) {
+				if !yield(tileHash{id: e.TileID + TileID(i), hash: hash}, nil) {
+					return
+				}
+			}
+		}
+	}
+}
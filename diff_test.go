@@ -0,0 +1,78 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestDiffArchives(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	single, err := pmtilr.NewSource(
+		t.Context(), "",
+		pmtilr.WithRangeReader(pmtilrtest.NewArchive().WithTile(0, 0, 0, []byte("tile")).RangeReader()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	count := func(diffs map[pmtilr.DiffKind]uint64, a, b pmtilr.Source, options ...pmtilr.DiffOption) {
+		t.Helper()
+		for d, err := range pmtilr.DiffArchives(t.Context(), a, b, options...) {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			id, _ := pmtilr.NewTileID(d.Z, d.X, d.Y)
+			if id != d.TileID {
+				t.Fatalf("expected z/x/y %d/%d/%d to match tile id %d", d.Z, d.X, d.Y, d.TileID)
+			}
+			diffs[d.Kind]++
+		}
+	}
+
+	total := src.Header().AddressedTilesCount
+
+	t.Run("identical", func(t *testing.T) {
+		diffs := map[pmtilr.DiffKind]uint64{}
+		count(diffs, src, src)
+		if len(diffs) != 0 {
+			t.Fatalf("expected no differences, got: %v", diffs)
+		}
+
+		count(diffs, src, src, pmtilr.WithDiffUnchanged())
+		if diffs[pmtilr.DiffUnchanged] != total {
+			t.Fatalf("expected %d unchanged tiles, got: %d", total, diffs[pmtilr.DiffUnchanged])
+		}
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		diffs := map[pmtilr.DiffKind]uint64{}
+		count(diffs, src, single)
+		if diffs[pmtilr.DiffChanged] != 1 || diffs[pmtilr.DiffRemoved] != total-1 {
+			t.Fatalf("unexpected differences: %v", diffs)
+		}
+	})
+
+	t.Run("added", func(t *testing.T) {
+		diffs := map[pmtilr.DiffKind]uint64{}
+		count(diffs, single, src)
+		if diffs[pmtilr.DiffChanged] != 1 || diffs[pmtilr.DiffAdded] != total-1 {
+			t.Fatalf("unexpected differences: %v", diffs)
+		}
+	})
+}
+
+func TestDiffArchivesUnsupported(t *testing.T) {
+	for _, err := range pmtilr.DiffArchives(t.Context(), rasterSource{}, rasterSource{}) {
+		if err == nil {
+			t.Fatal("expected error for sources without entries")
+		}
+		return
+	}
+	t.Fatal("expected error for sources without entries")
+}
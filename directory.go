@@ -9,8 +9,8 @@ import (
 	"fmt"
 	"io"
 	"iter"
+	"math"
 	"sort"
-	"sync"
 
 	sfx "github.com/iwpnd/singleflightx"
 )
@@ -20,15 +20,13 @@ const (
 	defaultSfxShardCount uint64 = directoryMaxDepth
 )
 
-var readerPool = sync.Pool{
-	New: func() any {
-		// allocate a *bytes.Reader with zero‐length backing slice
-		return new(bufio.Reader)
-	},
-}
+var readerPool = newPool(func() *bufio.Reader {
+	// allocate a *bufio.Reader without buffer, Reset allocates it
+	return new(bufio.Reader)
+})
 
 func acquireReader(newReader io.Reader) *bufio.Reader {
-	r := readerPool.Get().(*bufio.Reader) //nolint:errcheck,forcetypeassert
+	r := readerPool.Get()
 	r.Reset(newReader)
 	return r
 }
@@ -42,7 +40,7 @@ func releaseReader(usedReader *bufio.Reader) {
 // Each entry describes either where a specific tile can be found in the tile data
 // section or where a leaf directory can be found in the leaf directories section.
 type Entry struct {
-	TileID    uint64 `json:"tile_id"`    // 8bytes
+	TileID    TileID `json:"tile_id"`    // 8bytes
 	Offset    uint64 `json:"offset"`     // 8bytes
 	Length    uint64 `json:"length"`     // 8bytes
 	RunLength uint32 `json:"run_length"` // 4bytes
@@ -53,6 +51,11 @@ func (e *Entry) ReadTileBytes(
 	rr RangeReader,
 	tileDataOffset uint64,
 ) ([]byte, error) {
+	size, err := bufferLen(e.Length)
+	if err != nil {
+		return nil, fmt.Errorf("reading tile: %w", err)
+	}
+
 	offset := tileDataOffset + e.Offset
 	rc, err := rr.ReadRange(ctx, NewRange(offset, e.Length))
 	if err != nil {
@@ -68,7 +71,7 @@ func (e *Entry) ReadTileBytes(
 		}
 	}()
 
-	b := bytes.NewBuffer(make([]byte, 0, e.Length))
+	b := bytes.NewBuffer(make([]byte, 0, size))
 	_, cErr := io.Copy(b, rc)
 	if cErr != nil {
 		return nil, fmt.Errorf("reading tile: %w", cErr)
@@ -98,14 +101,32 @@ type Entries []Entry
 // offset propagation.
 //
 // Returns a fully populated Entries slice, or an error if decoding fails.
-func readEntries(br *bufio.Reader) (entries Entries, err error) {
+func readEntries(br *bufio.Reader, sizeHint uint64) (entries Entries, err error) {
 	countEntries, err := binary.ReadUvarint(br)
 	if err != nil {
 		return nil, fmt.Errorf("reading directory entries count: %w", err)
 	}
 
-	entries = make([]Entry, countEntries)
-	err = entries.deserialize(br)
+	// the count and the size hint are read from untrusted bytes, so the
+	// count is only trusted up to the entries the hinted bytes can encode,
+	// and never beyond maxEntriesPreallocHint. Entries grow while reading
+	// beyond that.
+	prealloc := max(sizeHint/minEncodedEntrySize, maxEntriesPrealloc)
+	entries = make(Entries, 0, min(countEntries, prealloc, maxEntriesPreallocHint))
+	var lastId uint64
+	for i := range countEntries {
+		delta, err := binary.ReadUvarint(br)
+		if err != nil {
+			return entries, fmt.Errorf("reading tileId delta at %d: %w", i, err)
+		}
+		if lastId+delta < lastId {
+			return entries, fmt.Errorf("tileId delta at %d overflows", i)
+		}
+		lastId += delta
+		entries = append(entries, Entry{TileID: TileID(lastId)})
+	}
+
+	err = entries.deserializeAttributes(br)
 	if err != nil {
 		return entries, err
 	}
@@ -113,6 +134,18 @@ func readEntries(br *bufio.Reader) (entries Entries, err error) {
 	return entries, err
 }
 
+const (
+	// maxEntriesPrealloc is the number of entries readEntries allocates
+	// upfront regardless of the size hint.
+	maxEntriesPrealloc = 4096
+	// maxEntriesPreallocHint caps the entries readEntries allocates upfront
+	// for a size hint, 8 MiB of entries.
+	maxEntriesPreallocHint = 1 << 18
+	// minEncodedEntrySize is the smallest encoding of an entry, one byte
+	// each for the tile id delta, run length, length and offset.
+	minEncodedEntrySize = 4
+)
+
 // deserialize populates the Entries slice by reading tile ID deltas,
 // runlengths, lengths, and offsets from the given reader.
 func (e Entries) deserialize(br *bufio.Reader) (err error) {
@@ -120,8 +153,17 @@ func (e Entries) deserialize(br *bufio.Reader) (err error) {
 		return fmt.Errorf("cannot deserialize a nil array")
 	}
 
+	if err := e.addTileID(br); err != nil {
+		return err
+	}
+
+	return e.deserializeAttributes(br)
+}
+
+// deserializeAttributes populates the runlengths, lengths and offsets of
+// entries whose tile IDs are already set.
+func (e Entries) deserializeAttributes(br *bufio.Reader) (err error) {
 	deserializeInOrder := []func(*bufio.Reader) error{
-		e.addTileID,
 		e.addRunLength,
 		e.addLength,
 		e.addOffset,
@@ -151,8 +193,11 @@ func (e Entries) addTileID(br *bufio.Reader) (err error) {
 		if err != nil {
 			return fmt.Errorf("reading tileId delta at %d: %w", i, err)
 		}
-		e[i].TileID = lastId + delta
-		lastId = e[i].TileID
+		if lastId+delta < lastId {
+			return fmt.Errorf("tileId delta at %d overflows", i)
+		}
+		lastId += delta
+		e[i].TileID = TileID(lastId)
 	}
 	return err
 }
@@ -165,7 +210,10 @@ func (e Entries) addRunLength(br *bufio.Reader) (err error) {
 		if err != nil {
 			return fmt.Errorf("reading runLength at %d: %w", i, err)
 		}
-		e[i].RunLength = uint32(runLength) //nolint:gosec
+		if runLength > math.MaxUint32 {
+			return fmt.Errorf("runLength at %d overflows", i)
+		}
+		e[i].RunLength = uint32(runLength)
 	}
 	return err
 }
@@ -196,10 +244,14 @@ func (e Entries) addOffset(br *bufio.Reader) (err error) {
 		if err != nil {
 			return fmt.Errorf("reading offset at %d: %w", i, err)
 		}
-		if offset == 0 && i > 0 {
+		switch {
+		case offset == 0 && i > 0:
 			// previous offset + previous length
 			e[i].Offset = e[i-1].Offset + e[i-1].Length
-		} else {
+			if e[i].Offset < e[i-1].Offset {
+				return fmt.Errorf("propagated offset at %d overflows", i)
+			}
+		default:
 			e[i].Offset = offset - 1
 		}
 	}
@@ -241,7 +293,7 @@ func NewDirectory(
 	}()
 
 	dir := Directory{}
-	if err := dir.deserialize(decompReader); err != nil {
+	if err := dir.deserialize(decompReader, ranger.Length()); err != nil {
 		return Directory{}, fmt.Errorf("deserializing directory: %w", err)
 	}
 
@@ -278,7 +330,7 @@ func (d *Directory) IterEntries() iter.Seq[Entry] {
 }
 
 // FindEntry resolves an Entry by tileID.
-func (d *Directory) FindEntry(tileId uint64) *Entry {
+func (d *Directory) FindEntry(tileId TileID) *Entry {
 	// Binary search for the first entry whose tileId > target.
 	i := sort.Search(len(d.entries), func(i int) bool {
 		return d.entries[i].TileID > tileId
@@ -300,7 +352,7 @@ func (d *Directory) FindEntry(tileId uint64) *Entry {
 	}
 
 	// Check exact match or run‑length cover:
-	if tileId == e.TileID || tileId < e.TileID+uint64(e.RunLength) {
+	if tileId == e.TileID || tileId < e.TileID+TileID(e.RunLength) {
 		return e
 	}
 
@@ -309,11 +361,12 @@ func (d *Directory) FindEntry(tileId uint64) *Entry {
 }
 
 // deserialize the directory from a decompression reader entry by entry.
-func (d *Directory) deserialize(r io.Reader) (err error) {
+// sizeHint is the length of the (compressed) directory.
+func (d *Directory) deserialize(r io.Reader, sizeHint uint64) (err error) {
 	br := acquireReader(r)
 	defer releaseReader(br)
 
-	entries, err := readEntries(br)
+	entries, err := readEntries(br, sizeHint)
 	if err != nil {
 		return err
 	}
@@ -324,6 +377,81 @@ func (d *Directory) deserialize(r io.Reader) (err error) {
 	return err
 }
 
+// directoryBinaryVersion is the version of the MarshalBinary layout.
+const directoryBinaryVersion = 1
+
+// MarshalBinary encodes the directory for cache tiers and persistence as a
+// version byte, the length-prefixed key and the entries in the PMTiles
+// directory layout: tile ID deltas, run lengths, lengths and offsets, with
+// offsets contiguous to the previous entry stored as 0.
+func (d *Directory) MarshalBinary() ([]byte, error) {
+	// a few bytes per column and entry for the delta encoded columns
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(d.key)+8*len(d.entries))
+	buf = append(buf, directoryBinaryVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(d.key)))
+	buf = append(buf, d.key...)
+	return d.entries.serialize(buf), nil
+}
+
+// UnmarshalBinary decodes a directory encoded with MarshalBinary.
+func (d *Directory) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != directoryBinaryVersion {
+		return errors.New("decoding directory: unsupported version")
+	}
+	data = data[1:]
+
+	keyLen, n := binary.Uvarint(data)
+	if n <= 0 || keyLen > uint64(len(data[n:])) {
+		return errors.New("decoding directory: truncated key")
+	}
+	data = data[n:]
+	key := string(data[:keyLen])
+	data = data[keyLen:]
+
+	br := acquireReader(bytes.NewReader(data))
+	defer releaseReader(br)
+
+	entries, err := readEntries(br, uint64(len(data)))
+	if err != nil {
+		return fmt.Errorf("decoding directory: %w", err)
+	}
+	if _, err := br.ReadByte(); !errors.Is(err, io.EOF) {
+		return errors.New("decoding directory: trailing data")
+	}
+
+	d.key = key
+	d.entries = entries
+	d.size = uint64(len(entries))
+
+	return nil
+}
+
+// serialize appends the entries in the PMTiles directory layout to buf.
+func (e Entries) serialize(buf []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(e)))
+
+	var lastID TileID
+	for _, entry := range e {
+		buf = binary.AppendUvarint(buf, uint64(entry.TileID-lastID))
+		lastID = entry.TileID
+	}
+	for _, entry := range e {
+		buf = binary.AppendUvarint(buf, uint64(entry.RunLength))
+	}
+	for _, entry := range e {
+		buf = binary.AppendUvarint(buf, entry.Length)
+	}
+	for i, entry := range e {
+		if i > 0 && entry.Offset == e[i-1].Offset+e[i-1].Length {
+			buf = binary.AppendUvarint(buf, 0)
+			continue
+		}
+		buf = binary.AppendUvarint(buf, entry.Offset+1)
+	}
+
+	return buf
+}
+
 type Repository interface {
 	Close()
 	DirectoryAt(
@@ -335,21 +463,39 @@ type Repository interface {
 	) (Directory, bool, error)
 }
 
+// RepositoryOption is a functional option for configuring a
+// DirectoryRepository.
+type RepositoryOption = func(repository *DirectoryRepository)
+
+// WithRepositoryKeyFunc sets the KeyFunc deriving cache keys, defaults to
+// HashKey.
+func WithRepositoryKeyFunc(keyFunc KeyFunc) RepositoryOption {
+	return func(repository *DirectoryRepository) {
+		repository.keyFunc = keyFunc
+	}
+}
+
 func NewDirectoryRepository(
 	cache Cacher,
 	singleflight sfx.Singleflighter[string, Directory],
+	options ...RepositoryOption,
 ) (*DirectoryRepository, error) {
 	dirs := &DirectoryRepository{
-		cache: cache,
-		sg:    singleflight,
+		cache:   cache,
+		sg:      singleflight,
+		keyFunc: HashKey,
+	}
+	for _, optFn := range options {
+		optFn(dirs)
 	}
 
 	return dirs, nil
 }
 
 type DirectoryRepository struct {
-	cache Cacher
-	sg    sfx.Singleflighter[string, Directory]
+	cache   Cacher
+	sg      sfx.Singleflighter[string, Directory]
+	keyFunc KeyFunc
 }
 
 func (r *DirectoryRepository) DirectoryAt(
@@ -359,7 +505,7 @@ func (r *DirectoryRepository) DirectoryAt(
 	ranger Ranger,
 	decompress DecompressFunc,
 ) (Directory, bool, error) {
-	key := buildCacheKey(header.Etag, ranger.Offset(), ranger.Length())
+	key := r.keyFunc(header.Etag, ranger.Offset(), ranger.Length())
 	dir, ok := r.cache.Get(ctx, key)
 	if ok {
 		return dir, false, nil
@@ -388,6 +534,18 @@ func (r *DirectoryRepository) Flush() {
 	r.cache.Clear()
 }
 
+// Snapshot writes the cached directories to w. The cache must implement
+// IterableCacher.
+func (r *DirectoryRepository) Snapshot(ctx context.Context, w io.Writer) error {
+	return writeSnapshot(ctx, r.cache, w)
+}
+
+// Restore adds the directories of a snapshot written by Snapshot to the
+// cache. Nothing is added unless the whole snapshot is valid.
+func (r *DirectoryRepository) Restore(ctx context.Context, rd io.Reader) error {
+	return readSnapshot(ctx, r.cache, rd)
+}
+
 func (r *DirectoryRepository) Close() {
 	r.cache.Close()
 }
@@ -399,15 +557,56 @@ func TileEntry(
 	reader RangeReader,
 	decompress DecompressFunc, z, x, y uint64,
 ) (*Entry, error) {
-	tileId, err := FastZXYToHilbertTileID(z, x, y)
+	return tileEntry(ctx, repo, header, reader, decompress, nil, z, x, y)
+}
+
+// TileEntryByID resolves the Entry of the tile id, see TileEntry.
+func TileEntryByID(
+	ctx context.Context,
+	repo Repository,
+	header HeaderV3,
+	reader RangeReader,
+	decompress DecompressFunc, id TileID,
+) (*Entry, error) {
+	if !id.Valid() {
+		return nil, fmt.Errorf("invalid tile id %d beyond zoom %d", uint64(id), MaxZ)
+	}
+	return tileEntryByID(ctx, repo, header, reader, decompress, nil, id)
+}
+
+// tileEntry resolves the Entry of z, x, y and records the lookup to stats,
+// which may be nil.
+func tileEntry(
+	ctx context.Context,
+	repo Repository,
+	header HeaderV3,
+	reader RangeReader,
+	decompress DecompressFunc,
+	stats *sourceStats,
+	z, x, y uint64,
+) (*Entry, error) {
+	tileId, err := NewTileID(z, x, y)
 	if err != nil {
 		return nil, fmt.Errorf("resolving hilbert tile id from z:%d x:%d y:%d", z, x, y)
 	}
+	return tileEntryByID(ctx, repo, header, reader, decompress, stats, tileId)
+}
 
+// tileEntryByID resolves the Entry of tileId and records the lookup to
+// stats, which may be nil.
+func tileEntryByID(
+	ctx context.Context,
+	repo Repository,
+	header HeaderV3,
+	reader RangeReader,
+	decompress DecompressFunc,
+	stats *sourceStats,
+	tileId TileID,
+) (*Entry, error) {
 	dO := header.RootOffset
 	dS := header.RootLength
 
-	for range directoryMaxDepth {
+	for depth := range int(directoryMaxDepth) {
 		dir, _, derr := repo.DirectoryAt(ctx, header, reader, NewRange(dO, dS), decompress)
 		if derr != nil {
 			return nil, derr
@@ -424,9 +623,11 @@ func TileEntry(
 			// Dive further
 			dO = header.LeafDirectoryOffset + entry.Offset
 			dS = entry.Length
+			stats.observeLeaf(dS)
 			continue
 		}
 
+		stats.observeLookup(depth + 1)
 		return entry, nil
 	}
 
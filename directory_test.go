@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -78,6 +79,40 @@ func TestReadEntries(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "invalid count beyond data",
+			dataFunc: func() []byte {
+				buf := &bytes.Buffer{}
+				writeUvarint(buf, 1<<62)
+				writeUvarint(buf, 1)
+				return buf.Bytes()
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid overflowing tileId",
+			dataFunc: func() []byte {
+				buf := &bytes.Buffer{}
+				writeUvarint(buf, 2)
+				writeUvarint(buf, 1<<63)
+				writeUvarint(buf, 1<<63)
+				return buf.Bytes()
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid overflowing runLength",
+			dataFunc: func() []byte {
+				buf := &bytes.Buffer{}
+				writeUvarint(buf, 1)
+				writeUvarint(buf, 1)
+				writeUvarint(buf, 1<<32)
+				writeUvarint(buf, 1)
+				writeUvarint(buf, 1)
+				return buf.Bytes()
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -96,7 +131,7 @@ func TestReadEntries(t *testing.T) {
 			defer readCloser.Close()
 
 			br := bufio.NewReader(readCloser)
-			entries, err := readEntries(br)
+			entries, err := readEntries(br, 0)
 
 			if tc.expectErr {
 				if err == nil {
@@ -121,6 +156,35 @@ func TestReadEntries(t *testing.T) {
 	}
 }
 
+func TestReadEntriesPreallocation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		count    uint64
+		sizeHint uint64
+		expected int
+	}{
+		{name: "count below hint", count: 10, sizeHint: 1 << 20, expected: 10},
+		{name: "small hint", count: 1 << 40, sizeHint: 64, expected: maxEntriesPrealloc},
+		{name: "hint bounds count", count: 1 << 40, sizeHint: 1 << 16, expected: 1 << 14},
+		{name: "huge hint", count: 1 << 40, sizeHint: 16 << 30, expected: maxEntriesPreallocHint},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data := binary.AppendUvarint(nil, tc.count)
+			entries, err := readEntries(bufio.NewReader(bytes.NewReader(data)), tc.sizeHint)
+			if err == nil {
+				t.Fatal("expected error for truncated entries")
+			}
+			if cap(entries) != tc.expected {
+				t.Fatalf("expected capacity %d, got: %d", tc.expected, cap(entries))
+			}
+		})
+	}
+}
+
 func TestRepositoryDirectoryAt(t *testing.T) {
 	t.Parallel()
 
@@ -181,7 +245,7 @@ func TestRepositoryDirectoryAt(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			key := fmt.Sprintf("%s:%d:%d", tc.header.Etag, tc.ranger.Offset(), tc.ranger.Length())
+			key := HashKey(tc.header.Etag, tc.ranger.Offset(), tc.ranger.Length())
 
 			dir, _, err := repo.DirectoryAt(ctx, tc.header, tc.reader, tc.ranger, tc.decompress)
 
@@ -291,20 +355,20 @@ func buildDirs(n uint64) *Directory {
 	d := &Directory{size: n}
 	d.entries = make(Entries, n)
 
-	var id uint64
+	var id TileID
 	for i := range n {
-		id += uint64(rand.Intn(4) + 1)
+		id += TileID(rand.Intn(4) + 1)
 		d.entries[i] = Entry{TileID: id, Offset: i, Length: i, RunLength: uint32(i%7) + 1}
 	}
 	return d
 }
 
 // precompute a set of random target keys spanning the whole id range
-func buildTargets(d *Directory, count int) []uint64 {
+func buildTargets(d *Directory, count int) []TileID {
 	maxID := d.entries[len(d.entries)-1].TileID
-	t := make([]uint64, count)
+	t := make([]TileID, count)
 	for i := range t {
-		t[i] = rand.Uint64() % (maxID + 1)
+		t[i] = TileID(rand.Uint64()) % (maxID + 1)
 	}
 	return t
 }
@@ -360,3 +424,99 @@ func BenchmarkFindEntry(b *testing.B) {
 		})
 	}
 }
+
+func TestDirectoryBinaryRoundTrip(t *testing.T) {
+	want := buildDirs(1000)
+	want.key = "etag:127:1024"
+	// contiguous offsets are stored as 0
+	for i := range want.entries {
+		want.entries[i].Offset = uint64(i) * 10
+		want.entries[i].Length = 10
+	}
+
+	data, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshaling directory: %v", err)
+	}
+
+	var got Directory
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshaling directory: %v", err)
+	}
+	if got.Key() != want.key || got.Size() != want.size {
+		t.Errorf("got key %q size %d, want %q %d", got.Key(), got.Size(), want.key, want.size)
+	}
+	if !reflect.DeepEqual(got.entries, want.entries) {
+		t.Error("entries differ after round trip")
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "unsupported version", data: append([]byte{2}, data[1:]...)},
+		{name: "truncated key", data: data[:5]},
+		{name: "truncated entries", data: data[:len(data)-1]},
+		{name: "trailing data", data: append(bytes.Clone(data), 0)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Directory
+			if err := d.UnmarshalBinary(tt.data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func BenchmarkDirectoryBinary(b *testing.B) {
+	d := buildDirs(10_000)
+	d.key = "etag:127:1024"
+	data, err := d.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("marshal/N=10000", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := d.MarshalBinary(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(len(data)), "bytes")
+	})
+
+	b.Run("unmarshal/N=10000", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var out Directory
+			if err := out.UnmarshalBinary(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// plainRepository is a Repository without Snapshotter, as implemented
+// outside of the package.
+type plainRepository struct{}
+
+func (plainRepository) Close() {}
+
+func (plainRepository) DirectoryAt(
+	context.Context, HeaderV3, RangeReader, Ranger, DecompressFunc,
+) (Directory, bool, error) {
+	return Directory{}, false, nil
+}
+
+func TestTileSourceSnapshotWithoutSnapshotter(t *testing.T) {
+	s := &TileSource{repository: plainRepository{}}
+
+	if err := s.Snapshot(t.Context(), &bytes.Buffer{}); err == nil {
+		t.Error("expected error for repository without snapshots")
+	}
+	if err := s.Restore(t.Context(), bytes.NewReader(nil)); err == nil {
+		t.Error("expected error for repository without snapshots")
+	}
+}
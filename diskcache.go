@@ -0,0 +1,612 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	diskCacheDataExt  = ".data"
+	diskCacheIndexExt = ".idx"
+
+	// diskCacheCompactRecords is the number of appended index records beyond
+	// the merged intervals after which the index is rewritten.
+	diskCacheCompactRecords = 256
+)
+
+// ETagger is implemented by RangeReaders that know the ETag of the archive
+// they read from, e.g. HTTPRangeReader once the first response was received.
+type ETagger interface {
+	ETag() string
+}
+
+// readerETag returns the ETag of the first ETagger in the chain of reader,
+// see ReaderAs, or an empty string.
+func readerETag(reader RangeReader) string {
+	if e, ok := ReaderAs[ETagger](reader); ok {
+		return e.ETag()
+	}
+	return ""
+}
+
+type diskCacheConfig struct {
+	key string
+}
+
+// DiskCacheOption is a functional option for configuring a DiskCacheRangeReader.
+type DiskCacheOption = func(config *diskCacheConfig)
+
+// WithDiskCacheKey sets the key the archive is cached under. Use it for
+// readers that do not implement ETagger; the key must change whenever the
+// archive changes.
+func WithDiskCacheKey(key string) DiskCacheOption {
+	return func(config *diskCacheConfig) {
+		config.key = key
+	}
+}
+
+// DiskCacheRangeReader is a read-through cache persisting byte ranges fetched
+// from a (remote) RangeReader on local disk. Every archive is stored as a
+// sparse file keyed by its ETag together with an index of the cached ranges,
+// so a remote archive effectively gets a warm local mirror that survives
+// restarts.
+//
+// Ranges are only cached once the archive key is known: either set with
+// WithDiskCacheKey or reported by an inner RangeReader implementing ETagger.
+// If the cache grows beyond maxBytes, archives cached under other keys are
+// evicted oldest first, then the cache of the current archive is reset.
+type DiskCacheRangeReader struct {
+	inner    RangeReader
+	dir      string
+	maxBytes uint64
+	cfg      diskCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*diskCacheEntry
+	total   uint64
+
+	// evicting is held while evicting, so only a single store scans the
+	// cache directory at a time.
+	evicting sync.Mutex
+}
+
+// NewDiskCacheRangeReader creates a DiskCacheRangeReader caching reads of
+// inner in dir, bounded by maxBytes.
+func NewDiskCacheRangeReader(
+	inner RangeReader,
+	dir string,
+	maxBytes uint64,
+	options ...DiskCacheOption,
+) (*DiskCacheRangeReader, error) {
+	cfg := diskCacheConfig{}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating disk cache directory %s: %w", dir, err)
+	}
+
+	d := &DiskCacheRangeReader{
+		inner:    inner,
+		dir:      filepath.Clean(dir),
+		maxBytes: maxBytes,
+		cfg:      cfg,
+		entries:  map[string]*diskCacheEntry{},
+	}
+
+	cached, err := d.scan()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cached {
+		d.total += c.bytes
+	}
+
+	return d, nil
+}
+
+// key returns the key of the archive, or an empty string if not yet known.
+func (d *DiskCacheRangeReader) key() string {
+	if d.cfg.key != "" {
+		return d.cfg.key
+	}
+	return readerETag(d.inner)
+}
+
+// ReadRange serves the range from disk if cached, otherwise reads it from
+// the inner RangeReader and persists it.
+func (d *DiskCacheRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	key := d.key()
+	if key == "" {
+		return d.inner.ReadRange(ctx, ranger)
+	}
+
+	entry, err := d.entry(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rc, ok := entry.read(ranger.Offset(), ranger.Length()); ok {
+		return rc, nil
+	}
+
+	rc, err := d.inner.ReadRange(ctx, ranger)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading range: %w", err)
+	}
+
+	if err := d.store(entry, ranger, data); err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// entry returns the open cache entry for key, opening it if necessary.
+func (d *DiskCacheRangeReader) entry(key string) (*diskCacheEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.entries[key]; ok {
+		return e, nil
+	}
+
+	e, err := openDiskCacheEntry(d.dir, key)
+	if err != nil {
+		return nil, err
+	}
+	d.entries[key] = e
+
+	return e, nil
+}
+
+// store writes data to the entry and enforces maxBytes.
+func (d *DiskCacheRangeReader) store(entry *diskCacheEntry, ranger Ranger, data []byte) error {
+	added, err := entry.write(ranger.Offset(), data, uint64(len(data)) < ranger.Length())
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.total += added
+	exceeded := d.total > d.maxBytes
+	d.mu.Unlock()
+
+	if !exceeded {
+		return nil
+	}
+	return d.evict(entry)
+}
+
+// evict removes archives cached under other keys, oldest first, until the
+// cache fits maxBytes, then resets entry if it still does not fit. The cache
+// directory is scanned without holding d.mu, so concurrent reads are not
+// blocked; if another store is already evicting, evict returns right away.
+func (d *DiskCacheRangeReader) evict(entry *diskCacheEntry) error {
+	if !d.evicting.TryLock() {
+		return nil
+	}
+	defer d.evicting.Unlock()
+
+	cached, err := d.scan()
+	if err != nil {
+		return err
+	}
+	for _, c := range cached {
+		if c.name == entry.name {
+			continue
+		}
+		if !d.remove(c) {
+			return nil
+		}
+	}
+
+	d.mu.Lock()
+	exceeded := d.total > d.maxBytes
+	d.mu.Unlock()
+
+	if !exceeded {
+		return nil
+	}
+
+	freed, err := entry.reset()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.total -= min(d.total, freed)
+	d.mu.Unlock()
+
+	return nil
+}
+
+// remove deletes the cached archive c unless it is open, and reports whether
+// the cache still exceeds maxBytes.
+func (d *DiskCacheRangeReader) remove(c diskCacheFile) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.total <= d.maxBytes {
+		return false
+	}
+	if e, ok := d.entries[c.key]; ok && e.name == c.name {
+		return true
+	}
+
+	_ = os.Remove(filepath.Join(d.dir, c.name+diskCacheDataExt))  //nolint:errcheck
+	_ = os.Remove(filepath.Join(d.dir, c.name+diskCacheIndexExt)) //nolint:errcheck
+	d.total -= min(d.total, c.bytes)
+
+	return d.total > d.maxBytes
+}
+
+type diskCacheFile struct {
+	name    string
+	key     string
+	bytes   uint64
+	modTime time.Time
+}
+
+// scan lists the cached archives in dir, oldest first.
+func (d *DiskCacheRangeReader) scan() ([]diskCacheFile, error) {
+	dirEntries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading disk cache directory %s: %w", d.dir, err)
+	}
+
+	var files []diskCacheFile
+	for _, de := range dirEntries {
+		name, ok := strings.CutSuffix(de.Name(), diskCacheIndexExt)
+		if !ok {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+
+		idx, err := readDiskCacheIndex(filepath.Join(d.dir, de.Name()))
+		if err != nil {
+			continue
+		}
+
+		files = append(files, diskCacheFile{
+			name:    name,
+			key:     idx.key,
+			bytes:   idx.bytes(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	return files, nil
+}
+
+// ETag returns the key the archive is cached under, implementing ETagger
+// for caches layered on top.
+func (d *DiskCacheRangeReader) ETag() string {
+	return d.key()
+}
+
+// Unwrap returns the inner reader, see Unwrapper.
+func (d *DiskCacheRangeReader) Unwrap() RangeReader {
+	return d.inner
+}
+
+// Close closes all open cache files and the inner reader if it implements
+// io.Closer.
+func (d *DiskCacheRangeReader) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	errs := []error{CloseReader(d.inner)}
+	for key, e := range d.entries {
+		errs = append(errs, e.close())
+		delete(d.entries, key)
+	}
+
+	return errors.Join(errs...)
+}
+
+// diskCacheEntry is the sparse data file and index of a single archive.
+// Stores append a record to the index file, which is compacted once the
+// records outgrow the merged intervals.
+type diskCacheEntry struct {
+	name string
+	base string
+	file *os.File
+
+	mu      sync.RWMutex
+	idx     diskCacheIndex
+	index   *os.File
+	records int
+}
+
+func openDiskCacheEntry(dir, key string) (*diskCacheEntry, error) {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:16])
+	base := filepath.Join(dir, name)
+
+	idx, err := readDiskCacheIndex(base + diskCacheIndexExt)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && idx.key != key) {
+		idx, err = diskCacheIndex{key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(base+diskCacheDataExt, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening disk cache file: %w", err)
+	}
+
+	e := &diskCacheEntry{name: name, base: base, file: f, idx: idx}
+	if err := e.compact(); err != nil {
+		_ = f.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// read returns the cached range if it is fully cached. The range is copied
+// while holding the lock, so it cannot be torn by a concurrent reset or close.
+func (e *diskCacheEntry) read(offset, length uint64) (io.ReadCloser, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	end := offset + length
+	if e.idx.size > 0 {
+		end = min(end, e.idx.size)
+	}
+	if offset >= end {
+		if e.idx.size > 0 {
+			return io.NopCloser(bytes.NewReader(nil)), true
+		}
+		return nil, false
+	}
+
+	if !e.idx.covers(offset, end) {
+		return nil, false
+	}
+
+	n, err := bufferLen(end - offset)
+	if err != nil {
+		return nil, false
+	}
+	data := make([]byte, n)
+	if _, err := e.file.ReadAt(data, int64(offset)); err != nil { //nolint:gosec
+		return nil, false
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), true
+}
+
+// write persists data at offset and returns the number of newly cached bytes.
+// A short read marks the end of the archive.
+func (e *diskCacheEntry) write(offset uint64, data []byte, short bool) (uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(data) > 0 {
+		if _, err := e.file.WriteAt(data, int64(offset)); err != nil { //nolint:gosec
+			return 0, fmt.Errorf("writing disk cache file: %w", err)
+		}
+		// the data must be on disk before the index references it.
+		if err := e.file.Sync(); err != nil {
+			return 0, fmt.Errorf("writing disk cache file: %w", err)
+		}
+	}
+
+	before := e.idx.bytes()
+	end := offset + uint64(len(data))
+	e.idx.add(offset, end)
+	if short {
+		e.idx.size = end
+	}
+
+	if e.records > 2*len(e.idx.intervals)+diskCacheCompactRecords {
+		if err := e.compact(); err != nil {
+			return 0, err
+		}
+	} else {
+		if _, err := e.index.Write(appendDiskCacheRecord(nil, offset, end, e.idx.size)); err != nil {
+			return 0, fmt.Errorf("writing disk cache index: %w", err)
+		}
+		e.records++
+	}
+
+	return e.idx.bytes() - before, nil
+}
+
+// compact atomically rewrites the index from the merged intervals and
+// reopens it for appending.
+func (e *diskCacheEntry) compact() error {
+	if e.index != nil {
+		_ = e.index.Close() //nolint:errcheck
+		e.index = nil
+	}
+
+	path := e.base + diskCacheIndexExt
+	if err := e.idx.writeFile(path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening disk cache index: %w", err)
+	}
+	e.index, e.records = f, len(e.idx.intervals)
+
+	return nil
+}
+
+// reset drops all cached ranges and returns the number of freed bytes.
+func (e *diskCacheEntry) reset() (uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// the index is emptied first, so it never references truncated data.
+	freed := e.idx.bytes()
+	e.idx = diskCacheIndex{key: e.idx.key}
+	if err := e.compact(); err != nil {
+		return 0, err
+	}
+	if err := e.file.Truncate(0); err != nil {
+		return 0, fmt.Errorf("resetting disk cache file: %w", err)
+	}
+
+	return freed, nil
+}
+
+// close closes the data and index files once no read or write is in flight.
+func (e *diskCacheEntry) close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var err error
+	if e.index != nil {
+		err = e.index.Close()
+	}
+	return errors.Join(err, e.file.Close())
+}
+
+// diskCacheIndex tracks the cached byte ranges of an archive as sorted,
+// non-overlapping [start, end) intervals.
+type diskCacheIndex struct {
+	key       string
+	size      uint64 // archive size if known, 0 otherwise
+	intervals [][2]uint64
+}
+
+func (idx *diskCacheIndex) covers(start, end uint64) bool {
+	i := sort.Search(len(idx.intervals), func(i int) bool {
+		return idx.intervals[i][1] >= end
+	})
+	return i < len(idx.intervals) && idx.intervals[i][0] <= start
+}
+
+func (idx *diskCacheIndex) add(start, end uint64) {
+	if start >= end {
+		return
+	}
+
+	merged := make([][2]uint64, 0, len(idx.intervals)+1)
+	i := 0
+	for ; i < len(idx.intervals) && idx.intervals[i][1] < start; i++ {
+		merged = append(merged, idx.intervals[i])
+	}
+	for ; i < len(idx.intervals) && idx.intervals[i][0] <= end; i++ {
+		start = min(start, idx.intervals[i][0])
+		end = max(end, idx.intervals[i][1])
+	}
+	merged = append(merged, [2]uint64{start, end})
+	idx.intervals = append(merged, idx.intervals[i:]...)
+}
+
+func (idx *diskCacheIndex) bytes() uint64 {
+	var n uint64
+	for _, iv := range idx.intervals {
+		n += iv[1] - iv[0]
+	}
+	return n
+}
+
+// appendDiskCacheRecord appends an index record of the cached range
+// [start, end) and the archive size as uvarints.
+func appendDiskCacheRecord(buf []byte, start, end, size uint64) []byte {
+	buf = binary.AppendUvarint(buf, start)
+	buf = binary.AppendUvarint(buf, end)
+	return binary.AppendUvarint(buf, size)
+}
+
+// writeFile atomically persists the index as uvarints: key length, key,
+// then a record of the size followed by a record per interval.
+func (idx *diskCacheIndex) writeFile(path string) error {
+	buf := binary.AppendUvarint(nil, uint64(len(idx.key)))
+	buf = append(buf, idx.key...)
+	buf = appendDiskCacheRecord(buf, 0, 0, idx.size)
+	for _, iv := range idx.intervals {
+		buf = appendDiskCacheRecord(buf, iv[0], iv[1], idx.size)
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(filepath.Clean(tmp), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("writing disk cache index: %w", err)
+	}
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing disk cache index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing disk cache index: %w", err)
+	}
+	return nil
+}
+
+func readDiskCacheIndex(path string) (diskCacheIndex, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return diskCacheIndex{}, err
+	}
+
+	r := bytes.NewReader(data)
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil || keyLen > uint64(r.Len()) {
+		return diskCacheIndex{}, fmt.Errorf("corrupt disk cache index %s", path)
+	}
+	key := make([]byte, keyLen)
+	_, _ = r.Read(key) //nolint:errcheck
+
+	// records are applied up to the first incomplete one, which is the tail
+	// of an append interrupted by a crash.
+	idx := diskCacheIndex{key: string(key)}
+	for r.Len() > 0 {
+		start, serr := binary.ReadUvarint(r)
+		end, eerr := binary.ReadUvarint(r)
+		size, zerr := binary.ReadUvarint(r)
+		if serr != nil || eerr != nil || zerr != nil || start > end {
+			break
+		}
+		idx.add(start, end)
+		idx.size = max(idx.size, size)
+	}
+
+	return idx, nil
+}
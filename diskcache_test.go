@@ -0,0 +1,220 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// etagRangeReader serves ranges of data and reports etag.
+type etagRangeReader struct {
+	data  []byte
+	etag  string
+	calls atomic.Int32
+}
+
+func (e *etagRangeReader) ETag() string { return e.etag }
+
+func (e *etagRangeReader) ReadRange(_ context.Context, r pmtilr.Ranger) (io.ReadCloser, error) {
+	e.calls.Add(1)
+	start := min(r.Offset(), uint64(len(e.data)))
+	end := min(r.Offset()+r.Length(), uint64(len(e.data)))
+	return io.NopCloser(bytes.NewReader(e.data[start:end])), nil
+}
+
+func readAll(t *testing.T, reader pmtilr.RangeReader, offset, length uint64) string {
+	t.Helper()
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(offset, length))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rc.Close()
+
+	result, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return string(result)
+}
+
+func TestDiskCacheRangeReader(t *testing.T) {
+	testData := []byte("This is some test data for the RangeReader implementation.")
+
+	t.Run("serves cached ranges from disk across restarts", func(t *testing.T) {
+		dir := t.TempDir()
+		inner := &etagRangeReader{data: testData, etag: `"v1"`}
+
+		reader, err := pmtilr.NewDiskCacheRangeReader(inner, dir, 1<<20)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if got := readAll(t, reader, 0, 10); got != "This is so" {
+			t.Fatalf("expected %s, got: %s", "This is so", got)
+		}
+		if got := readAll(t, reader, 10, 10); got != "me test da" {
+			t.Fatalf("expected %s, got: %s", "me test da", got)
+		}
+		// served from the two adjacent cached ranges
+		if got := readAll(t, reader, 5, 10); got != "is some te" {
+			t.Fatalf("expected %s, got: %s", "is some te", got)
+		}
+		if inner.calls.Load() != 2 {
+			t.Fatalf("expected 2 inner reads, got: %d", inner.calls.Load())
+		}
+		reader.Close()
+
+		restarted, err := pmtilr.NewDiskCacheRangeReader(inner, dir, 1<<20)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer restarted.Close()
+
+		if got := readAll(t, restarted, 0, 20); got != "This is some test da" {
+			t.Fatalf("expected %s, got: %s", "This is some test da", got)
+		}
+		if inner.calls.Load() != 2 {
+			t.Fatalf("expected cache to survive restart, got %d inner reads", inner.calls.Load())
+		}
+	})
+
+	t.Run("caches end of archive", func(t *testing.T) {
+		inner := &etagRangeReader{data: testData, etag: `"v1"`}
+
+		reader, err := pmtilr.NewDiskCacheRangeReader(inner, t.TempDir(), 1<<20)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+
+		for range 2 {
+			if got := readAll(t, reader, uint64(len(testData)-5), 50); got != "tion." {
+				t.Fatalf("expected %s, got: %s", "tion.", got)
+			}
+		}
+		if inner.calls.Load() != 1 {
+			t.Fatalf("expected 1 inner read, got: %d", inner.calls.Load())
+		}
+	})
+
+	t.Run("evicts other archives beyond max bytes", func(t *testing.T) {
+		dir := t.TempDir()
+
+		old, err := pmtilr.NewDiskCacheRangeReader(&etagRangeReader{data: testData, etag: `"v1"`}, dir, 30)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		readAll(t, old, 0, 20)
+		old.Close()
+
+		reader, err := pmtilr.NewDiskCacheRangeReader(&etagRangeReader{data: testData, etag: `"v2"`}, dir, 30)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+		readAll(t, reader, 0, 20)
+
+		files, _ := os.ReadDir(dir)
+		if len(files) != 2 {
+			t.Fatalf("expected data and index of a single archive, got %d files", len(files))
+		}
+	})
+
+	t.Run("reads are not torn by concurrent resets", func(t *testing.T) {
+		inner := &etagRangeReader{data: testData, etag: `"v1"`}
+
+		// every store exceeds max bytes and resets the cache of the archive.
+		reader, err := pmtilr.NewDiskCacheRangeReader(inner, t.TempDir(), 15)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Go(func() {
+				for j := range 100 {
+					offset := uint64((i + j) % 4 * 10)
+					rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(offset, 10))
+					if err != nil {
+						t.Errorf("unexpected error: %s", err)
+						return
+					}
+					// a slow consumer, other goroutines reset the cache meanwhile.
+					time.Sleep(time.Millisecond)
+					got, _ := io.ReadAll(rc)
+					rc.Close()
+
+					if expected := string(testData[offset : offset+10]); string(got) != expected {
+						t.Errorf("expected %q, got: %q", expected, got)
+						return
+					}
+				}
+			})
+		}
+		wg.Wait()
+	})
+
+	t.Run("appends to the index and ignores a torn record", func(t *testing.T) {
+		dir := t.TempDir()
+		inner := &etagRangeReader{data: testData, etag: `"v1"`}
+
+		reader, err := pmtilr.NewDiskCacheRangeReader(inner, dir, 1<<20)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		readAll(t, reader, 0, 10)
+		readAll(t, reader, 20, 10)
+		reader.Close()
+
+		index, err := filepath.Glob(filepath.Join(dir, "*.idx"))
+		if err != nil || len(index) != 1 {
+			t.Fatalf("expected a single index, got %v: %v", index, err)
+		}
+		// an append interrupted by a crash leaves an incomplete record.
+		f, err := os.OpenFile(index[0], os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		f.Write([]byte{0x80})
+		f.Close()
+
+		restarted, err := pmtilr.NewDiskCacheRangeReader(inner, dir, 1<<20)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer restarted.Close()
+
+		if got := readAll(t, restarted, 20, 10); got != string(testData[20:30]) {
+			t.Fatalf("expected %s, got: %s", testData[20:30], got)
+		}
+		if inner.calls.Load() != 2 {
+			t.Fatalf("expected cache to survive a torn record, got %d inner reads", inner.calls.Load())
+		}
+	})
+
+	t.Run("passes through without key", func(t *testing.T) {
+		inner := &etagRangeReader{data: testData}
+
+		reader, err := pmtilr.NewDiskCacheRangeReader(inner, t.TempDir(), 1<<20)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+
+		readAll(t, reader, 0, 10)
+		readAll(t, reader, 0, 10)
+		if inner.calls.Load() != 2 {
+			t.Fatalf("expected 2 inner reads, got: %d", inner.calls.Load())
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package pmtilr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// contentEncodings maps Compression to its HTTP content coding.
+var contentEncodings = map[Compression]string{
+	CompressionGZIP:   "gzip",
+	CompressionBrotli: "br",
+	CompressionZstd:   "zstd",
+}
+
+// ContentEncoding returns the HTTP Content-Encoding of the compression,
+// e.g. "br" for CompressionBrotli, or an empty string for CompressionNone
+// and unknown values.
+func (c Compression) ContentEncoding() string {
+	return contentEncodings[c]
+}
+
+// NegotiateEncoding decides how to serve a tile compressed with compression
+// to a client sending acceptEncoding, e.g. the TileCompression of the
+// archive and the request's Accept-Encoding header. If the client accepts
+// the compression, the tile is passed through as is and contentEncoding is
+// the Content-Encoding to send. Otherwise decompress is true and the tile
+// must be decompressed, e.g. with Decompress, and sent without
+// Content-Encoding. Clients that send no Accept-Encoding are served
+// decompressed tiles.
+//
+// Uncompressed tiles and tiles of unknown compression are passed through
+// without Content-Encoding.
+func NegotiateEncoding(compression Compression, acceptEncoding string) (contentEncoding string, decompress bool) {
+	coding := compression.ContentEncoding()
+	if coding == "" {
+		return "", false
+	}
+	if acceptsEncoding(acceptEncoding, coding) {
+		return coding, false
+	}
+	return "", true
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value accepts
+// coding with a non-zero quality. An explicit entry for coding takes
+// precedence over the "*" wildcard.
+func acceptsEncoding(acceptEncoding, coding string) bool {
+	wildcard := false
+	for entry := range strings.SplitSeq(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		quality, ok := encodingQuality(params)
+		if !ok {
+			continue
+		}
+		accepted := quality > 0
+
+		switch {
+		case name == coding, coding == "gzip" && name == "x-gzip":
+			return accepted
+		case name == "*":
+			wildcard = accepted
+		}
+	}
+	return wildcard
+}
+
+// encodingQuality returns the q parameter of an Accept-Encoding entry,
+// defaulting to 1, and whether it is valid.
+func encodingQuality(params string) (float64, bool) {
+	for param := range strings.SplitSeq(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || quality < 0 || quality > 1 {
+			return 0, false
+		}
+		return quality, true
+	}
+	return 1, true
+}
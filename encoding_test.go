@@ -0,0 +1,43 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		compression    pmtilr.Compression
+		acceptEncoding string
+		wantEncoding   string
+		wantDecompress bool
+	}{
+		{name: "gzip accepted", compression: pmtilr.CompressionGZIP, acceptEncoding: "gzip, deflate, br", wantEncoding: "gzip"},
+		{name: "x-gzip accepted", compression: pmtilr.CompressionGZIP, acceptEncoding: "x-gzip", wantEncoding: "gzip"},
+		{name: "brotli accepted", compression: pmtilr.CompressionBrotli, acceptEncoding: "gzip, BR", wantEncoding: "br"},
+		{name: "zstd accepted", compression: pmtilr.CompressionZstd, acceptEncoding: "zstd;q=0.5", wantEncoding: "zstd"},
+		{name: "brotli not accepted", compression: pmtilr.CompressionBrotli, acceptEncoding: "gzip, deflate", wantDecompress: true},
+		{name: "refused with q=0", compression: pmtilr.CompressionGZIP, acceptEncoding: "gzip;q=0, br", wantDecompress: true},
+		{name: "wildcard", compression: pmtilr.CompressionZstd, acceptEncoding: "*", wantEncoding: "zstd"},
+		{name: "explicit refusal overrides wildcard", compression: pmtilr.CompressionGZIP, acceptEncoding: "*, gzip;q=0", wantDecompress: true},
+		{name: "refused wildcard", compression: pmtilr.CompressionGZIP, acceptEncoding: "identity, *;q=0", wantDecompress: true},
+		{name: "invalid quality ignored", compression: pmtilr.CompressionGZIP, acceptEncoding: "gzip;q=abc", wantDecompress: true},
+		{name: "no accept-encoding", compression: pmtilr.CompressionGZIP, wantDecompress: true},
+		{name: "uncompressed", compression: pmtilr.CompressionNone, acceptEncoding: "gzip"},
+		{name: "unknown compression", compression: pmtilr.CompressionUnknown, acceptEncoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding, decompress := pmtilr.NegotiateEncoding(tt.compression, tt.acceptEncoding)
+			if encoding != tt.wantEncoding || decompress != tt.wantDecompress {
+				t.Errorf(
+					"expected (%q, %t), got (%q, %t)",
+					tt.wantEncoding, tt.wantDecompress, encoding, decompress,
+				)
+			}
+		})
+	}
+}
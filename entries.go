@@ -0,0 +1,77 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+// EntryReader is implemented by Sources that enumerate the tile entries of
+// their archive and read the bytes of an entry directly, such as
+// TileSource. Export, NewHashIndex and DiffArchives require it, reading
+// every run of tiles once.
+type EntryReader interface {
+	// Entries yields every tile Entry in ascending TileID order.
+	Entries(ctx context.Context) iter.Seq2[Entry, error]
+	// EntryBytes reads the tile bytes of an Entry yielded by Entries.
+	EntryBytes(ctx context.Context, e Entry) ([]byte, error)
+}
+
+// sourceEntryReader returns source as an EntryReader.
+func sourceEntryReader(source Source) (EntryReader, error) {
+	er, ok := source.(EntryReader)
+	if !ok {
+		return nil, fmt.Errorf("%T does not read archive entries", source)
+	}
+	return er, nil
+}
+
+// TileEntries walks the directories of the archive and yields every tile
+// Entry in ascending TileID order. Leaf directories are read one at a time
+// through repo, so memory is bounded by the directory cache.
+func TileEntries(
+	ctx context.Context,
+	repo Repository,
+	header HeaderV3,
+	reader RangeReader,
+	decompress DecompressFunc,
+) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		var walk func(ranger Ranger, depth uint64) bool
+		walk = func(ranger Ranger, depth uint64) bool {
+			if depth >= directoryMaxDepth {
+				yield(Entry{}, fmt.Errorf("maximum directory depth exceeded"))
+				return false
+			}
+
+			dir, _, err := repo.DirectoryAt(ctx, header, reader, ranger, decompress)
+			if err != nil {
+				yield(Entry{}, err)
+				return false
+			}
+
+			for e := range dir.IterEntries() {
+				if err := ctx.Err(); err != nil {
+					yield(Entry{}, err)
+					return false
+				}
+
+				if e.IsDirectory() {
+					leaf := NewRange(header.LeafDirectoryOffset+e.Offset, e.Length)
+					if !walk(leaf, depth+1) {
+						return false
+					}
+					continue
+				}
+
+				if !yield(e, nil) {
+					return false
+				}
+			}
+
+			return true
+		}
+
+		walk(NewRange(header.RootOffset, header.RootLength), 0)
+	}
+}
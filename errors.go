@@ -3,3 +3,10 @@ package pmtilr
 import "errors"
 
 var ErrTileNotFound = errors.New("tile not found")
+
+// ErrArchiveChanged is returned by remote RangeReaders when the archive was
+// replaced upstream after its validators (ETag/Last-Modified) were pinned.
+var ErrArchiveChanged = errors.New("archive changed upstream")
+
+// ErrSourceClosed is returned when reading from a Source that was closed.
+var ErrSourceClosed = errors.New("source closed")
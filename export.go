@@ -0,0 +1,238 @@
+package pmtilr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const defaultCheckpointInterval = 1000
+
+// ErrCheckpointMismatch is returned when resuming from a checkpoint written
+// for a different archive.
+var ErrCheckpointMismatch = errors.New("checkpoint belongs to a different archive")
+
+// TileWriter receives the tiles of an export.
+type TileWriter interface {
+	WriteTile(ctx context.Context, z, x, y uint64, data []byte) error
+}
+
+// TileWriterFunc adapts a function to TileWriter.
+type TileWriterFunc func(ctx context.Context, z, x, y uint64, data []byte) error
+
+// WriteTile implements TileWriter.
+func (f TileWriterFunc) WriteTile(ctx context.Context, z, x, y uint64, data []byte) error {
+	return f(ctx, z, x, y, data)
+}
+
+// Checkpoint is the persisted progress of an export.
+type Checkpoint struct {
+	// Fingerprint identifies the exported archive, see
+	// archiveFingerprint.
+	Fingerprint string `json:"fingerprint"`
+	LastTileID  TileID `json:"last_tile_id"`
+	Tiles       uint64 `json:"tiles"`
+	Started     bool   `json:"started"`
+}
+
+type exportConfig struct {
+	checkpointPath     string
+	checkpointInterval uint64
+	zooms              *ZoomRange
+}
+
+// ExportOption configures Export.
+type ExportOption = func(*exportConfig)
+
+// WithCheckpoint persists the export progress to path and resumes from it
+// if it exists. The file is removed once the export completes.
+func WithCheckpoint(path string) ExportOption {
+	return func(cfg *exportConfig) {
+		cfg.checkpointPath = path
+	}
+}
+
+// WithCheckpointInterval sets after how many tiles the checkpoint is
+// persisted. Defaults to 1000.
+func WithCheckpointInterval(tiles uint64) ExportOption {
+	return func(cfg *exportConfig) {
+		cfg.checkpointInterval = max(tiles, 1)
+	}
+}
+
+// WithExportZoomRange restricts the export to zooms.
+func WithExportZoomRange(zooms ZoomRange) ExportOption {
+	return func(cfg *exportConfig) {
+		cfg.zooms = &zooms
+	}
+}
+
+// Export writes every tile of source to w in ascending TileID order. source
+// must implement EntryReader; the bytes of every run of tiles are read once.
+//
+// With WithCheckpoint the last written TileID is persisted periodically and
+// whenever Export returns early, including on context cancellation, so a
+// later call resumes after the last tile that was written. A tile is only
+// recorded after w.WriteTile returned, so w may see the tiles since the
+// last persisted checkpoint again and should overwrite them.
+func Export(ctx context.Context, source Source, w TileWriter, options ...ExportOption) (err error) {
+	cfg := exportConfig{checkpointInterval: defaultCheckpointInterval}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	if cfg.zooms != nil {
+		if err := cfg.zooms.Validate(); err != nil {
+			return err
+		}
+	}
+
+	er, err := sourceEntryReader(source)
+	if err != nil {
+		return fmt.Errorf("exporting: %w", err)
+	}
+
+	fingerprint, err := archiveFingerprint(source)
+	if err != nil {
+		return err
+	}
+	cp := Checkpoint{Fingerprint: fingerprint}
+	if cfg.checkpointPath != "" {
+		loaded, err := readCheckpoint(cfg.checkpointPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return err
+		case loaded.Fingerprint != fingerprint:
+			return fmt.Errorf("%w: %s", ErrCheckpointMismatch, cfg.checkpointPath)
+		default:
+			cp = loaded
+		}
+	}
+
+	var pending uint64
+	save := func() error {
+		if cfg.checkpointPath == "" || pending == 0 {
+			return nil
+		}
+		pending = 0
+		return writeCheckpoint(cfg.checkpointPath, cp)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, save())
+		}
+	}()
+
+	for e, err := range er.Entries(ctx) {
+		if err != nil {
+			return fmt.Errorf("exporting: %w", err)
+		}
+		if cp.Started && e.TileID+TileID(e.RunLength) <= cp.LastTileID+1 {
+			continue
+		}
+
+		zxy, err := FastZXYfromHilbertTileID(uint64(e.TileID))
+		if err != nil {
+			return err
+		}
+		if cfg.zooms != nil && (zxy[0] < cfg.zooms.MinZoom() || zxy[0] > cfg.zooms.MaxZoom()) {
+			continue
+		}
+
+		data, err := er.EntryBytes(ctx, e)
+		if err != nil {
+			return fmt.Errorf("exporting tile %d/%d/%d: %w", zxy[0], zxy[1], zxy[2], err)
+		}
+
+		for i := range uint64(e.RunLength) {
+			id := e.TileID + TileID(i)
+			if cp.Started && id <= cp.LastTileID {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			tile := zxy
+			if i > 0 {
+				if tile, err = FastZXYfromHilbertTileID(uint64(id)); err != nil {
+					return err
+				}
+			}
+			if err := w.WriteTile(ctx, tile[0], tile[1], tile[2], data); err != nil {
+				return fmt.Errorf("writing tile %d/%d/%d: %w", tile[0], tile[1], tile[2], err)
+			}
+
+			cp.LastTileID, cp.Started = id, true
+			cp.Tiles++
+			pending++
+			if pending >= cfg.checkpointInterval {
+				if err := save(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if cfg.checkpointPath != "" {
+		if err := os.Remove(cfg.checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// archiveFingerprint identifies the archive of source by a hash of its
+// header and metadata. Unlike the header's Etag, which is random for readers
+// that are not ETaggers, it is stable across processes, so a checkpoint can
+// be resumed by a freshly opened Source. The header holds the offsets and
+// lengths of all sections and thereby the size of the archive.
+func archiveFingerprint(source Source) (string, error) {
+	header := source.Header()
+	header.Etag = ""
+
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(header); err != nil {
+		return "", fmt.Errorf("fingerprinting archive: %w", err)
+	}
+	if err := json.NewEncoder(h).Encode(source.Meta()); err != nil {
+		return "", fmt.Errorf("fingerprinting archive: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readCheckpoint(path string) (Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("decoding checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint atomically persists cp to path.
+func writeCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
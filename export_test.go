@@ -0,0 +1,135 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestExportResume(t *testing.T) {
+	tests := []struct {
+		name   string
+		reopen bool
+	}{
+		{name: "same source"},
+		// the file reader reports no ETag, so a new process resumes with a
+		// different random Etag.
+		{name: "fresh source", reopen: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+			if err != nil {
+				t.Fatalf("creating source should not fail: %s", err)
+			}
+			total := src.Header().AddressedTilesCount
+			checkpoint := filepath.Join(t.TempDir(), "export.checkpoint")
+
+			written := map[[3]uint64]int{}
+			record := func(_ context.Context, z, x, y uint64, data []byte) error {
+				if len(data) == 0 {
+					t.Errorf("expected tile data for %d/%d/%d", z, x, y)
+				}
+				written[[3]uint64{z, x, y}]++
+				return nil
+			}
+
+			// interrupt the export after 100 tiles
+			ctx, cancel := context.WithCancel(t.Context())
+			var n int
+			err = pmtilr.Export(ctx, src, pmtilr.TileWriterFunc(func(ctx context.Context, z, x, y uint64, data []byte) error {
+				if n++; n == 100 {
+					cancel()
+				}
+				return record(ctx, z, x, y, data)
+			}), pmtilr.WithCheckpoint(checkpoint))
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got: %v", err)
+			}
+			if _, err := os.Stat(checkpoint); err != nil {
+				t.Fatalf("expected checkpoint to be written: %s", err)
+			}
+
+			if tt.reopen {
+				etag := src.Header().Etag
+				if src, err = pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation()); err != nil {
+					t.Fatalf("creating source should not fail: %s", err)
+				}
+				if src.Header().Etag == etag {
+					t.Fatal("expected a fresh source with a different etag")
+				}
+			}
+
+			err = pmtilr.Export(t.Context(), src, pmtilr.TileWriterFunc(record), pmtilr.WithCheckpoint(checkpoint))
+			if err != nil {
+				t.Fatalf("resuming export should not fail: %s", err)
+			}
+
+			if uint64(len(written)) != total {
+				t.Fatalf("expected %d tiles, got: %d", total, len(written))
+			}
+			for zxy, count := range written {
+				if count != 1 {
+					t.Fatalf("expected tile %v to be written once, got: %d", zxy, count)
+				}
+			}
+			if _, err := os.Stat(checkpoint); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("expected checkpoint to be removed after completion, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestExportCheckpointMismatch(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	checkpoint := filepath.Join(t.TempDir(), "export.checkpoint")
+	if err := os.WriteFile(checkpoint, []byte(`{"fingerprint":"other","last_tile_id":5,"started":true}`), 0o600); err != nil {
+		t.Fatalf("writing checkpoint: %s", err)
+	}
+
+	err = pmtilr.Export(t.Context(), src, pmtilr.TileWriterFunc(
+		func(context.Context, uint64, uint64, uint64, []byte) error { return nil },
+	), pmtilr.WithCheckpoint(checkpoint))
+	if !errors.Is(err, pmtilr.ErrCheckpointMismatch) {
+		t.Fatalf("expected ErrCheckpointMismatch, got: %v", err)
+	}
+}
+
+func TestExportZoomRange(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	var count uint64
+	err = pmtilr.Export(t.Context(), src, pmtilr.TileWriterFunc(
+		func(_ context.Context, z, _, _ uint64, _ []byte) error {
+			if z > 2 {
+				t.Errorf("unexpected tile at zoom %d", z)
+			}
+			count++
+			return nil
+		},
+	), pmtilr.WithExportZoomRange(pmtilr.NewZoomRange(0, 2)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var expected uint64
+	for z := range uint8(3) {
+		coverage, _ := src.(pmtilr.Coverager).Coverage(t.Context(), z)
+		expected += coverage.Count()
+	}
+	if count != expected {
+		t.Fatalf("expected %d tiles, got: %d", expected, count)
+	}
+}
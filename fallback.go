@@ -0,0 +1,127 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultFallbackFailureThreshold uint32 = 3
+	defaultFallbackCooldown                = 30 * time.Second
+)
+
+type fallbackConfig struct {
+	failureThreshold uint32
+	cooldown         time.Duration
+}
+
+// FallbackOption is a functional option for configuring a FallbackRangeReader.
+type FallbackOption = func(config *fallbackConfig)
+
+// WithFallbackFailureThreshold sets the number of consecutive primary failures
+// after which the primary is considered unhealthy. Defaults to 3.
+func WithFallbackFailureThreshold(n uint32) FallbackOption {
+	return func(config *fallbackConfig) {
+		config.failureThreshold = n
+	}
+}
+
+// WithFallbackCooldown sets how long an unhealthy primary is skipped before
+// it is probed again. Defaults to 30s.
+func WithFallbackCooldown(d time.Duration) FallbackOption {
+	return func(config *fallbackConfig) {
+		config.cooldown = d
+	}
+}
+
+// FallbackRangeReader reads from a primary RangeReader and transparently
+// falls back to a secondary RangeReader when the primary errors, e.g. local
+// mirror → S3, or regional bucket → global bucket.
+//
+// After a number of consecutive primary failures the primary is marked
+// unhealthy and reads go to the secondary directly until the cooldown has
+// passed, after which the primary is given another chance.
+type FallbackRangeReader struct {
+	primary   RangeReader
+	secondary RangeReader
+	cfg       fallbackConfig
+
+	failures       atomic.Uint32
+	unhealthyUntil atomic.Int64
+}
+
+// NewFallbackRangeReader creates a FallbackRangeReader over primary and secondary.
+func NewFallbackRangeReader(
+	primary, secondary RangeReader,
+	options ...FallbackOption,
+) *FallbackRangeReader {
+	cfg := fallbackConfig{
+		failureThreshold: defaultFallbackFailureThreshold,
+		cooldown:         defaultFallbackCooldown,
+	}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+
+	return &FallbackRangeReader{
+		primary:   primary,
+		secondary: secondary,
+		cfg:       cfg,
+	}
+}
+
+// Healthy reports whether reads are currently attempted against the primary.
+func (f *FallbackRangeReader) Healthy() bool {
+	return time.Now().UnixNano() >= f.unhealthyUntil.Load()
+}
+
+// ReadRange reads the range from the primary, or from the secondary if the
+// primary is unhealthy or fails. Context cancellation is never retried.
+func (f *FallbackRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if !f.Healthy() {
+		return f.secondary.ReadRange(ctx, ranger)
+	}
+
+	rc, err := f.primary.ReadRange(ctx, ranger)
+	if err == nil {
+		f.failures.Store(0)
+		return rc, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	if f.failures.Add(1) >= f.cfg.failureThreshold {
+		f.unhealthyUntil.Store(time.Now().Add(f.cfg.cooldown).UnixNano())
+		f.failures.Store(0)
+	}
+
+	rc, serr := f.secondary.ReadRange(ctx, ranger)
+	if serr != nil {
+		return nil, fmt.Errorf("reading from fallback: %w", errors.Join(err, serr))
+	}
+
+	return rc, nil
+}
+
+// Close closes the primary and secondary reader if they implement io.Closer.
+func (f *FallbackRangeReader) Close() error {
+	return errors.Join(CloseReader(f.primary), CloseReader(f.secondary))
+}
+
+// Probe succeeds if the primary or the secondary reader is reachable, see
+// Prober.
+func (f *FallbackRangeReader) Probe(ctx context.Context) error {
+	perr := Probe(ctx, f.primary)
+	if perr == nil {
+		return nil
+	}
+	if serr := Probe(ctx, f.secondary); serr != nil {
+		return fmt.Errorf("probing fallback: %w", errors.Join(perr, serr))
+	}
+	return nil
+}
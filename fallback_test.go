@@ -0,0 +1,93 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// stubRangeReader counts calls and returns either data or err.
+type stubRangeReader struct {
+	data  string
+	err   error
+	calls atomic.Int32
+}
+
+func (s *stubRangeReader) ReadRange(_ context.Context, _ pmtilr.Ranger) (io.ReadCloser, error) {
+	s.calls.Add(1)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return io.NopCloser(strings.NewReader(s.data)), nil
+}
+
+func TestFallbackRangeReader(t *testing.T) {
+	t.Run("reads from primary", func(t *testing.T) {
+		primary := &stubRangeReader{data: "primary"}
+		secondary := &stubRangeReader{data: "secondary"}
+
+		reader := pmtilr.NewFallbackRangeReader(primary, secondary)
+
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 7))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result, _ := io.ReadAll(rc)
+		if string(result) != "primary" {
+			t.Fatalf("expected %s, got: %s", "primary", string(result))
+		}
+		if secondary.calls.Load() != 0 {
+			t.Fatal("expected secondary not to be called")
+		}
+	})
+
+	t.Run("falls back and skips unhealthy primary", func(t *testing.T) {
+		primary := &stubRangeReader{err: errors.New("mount blip")}
+		secondary := &stubRangeReader{data: "secondary"}
+
+		reader := pmtilr.NewFallbackRangeReader(
+			primary, secondary,
+			pmtilr.WithFallbackFailureThreshold(2),
+			pmtilr.WithFallbackCooldown(time.Hour),
+		)
+
+		for range 4 {
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 9))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			result, _ := io.ReadAll(rc)
+			if string(result) != "secondary" {
+				t.Fatalf("expected %s, got: %s", "secondary", string(result))
+			}
+		}
+
+		if primary.calls.Load() != 2 {
+			t.Fatalf("expected primary to be called 2 times, got: %d", primary.calls.Load())
+		}
+		if reader.Healthy() {
+			t.Fatal("expected primary to be marked unhealthy")
+		}
+	})
+
+	t.Run("fails if both fail", func(t *testing.T) {
+		primaryErr := errors.New("primary down")
+		secondaryErr := errors.New("secondary down")
+
+		reader := pmtilr.NewFallbackRangeReader(
+			&stubRangeReader{err: primaryErr},
+			&stubRangeReader{err: secondaryErr},
+		)
+
+		_, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 1))
+		if !errors.Is(err, primaryErr) || !errors.Is(err, secondaryErr) {
+			t.Fatalf("expected both errors, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package pmtilr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+const fuzzArchive = "testdata/cb_2018_us_county_500k.pmtiles"
+
+// fuzzSeeds returns the header and the compressed and decompressed root
+// directory of the fixture archive.
+func fuzzSeeds(f *testing.F) (header, root, rootDecompressed []byte) {
+	f.Helper()
+
+	data, err := os.ReadFile(fuzzArchive)
+	if err != nil {
+		f.Fatalf("reading fixture: %v", err)
+	}
+
+	h, err := NewHeader(bytes.NewReader(data))
+	if err != nil {
+		f.Fatalf("reading header: %v", err)
+	}
+	root = data[h.RootOffset : h.RootOffset+h.RootLength]
+
+	rc, err := Decompress(io.NopCloser(bytes.NewReader(root)), h.InternalCompression)
+	if err != nil {
+		f.Fatalf("decompressing root directory: %v", err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	rootDecompressed, err = io.ReadAll(rc)
+	if err != nil {
+		f.Fatalf("decompressing root directory: %v", err)
+	}
+
+	return data[:HeaderSizeBytes], root, rootDecompressed
+}
+
+func FuzzNewHeader(f *testing.F) {
+	header, _, _ := fuzzSeeds(f)
+
+	f.Add(header)
+	f.Add(header[:HeaderSizeBytes-1])
+	f.Add(makeValidHeaderBytes(nil))
+	f.Add(makeValidHeaderBytes(func(d []byte) []byte {
+		d[7] = 2
+		return d
+	}))
+	f.Add([]byte("PMTiles"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h, err := NewHeader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if len(data) < HeaderSizeBytes {
+			t.Fatalf("parsed header from %d bytes", len(data))
+		}
+		if h.SpecVersion != 3 {
+			t.Fatalf("parsed header with spec version %d", h.SpecVersion)
+		}
+	})
+}
+
+func FuzzReadEntries(f *testing.F) {
+	_, _, root := fuzzSeeds(f)
+
+	f.Add(root)
+	f.Add(root[:len(root)/2])
+	// entry count far beyond the available bytes.
+	f.Add(binary.AppendUvarint(nil, 1<<62))
+	// tile id deltas overflowing uint64.
+	f.Add(binary.AppendUvarint(binary.AppendUvarint(binary.AppendUvarint(nil, 2), 1<<63), 1<<63))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// the size hint is read from untrusted bytes as well, e.g. a 16 GiB
+		// directory length in the header.
+		entries, err := readEntries(bufio.NewReader(bytes.NewReader(data)), 16<<30)
+		if err != nil {
+			return
+		}
+		for i := 1; i < len(entries); i++ {
+			if entries[i].TileID < entries[i-1].TileID {
+				t.Fatalf("tile ids not ascending at %d", i)
+			}
+		}
+	})
+}
+
+func FuzzDecompress(f *testing.F) {
+	_, root, rootDecompressed := fuzzSeeds(f)
+
+	f.Add(root, uint8(CompressionGZIP))
+	f.Add(root[:len(root)/2], uint8(CompressionGZIP))
+	f.Add(rootDecompressed, uint8(CompressionNone))
+	f.Add(rootDecompressed, uint8(CompressionGZIP))
+	f.Add(root, uint8(CompressionZstd))
+
+	f.Fuzz(func(t *testing.T, data []byte, compression uint8) {
+		rc, err := Decompress(io.NopCloser(bytes.NewReader(data)), Compression(compression))
+		if err != nil {
+			return
+		}
+		defer rc.Close() //nolint:errcheck
+
+		// bound the output, a small input may decompress to a lot.
+		_, _ = io.Copy(io.Discard, io.LimitReader(rc, 1<<20)) //nolint:errcheck
+	})
+}
@@ -0,0 +1,347 @@
+package pmtilr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math"
+)
+
+// Geometry is a GeoJSON geometry in WGS84. Point, MultiPoint, LineString,
+// MultiLineString, Polygon, MultiPolygon and GeometryCollection are
+// supported.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Geometries  []Geometry      `json:"geometries,omitempty"`
+}
+
+// ParseGeometry parses a GeoJSON geometry, Feature or FeatureCollection.
+// The geometries of a FeatureCollection are returned as GeometryCollection.
+func ParseGeometry(data []byte) (Geometry, error) {
+	var obj struct {
+		Geometry
+		Feature  *Geometry `json:"geometry"`
+		Features []struct {
+			Geometry *Geometry `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return Geometry{}, fmt.Errorf("parsing geometry: %w", err)
+	}
+
+	var g Geometry
+	switch obj.Type {
+	case "Feature":
+		if obj.Feature == nil {
+			return Geometry{}, fmt.Errorf("parsing geometry: feature without geometry")
+		}
+		g = *obj.Feature
+	case "FeatureCollection":
+		g = Geometry{Type: "GeometryCollection"}
+		for _, f := range obj.Features {
+			if f.Geometry != nil {
+				g.Geometries = append(g.Geometries, *f.Geometry)
+			}
+		}
+	default:
+		g = obj.Geometry
+	}
+
+	if _, err := g.shapes(); err != nil {
+		return Geometry{}, err
+	}
+	return g, nil
+}
+
+// shapes holds a geometry decomposed into its primitives.
+type shapes struct {
+	points   [][2]float64
+	lines    [][][2]float64
+	polygons [][][][2]float64
+}
+
+// shapes decomposes the geometry into points, lines and polygons.
+func (g Geometry) shapes() (shapes, error) {
+	var s shapes
+	if err := g.appendShapes(&s); err != nil {
+		return shapes{}, fmt.Errorf("parsing geometry: %w", err)
+	}
+	return s, nil
+}
+
+func (g Geometry) appendShapes(s *shapes) error {
+	decode := func(v any) error {
+		return json.Unmarshal(g.Coordinates, v)
+	}
+
+	switch g.Type {
+	case "Point":
+		var p [2]float64
+		if err := decode(&p); err != nil {
+			return err
+		}
+		s.points = append(s.points, p)
+	case "MultiPoint":
+		var ps [][2]float64
+		if err := decode(&ps); err != nil {
+			return err
+		}
+		s.points = append(s.points, ps...)
+	case "LineString":
+		var l [][2]float64
+		if err := decode(&l); err != nil {
+			return err
+		}
+		s.lines = append(s.lines, l)
+	case "MultiLineString":
+		var ls [][][2]float64
+		if err := decode(&ls); err != nil {
+			return err
+		}
+		s.lines = append(s.lines, ls...)
+	case "Polygon":
+		var p [][][2]float64
+		if err := decode(&p); err != nil {
+			return err
+		}
+		s.polygons = append(s.polygons, p)
+	case "MultiPolygon":
+		var ps [][][][2]float64
+		if err := decode(&ps); err != nil {
+			return err
+		}
+		s.polygons = append(s.polygons, ps...)
+	case "GeometryCollection":
+		for _, child := range g.Geometries {
+			if err := child.appendShapes(s); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+	return nil
+}
+
+// bounds returns the bounding box of all coordinates.
+func (s shapes) bounds() (Bounds, bool) {
+	b := NewBounds(math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1))
+	extend := func(p [2]float64) {
+		b[indexMinLon] = min(b[indexMinLon], p[0])
+		b[indexMinLat] = min(b[indexMinLat], p[1])
+		b[indexMaxLon] = max(b[indexMaxLon], p[0])
+		b[indexMaxLat] = max(b[indexMaxLat], p[1])
+	}
+
+	for _, p := range s.points {
+		extend(p)
+	}
+	for _, l := range s.lines {
+		for _, p := range l {
+			extend(p)
+		}
+	}
+	for _, poly := range s.polygons {
+		for _, ring := range poly {
+			for _, p := range ring {
+				extend(p)
+			}
+		}
+	}
+
+	return b, b.Validate() == nil
+}
+
+// TileCover yields the x/y of the tiles at zoom intersecting geom.
+func TileCover(geom Geometry, zoom uint8) (iter.Seq2[uint64, uint64], error) {
+	s, err := geom.shapes()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(uint64, uint64) bool) {
+		b, ok := s.bounds()
+		if !ok {
+			return
+		}
+
+		project := func(p [2]float64) [2]float64 {
+			return lonLatToTileFloat(p[0], p[1], zoom)
+		}
+		points := make([][2]float64, len(s.points))
+		for i, p := range s.points {
+			points[i] = project(p)
+		}
+		lines := make([][][2]float64, len(s.lines))
+		for i, l := range s.lines {
+			for _, p := range l {
+				lines[i] = append(lines[i], project(p))
+			}
+		}
+		polygons := make([][][][2]float64, len(s.polygons))
+		for i, poly := range s.polygons {
+			polygons[i] = make([][][2]float64, len(poly))
+			for j, ring := range poly {
+				for _, p := range ring {
+					polygons[i][j] = append(polygons[i][j], project(p))
+				}
+			}
+		}
+
+		minX, minY, maxX, maxY := b.TileRange(zoom)
+		for x := minX; x <= maxX; x++ {
+			for y := minY; y <= maxY; y++ {
+				rect := tileRect{float64(x), float64(y), float64(x + 1), float64(y + 1)}
+				if rect.intersects(points, lines, polygons) && !yield(x, y) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// tileRect is a tile in fractional tile coordinates.
+type tileRect struct {
+	minX, minY, maxX, maxY float64
+}
+
+func (r tileRect) contains(p [2]float64) bool {
+	return p[0] >= r.minX && p[0] <= r.maxX && p[1] >= r.minY && p[1] <= r.maxY
+}
+
+func (r tileRect) intersects(
+	points [][2]float64,
+	lines [][][2]float64,
+	polygons [][][][2]float64,
+) bool {
+	for _, p := range points {
+		if r.contains(p) {
+			return true
+		}
+	}
+	for _, l := range lines {
+		if r.intersectsPath(l, false) {
+			return true
+		}
+	}
+	for _, poly := range polygons {
+		if r.intersectsPolygon(poly) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r tileRect) intersectsPolygon(rings [][][2]float64) bool {
+	for _, ring := range rings {
+		if r.intersectsPath(ring, true) {
+			return true
+		}
+	}
+
+	// the tile is either fully inside or fully outside the polygon
+	center := [2]float64{(r.minX + r.maxX) / 2, (r.minY + r.maxY) / 2}
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > center[1]) != (b[1] > center[1]) &&
+				center[0] < (b[0]-a[0])*(center[1]-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func (r tileRect) intersectsPath(path [][2]float64, closed bool) bool {
+	if len(path) == 1 {
+		return r.contains(path[0])
+	}
+	for i := 1; i < len(path); i++ {
+		if r.intersectsSegment(path[i-1], path[i]) {
+			return true
+		}
+	}
+	return closed && len(path) > 2 && r.intersectsSegment(path[len(path)-1], path[0])
+}
+
+// intersectsSegment clips the segment a-b against the rectangle
+// (Liang-Barsky).
+func (r tileRect) intersectsSegment(a, b [2]float64) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b[0]-a[0], b[1]-a[1]
+
+	for _, edge := range [4][2]float64{
+		{-dx, a[0] - r.minX},
+		{dx, r.maxX - a[0]},
+		{-dy, a[1] - r.minY},
+		{dy, r.maxY - a[1]},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = max(t0, t)
+		} else {
+			t1 = min(t1, t)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
+
+// TilesIntersecting yields the z/x/y of tiles present in the archive that
+// intersect geom, ordered by zoom, x and y. Errors are yielded once, after
+// which iteration stops.
+func TilesIntersecting(
+	ctx context.Context,
+	source Source,
+	geom Geometry,
+	zooms ZoomRange,
+) iter.Seq2[[3]uint64, error] {
+	return func(yield func([3]uint64, error) bool) {
+		if err := zooms.Validate(); err != nil {
+			yield([3]uint64{}, err)
+			return
+		}
+		if zooms.MaxZoom() > MaxZ {
+			yield([3]uint64{}, fmt.Errorf("zoom %d exceeds limit of %d", zooms.MaxZoom(), MaxZ))
+			return
+		}
+
+		for z := zooms.MinZoom(); z <= zooms.MaxZoom(); z++ {
+			zoom := uint8(z) //nolint:gosec
+
+			cover, err := TileCover(geom, zoom)
+			if err != nil {
+				yield([3]uint64{}, err)
+				return
+			}
+
+			coverage, err := sourceCoverage(ctx, source, zoom)
+			if err != nil {
+				yield([3]uint64{}, err)
+				return
+			}
+
+			for x, y := range cover {
+				if !coverage.Contains(x, y) {
+					continue
+				}
+				if !yield([3]uint64{z, x, y}, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package pmtilr_test
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestTileCover(t *testing.T) {
+	tests := []struct {
+		name     string
+		geojson  string
+		zoom     uint8
+		expected [][2]uint64
+		wantErr  bool
+	}{
+		{
+			name:     "point",
+			geojson:  `{"type":"Point","coordinates":[13.4,52.5]}`,
+			zoom:     10,
+			expected: [][2]uint64{{550, 335}},
+		},
+		{
+			name:     "line across tiles",
+			geojson:  `{"type":"LineString","coordinates":[[-170,10],[-10,10]]}`,
+			zoom:     1,
+			expected: [][2]uint64{{0, 0}},
+		},
+		{
+			name:     "diagonal line",
+			geojson:  `{"type":"LineString","coordinates":[[-170,-60],[170,50]]}`,
+			zoom:     1,
+			expected: [][2]uint64{{0, 1}, {1, 0}, {1, 1}},
+		},
+		{
+			name:    "polygon with hole",
+			geojson: `{"type":"Polygon","coordinates":[[[-179,-84],[179,-84],[179,84],[-179,84],[-179,-84]],[[-80,-60],[80,-60],[80,60],[-80,60],[-80,-60]]]}`,
+			zoom:    2,
+			expected: [][2]uint64{
+				{0, 0}, {0, 1}, {0, 2}, {0, 3},
+				{1, 0}, {1, 1}, {1, 2}, {1, 3},
+				{2, 0}, {2, 1}, {2, 2}, {2, 3},
+				{3, 0}, {3, 1}, {3, 2}, {3, 3},
+			},
+		},
+		{
+			name:     "polygon inside a tile",
+			geojson:  `{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[10,10],[20,10],[20,20],[10,20],[10,10]]]}}`,
+			zoom:     3,
+			expected: [][2]uint64{{4, 3}},
+		},
+		{
+			name:     "feature collection",
+			geojson:  `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[-90,45]}},{"type":"Feature","geometry":{"type":"Point","coordinates":[90,-45]}}]}`,
+			zoom:     1,
+			expected: [][2]uint64{{0, 0}, {1, 1}},
+		},
+		{
+			name:    "unsupported type",
+			geojson: `{"type":"Circle","coordinates":[0,0]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geom, err := pmtilr.ParseGeometry([]byte(tt.geojson))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got: %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			cover, err := pmtilr.TileCover(geom, tt.zoom)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var got [][2]uint64
+			for x, y := range cover {
+				got = append(got, [2]uint64{x, y})
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTilesIntersecting(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	// roughly Colorado and the Pacific to its west
+	geom, err := pmtilr.ParseGeometry([]byte(
+		`{"type":"Polygon","coordinates":[[[-140,37],[-102,37],[-102,41],[-140,41],[-140,37]]]}`,
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	zooms := pmtilr.NewZoomRange(3, 6)
+	var got [][3]uint64
+	for zxy, err := range pmtilr.TilesIntersecting(t.Context(), src, geom, zooms) {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got = append(got, zxy)
+	}
+
+	var expected [][3]uint64
+	for z := zooms.MinZoom(); z <= zooms.MaxZoom(); z++ {
+		cover, _ := pmtilr.TileCover(geom, uint8(z))
+		for x, y := range cover {
+			_, err := src.Tile(t.Context(), z, x, y)
+			if errors.Is(err, pmtilr.ErrTileNotFound) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected = append(expected, [3]uint64{z, x, y})
+		}
+	}
+
+	if len(got) == 0 || !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got: %v", expected, got)
+	}
+}
+
+func TestTilesIntersectingHighZoom(t *testing.T) {
+	src, err := pmtilr.NewSource(
+		t.Context(), "",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	geom, err := pmtilr.ParseGeometry([]byte(`{"type":"Point","coordinates":[13.4,52.5]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for zxy, err := range pmtilr.TilesIntersecting(t.Context(), src, geom, pmtilr.NewZoomRange(20, 22)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		t.Fatalf("expected no tiles beyond the archive's max zoom, got: %v", zxy)
+	}
+}
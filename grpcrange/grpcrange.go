@@ -0,0 +1,233 @@
+// Package grpcrange provides a tiny gRPC "range service" that exposes a
+// pmtilr.RangeReader over the network, and a client RangeReader for it.
+//
+// A fleet of tile servers can proxy their reads through a shared byte-cache
+// service instead of each hitting the origin (e.g. S3):
+//
+//	// cache service, reader is e.g. a caching RangeReader in front of S3
+//	s := grpc.NewServer()
+//	grpcrange.Register(s, reader)
+//
+//	// tile servers
+//	conn, _ := grpc.NewClient("cache:9000", ...)
+//	src, _ := pmtilr.NewSource(ctx, "", pmtilr.WithRangeReader(grpcrange.NewRangeReader(conn)))
+//
+// Errors of the remote reader wrapping pmtilr.ErrArchiveChanged or a context
+// error are sent with distinct status codes and returned as such by the
+// client, so e.g. a pmtilr.RefreshingSource sees archive changes.
+//
+// The service is equivalent to the following protobuf definition, but does
+// not require generated code:
+//
+//	syntax = "proto3";
+//	package pmtilr.range.v1;
+//
+//	service RangeService {
+//	  rpc ReadRange(ReadRangeRequest) returns (stream ReadRangeChunk);
+//	}
+//	message ReadRangeRequest { uint64 offset = 1; uint64 length = 2; }
+//	message ReadRangeChunk { bytes data = 1; }
+package grpcrange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/iwpnd/pmtilr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	serviceName      = "pmtilr.range.v1.RangeService"
+	readRangeMethod  = "/" + serviceName + "/ReadRange"
+	defaultChunkSize = 256 << 10
+)
+
+// serviceDesc describes the range service for grpc.Server.RegisterService.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*pmtilr.RangeReader)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReadRange",
+			Handler:       readRangeHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+// Register registers the range service serving reads from reader on s.
+func Register(s grpc.ServiceRegistrar, reader pmtilr.RangeReader) {
+	s.RegisterService(&serviceDesc, reader)
+}
+
+func readRangeHandler(srv any, stream grpc.ServerStream) error {
+	reader, _ := srv.(pmtilr.RangeReader) //nolint:errcheck
+
+	var req ReadRangeRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	rc, err := reader.ReadRange(stream.Context(), pmtilr.NewRange(req.Offset, req.Length))
+	if err != nil {
+		return toStatus(fmt.Errorf("reading range: %w", err))
+	}
+	defer rc.Close() //nolint:errcheck
+
+	buf := make([]byte, min(req.Length, defaultChunkSize))
+	for {
+		n, rerr := readChunk(rc, buf)
+		if n > 0 {
+			if err := stream.SendMsg(&ReadRangeChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(rerr, io.EOF) {
+			return nil
+		}
+		if rerr != nil {
+			return toStatus(fmt.Errorf("reading range body: %w", rerr))
+		}
+	}
+}
+
+// readChunk reads into buf until it is full or r fails. Unlike io.ReadFull,
+// only io.EOF marks the end of the range, so a truncated body, e.g. an
+// io.ErrUnexpectedEOF of a dropped connection, is returned as an error.
+func readChunk(r io.Reader, buf []byte) (int, error) {
+	var n int
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// toStatus converts err to a gRPC status error, keeping archive changes and
+// context errors distinguishable for the client, see fromStatus.
+func toStatus(err error) error {
+	code := codes.Unavailable
+	switch {
+	case errors.Is(err, pmtilr.ErrArchiveChanged):
+		code = codes.FailedPrecondition
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
+
+// fromStatus converts a gRPC status error back to the errors of pmtilr and
+// the context package, see toStatus.
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.FailedPrecondition:
+		return fmt.Errorf("%w: %s", pmtilr.ErrArchiveChanged, st.Message())
+	case codes.Canceled:
+		return fmt.Errorf("%w: %s", context.Canceled, st.Message())
+	case codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %s", context.DeadlineExceeded, st.Message())
+	default:
+		return err
+	}
+}
+
+// RangeReader implements pmtilr.RangeReader against a remote range service.
+type RangeReader struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewRangeReader returns a RangeReader issuing reads over conn.
+func NewRangeReader(conn grpc.ClientConnInterface) *RangeReader {
+	return &RangeReader{conn: conn}
+}
+
+// ReadRange streams the requested range from the range service.
+// The caller is responsible for closing the returned io.ReadCloser, which
+// cancels the underlying stream.
+func (r *RangeReader) ReadRange(ctx context.Context, ranger pmtilr.Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := r.conn.NewStream(
+		ctx,
+		&serviceDesc.Streams[0],
+		readRangeMethod,
+		grpc.CallContentSubtype(codecName),
+	)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("opening range stream: %w", fromStatus(err))
+	}
+
+	req := &ReadRangeRequest{Offset: ranger.Offset(), Length: ranger.Length()}
+	if err := stream.SendMsg(req); err != nil {
+		cancel()
+		return nil, fmt.Errorf("sending range request: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("sending range request: %w", err)
+	}
+
+	// receive the first chunk eagerly so errors of the remote reader surface
+	// from ReadRange instead of the first Read.
+	first := &ReadRangeChunk{}
+	err = stream.RecvMsg(first)
+	if err != nil && !errors.Is(err, io.EOF) {
+		cancel()
+		return nil, fmt.Errorf("receiving range: %w", fromStatus(err))
+	}
+
+	return &streamReader{stream: stream, cancel: cancel, buf: first.Data, err: err}, nil
+}
+
+// streamReader adapts a stream of ReadRangeChunk to io.ReadCloser.
+type streamReader struct {
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+	buf    []byte
+	err    error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		chunk := &ReadRangeChunk{}
+		if err := s.stream.RecvMsg(chunk); err != nil {
+			if !errors.Is(err, io.EOF) {
+				err = fromStatus(err)
+			}
+			s.err = err
+			continue
+		}
+		s.buf = chunk.Data
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *streamReader) Close() error {
+	s.cancel()
+	return nil
+}
@@ -0,0 +1,150 @@
+package grpcrange_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/grpcrange"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bytesRangeReader serves ranges of data, or fails with err. A body of
+// truncated reads fails with io.ErrUnexpectedEOF after data.
+type bytesRangeReader struct {
+	data      []byte
+	err       error
+	truncated bool
+}
+
+func (b *bytesRangeReader) ReadRange(_ context.Context, r pmtilr.Ranger) (io.ReadCloser, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	end := min(r.Offset()+r.Length(), uint64(len(b.data)))
+	body := io.Reader(bytes.NewReader(b.data[r.Offset():end]))
+	if b.truncated {
+		body = io.MultiReader(body, iotest.ErrReader(io.ErrUnexpectedEOF))
+	}
+	return io.NopCloser(body), nil
+}
+
+func setupRangeService(t *testing.T, reader pmtilr.RangeReader) *grpcrange.RangeReader {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	grpcrange.Register(s, reader)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("creating client should not fail: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return grpcrange.NewRangeReader(conn)
+}
+
+func TestRangeReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100_000)
+
+	tests := []struct {
+		name          string
+		reader        pmtilr.RangeReader
+		offset        uint64
+		length        uint64
+		expectedData  []byte
+		expectedError string
+		expectedIs    error
+	}{
+		{
+			name:         "small range",
+			reader:       &bytesRangeReader{data: data},
+			offset:       5,
+			length:       10,
+			expectedData: data[5:15],
+		},
+		{
+			name:         "range spanning multiple chunks",
+			reader:       &bytesRangeReader{data: data},
+			offset:       3,
+			length:       900_000,
+			expectedData: data[3:900_003],
+		},
+		{
+			name:          "remote reader error",
+			reader:        &bytesRangeReader{err: errors.New("origin down")},
+			offset:        0,
+			length:        10,
+			expectedError: "origin down",
+		},
+		{
+			name:          "truncated remote body",
+			reader:        &bytesRangeReader{data: data[:10], truncated: true},
+			offset:        0,
+			length:        100,
+			expectedError: "unexpected EOF",
+		},
+		{
+			name:          "archive changed",
+			reader:        &bytesRangeReader{err: fmt.Errorf("reading: %w", pmtilr.ErrArchiveChanged)},
+			offset:        0,
+			length:        10,
+			expectedError: "archive changed",
+			expectedIs:    pmtilr.ErrArchiveChanged,
+		},
+		{
+			name:          "remote deadline exceeded",
+			reader:        &bytesRangeReader{err: context.DeadlineExceeded},
+			offset:        0,
+			length:        10,
+			expectedError: "deadline exceeded",
+			expectedIs:    context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := setupRangeService(t, tt.reader)
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(tt.offset, tt.length))
+			var result []byte
+			if err == nil {
+				result, err = io.ReadAll(rc)
+				rc.Close()
+			}
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				if tt.expectedIs != nil && !errors.Is(err, tt.expectedIs) {
+					t.Fatalf("expected %v, got: %v", tt.expectedIs, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(tt.expectedData, result) {
+				t.Fatalf("expected %d bytes, got %d bytes", len(tt.expectedData), len(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package pmtilr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+)
+
+// ContentHash is the SHA-256 of a tile's bytes as read by
+// EntryReader.EntryBytes.
+type ContentHash [sha256.Size]byte
+
+// hashTile returns the ContentHash of data.
+func hashTile(data []byte) ContentHash {
+	return sha256.Sum256(data)
+}
+
+// String returns the hex encoded hash.
+func (h ContentHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// ETag returns a strong HTTP ETag for the tile content.
+func (h ContentHash) ETag() string {
+	return `"` + h.String()[:32] + `"`
+}
+
+// hashIndexEntry maps a run of tiles to the hash of their shared content.
+type hashIndexEntry struct {
+	tileID    TileID
+	runLength uint32
+	hash      ContentHash
+}
+
+// HashIndex maps tile IDs to the content hash of their bytes.
+type HashIndex struct {
+	entries []hashIndexEntry
+}
+
+// NewHashIndex computes the HashIndex of source, which must implement
+// EntryReader. Every run of tiles is read once, as are tile contents shared
+// by several entries (deduplicated archives).
+func NewHashIndex(ctx context.Context, source Source) (*HashIndex, error) {
+	er, err := sourceEntryReader(source)
+	if err != nil {
+		return nil, fmt.Errorf("building hash index: %w", err)
+	}
+
+	idx := &HashIndex{}
+	byOffset := map[uint64]ContentHash{}
+
+	for e, err := range er.Entries(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("building hash index: %w", err)
+		}
+
+		hash, ok := byOffset[e.Offset]
+		if !ok {
+			data, err := er.EntryBytes(ctx, e)
+			if err != nil {
+				return nil, fmt.Errorf("building hash index: %w", err)
+			}
+			hash = hashTile(data)
+			byOffset[e.Offset] = hash
+		}
+
+		idx.entries = append(idx.entries, hashIndexEntry{
+			tileID:    e.TileID,
+			runLength: e.RunLength,
+			hash:      hash,
+		})
+	}
+
+	return idx, nil
+}
+
+// Len returns the number of tiles in the index.
+func (idx *HashIndex) Len() uint64 {
+	var n uint64
+	for _, e := range idx.entries {
+		n += uint64(e.runLength)
+	}
+	return n
+}
+
+// HashByID returns the content hash of the tile with tileID.
+func (idx *HashIndex) HashByID(tileID TileID) (ContentHash, bool) {
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.entries[i].tileID > tileID
+	})
+	if i == 0 {
+		return ContentHash{}, false
+	}
+
+	e := idx.entries[i-1]
+	if tileID >= e.tileID+TileID(e.runLength) {
+		return ContentHash{}, false
+	}
+	return e.hash, true
+}
+
+// Hash returns the content hash of tile z/x/y.
+func (idx *HashIndex) Hash(z, x, y uint64) (ContentHash, bool) {
+	id, err := NewTileID(z, x, y)
+	if err != nil {
+		return ContentHash{}, false
+	}
+	return idx.HashByID(id)
+}
+
+// All iterates tile IDs and their content hashes in ascending order.
+func (idx *HashIndex) All() iter.Seq2[TileID, ContentHash] {
+	return func(yield func(TileID, ContentHash) bool) {
+		for _, e := range idx.entries {
+			for i := range TileID(e.runLength) {
+				if !yield(e.tileID+i, e.hash) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// MarshalBinary encodes the index as an entry count followed by
+// delta-encoded tile IDs, run lengths and hashes per entry.
+func (idx *HashIndex) MarshalBinary() ([]byte, error) {
+	buf := binary.AppendUvarint(nil, uint64(len(idx.entries)))
+
+	var last TileID
+	for _, e := range idx.entries {
+		buf = binary.AppendUvarint(buf, uint64(e.tileID-last))
+		buf = binary.AppendUvarint(buf, uint64(e.runLength))
+		buf = append(buf, e.hash[:]...)
+		last = e.tileID
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes an index encoded with MarshalBinary.
+func (idx *HashIndex) UnmarshalBinary(data []byte) error {
+	errTruncated := errors.New("decoding hash index: truncated data")
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return errTruncated
+	}
+	data = data[n:]
+
+	entries := make([]hashIndexEntry, 0, min(count, uint64(len(data))))
+	var last TileID
+	for range count {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		run, n := binary.Uvarint(data)
+		if n <= 0 || len(data[n:]) < sha256.Size {
+			return errTruncated
+		}
+		data = data[n:]
+
+		e := hashIndexEntry{tileID: last + TileID(delta), runLength: uint32(run)} //nolint:gosec
+		copy(e.hash[:], data[:sha256.Size])
+		data = data[sha256.Size:]
+
+		entries = append(entries, e)
+		last = e.tileID
+	}
+
+	idx.entries = entries
+	return nil
+}
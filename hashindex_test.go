@@ -0,0 +1,60 @@
+package pmtilr_test
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestHashIndex(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	idx, err := pmtilr.NewHashIndex(t.Context(), src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if idx.Len() != src.Header().AddressedTilesCount {
+		t.Fatalf("expected %d tiles, got: %d", src.Header().AddressedTilesCount, idx.Len())
+	}
+
+	data, err := src.Tile(t.Context(), 4, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hash, ok := idx.Hash(4, 3, 5)
+	if !ok {
+		t.Fatal("expected hash for 4/3/5")
+	}
+	if hash != pmtilr.ContentHash(sha256.Sum256(data)) {
+		t.Fatalf("expected hash of tile bytes, got: %s", hash)
+	}
+	if etag := hash.ETag(); len(etag) != 34 || etag[0] != '"' {
+		t.Fatalf("unexpected etag: %s", etag)
+	}
+
+	if _, ok := idx.Hash(4, 0, 0); ok {
+		t.Fatal("expected no hash for tile outside of the archive")
+	}
+
+	encoded, err := idx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded := &pmtilr.HashIndex{}
+	if err := decoded.UnmarshalBinary(encoded); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(idx, decoded) {
+		t.Fatal("expected decoded index to equal the original")
+	}
+
+	if err := decoded.UnmarshalBinary(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("expected error for truncated index")
+	}
+}
@@ -73,6 +73,11 @@ func (h *HeaderV3) ReadFrom(ctx context.Context, r RangeReader) (err error) {
 	if err != nil {
 		return fmt.Errorf("reading header: %w", err)
 	}
+	// prefer the etag of the remote archive to make the etag stable
+	// across processes, fall back to a random one.
+	if e, ok := ReaderAs[ETagger](r); ok {
+		newHeader.Etag = e.ETag()
+	}
 	if newHeader.Etag == "" {
 		newHeader.Etag = ksuid.New().String()
 	}
@@ -3,6 +3,7 @@ package pmtilr
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -109,3 +110,23 @@ func TestHeaderString(t *testing.T) {
 		t.Errorf("expected TileType Content-Type to exist, got %s", ct)
 	}
 }
+
+func TestHeaderJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	h := HeaderV3{
+		SpecVersion:         3,
+		TileCompression:     CompressionBrotli,
+		TileType:            TileTypeWebp,
+		InternalCompression: CompressionGZIP,
+		MaxZoom:             14,
+	}
+
+	var got HeaderV3
+	if err := json.Unmarshal([]byte(h.String()), &got); err != nil {
+		t.Fatalf("unmarshaling header: %v", err)
+	}
+	if got != h {
+		t.Errorf("got %+v, want %+v", got, h)
+	}
+}
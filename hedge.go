@@ -0,0 +1,117 @@
+package pmtilr
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// HedgedRangeReader tames the tail latency of remote backends, e.g. S3 at
+// p99, by hedging slow reads: if a read has not returned after the delay,
+// a duplicate read of the same range is issued and the first successful
+// response is used. The other read is cancelled and its body closed.
+//
+// Hedging only applies to the ReadRange call, reading the body is not
+// hedged. Reads failing before the delay are not hedged either, compose
+// with NewRetryRangeReader to retry them. A delay around the p95 latency
+// of the backend hedges about 5% of the reads.
+type HedgedRangeReader struct {
+	reader RangeReader
+	delay  time.Duration
+
+	hedges atomic.Uint64
+	won    atomic.Uint64
+}
+
+// NewHedgedRangeReader wraps reader to hedge reads slower than delay.
+func NewHedgedRangeReader(reader RangeReader, delay time.Duration) *HedgedRangeReader {
+	return &HedgedRangeReader{reader: reader, delay: delay}
+}
+
+// hedgeResult is the outcome of one of the reads of a hedged range.
+type hedgeResult struct {
+	attempt int
+	rc      io.ReadCloser
+	err     error
+}
+
+// ReadRange reads the range, issuing a second read if the first has not
+// returned after the delay. The returned body cancels the read when closed.
+func (h *HedgedRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	results := make(chan hedgeResult, 2)
+	cancels := make([]context.CancelFunc, 0, 2)
+	launch := func() {
+		actx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		attempt := len(cancels) - 1
+		go func() {
+			rc, err := h.reader.ReadRange(actx, ranger)
+			results <- hedgeResult{attempt: attempt, rc: rc, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+
+	pending := 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if ctx.Err() == nil {
+				h.hedges.Add(1)
+				launch()
+				pending++
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if res.attempt > 0 {
+					h.won.Add(1)
+				}
+				for i, cancel := range cancels {
+					if i != res.attempt {
+						cancel()
+					}
+				}
+				go drainHedges(results, pending)
+				return newObservedBody(res.rc, func(int64, error) { cancels[res.attempt]() }), nil
+			}
+
+			cancels[res.attempt]()
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			// failures before the delay are not hedged.
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// drainHedges closes the bodies of the n reads that lost.
+func drainHedges(results <-chan hedgeResult, n int) {
+	for range n {
+		if res := <-results; res.rc != nil {
+			_ = res.rc.Close() //nolint:errcheck
+		}
+	}
+}
+
+// Hedges returns the number of duplicate reads issued so far.
+func (h *HedgedRangeReader) Hedges() uint64 {
+	return h.hedges.Load()
+}
+
+// HedgesWon returns the number of reads answered by the duplicate read.
+func (h *HedgedRangeReader) HedgesWon() uint64 {
+	return h.won.Load()
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (h *HedgedRangeReader) Unwrap() RangeReader {
+	return h.reader
+}
@@ -0,0 +1,108 @@
+package pmtilr_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestHedgedRangeReader(t *testing.T) {
+	errBackend := errors.New("backend down")
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name          string
+		responses     []pmtilrtest.Response
+		expectedErr   error
+		expectedCalls int
+		expectedWon   uint64
+	}{
+		{
+			name:          "fast read is not hedged",
+			responses:     []pmtilrtest.Response{{Latency: time.Millisecond}},
+			expectedCalls: 1,
+		},
+		{
+			name: "slow read is hedged",
+			responses: []pmtilrtest.Response{
+				{Latency: time.Second},
+				{Latency: time.Millisecond},
+			},
+			expectedCalls: 2,
+			expectedWon:   1,
+		},
+		{
+			name: "slow read wins over slower hedge",
+			responses: []pmtilrtest.Response{
+				{Latency: 40 * time.Millisecond},
+				{Latency: time.Second},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:          "failure before the delay is not hedged",
+			responses:     []pmtilrtest.Response{{Err: errBackend, Latency: time.Millisecond}},
+			expectedErr:   errBackend,
+			expectedCalls: 1,
+		},
+		{
+			name: "failing read waits for the hedge",
+			responses: []pmtilrtest.Response{
+				{Err: errBackend, Latency: 40 * time.Millisecond},
+				{Latency: 40 * time.Millisecond},
+			},
+			expectedCalls: 2,
+			expectedWon:   1,
+		},
+		{
+			name: "both reads fail",
+			responses: []pmtilrtest.Response{
+				{Err: errBackend, Latency: 40 * time.Millisecond},
+				{Err: errors.New("hedge down"), Latency: 40 * time.Millisecond},
+			},
+			expectedErr:   errBackend,
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pmtilrtest.NewRangeReader(data).WithResponses(tt.responses...)
+			reader := pmtilr.NewHedgedRangeReader(mock, 20*time.Millisecond)
+
+			start := time.Now()
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 4))
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				got, _ := io.ReadAll(rc)
+				rc.Close()
+				if string(got) != "2345" {
+					t.Errorf("expected %q, got %q", "2345", got)
+				}
+			}
+
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("expected the slow read to be cancelled, took %s", elapsed)
+			}
+			if got := len(mock.Calls()); got != tt.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tt.expectedCalls, got)
+			}
+			if got := reader.Hedges(); got != uint64(tt.expectedCalls-1) {
+				t.Errorf("expected %d hedges, got %d", tt.expectedCalls-1, got)
+			}
+			if got := reader.HedgesWon(); got != tt.expectedWon {
+				t.Errorf("expected %d hedges won, got %d", tt.expectedWon, got)
+			}
+		})
+	}
+}
@@ -103,6 +103,16 @@ func ZoomFromHilbertTileID(i uint64) int {
 // https://github.com/protomaps/PMTiles/issues/393
 const invalidTileID uint64 = 0x5555555555555555
 
+// Lookup tables of the fast Hilbert curve state machine, indexed by the
+// curve state and the bits of one zoom level.
+const (
+	hilbertEncodeLUT      uint64 = 0x361E9CB4
+	hilbertEncodeStateLUT uint64 = 0x8FE65831
+	hilbertDecodeXLUT     uint64 = 0x936C
+	hilbertDecodeYLUT     uint64 = 0x39C6
+	hilbertDecodeStateLUT uint64 = 0x3E6B94C1
+)
+
 // FastZXYToHilbertTileID converts tile coordinates (z, x, y) to a compact 64-bit ID.
 func FastZXYToHilbertTileID(z, x, y uint64) (uint64, error) {
 	if z > 31 {
@@ -112,23 +122,40 @@ func FastZXYToHilbertTileID(z, x, y uint64) (uint64, error) {
 		return 0, errors.New("tile x/y outside zoom level bounds")
 	}
 
+	return fastHilbertEncode(z, x, y), nil
+}
+
+// FastZXYToHilbertTileIDs converts a batch of tile coordinates to tile IDs,
+// see FastZXYToHilbertTileID. It fails on the first invalid coordinate.
+func FastZXYToHilbertTileIDs(zxys [][3]uint64) ([]uint64, error) {
+	ids := make([]uint64, len(zxys))
+	for i, zxy := range zxys {
+		z, x, y := zxy[0], zxy[1], zxy[2]
+		if z > 31 || x >= 1<<z || y >= 1<<z {
+			return nil, fmt.Errorf("tile %d/%d/%d at index %d outside of bounds", z, x, y, i)
+		}
+		ids[i] = fastHilbertEncode(z, x, y)
+	}
+	return ids, nil
+}
+
+// fastHilbertEncode converts valid tile coordinates to a tile ID.
+func fastHilbertEncode(z, x, y uint64) uint64 {
 	// prefix is ((1 << (2*z)) - 1) / 3
 	prefix := ((uint64(1) << (2 * z)) - 1) / 3
 
 	var state, result uint64
-	const lut1 = 0x361E9CB4
-	const lut2 = 0x8FE65831
 
 	// Iterate bits from highest zoom down to 0
 	for i := z; i > 0; i-- {
 		shift := i - 1
 		// build row index: 3 bits = [state(2)] [x_i(1)] [y_i(1)]
 		row2 := (state << 3) | ((x>>shift)&1)<<2 | ((y>>shift)&1)<<1
-		result = (result << 2) | ((lut1 >> row2) & 3)
-		state = (lut2 >> row2) & 3
+		result = (result << 2) | ((hilbertEncodeLUT >> row2) & 3)
+		state = (hilbertEncodeStateLUT >> row2) & 3
 	}
 
-	return prefix + result, nil
+	return prefix + result
 }
 
 // FastZXYfromHilbertTileID converts a 64-bit tile ID back into (z, x, y) coordinates.
@@ -137,20 +164,33 @@ func FastZXYfromHilbertTileID(tileID uint64) ([3]uint64, error) {
 		return [3]uint64{}, errors.New("tile zoom exceeds 64-bit limit")
 	}
 
-	// Determine zoom level z by finding largest z such that (1 << (2*(z+1))) <= 3*tileID+1
-	var z uint64
-	for (uint64(1) << (2 * (z + 1))) <= 3*tileID+1 {
-		z++
+	return fastHilbertDecode(tileID), nil
+}
+
+// FastZXYfromHilbertTileIDs converts a batch of tile IDs back into (z, x, y)
+// coordinates, see FastZXYfromHilbertTileID. It fails on the first invalid
+// tile ID.
+func FastZXYfromHilbertTileIDs(tileIDs []uint64) ([][3]uint64, error) {
+	zxys := make([][3]uint64, len(tileIDs))
+	for i, tileID := range tileIDs {
+		if tileID >= invalidTileID {
+			return nil, fmt.Errorf("tile ID %d at index %d exceeds 64-bit limit", tileID, i)
+		}
+		zxys[i] = fastHilbertDecode(tileID)
 	}
+	return zxys, nil
+}
+
+// fastHilbertDecode converts a valid tile ID to tile coordinates.
+func fastHilbertDecode(tileID uint64) [3]uint64 {
+	// z is the largest zoom with (1 << (2*z)) <= 3*tileID+1
+	z := uint64(ZoomFromHilbertTileID(tileID)) //nolint:gosec // at most 31
 
 	// subtract prefix
 	prefix := ((uint64(1) << (2 * z)) - 1) / 3
 	code := tileID - prefix
 
 	var state uint64
-	const lutX = 0x936C
-	const lutY = 0x39C6
-	const lutState = 0x3E6B94C1
 
 	var x, y uint64
 	// iterate over code bits in pairs
@@ -158,10 +198,10 @@ func FastZXYfromHilbertTileID(tileID uint64) ([3]uint64, error) {
 		shift := i - 2
 		codeBits := (code >> shift) & 3
 		row := (state << 2) | codeBits
-		x = (x << 1) | ((lutX >> row) & 1)
-		y = (y << 1) | ((lutY >> row) & 1)
-		state = (lutState >> (2 * row)) & 3
+		x = (x << 1) | ((hilbertDecodeXLUT >> row) & 1)
+		y = (y << 1) | ((hilbertDecodeYLUT >> row) & 1)
+		state = (hilbertDecodeStateLUT >> (2 * row)) & 3
 	}
 
-	return [3]uint64{z, x, y}, nil
+	return [3]uint64{z, x, y}
 }
@@ -66,6 +66,58 @@ func TestFastMatchesOriginal(t *testing.T) {
 	}
 }
 
+func TestFastBatchConversions(t *testing.T) {
+	t.Parallel()
+
+	zxys := [][3]uint64{{0, 0, 0}, {3, 1, 3}, {5, 7, 12}, {10, 205, 342}, {30, 1<<30 - 1, 0}, {31, 1<<31 - 1, 1<<31 - 1}}
+
+	ids, err := FastZXYToHilbertTileIDs(zxys)
+	if err != nil {
+		t.Fatalf("FastZXYToHilbertTileIDs returned error: %v", err)
+	}
+	for i, zxy := range zxys {
+		id, _ := FastZXYToHilbertTileID(zxy[0], zxy[1], zxy[2])
+		if ids[i] != id {
+			t.Errorf("encode mismatch for %v: batch=%d single=%d", zxy, ids[i], id)
+		}
+	}
+
+	out, err := FastZXYfromHilbertTileIDs(ids)
+	if err != nil {
+		t.Fatalf("FastZXYfromHilbertTileIDs returned error: %v", err)
+	}
+	for i, zxy := range zxys {
+		if out[i] != zxy {
+			t.Errorf("decode mismatch for ID %d: input=%v batch=%v", ids[i], zxy, out[i])
+		}
+	}
+
+	if _, err := FastZXYToHilbertTileIDs([][3]uint64{{1, 0, 0}, {1, 2, 0}}); err == nil {
+		t.Error("expected error for coordinates outside of bounds")
+	}
+	if _, err := FastZXYfromHilbertTileIDs([]uint64{0, invalidTileID}); err == nil {
+		t.Error("expected error for tile ID beyond 64-bit limit")
+	}
+}
+
+func TestFastZXYfromHilbertTileIDZoomBoundaries(t *testing.T) {
+	t.Parallel()
+
+	for z := range uint64(32) {
+		first := ((uint64(1) << (2 * z)) - 1) / 3
+		last := ((uint64(1)<<(2*z))*4-1)/3 - 1
+		for _, tileID := range []uint64{first, last} {
+			zxy, err := FastZXYfromHilbertTileID(tileID)
+			if err != nil {
+				t.Fatalf("FastZXYfromHilbertTileID(%d) returned error: %v", tileID, err)
+			}
+			if zxy[0] != z {
+				t.Errorf("zoom mismatch for ID %d: expected=%d got=%d", tileID, z, zxy[0])
+			}
+		}
+	}
+}
+
 var (
 	benchZ uint64 = 18
 	benchX uint64 = 51542
@@ -104,3 +156,25 @@ func BenchmarkFastZXYfromHilbertTileID(b *testing.B) {
 		_, _ = FastZXYfromHilbertTileID(tileID)
 	}
 }
+
+func BenchmarkFastZXYToHilbertTileIDs(b *testing.B) {
+	zxys := make([][3]uint64, 1024)
+	for i := range zxys {
+		zxys[i] = [3]uint64{benchZ, benchX + uint64(i), benchY}
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = FastZXYToHilbertTileIDs(zxys)
+	}
+}
+
+func BenchmarkFastZXYfromHilbertTileIDs(b *testing.B) {
+	tileIDs := make([]uint64, 1024)
+	for i := range tileIDs {
+		tileIDs[i], _ = FastZXYToHilbertTileID(benchZ, benchX+uint64(i), benchY)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = FastZXYfromHilbertTileIDs(tileIDs)
+	}
+}
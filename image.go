@@ -0,0 +1,70 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/webp"
+)
+
+// ErrUnsupportedImageType is returned when a tile payload cannot be decoded
+// into an image.Image, e.g. for vector or AVIF tiles.
+var ErrUnsupportedImageType = errors.New("unsupported image tile type")
+
+// DecodeImage decodes an uncompressed raster tile payload into an image.Image
+// based on the archive's TileType. PNG, JPEG and WebP are supported.
+func DecodeImage(data []byte, tileType TileType) (image.Image, error) {
+	r := bytes.NewReader(data)
+
+	var (
+		img image.Image
+		err error
+	)
+	switch tileType {
+	case TileTypePNG:
+		img, err = png.Decode(r)
+	case TileTypeJPEG:
+		img, err = jpeg.Decode(r)
+	case TileTypeWebp:
+		img, err = webp.Decode(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImageType, tileType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s tile: %w", tileType, err)
+	}
+	return img, nil
+}
+
+// TileImage fetches the tile at z/x/y from source, decompresses it according
+// to the header's TileCompression and decodes it into an image.Image.
+func TileImage(ctx context.Context, source Source, z, x, y uint64) (image.Image, error) {
+	header := source.Header()
+	if header.TileType.IsVector() {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImageType, header.TileType)
+	}
+
+	data, err := source.Tile(ctx, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := Decompress(io.NopCloser(bytes.NewReader(data)), header.TileCompression)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading tile: %w", err)
+	}
+
+	return DecodeImage(raw, header.TileType)
+}
@@ -0,0 +1,82 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestDecodeImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	src.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	var pngBuf, jpegBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, src); err != nil {
+		t.Fatalf("encoding png: %s", err)
+	}
+	if err := jpeg.Encode(&jpegBuf, src, nil); err != nil {
+		t.Fatalf("encoding jpeg: %s", err)
+	}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		tileType      pmtilr.TileType
+		expectedError error
+	}{
+		{name: "png", data: pngBuf.Bytes(), tileType: pmtilr.TileTypePNG},
+		{name: "jpeg", data: jpegBuf.Bytes(), tileType: pmtilr.TileTypeJPEG},
+		{
+			name:          "vector tile",
+			data:          []byte{0x1a, 0x00},
+			tileType:      pmtilr.TileTypeMVT,
+			expectedError: pmtilr.ErrUnsupportedImageType,
+		},
+		{
+			name:          "avif",
+			data:          []byte{0x00},
+			tileType:      pmtilr.TileTypeAvif,
+			expectedError: pmtilr.ErrUnsupportedImageType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := pmtilr.DecodeImage(tt.data, tt.tileType)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got: %v", tt.expectedError, err)
+			}
+			if err != nil {
+				return
+			}
+			if img.Bounds() != src.Bounds() {
+				t.Fatalf("expected bounds %v, got: %v", src.Bounds(), img.Bounds())
+			}
+		})
+	}
+
+	t.Run("corrupt png", func(t *testing.T) {
+		_, err := pmtilr.DecodeImage([]byte("not a png"), pmtilr.TileTypePNG)
+		if err == nil {
+			t.Fatal("expected error for corrupt payload")
+		}
+	})
+}
+
+func TestTileImageVectorArchive(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	_, err = pmtilr.TileImage(t.Context(), src, 4, 3, 5)
+	if !errors.Is(err, pmtilr.ErrUnsupportedImageType) {
+		t.Fatalf("expected ErrUnsupportedImageType, got: %v", err)
+	}
+}
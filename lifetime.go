@@ -0,0 +1,26 @@
+package pmtilr
+
+import "context"
+
+// NewSourceWithLifetime is like NewSource, but binds the Source to ctx:
+// once ctx is done the Source is closed, stopping background work such as
+// warming, and further reads fail with ErrSourceClosed. This makes it safe
+// to use in errgroup managed services without ordering Close calls by hand.
+//
+// ctx is also used to load the header and metadata, so it must outlive the
+// Source rather than a single request.
+func NewSourceWithLifetime(
+	ctx context.Context,
+	uri string,
+	options ...SourceOption,
+) (Source, error) {
+	src, err := NewSource(ctx, uri, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, ok := src.(sourceCloser); ok {
+		context.AfterFunc(ctx, c.Close)
+	}
+	return src, nil
+}
@@ -0,0 +1,105 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestNewSourceWithLifetime(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	source, err := pmtilr.NewSourceWithLifetime(
+		ctx,
+		"memory",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+	)
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+
+	if _, err := source.Tile(t.Context(), 1, 0, 0); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := source.Tile(t.Context(), 1, 0, 0)
+		if errors.Is(err, pmtilr.ErrSourceClosed) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected ErrSourceClosed after cancel, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := pmtilr.TileTo(t.Context(), source, io.Discard, 1, 0, 0); !errors.Is(err, pmtilr.ErrSourceClosed) {
+		t.Errorf("expected ErrSourceClosed from TileTo, got %v", err)
+	}
+
+	// closing again is a no-op.
+	source.(interface{ Close() }).Close()
+}
+
+func TestSourceCloseClosesReader(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).Bytes()
+
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	source, err := pmtilr.NewSource(t.Context(), server.URL, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+	if _, err := source.Tile(t.Context(), 1, 0, 0); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+
+	source.(interface{ Close() }).Close()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the pooled connection to be closed with the source")
+	}
+}
+
+func TestSourceCloseKeepsCustomReader(t *testing.T) {
+	reader := &closeRecorder{BytesRangeReader: pmtilr.NewBytesRangeReader(pmtilrtest.FixtureArchive(2).Bytes())}
+
+	source, err := pmtilr.NewSource(t.Context(), "memory", pmtilr.WithRangeReader(reader))
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+
+	source.(interface{ Close() }).Close()
+
+	if reader.closed.Load() {
+		t.Fatal("expected a reader set with WithRangeReader to stay open")
+	}
+}
@@ -0,0 +1,64 @@
+package pmtilr
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// LoggingRangeReader wraps a RangeReader to log every read with its offset,
+// length, duration, the number of bytes read and the error, if any. Reads
+// are logged once their body is closed, so the duration covers reading it.
+// Failed reads are logged at least at slog.LevelWarn.
+type LoggingRangeReader struct {
+	reader RangeReader
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// NewLoggingRangeReader wraps reader to log reads to logger at level, e.g.
+// slog.LevelDebug.
+func NewLoggingRangeReader(reader RangeReader, logger *slog.Logger, level slog.Level) *LoggingRangeReader {
+	return &LoggingRangeReader{reader: reader, logger: logger, level: level}
+}
+
+// ReadRange reads and logs the range.
+func (l *LoggingRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := l.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		l.log(ctx, ranger, start, 0, err)
+		return nil, err
+	}
+
+	return newObservedBody(rc, func(n int64, err error) {
+		l.log(ctx, ranger, start, n, err)
+	}), nil
+}
+
+func (l *LoggingRangeReader) log(ctx context.Context, ranger Ranger, start time.Time, n int64, err error) {
+	level := l.level
+	if err != nil {
+		level = max(level, slog.LevelWarn)
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.Uint64("offset", ranger.Offset()),
+		slog.Uint64("length", ranger.Length()),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("bytes", n),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.logger.LogAttrs(ctx, level, "pmtilr: read range", attrs...)
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (l *LoggingRangeReader) Unwrap() RangeReader {
+	return l.reader
+}
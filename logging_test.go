@@ -0,0 +1,68 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestLoggingRangeReader(t *testing.T) {
+	errBackend := errors.New("backend unavailable")
+
+	tests := []struct {
+		name      string
+		err       error
+		level     slog.Level
+		wantLevel string
+		wantBytes float64
+	}{
+		{name: "success", level: slog.LevelDebug, wantLevel: "DEBUG", wantBytes: 3},
+		{name: "failure", err: errBackend, level: slog.LevelDebug, wantLevel: "WARN"},
+		{name: "failure above warn", err: errBackend, level: slog.LevelError, wantLevel: "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			mock := pmtilrtest.NewRangeReader([]byte("0123456789")).WithError(tt.err)
+			reader := pmtilr.NewLoggingRangeReader(mock, logger, tt.level)
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 3))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if rc != nil {
+				io.ReadAll(rc)
+				if buf.Len() > 0 {
+					t.Error("expected reads to be logged once the body is closed")
+				}
+				rc.Close()
+			}
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("decoding log record %q: %v", buf.String(), err)
+			}
+			if record["level"] != tt.wantLevel {
+				t.Errorf("got level %v, want %s", record["level"], tt.wantLevel)
+			}
+			if record["offset"] != 2.0 || record["length"] != 3.0 || record["bytes"] != tt.wantBytes {
+				t.Errorf("unexpected record %v", record)
+			}
+			if _, ok := record["duration"]; !ok {
+				t.Error("expected duration to be logged")
+			}
+			if _, ok := record["error"]; ok != (tt.err != nil) {
+				t.Errorf("unexpected error attribute in %v", record)
+			}
+		})
+	}
+}
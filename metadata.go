@@ -1,11 +1,15 @@
 package pmtilr
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type VectorLayer struct {
@@ -24,6 +28,10 @@ type Metadata struct {
 	Version      string        `json:"version"`
 	VectorLayers []VectorLayer `json:"vector_layers"`
 
+	// TTL is the cache lifetime hint set by the publisher with the
+	// "pmtilr:ttl" key in seconds, or 0 if unset. See CacheMaxAge.
+	TTL time.Duration `json:"-"`
+
 	metadataStr string // cache string representation
 }
 
@@ -55,10 +63,12 @@ func (m *Metadata) ReadFrom(
 		return fmt.Errorf("decompressing metadata: %w", err)
 	}
 
-	jsonData, err := io.ReadAll(decompReader)
-	if err != nil {
+	buf := acquireBuffer(decompressedSizeHint(header.MetadataLength, header.InternalCompression) + bytes.MinRead)
+	defer releaseBuffer(buf)
+	if _, err := buf.ReadFrom(decompReader); err != nil {
 		return fmt.Errorf("reading decompressed metadata: %w", err)
 	}
+	jsonData := buf.Bytes()
 
 	defer func() {
 		if cerr := decompReader.Close(); cerr != nil {
@@ -74,9 +84,44 @@ func (m *Metadata) ReadFrom(
 		return fmt.Errorf("unmarshalling metadata: %w", err)
 	}
 
+	var hints struct {
+		TTL json.RawMessage `json:"pmtilr:ttl"`
+	}
+	if err := json.Unmarshal(jsonData, &hints); err == nil {
+		m.TTL = parseTTL(hints.TTL)
+	}
+
 	return nil
 }
 
+// parseTTL parses a TTL hint given in seconds, either as a JSON number or a
+// string such as "86400". Invalid and negative hints are ignored.
+func parseTTL(raw json.RawMessage) time.Duration {
+	if len(raw) == 0 {
+		return 0
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || seconds <= 0 || seconds > math.MaxInt64/int64(time.Second) {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CacheMaxAge returns the cache lifetime for tiles and TileJSON of the
+// archive: the TTL hint of the metadata if set, else fallback.
+func (m Metadata) CacheMaxAge(fallback time.Duration) time.Duration {
+	if m.TTL > 0 {
+		return m.TTL
+	}
+	return fallback
+}
+
 func (m Metadata) String() string {
 	if m.metadataStr != "" {
 		return m.metadataStr
@@ -0,0 +1,59 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+// MissingTiles yields the z/x/y of tiles inside bounds and zooms that are
+// absent from the archive, ordered by zoom, x and y. The coverage of one
+// zoom level is held in memory at a time. Errors are yielded once, after
+// which iteration stops.
+func MissingTiles(
+	ctx context.Context,
+	source Source,
+	bounds Bounds,
+	zooms ZoomRange,
+) iter.Seq2[[3]uint64, error] {
+	return func(yield func([3]uint64, error) bool) {
+		if err := bounds.Validate(); err != nil {
+			yield([3]uint64{}, err)
+			return
+		}
+		if err := zooms.Validate(); err != nil {
+			yield([3]uint64{}, err)
+			return
+		}
+		if zooms.MaxZoom() > MaxZ {
+			yield([3]uint64{}, fmt.Errorf("zoom %d exceeds limit of %d", zooms.MaxZoom(), MaxZ))
+			return
+		}
+
+		for z := zooms.MinZoom(); z <= zooms.MaxZoom(); z++ {
+			zoom := uint8(z) //nolint:gosec
+
+			coverage, err := sourceCoverage(ctx, source, zoom)
+			if err != nil {
+				yield([3]uint64{}, err)
+				return
+			}
+
+			minX, minY, maxX, maxY := bounds.TileRange(zoom)
+			for x := minX; x <= maxX; x++ {
+				for y := minY; y <= maxY; y++ {
+					if coverage.Contains(x, y) {
+						continue
+					}
+					if err := ctx.Err(); err != nil {
+						yield([3]uint64{}, err)
+						return
+					}
+					if !yield([3]uint64{uint64(zoom), x, y}, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pmtilr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestBoundsTileRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		bounds   pmtilr.Bounds
+		zoom     uint8
+		expected [4]uint64
+	}{
+		{name: "world z0", bounds: pmtilr.NewBounds(-180, -90, 180, 90), zoom: 0, expected: [4]uint64{0, 0, 0, 0}},
+		{name: "world z2", bounds: pmtilr.NewBounds(-180, -90, 180, 90), zoom: 2, expected: [4]uint64{0, 0, 3, 3}},
+		{name: "berlin z10", bounds: pmtilr.NewBounds(13.3, 52.4, 13.5, 52.6), zoom: 10, expected: [4]uint64{549, 335, 550, 336}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minX, minY, maxX, maxY := tt.bounds.TileRange(tt.zoom)
+			if got := [4]uint64{minX, minY, maxX, maxY}; got != tt.expected {
+				t.Fatalf("expected %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMissingTiles(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	world := pmtilr.NewBounds(-180, -85, 180, 85)
+	zooms := pmtilr.NewZoomRange(0, 4)
+
+	missing := map[[3]uint64]bool{}
+	for zxy, err := range pmtilr.MissingTiles(t.Context(), src, world, zooms) {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		missing[zxy] = true
+	}
+
+	for z := range uint64(5) {
+		for x := range uint64(1) << z {
+			for y := range uint64(1) << z {
+				_, err := src.Tile(t.Context(), z, x, y)
+				absent := errors.Is(err, pmtilr.ErrTileNotFound)
+				if missing[[3]uint64{z, x, y}] != absent {
+					t.Fatalf("%d/%d/%d: expected missing=%t", z, x, y, absent)
+				}
+			}
+		}
+	}
+	if len(missing) == 0 {
+		t.Fatal("expected missing tiles outside of the US")
+	}
+
+	for _, err := range pmtilr.MissingTiles(t.Context(), src, world, pmtilr.NewZoomRange(3, 1)) {
+		if err == nil {
+			t.Fatal("expected error for invalid zoom range")
+		}
+	}
+}
+
+func TestMissingTilesHighZoom(t *testing.T) {
+	src, err := pmtilr.NewSource(
+		t.Context(), "",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	// a single tile at z22, whose zoom holds 4^22 tiles.
+	bounds := pmtilr.NewBounds(13.4, 52.5, 13.4, 52.5)
+	var missing [][3]uint64
+	for zxy, err := range pmtilr.MissingTiles(t.Context(), src, bounds, pmtilr.NewZoomRange(22, 22)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		missing = append(missing, zxy)
+	}
+	if len(missing) != 1 || missing[0][0] != 22 {
+		t.Fatalf("expected one missing tile at z22, got: %v", missing)
+	}
+}
@@ -0,0 +1,47 @@
+package pmtilr
+
+import "fmt"
+
+// HilbertToMorton returns the zoom and Morton (Z-order) code of the tile,
+// e.g. to map tile inventories indexed on a Z-order curve. The code
+// interleaves the bits of x and y, with x in the even bits, like the digits
+// of a quadkey. It converts the id level by level without decoding it to
+// z/x/y.
+func HilbertToMorton(id TileID) (zoom uint8, morton uint64, err error) {
+	if !id.Valid() {
+		return 0, 0, fmt.Errorf("tile id %d exceeds zoom limit of %d", uint64(id), MaxZ)
+	}
+
+	zoom = id.Zoom()
+	code := uint64(id) - zoomBaseTileID(zoom)
+
+	var state uint64
+	for i := 2 * uint64(zoom); i > 0; i -= 2 {
+		row := (state << 2) | (code>>(i-2))&3
+		x := (hilbertDecodeXLUT >> row) & 1
+		y := (hilbertDecodeYLUT >> row) & 1
+		morton = (morton << 2) | y<<1 | x
+		state = (hilbertDecodeStateLUT >> (2 * row)) & 3
+	}
+	return zoom, morton, nil
+}
+
+// MortonToHilbert returns the TileID of the tile with the Morton code at
+// zoom, see HilbertToMorton.
+func MortonToHilbert(zoom uint8, morton uint64) (TileID, error) {
+	if zoom > MaxZ {
+		return 0, fmt.Errorf("zoom %d exceeds limit of %d", zoom, MaxZ)
+	}
+	if morton >= 1<<(2*uint64(zoom)) {
+		return 0, fmt.Errorf("morton code %d outside of bounds for zoom %d", morton, zoom)
+	}
+
+	var state, code uint64
+	for i := 2 * uint64(zoom); i > 0; i -= 2 {
+		digit := (morton >> (i - 2)) & 3
+		row := (state << 3) | (digit&1)<<2 | (digit>>1)<<1
+		code = (code << 2) | ((hilbertEncodeLUT >> row) & 3)
+		state = (hilbertEncodeStateLUT >> row) & 3
+	}
+	return TileID(zoomBaseTileID(zoom) + code), nil
+}
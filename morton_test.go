@@ -0,0 +1,73 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// interleave returns the Morton code of x/y with x in the even bits.
+func interleave(x, y uint64) uint64 {
+	var morton uint64
+	for i := range uint64(32) {
+		morton |= (x>>i)&1<<(2*i) | (y>>i)&1<<(2*i+1)
+	}
+	return morton
+}
+
+func TestMorton(t *testing.T) {
+	for z := range uint64(6) {
+		for x := range uint64(1) << z {
+			for y := range uint64(1) << z {
+				id, err := pmtilr.NewTileID(z, x, y)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				zoom, morton, err := pmtilr.HilbertToMorton(id)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if uint64(zoom) != z || morton != interleave(x, y) {
+					t.Fatalf("%s: expected %d/%d, got: %d/%d", id, z, interleave(x, y), zoom, morton)
+				}
+
+				got, err := pmtilr.MortonToHilbert(zoom, morton)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got != id {
+					t.Fatalf("expected %s, got: %s", id, got)
+				}
+			}
+		}
+	}
+}
+
+func TestMortonMaxZoom(t *testing.T) {
+	x, y := uint64(1<<pmtilr.MaxZ-1), uint64(12345)
+	id, err := pmtilr.NewTileID(pmtilr.MaxZ, x, y)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	zoom, morton, err := pmtilr.HilbertToMorton(id)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if zoom != pmtilr.MaxZ || morton != interleave(x, y) {
+		t.Fatalf("expected %d/%d, got: %d/%d", pmtilr.MaxZ, interleave(x, y), zoom, morton)
+	}
+}
+
+func TestMortonErrors(t *testing.T) {
+	if _, _, err := pmtilr.HilbertToMorton(pmtilr.TileID(1 << 62)); err == nil {
+		t.Fatal("expected error for invalid tile id")
+	}
+	if id, err := pmtilr.MortonToHilbert(pmtilr.MaxZ+1, 0); err == nil {
+		t.Fatalf("expected error beyond max zoom, got: %s", id)
+	}
+	if id, err := pmtilr.MortonToHilbert(2, 16); err == nil {
+		t.Fatalf("expected error for morton code outside zoom, got: %s", id)
+	}
+}
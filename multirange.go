@@ -0,0 +1,157 @@
+package pmtilr
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	DefaultCoalesceGap       = 16 << 10
+	DefaultCoalesceMaxLength = 16 << 20
+)
+
+// MultiRangeReader is implemented by RangeReaders that read several ranges
+// at once, e.g. for bulk tile export or prefetching.
+type MultiRangeReader interface {
+	// ReadRanges returns the bytes of every ranger, in the order of
+	// rangers. Like files, ranges past the end of the archive are
+	// truncated.
+	ReadRanges(ctx context.Context, rangers []Ranger) ([][]byte, error)
+}
+
+// ReadRanges reads rangers from reader with a single call if it implements
+// MultiRangeReader, otherwise with a CoalescingRangeReader using the
+// defaults.
+func ReadRanges(ctx context.Context, reader RangeReader, rangers []Ranger) ([][]byte, error) {
+	if m, ok := reader.(MultiRangeReader); ok {
+		return m.ReadRanges(ctx, rangers)
+	}
+	return NewCoalescingRangeReader(reader).ReadRanges(ctx, rangers)
+}
+
+type coalesceConfig struct {
+	gap       uint64
+	maxLength uint64
+}
+
+// CoalesceOption is a functional option for configuring a
+// CoalescingRangeReader.
+type CoalesceOption = func(config *coalesceConfig)
+
+// WithCoalesceGap merges ranges separated by at most gap bytes, defaults to
+// 16 KiB. Reading the gap is usually cheaper than another request.
+func WithCoalesceGap(gap uint64) CoalesceOption {
+	return func(config *coalesceConfig) {
+		config.gap = gap
+	}
+}
+
+// WithCoalesceMaxLength caps the length of a merged read, defaults to
+// 16 MiB.
+func WithCoalesceMaxLength(maxLength uint64) CoalesceOption {
+	return func(config *coalesceConfig) {
+		config.maxLength = maxLength
+	}
+}
+
+// CoalescingRangeReader implements MultiRangeReader for any RangeReader by
+// merging adjacent, overlapping and nearby ranges into fewer reads of the
+// inner reader and splitting the responses. Single ranges are passed
+// through.
+type CoalescingRangeReader struct {
+	reader RangeReader
+	cfg    coalesceConfig
+}
+
+// NewCoalescingRangeReader wraps reader to coalesce batched reads.
+func NewCoalescingRangeReader(reader RangeReader, options ...CoalesceOption) *CoalescingRangeReader {
+	cfg := coalesceConfig{
+		gap:       DefaultCoalesceGap,
+		maxLength: DefaultCoalesceMaxLength,
+	}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+	return &CoalescingRangeReader{reader: reader, cfg: cfg}
+}
+
+// ReadRange reads a single range from the inner reader.
+func (c *CoalescingRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	return c.reader.ReadRange(ctx, ranger)
+}
+
+// ReadRanges implements MultiRangeReader. The returned slices of merged
+// ranges share memory and must not be modified.
+func (c *CoalescingRangeReader) ReadRanges(ctx context.Context, rangers []Ranger) ([][]byte, error) {
+	for _, ranger := range rangers {
+		if err := ranger.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid ranger: %w", err)
+		}
+	}
+
+	order := make([]int, len(rangers))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(rangers[a].Offset(), rangers[b].Offset())
+	})
+
+	out := make([][]byte, len(rangers))
+	for len(order) > 0 {
+		start := rangers[order[0]].Offset()
+		end := start + rangers[order[0]].Length()
+
+		n := 1
+		for ; n < len(order); n++ {
+			next := rangers[order[n]]
+			nextEnd := max(end, next.Offset()+next.Length())
+			if next.Offset() > end+c.cfg.gap || nextEnd-start > c.cfg.maxLength {
+				break
+			}
+			end = nextEnd
+		}
+
+		data, err := c.read(ctx, NewRange(start, end-start))
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range order[:n] {
+			lo := min(rangers[i].Offset()-start, uint64(len(data)))
+			hi := min(lo+rangers[i].Length(), uint64(len(data)))
+			out[i] = data[lo:hi:hi]
+		}
+		order = order[n:]
+	}
+
+	return out, nil
+}
+
+func (c *CoalescingRangeReader) read(ctx context.Context, rng Range) ([]byte, error) {
+	n, err := bufferLen(rng.Length())
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := c.reader.ReadRange(ctx, rng)
+	if err != nil {
+		return nil, fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	var buf bytes.Buffer
+	buf.Grow(n)
+	if _, err := buf.ReadFrom(rc); err != nil {
+		return nil, fmt.Errorf("reading range %d+%d: %w", rng.Offset(), rng.Length(), err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Unwrap returns the inner reader, see Unwrapper.
+func (c *CoalescingRangeReader) Unwrap() RangeReader {
+	return c.reader
+}
@@ -0,0 +1,101 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestCoalescingRangeReader(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	tests := []struct {
+		name    string
+		options []pmtilr.CoalesceOption
+		rangers []pmtilr.Ranger
+		want    []string
+		calls   int64
+	}{
+		{
+			name:    "adjacent",
+			options: []pmtilr.CoalesceOption{pmtilr.WithCoalesceGap(0)},
+			rangers: []pmtilr.Ranger{pmtilr.NewRange(0, 3), pmtilr.NewRange(3, 3)},
+			want:    []string{"012", "345"},
+			calls:   1,
+		},
+		{
+			name:    "overlapping and unsorted",
+			options: []pmtilr.CoalesceOption{pmtilr.WithCoalesceGap(0)},
+			rangers: []pmtilr.Ranger{pmtilr.NewRange(4, 4), pmtilr.NewRange(0, 6), pmtilr.NewRange(5, 1)},
+			want:    []string{"4567", "012345", "5"},
+			calls:   1,
+		},
+		{
+			name:    "within gap",
+			options: []pmtilr.CoalesceOption{pmtilr.WithCoalesceGap(4)},
+			rangers: []pmtilr.Ranger{pmtilr.NewRange(0, 2), pmtilr.NewRange(6, 2)},
+			want:    []string{"01", "67"},
+			calls:   1,
+		},
+		{
+			name:    "beyond gap",
+			options: []pmtilr.CoalesceOption{pmtilr.WithCoalesceGap(3)},
+			rangers: []pmtilr.Ranger{pmtilr.NewRange(0, 2), pmtilr.NewRange(6, 2)},
+			want:    []string{"01", "67"},
+			calls:   2,
+		},
+		{
+			name:    "beyond max length",
+			options: []pmtilr.CoalesceOption{pmtilr.WithCoalesceMaxLength(8)},
+			rangers: []pmtilr.Ranger{pmtilr.NewRange(0, 4), pmtilr.NewRange(4, 4), pmtilr.NewRange(8, 4)},
+			want:    []string{"0123", "4567", "89ab"},
+			calls:   2,
+		},
+		{
+			name:    "past the end",
+			rangers: []pmtilr.Ranger{pmtilr.NewRange(30, 4), pmtilr.NewRange(34, 10)},
+			want:    []string{"uvwx", "yz"},
+			calls:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingRangeReader{BytesRangeReader: pmtilr.NewBytesRangeReader(data)}
+			reader := pmtilr.NewCoalescingRangeReader(inner, tt.options...)
+
+			got, err := reader.ReadRanges(t.Context(), tt.rangers)
+			if err != nil {
+				t.Fatalf("reading ranges: %v", err)
+			}
+			for i := range tt.want {
+				if string(got[i]) != tt.want[i] {
+					t.Errorf("range %d: got %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+			if calls := inner.calls.Load(); calls != tt.calls {
+				t.Errorf("got %d inner reads, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestReadRanges(t *testing.T) {
+	mock := pmtilrtest.NewRangeReader([]byte("0123456789"))
+
+	got, err := pmtilr.ReadRanges(t.Context(), mock, []pmtilr.Ranger{pmtilr.NewRange(7, 3), pmtilr.NewRange(1, 2)})
+	if err != nil {
+		t.Fatalf("reading ranges: %v", err)
+	}
+	if string(got[0]) != "789" || string(got[1]) != "12" {
+		t.Errorf("got %q", got)
+	}
+	if calls := mock.Calls(); len(calls) != 1 || calls[0] != pmtilr.NewRange(1, 9) {
+		t.Errorf("expected a single coalesced read, got %v", calls)
+	}
+
+	if _, err := pmtilr.ReadRanges(t.Context(), mock, []pmtilr.Ranger{pmtilr.NewRange(0, 0)}); err == nil {
+		t.Error("expected invalid ranger error")
+	}
+}
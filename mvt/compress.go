@@ -0,0 +1,40 @@
+package mvt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// transformCompressed applies fn to the decompressed tile data and
+// compresses the result the same way as data.
+func transformCompressed(
+	data []byte,
+	compression pmtilr.Compression,
+	fn func([]byte) ([]byte, error),
+) ([]byte, error) {
+	switch compression {
+	case pmtilr.CompressionNone, pmtilr.CompressionUnknown:
+		return fn(data)
+	}
+
+	rc, err := pmtilr.Decompress(io.NopCloser(bytes.NewReader(data)), compression)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading tile: %w", err)
+	}
+
+	out, err := fn(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return pmtilr.CompressBytes(out, compression)
+}
@@ -0,0 +1,363 @@
+package mvt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// field numbers as per vector_tile.proto v2.1.
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueFloat  = 2
+	valueDouble = 3
+	valueInt    = 4
+	valueUint   = 5
+	valueSint   = 6
+	valueBool   = 7
+)
+
+// geometry command ids.
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+var errMalformed = errors.New("mvt: malformed tile")
+
+// Decode decodes a decompressed vector tile.
+func Decode(data []byte) (*Tile, error) {
+	t := &Tile{}
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != tileLayers || typ != protowire.BytesType {
+			return skip(num, typ, b)
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		l, err := decodeLayer(v)
+		if err != nil {
+			return 0, err
+		}
+		t.Layers = append(t.Layers, l)
+
+		return n, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// LayerNames returns the layer names of a decompressed vector tile without
+// decoding features.
+func LayerNames(data []byte) ([]string, error) {
+	var names []string
+
+	err := walkLayers(data, func(name string, _ []byte) error {
+		names = append(names, name)
+		return nil
+	})
+
+	return names, err
+}
+
+// walkLayers calls fn with the name and the raw message of every layer.
+func walkLayers(data []byte, fn func(name string, raw []byte) error) error {
+	return walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != tileLayers || typ != protowire.BytesType {
+			return skip(num, typ, b)
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		var name string
+		err := walk(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+			if num != layerName || typ != protowire.BytesType {
+				return skip(num, typ, b)
+			}
+			s, n := protowire.ConsumeString(b)
+			name = s
+			return n, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		return n, fn(name, v)
+	})
+}
+
+func decodeLayer(data []byte) (*Layer, error) {
+	l := &Layer{Version: 1, Extent: DefaultExtent}
+
+	var (
+		keys     []string
+		values   []any
+		features [][]byte
+	)
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == layerName && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(b)
+			l.Name = s
+			return n, nil
+		case num == layerFeatures && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			features = append(features, v)
+			return n, nil
+		case num == layerKeys && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(b)
+			keys = append(keys, s)
+			return n, nil
+		case num == layerValues && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			val, err := decodeValue(v)
+			if err != nil {
+				return 0, err
+			}
+			values = append(values, val)
+			return n, nil
+		case num == layerExtent && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			l.Extent = uint32(v) //nolint:gosec
+			return n, nil
+		case num == layerVersion && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			l.Version = uint32(v) //nolint:gosec
+			return n, nil
+		default:
+			return skip(num, typ, b)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l.Features = make([]*Feature, 0, len(features))
+	for _, raw := range features {
+		f, err := decodeFeature(raw, keys, values)
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+		}
+		l.Features = append(l.Features, f)
+	}
+
+	return l, nil
+}
+
+func decodeValue(data []byte) (any, error) {
+	var val any
+
+	err := walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == valueString && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(b)
+			val = s
+			return n, nil
+		case num == valueFloat && typ == protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			val = math.Float32frombits(v)
+			return n, nil
+		case num == valueDouble && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			val = math.Float64frombits(v)
+			return n, nil
+		case num == valueInt && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			val = int64(v) //nolint:gosec
+			return n, nil
+		case num == valueUint && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			val = v
+			return n, nil
+		case num == valueSint && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			val = protowire.DecodeZigZag(v)
+			return n, nil
+		case num == valueBool && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			val = v != 0
+			return n, nil
+		default:
+			return skip(num, typ, b)
+		}
+	})
+
+	return val, err
+}
+
+func decodeFeature(data []byte, keys []string, values []any) (*Feature, error) {
+	f := &Feature{}
+
+	var tags, geometry []uint32
+	err := walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == featureID && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			f.ID, f.HasID = v, true
+			return n, nil
+		case num == featureType && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			f.Type = GeomType(v) //nolint:gosec
+			return n, nil
+		case num == featureTags:
+			return consumeUint32s(typ, b, &tags)
+		case num == featureGeometry:
+			return consumeUint32s(typ, b, &geometry)
+		default:
+			return skip(num, typ, b)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of feature tags", errMalformed)
+	}
+	f.Properties = make(map[string]any, len(tags)/2)
+	for i := 0; i < len(tags); i += 2 {
+		// compare unconverted, tags above MaxInt32 turn negative as int on
+		// 32-bit platforms.
+		k, v := tags[i], tags[i+1]
+		if uint64(k) >= uint64(len(keys)) || uint64(v) >= uint64(len(values)) {
+			return nil, fmt.Errorf("%w: feature tag out of range", errMalformed)
+		}
+		f.Properties[keys[k]] = values[v]
+	}
+
+	f.Geometry, err = decodeGeometry(f.Type, geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// decodeGeometry decodes the command integers of a feature geometry.
+func decodeGeometry(typ GeomType, cmds []uint32) (Geometry, error) {
+	var (
+		geom Geometry
+		part []Point
+		x, y int32
+	)
+
+	for i := 0; i < len(cmds); {
+		id, count := cmds[i]&0x7, int(cmds[i]>>3)
+		i++
+
+		switch id {
+		case cmdMoveTo, cmdLineTo:
+			if i+2*count > len(cmds) {
+				return nil, fmt.Errorf("%w: truncated geometry", errMalformed)
+			}
+			if id == cmdMoveTo && typ != GeomTypePoint && len(part) > 0 {
+				geom = append(geom, part)
+				part = nil
+			}
+			for range count {
+				x += decodeZigZag32(cmds[i])
+				y += decodeZigZag32(cmds[i+1])
+				part = append(part, Point{X: x, Y: y})
+				i += 2
+			}
+		case cmdClosePath:
+			if len(part) > 0 {
+				geom = append(geom, part)
+				part = nil
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown geometry command %d", errMalformed, id)
+		}
+	}
+
+	if len(part) > 0 {
+		geom = append(geom, part)
+	}
+
+	return geom, nil
+}
+
+func decodeZigZag32(v uint32) int32 {
+	return int32(v>>1) ^ -int32(v&1) //nolint:gosec
+}
+
+// consumeUint32s appends a packed or unpacked repeated uint32 field to dst.
+func consumeUint32s(typ protowire.Type, b []byte, dst *[]uint32) (int, error) {
+	switch typ {
+	case protowire.VarintType:
+		v, n := protowire.ConsumeVarint(b)
+		*dst = append(*dst, uint32(v)) //nolint:gosec
+		return n, nil
+	case protowire.BytesType:
+		packed, n := protowire.ConsumeBytes(b)
+		for len(packed) > 0 {
+			v, m := protowire.ConsumeVarint(packed)
+			if m < 0 {
+				return m, nil
+			}
+			*dst = append(*dst, uint32(v)) //nolint:gosec
+			packed = packed[m:]
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("%w: unexpected wire type %d", errMalformed, typ)
+	}
+}
+
+func skip(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	return protowire.ConsumeFieldValue(num, typ, b), nil
+}
+
+// walk iterates the fields of a protobuf message. fn is called with the
+// bytes following each tag and returns the number of value bytes consumed,
+// or a negative protowire error code.
+func walk(data []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", errMalformed, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		n, err := fn(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %w", errMalformed, protowire.ParseError(n))
+		}
+		data = data[n:]
+	}
+	return nil
+}
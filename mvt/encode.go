@@ -0,0 +1,211 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Encode encodes the tile into (uncompressed) MVT bytes. Keys and values are
+// deduplicated per layer; properties are written in key order so encoding is
+// deterministic.
+func (t *Tile) Encode() ([]byte, error) {
+	var buf []byte
+
+	for _, l := range t.Layers {
+		layer, err := encodeLayer(l)
+		if err != nil {
+			return nil, fmt.Errorf("encoding layer %q: %w", l.Name, err)
+		}
+		buf = protowire.AppendTag(buf, tileLayers, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, layer)
+	}
+
+	return buf, nil
+}
+
+func encodeLayer(l *Layer) ([]byte, error) {
+	version, extent := l.Version, l.Extent
+	if version == 0 {
+		version = 2
+	}
+	if extent == 0 {
+		extent = DefaultExtent
+	}
+
+	var (
+		buf      []byte
+		keys     []string
+		values   []any
+		keyIdx   = map[string]uint32{}
+		valueIdx = map[any]uint32{}
+	)
+
+	buf = protowire.AppendTag(buf, layerVersion, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, uint64(version))
+	buf = protowire.AppendTag(buf, layerName, protowire.BytesType)
+	buf = protowire.AppendString(buf, l.Name)
+	buf = protowire.AppendTag(buf, layerExtent, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, uint64(extent))
+
+	for _, f := range l.Features {
+		propKeys := make([]string, 0, len(f.Properties))
+		for k := range f.Properties {
+			propKeys = append(propKeys, k)
+		}
+		slices.Sort(propKeys)
+
+		tags := make([]uint32, 0, 2*len(propKeys))
+		for _, k := range propKeys {
+			v := f.Properties[k]
+			if err := validateValue(v); err != nil {
+				return nil, fmt.Errorf("property %q: %w", k, err)
+			}
+
+			ki, ok := keyIdx[k]
+			if !ok {
+				ki = uint32(len(keys)) //nolint:gosec
+				keyIdx[k] = ki
+				keys = append(keys, k)
+			}
+			vi, ok := valueIdx[v]
+			if !ok {
+				vi = uint32(len(values)) //nolint:gosec
+				valueIdx[v] = vi
+				values = append(values, v)
+			}
+			tags = append(tags, ki, vi)
+		}
+
+		buf = protowire.AppendTag(buf, layerFeatures, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, encodeFeature(f, tags))
+	}
+
+	for _, k := range keys {
+		buf = protowire.AppendTag(buf, layerKeys, protowire.BytesType)
+		buf = protowire.AppendString(buf, k)
+	}
+	for _, v := range values {
+		buf = protowire.AppendTag(buf, layerValues, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, encodeValue(v))
+	}
+
+	return buf, nil
+}
+
+func validateValue(v any) error {
+	switch v.(type) {
+	case string, float32, float64, int64, uint64, bool:
+		return nil
+	default:
+		return fmt.Errorf("unsupported property type %T", v)
+	}
+}
+
+func encodeValue(v any) []byte {
+	var buf []byte
+
+	switch val := v.(type) {
+	case string:
+		buf = protowire.AppendTag(buf, valueString, protowire.BytesType)
+		buf = protowire.AppendString(buf, val)
+	case float32:
+		buf = protowire.AppendTag(buf, valueFloat, protowire.Fixed32Type)
+		buf = protowire.AppendFixed32(buf, math.Float32bits(val))
+	case float64:
+		buf = protowire.AppendTag(buf, valueDouble, protowire.Fixed64Type)
+		buf = protowire.AppendFixed64(buf, math.Float64bits(val))
+	case int64:
+		if val < 0 {
+			buf = protowire.AppendTag(buf, valueSint, protowire.VarintType)
+			buf = protowire.AppendVarint(buf, protowire.EncodeZigZag(val))
+		} else {
+			buf = protowire.AppendTag(buf, valueInt, protowire.VarintType)
+			buf = protowire.AppendVarint(buf, uint64(val))
+		}
+	case uint64:
+		buf = protowire.AppendTag(buf, valueUint, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, val)
+	case bool:
+		buf = protowire.AppendTag(buf, valueBool, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, protowire.EncodeBool(val))
+	}
+
+	return buf
+}
+
+func encodeFeature(f *Feature, tags []uint32) []byte {
+	var buf []byte
+
+	if f.HasID {
+		buf = protowire.AppendTag(buf, featureID, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, f.ID)
+	}
+	if len(tags) > 0 {
+		buf = protowire.AppendTag(buf, featureTags, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, packUint32s(tags))
+	}
+	buf = protowire.AppendTag(buf, featureType, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, uint64(f.Type))
+	buf = protowire.AppendTag(buf, featureGeometry, protowire.BytesType)
+	buf = protowire.AppendBytes(buf, packUint32s(encodeGeometry(f.Type, f.Geometry)))
+
+	return buf
+}
+
+// encodeGeometry encodes the geometry parts into command integers.
+func encodeGeometry(typ GeomType, geom Geometry) []uint32 {
+	var (
+		cmds []uint32
+		x, y int32
+	)
+
+	appendPoints := func(points []Point) {
+		for _, p := range points {
+			cmds = append(cmds, encodeZigZag32(p.X-x), encodeZigZag32(p.Y-y))
+			x, y = p.X, p.Y
+		}
+	}
+
+	for _, part := range geom {
+		if len(part) == 0 {
+			continue
+		}
+
+		if typ == GeomTypePoint {
+			cmds = append(cmds, command(cmdMoveTo, len(part)))
+			appendPoints(part)
+			continue
+		}
+
+		cmds = append(cmds, command(cmdMoveTo, 1))
+		appendPoints(part[:1])
+		if len(part) > 1 {
+			cmds = append(cmds, command(cmdLineTo, len(part)-1))
+			appendPoints(part[1:])
+		}
+		if typ == GeomTypePolygon {
+			cmds = append(cmds, command(cmdClosePath, 1))
+		}
+	}
+
+	return cmds
+}
+
+func command(id uint32, count int) uint32 {
+	return id | uint32(count)<<3 //nolint:gosec
+}
+
+func encodeZigZag32(v int32) uint32 {
+	return uint32((v << 1) ^ (v >> 31)) //nolint:gosec
+}
+
+func packUint32s(vals []uint32) []byte {
+	buf := make([]byte, 0, len(vals)*2)
+	for _, v := range vals {
+		buf = protowire.AppendVarint(buf, uint64(v))
+	}
+	return buf
+}
@@ -0,0 +1,73 @@
+package mvt
+
+import (
+	"context"
+	"slices"
+
+	"github.com/iwpnd/pmtilr"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// FilterLayers returns a copy of the decompressed vector tile data that only
+// contains the layers for which keep returns true. Layers are copied as is,
+// without decoding their features.
+func FilterLayers(data []byte, keep func(name string) bool) ([]byte, error) {
+	buf := make([]byte, 0, len(data))
+
+	err := walkLayers(data, func(name string, raw []byte) error {
+		if !keep(name) {
+			return nil
+		}
+		buf = protowire.AppendTag(buf, tileLayers, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, raw)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// KeepLayers returns a FilterLayers predicate keeping only the named layers.
+func KeepLayers(names ...string) func(string) bool {
+	return func(name string) bool { return slices.Contains(names, name) }
+}
+
+// DropLayers returns a FilterLayers predicate removing the named layers.
+func DropLayers(names ...string) func(string) bool {
+	return func(name string) bool { return !slices.Contains(names, name) }
+}
+
+type layersKey struct{}
+
+// WithLayers returns a context that restricts tiles passed through
+// LayerFilter to the named layers, e.g. as requested by ?layers=roads,water.
+func WithLayers(ctx context.Context, names ...string) context.Context {
+	return context.WithValue(ctx, layersKey{}, names)
+}
+
+// LayersFromContext returns the layers set with WithLayers, if any.
+func LayersFromContext(ctx context.Context) ([]string, bool) {
+	names, ok := ctx.Value(layersKey{}).([]string)
+	return names, ok
+}
+
+// LayerFilter returns a pmtilr.TileTransform that keeps only the layers set
+// on the request context with WithLayers. Tiles are decompressed and
+// recompressed according to the archive's TileCompression; requests without
+// layers and non-MVT archives are passed through unchanged.
+func LayerFilter() pmtilr.TileTransform {
+	return func(
+		ctx context.Context, header pmtilr.HeaderV3, _, _, _ uint64, data []byte,
+	) ([]byte, error) {
+		names, ok := LayersFromContext(ctx)
+		if !ok || header.TileType != pmtilr.TileTypeMVT {
+			return data, nil
+		}
+
+		return transformCompressed(data, header.TileCompression, func(data []byte) ([]byte, error) {
+			return FilterLayers(data, KeepLayers(names...))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package mvt_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/mvt"
+)
+
+func TestFilterLayers(t *testing.T) {
+	data, err := fixtureTile().Encode()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		keep     func(string) bool
+		expected []string
+	}{
+		{name: "keep", keep: mvt.KeepLayers("water"), expected: []string{"water"}},
+		{name: "drop", keep: mvt.DropLayers("water"), expected: []string{"roads"}},
+		{name: "keep all", keep: mvt.KeepLayers("roads", "water"), expected: []string{"roads", "water"}},
+		{name: "keep none", keep: mvt.KeepLayers("buildings"), expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := mvt.FilterLayers(data, tt.keep)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			tile, err := mvt.Decode(filtered)
+			if err != nil {
+				t.Fatalf("filtered tile should decode: %s", err)
+			}
+
+			var names []string
+			for _, l := range tile.Layers {
+				names = append(names, l.Name)
+				if !reflect.DeepEqual(l, fixtureTile().Layer(l.Name)) {
+					t.Errorf("expected layer %q to be unchanged", l.Name)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Fatalf("expected layers %v, got: %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestLayerFilterTransform(t *testing.T) {
+	src, err := pmtilr.NewSource(
+		t.Context(),
+		"../testdata/cb_2018_us_county_500k.pmtiles",
+		pmtilr.WithDisableInstrumentation(),
+		pmtilr.WithTileTransform(mvt.LayerFilter()),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	decode := func(data []byte) []string {
+		t.Helper()
+		rc, err := pmtilr.Decompress(io.NopCloser(bytes.NewReader(data)), src.Header().TileCompression)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer rc.Close()
+		raw, _ := io.ReadAll(rc)
+		names, err := mvt.LayerNames(raw)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return names
+	}
+
+	full, err := src.Tile(t.Context(), 4, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	names := decode(full)
+	if len(names) == 0 {
+		t.Fatal("expected layers in unfiltered tile")
+	}
+
+	filtered, err := src.Tile(mvt.WithLayers(t.Context(), "does-not-exist"), 4, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := decode(filtered); len(got) != 0 {
+		t.Fatalf("expected no layers, got: %v", got)
+	}
+
+	kept, err := src.Tile(mvt.WithLayers(t.Context(), names[0]), 4, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := decode(kept); !reflect.DeepEqual(got, names[:1]) {
+		t.Fatalf("expected layers %v, got: %v", names[:1], got)
+	}
+}
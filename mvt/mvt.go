@@ -0,0 +1,84 @@
+// Package mvt decodes and encodes Mapbox Vector Tiles (MVT, spec v2.1) as
+// stored in PMTiles archives of TileTypeMVT.
+//
+// Tiles are decoded into layers, features, attributes and geometries so
+// their content can be inspected and filtered without a generated protobuf
+// library. Tile bytes must be decompressed before decoding, see
+// pmtilr.Decompress and HeaderV3.TileCompression.
+package mvt
+
+import "fmt"
+
+// DefaultExtent is the extent of a layer if not set explicitly.
+const DefaultExtent = 4096
+
+// GeomType is the geometry type of a Feature.
+type GeomType uint8
+
+const (
+	GeomTypeUnknown GeomType = iota
+	GeomTypePoint
+	GeomTypeLineString
+	GeomTypePolygon
+)
+
+var geomTypeStrings = map[GeomType]string{
+	GeomTypeUnknown:    "unknown",
+	GeomTypePoint:      "point",
+	GeomTypeLineString: "linestring",
+	GeomTypePolygon:    "polygon",
+}
+
+func (g GeomType) String() string {
+	if s, ok := geomTypeStrings[g]; ok {
+		return s
+	}
+	return fmt.Sprintf("GeomType(%d)", uint8(g))
+}
+
+// Point is a position in tile coordinates, usually within [0, extent).
+type Point struct {
+	X, Y int32
+}
+
+// Geometry holds the parts of a feature geometry in tile coordinates:
+//   - GeomTypePoint: a single part with one or more points (multi point),
+//   - GeomTypeLineString: one part per line,
+//   - GeomTypePolygon: one part per ring, without the closing point; exterior
+//     rings are clockwise, interior rings counter-clockwise in screen
+//     coordinates (y pointing down).
+type Geometry [][]Point
+
+// Tile is a decoded vector tile.
+type Tile struct {
+	Layers []*Layer
+}
+
+// Layer returns the layer with the given name, or nil.
+func (t *Tile) Layer(name string) *Layer {
+	for _, l := range t.Layers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Layer is a named collection of features sharing an extent.
+type Layer struct {
+	Name     string
+	Version  uint32
+	Extent   uint32
+	Features []*Feature
+}
+
+// Feature is a single geometry with its attributes.
+//
+// Property values are one of string, float32, float64, int64, uint64 or bool.
+type Feature struct {
+	ID         uint64
+	HasID      bool
+	Type       GeomType
+	Properties map[string]any
+	Geometry   Geometry
+}
@@ -0,0 +1,160 @@
+package mvt_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/mvt"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func fixtureTile() *mvt.Tile {
+	return &mvt.Tile{
+		Layers: []*mvt.Layer{
+			{
+				Name:    "roads",
+				Version: 2,
+				Extent:  4096,
+				Features: []*mvt.Feature{
+					{
+						ID:    1,
+						HasID: true,
+						Type:  mvt.GeomTypeLineString,
+						Properties: map[string]any{
+							"name":   "Main St",
+							"lanes":  int64(2),
+							"oneway": true,
+						},
+						Geometry: mvt.Geometry{{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 0}}},
+					},
+				},
+			},
+			{
+				Name:    "water",
+				Version: 2,
+				Extent:  4096,
+				Features: []*mvt.Feature{
+					{
+						Type: mvt.GeomTypePolygon,
+						Properties: map[string]any{
+							"depth": -12.5,
+							"area":  uint64(1337),
+							"ratio": float32(0.5),
+							"level": int64(-1),
+						},
+						Geometry: mvt.Geometry{
+							{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}},
+							{{X: 10, Y: 10}, {X: 10, Y: 20}, {X: 20, Y: 20}},
+						},
+					},
+					{
+						Type:       mvt.GeomTypePoint,
+						Properties: map[string]any{},
+						Geometry:   mvt.Geometry{{{X: 5, Y: 5}, {X: 6, Y: 7}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := fixtureTile()
+
+	data, err := want.Encode()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := mvt.Decode(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected round-tripped tile to equal input:\n  got:  %+v\n  want: %+v", got, want)
+	}
+
+	names, err := mvt.LayerNames(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(names, []string{"roads", "water"}) {
+		t.Fatalf("unexpected layer names: %v", names)
+	}
+}
+
+func TestDecodeArchiveTile(t *testing.T) {
+	src, err := pmtilr.NewSource(
+		t.Context(), "../testdata/cb_2018_us_county_500k.pmtiles", pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	raw, err := src.Tile(t.Context(), 4, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	rc, err := pmtilr.Decompress(io.NopCloser(bytes.NewReader(raw)), src.Header().TileCompression)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, _ := io.ReadAll(rc)
+
+	tile, err := mvt.Decode(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(tile.Layers) == 0 {
+		t.Fatal("expected at least one layer")
+	}
+	for _, l := range tile.Layers {
+		if len(l.Features) == 0 {
+			t.Errorf("expected features in layer %q", l.Name)
+		}
+		for _, f := range l.Features {
+			if f.Type != mvt.GeomTypePolygon {
+				t.Errorf("expected county polygons, got %s", f.Type)
+			}
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	// a feature tag above MaxInt32, negative as int on 32-bit platforms.
+	feature := protowire.AppendTag(nil, 2, protowire.BytesType)
+	feature = protowire.AppendBytes(feature, protowire.AppendVarint(protowire.AppendVarint(nil, math.MaxUint32), 0))
+	layer := protowire.AppendTag(nil, 1, protowire.BytesType)
+	layer = protowire.AppendString(layer, "layer")
+	layer = protowire.AppendTag(layer, 2, protowire.BytesType)
+	layer = protowire.AppendBytes(layer, feature)
+	layer = protowire.AppendTag(layer, 3, protowire.BytesType)
+	layer = protowire.AppendString(layer, "key")
+	layer = protowire.AppendTag(layer, 4, protowire.BytesType)
+	layer = protowire.AppendBytes(layer, protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "value"))
+	tagOverflow := protowire.AppendTag(nil, 3, protowire.BytesType)
+	tagOverflow = protowire.AppendBytes(tagOverflow, layer)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated tile", data: []byte{0x1a, 0xff}},
+		{name: "feature tag out of range", data: tagOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := mvt.Decode(tt.data); err == nil {
+				t.Fatal("expected error for malformed tile")
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package mvt
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// MaxOverzoom bounds the zoom difference of Overzoom, so scaled coordinates
+// stay exact in float64 arithmetic.
+const MaxOverzoom = 20
+
+// bbox is a clip rectangle in scaled tile coordinates, min inclusive and
+// max exclusive.
+type bbox struct {
+	minX, minY, maxX, maxY float64
+}
+
+func (b bbox) contains(x, y float64) bool {
+	return x >= b.minX && x <= b.maxX && y >= b.minY && y <= b.maxY
+}
+
+// Overzoom returns the descendant of t dz zoom levels below, at offset
+// (dx, dy) in units of the descendant tile, 0 <= dx, dy < 1<<dz. Geometries
+// are rescaled to the descendant tile and clipped to its extent plus buffer
+// (in tile coordinates of the descendant). Features without geometry after
+// clipping and layers without features are omitted. It fails if dz exceeds
+// MaxOverzoom.
+func (t *Tile) Overzoom(dz uint8, dx, dy uint32, buffer uint32) (*Tile, error) {
+	if dz > MaxOverzoom {
+		return nil, fmt.Errorf("overzooming by %d zoom levels, at most %d are supported", dz, MaxOverzoom)
+	}
+	out := &Tile{}
+
+	for _, l := range t.Layers {
+		extent := l.Extent
+		if extent == 0 {
+			extent = DefaultExtent
+		}
+
+		scale := float64(uint64(1) << dz)
+		ext := float64(extent)
+		b := float64(buffer)
+		clip := bbox{minX: -b, minY: -b, maxX: ext + b, maxY: ext + b}
+		transform := func(p Point) (float64, float64) {
+			return float64(p.X)*scale - float64(dx)*ext, float64(p.Y)*scale - float64(dy)*ext
+		}
+
+		layer := &Layer{Name: l.Name, Version: l.Version, Extent: l.Extent}
+		for _, f := range l.Features {
+			var geom Geometry
+			switch f.Type {
+			case GeomTypePoint:
+				geom = clipPoints(f.Geometry, transform, clip)
+			case GeomTypeLineString:
+				geom = clipLines(f.Geometry, transform, clip)
+			case GeomTypePolygon:
+				geom = clipPolygon(f.Geometry, transform, clip)
+			default:
+				continue
+			}
+			if len(geom) == 0 {
+				continue
+			}
+
+			clipped := *f
+			clipped.Geometry = geom
+			layer.Features = append(layer.Features, &clipped)
+		}
+
+		if len(layer.Features) > 0 {
+			out.Layers = append(out.Layers, layer)
+		}
+	}
+
+	return out, nil
+}
+
+type transformFunc = func(Point) (float64, float64)
+
+func toPoint(x, y float64) Point {
+	return Point{X: int32(math.Round(x)), Y: int32(math.Round(y))}
+}
+
+// appendPoint appends p unless it repeats the last point of part.
+func appendPoint(part []Point, p Point) []Point {
+	if n := len(part); n > 0 && part[n-1] == p {
+		return part
+	}
+	return append(part, p)
+}
+
+func clipPoints(geom Geometry, transform transformFunc, clip bbox) Geometry {
+	var part []Point
+	for _, points := range geom {
+		for _, p := range points {
+			x, y := transform(p)
+			if clip.contains(x, y) {
+				part = append(part, toPoint(x, y))
+			}
+		}
+	}
+	if len(part) == 0 {
+		return nil
+	}
+	return Geometry{part}
+}
+
+// clipLines clips every line with Liang-Barsky, splitting lines that leave
+// and re-enter the clip rectangle.
+func clipLines(geom Geometry, transform transformFunc, clip bbox) Geometry {
+	var out Geometry
+
+	for _, line := range geom {
+		var part []Point
+		for i := 1; i < len(line); i++ {
+			x0, y0 := transform(line[i-1])
+			x1, y1 := transform(line[i])
+
+			t0, t1, ok := clipSegment(x0, y0, x1, y1, clip)
+			if !ok {
+				if len(part) > 1 {
+					out = append(out, part)
+				}
+				part = nil
+				continue
+			}
+
+			start := toPoint(x0+t0*(x1-x0), y0+t0*(y1-y0))
+			end := toPoint(x0+t1*(x1-x0), y0+t1*(y1-y0))
+			if n := len(part); n > 0 && part[n-1] != start {
+				if n > 1 {
+					out = append(out, part)
+				}
+				part = nil
+			}
+			part = appendPoint(part, start)
+			part = appendPoint(part, end)
+
+			// the segment left the rectangle, the next one starts a new part
+			if t1 < 1 {
+				if len(part) > 1 {
+					out = append(out, part)
+				}
+				part = nil
+			}
+		}
+		if len(part) > 1 {
+			out = append(out, part)
+		}
+	}
+
+	return out
+}
+
+// clipSegment returns the parameter interval [t0, t1] of the segment inside
+// the clip rectangle.
+func clipSegment(x0, y0, x1, y1 float64, clip bbox) (float64, float64, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := x1-x0, y1-y0
+
+	for _, edge := range [4][2]float64{
+		{-dx, x0 - clip.minX},
+		{dx, clip.maxX - x0},
+		{-dy, y0 - clip.minY},
+		{dy, clip.maxY - y0},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			t0 = max(t0, r)
+		} else {
+			t1 = min(t1, r)
+		}
+		if t0 > t1 {
+			return 0, 0, false
+		}
+	}
+
+	return t0, t1, true
+}
+
+// clipPolygon clips every ring with Sutherland-Hodgman. Interior rings are
+// dropped together with an exterior ring that vanished.
+func clipPolygon(geom Geometry, transform transformFunc, clip bbox) Geometry {
+	var (
+		out          Geometry
+		keepInterior bool
+	)
+
+	for _, ring := range geom {
+		exterior := signedArea(ring) > 0
+		if !exterior && !keepInterior {
+			continue
+		}
+
+		xs := make([][2]float64, len(ring))
+		for i, p := range ring {
+			xs[i][0], xs[i][1] = transform(p)
+		}
+		clipped := clipRing(xs, clip)
+
+		var part []Point
+		for _, p := range clipped {
+			part = appendPoint(part, toPoint(p[0], p[1]))
+		}
+		if len(part) > 1 && part[0] == part[len(part)-1] {
+			part = part[:len(part)-1]
+		}
+
+		valid := len(part) >= 3 && signedArea(part) != 0
+		if exterior {
+			keepInterior = valid
+		}
+		if valid {
+			out = append(out, part)
+		}
+	}
+
+	return out
+}
+
+func clipRing(ring [][2]float64, clip bbox) [][2]float64 {
+	type edge struct {
+		inside    func(p [2]float64) bool
+		intersect func(a, b [2]float64) [2]float64
+	}
+
+	atX := func(x float64) func(a, b [2]float64) [2]float64 {
+		return func(a, b [2]float64) [2]float64 {
+			return [2]float64{x, a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])}
+		}
+	}
+	atY := func(y float64) func(a, b [2]float64) [2]float64 {
+		return func(a, b [2]float64) [2]float64 {
+			return [2]float64{a[0] + (b[0]-a[0])*(y-a[1])/(b[1]-a[1]), y}
+		}
+	}
+
+	edges := [4]edge{
+		{func(p [2]float64) bool { return p[0] >= clip.minX }, atX(clip.minX)},
+		{func(p [2]float64) bool { return p[0] <= clip.maxX }, atX(clip.maxX)},
+		{func(p [2]float64) bool { return p[1] >= clip.minY }, atY(clip.minY)},
+		{func(p [2]float64) bool { return p[1] <= clip.maxY }, atY(clip.maxY)},
+	}
+
+	for _, e := range edges {
+		if len(ring) == 0 {
+			return nil
+		}
+
+		input := ring
+		ring = make([][2]float64, 0, len(input)+4)
+		prev := input[len(input)-1]
+		for _, cur := range input {
+			switch {
+			case e.inside(cur):
+				if !e.inside(prev) {
+					ring = append(ring, e.intersect(prev, cur))
+				}
+				ring = append(ring, cur)
+			case e.inside(prev):
+				ring = append(ring, e.intersect(prev, cur))
+			}
+			prev = cur
+		}
+	}
+
+	return ring
+}
+
+// signedArea returns twice the signed area of ring, positive for rings that
+// are clockwise in screen coordinates.
+func signedArea(ring []Point) int64 {
+	var area int64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		area += int64(p.X)*int64(q.Y) - int64(q.X)*int64(p.Y)
+	}
+	return area
+}
+
+// OverzoomFunc returns a pmtilr.OverzoomFunc that extracts MVT sub-tiles
+// from the parent tile, clipped to the tile extent plus buffer. Tiles are
+// decompressed and recompressed according to the archive's TileCompression.
+// MVT tiles more than MaxOverzoom zoom levels below the archive's MaxZoom
+// fail.
+func OverzoomFunc(buffer uint32) pmtilr.OverzoomFunc {
+	return func(
+		_ context.Context, header pmtilr.HeaderV3, parent []byte, dz uint8, dx, dy uint64,
+	) ([]byte, error) {
+		if header.TileType != pmtilr.TileTypeMVT {
+			return parent, nil
+		}
+
+		return transformCompressed(parent, header.TileCompression, func(data []byte) ([]byte, error) {
+			tile, err := Decode(data)
+			if err != nil {
+				return nil, err
+			}
+			child, err := tile.Overzoom(dz, uint32(dx), uint32(dy), buffer) //nolint:gosec
+			if err != nil {
+				return nil, err
+			}
+			return child.Encode()
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package mvt_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/mvt"
+)
+
+func singleFeatureTile(typ mvt.GeomType, geom mvt.Geometry) *mvt.Tile {
+	return &mvt.Tile{Layers: []*mvt.Layer{{
+		Name:    "layer",
+		Version: 2,
+		Extent:  4096,
+		Features: []*mvt.Feature{
+			{Type: typ, Properties: map[string]any{"k": "v"}, Geometry: geom},
+		},
+	}}}
+}
+
+func TestOverzoom(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      mvt.GeomType
+		geom     mvt.Geometry
+		dx, dy   uint32
+		buffer   uint32
+		expected mvt.Geometry
+	}{
+		{
+			name:     "points",
+			typ:      mvt.GeomTypePoint,
+			geom:     mvt.Geometry{{{X: 100, Y: 100}, {X: 3000, Y: 100}, {X: 2060, Y: 10}}},
+			buffer:   32,
+			expected: mvt.Geometry{{{X: 200, Y: 200}, {X: 4120, Y: 20}}},
+		},
+		{
+			name:     "line clipped at the edge",
+			typ:      mvt.GeomTypeLineString,
+			geom:     mvt.Geometry{{{X: 0, Y: 1024}, {X: 4096, Y: 1024}}},
+			expected: mvt.Geometry{{{X: 0, Y: 2048}, {X: 4096, Y: 2048}}},
+		},
+		{
+			name: "line leaving and re-entering",
+			typ:  mvt.GeomTypeLineString,
+			geom: mvt.Geometry{{{X: 0, Y: 100}, {X: 3000, Y: 100}, {X: 3000, Y: 1000}, {X: 0, Y: 1000}}},
+			expected: mvt.Geometry{
+				{{X: 0, Y: 200}, {X: 4096, Y: 200}},
+				{{X: 4096, Y: 2000}, {X: 0, Y: 2000}},
+			},
+		},
+		{
+			name:     "polygon covering the child",
+			typ:      mvt.GeomTypePolygon,
+			geom:     mvt.Geometry{{{X: 0, Y: 0}, {X: 4096, Y: 0}, {X: 4096, Y: 4096}, {X: 0, Y: 4096}}},
+			dx:       1,
+			dy:       1,
+			expected: mvt.Geometry{{{X: 0, Y: 0}, {X: 4096, Y: 0}, {X: 4096, Y: 4096}, {X: 0, Y: 4096}}},
+		},
+		{
+			name: "polygon outside the child",
+			typ:  mvt.GeomTypePolygon,
+			geom: mvt.Geometry{{{X: 0, Y: 0}, {X: 2048, Y: 0}, {X: 2048, Y: 4096}, {X: 0, Y: 4096}}},
+			dx:   1,
+		},
+		{
+			name: "interior ring dropped with its exterior",
+			typ:  mvt.GeomTypePolygon,
+			geom: mvt.Geometry{
+				{{X: 0, Y: 0}, {X: 1000, Y: 0}, {X: 1000, Y: 1000}, {X: 0, Y: 1000}},
+				{{X: 100, Y: 100}, {X: 100, Y: 200}, {X: 200, Y: 200}, {X: 200, Y: 100}},
+				{{X: 3000, Y: 3000}, {X: 4000, Y: 3000}, {X: 4000, Y: 4000}, {X: 3000, Y: 4000}},
+			},
+			dx: 1,
+			dy: 1,
+			expected: mvt.Geometry{
+				{{X: 1904, Y: 1904}, {X: 3904, Y: 1904}, {X: 3904, Y: 3904}, {X: 1904, Y: 3904}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			child, err := singleFeatureTile(tt.typ, tt.geom).Overzoom(1, tt.dx, tt.dy, tt.buffer)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if tt.expected == nil {
+				if len(child.Layers) != 0 {
+					t.Fatalf("expected empty tile, got: %+v", child.Layers[0].Features[0])
+				}
+				return
+			}
+
+			if len(child.Layers) != 1 || len(child.Layers[0].Features) != 1 {
+				t.Fatalf("expected a single feature, got: %+v", child.Layers)
+			}
+			f := child.Layers[0].Features[0]
+			if !reflect.DeepEqual(f.Geometry, tt.expected) {
+				t.Fatalf("unexpected geometry:\n  got:  %v\n  want: %v", f.Geometry, tt.expected)
+			}
+			if f.Properties["k"] != "v" {
+				t.Fatalf("expected properties to be kept, got: %v", f.Properties)
+			}
+		})
+	}
+}
+
+func TestOverzoomBeyondMax(t *testing.T) {
+	tile := singleFeatureTile(mvt.GeomTypePoint, mvt.Geometry{{{X: 10, Y: 10}}})
+
+	if _, err := tile.Overzoom(mvt.MaxOverzoom, 0, 0, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := tile.Overzoom(mvt.MaxOverzoom+1, 0, 0, 0); err == nil {
+		t.Fatal("expected error beyond MaxOverzoom")
+	}
+}
+
+func TestOverzoomSource(t *testing.T) {
+	const buffer = 64
+
+	src, err := pmtilr.NewSource(
+		t.Context(),
+		"../testdata/cb_2018_us_county_500k.pmtiles",
+		pmtilr.WithDisableInstrumentation(),
+		pmtilr.WithOverzoom(9, mvt.OverzoomFunc(buffer)),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	decode := func(data []byte) *mvt.Tile {
+		t.Helper()
+		rc, err := pmtilr.Decompress(io.NopCloser(bytes.NewReader(data)), src.Header().TileCompression)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer rc.Close()
+		raw, _ := io.ReadAll(rc)
+		tile, err := mvt.Decode(raw)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return tile
+	}
+
+	parent, err := src.Tile(t.Context(), 7, 28, 44)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := src.Tile(t.Context(), 9, 28*4+1, 44*4+2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := decode(data)
+	want, err := decode(parent).Overzoom(2, 1, 2, buffer)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal("expected overzoomed tile to equal the clipped parent")
+	}
+	if len(got.Layers) == 0 {
+		t.Fatal("expected layers in overzoomed tile")
+	}
+
+	for _, l := range got.Layers {
+		for _, f := range l.Features {
+			for _, part := range f.Geometry {
+				for _, p := range part {
+					if p.X < -buffer || p.X > 4096+buffer || p.Y < -buffer || p.Y > 4096+buffer {
+						t.Fatalf("expected point %v within the buffered extent", p)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := src.Tile(t.Context(), 10, 0, 0); err == nil {
+		t.Fatal("expected error beyond overzoom max zoom")
+	}
+}
@@ -0,0 +1,271 @@
+package pmtilr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/iwpnd/rip"
+)
+
+const (
+	// OCIPMTilesMediaType is the layer media type used to publish PMTiles
+	// archives as OCI artifacts, e.g. `oras push ... map.pmtiles:application/vnd.pmtiles`.
+	OCIPMTilesMediaType = "application/vnd.pmtiles"
+
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociTitleAnnotation      = "org.opencontainers.image.title"
+	ociDefaultReference     = "latest"
+	ociBearerChallenge      = "Bearer "
+)
+
+// ErrOCILayerNotFound is returned if an OCI manifest holds no PMTiles layer.
+var ErrOCILayerNotFound = errors.New("no pmtiles layer found in oci manifest")
+
+// ociReference is a parsed "oci://registry/repository[:tag|@digest]" reference.
+type ociReference struct {
+	registry   string
+	repository string
+	reference  string
+}
+
+// parseOCIReference parses an OCI artifact reference with or without the
+// "oci://" prefix. The tag defaults to "latest".
+func parseOCIReference(ref string) (ociReference, error) {
+	ref = strings.TrimPrefix(ref, SchemeOCI.String()+"://")
+
+	registry, name, ok := strings.Cut(ref, "/")
+	if !ok || registry == "" || name == "" {
+		return ociReference{}, fmt.Errorf("invalid oci reference %q: expected registry/repository", ref)
+	}
+
+	repository, reference := name, ociDefaultReference
+	if i := strings.Index(name, "@"); i >= 0 {
+		repository, reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i >= 0 {
+		repository, reference = name[:i], name[i+1:]
+	}
+
+	if repository == "" || reference == "" {
+		return ociReference{}, fmt.Errorf("invalid oci reference %q", ref)
+	}
+
+	return ociReference{
+		registry:   registry,
+		repository: repository,
+		reference:  reference,
+	}, nil
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// pmtilesLayer returns the layer holding the PMTiles archive. Layers are matched by
+// media type first, then by title annotation; a single layer manifest is used as is.
+func (m ociManifest) pmtilesLayer() (ociDescriptor, error) {
+	for _, l := range m.Layers {
+		if l.MediaType == OCIPMTilesMediaType {
+			return l, nil
+		}
+	}
+	for _, l := range m.Layers {
+		if strings.HasSuffix(l.Annotations[ociTitleAnnotation], ".pmtiles") {
+			return l, nil
+		}
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], nil
+	}
+
+	return ociDescriptor{}, ErrOCILayerNotFound
+}
+
+// NewOCIRangeReader resolves a PMTiles archive published as an OCI artifact,
+// e.g. "oci://ghcr.io/org/basemap:2026-10", and returns an HTTPRangeReader that
+// issues range requests against the archive blob in the registry.
+//
+// Registries requiring a bearer token are authenticated through the token
+// endpoint announced in the WWW-Authenticate challenge, anonymously unless
+// the options carry credentials, e.g. WithBasicAuth. Options are applied to
+// the registry, the token endpoint and the blob client, but once the
+// registry issues a token it takes precedence over an Authorization header
+// set by the options. Expired tokens are refreshed when the registry
+// answers with 401.
+func NewOCIRangeReader(ctx context.Context, ref string, options ...rip.Option) (*HTTPRangeReader, error) {
+	r, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := "https://" + r.registry
+	c, err := rip.NewClient(registry, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestPath := fmt.Sprintf("/v2/%s/manifests/%s", r.repository, r.reference)
+	res, err := newRequest(c).
+		SetHeader("Accept", ociManifestMediaType+", "+dockerManifestMediaType).
+		Execute(ctx, http.MethodGet, manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("fetching oci manifest: %w", err)
+	}
+
+	var token string
+	if res.StatusCode() == http.StatusUnauthorized {
+		token, err = ociToken(ctx, res.Header().Get("WWW-Authenticate"), options...)
+		_ = res.Close() //nolint:errcheck
+		if err != nil {
+			return nil, err
+		}
+
+		res, err = newRequest(c).
+			SetHeader("Accept", ociManifestMediaType+", "+dockerManifestMediaType).
+			SetHeaders(rip.Header{"Authorization": "Bearer " + token}).
+			Execute(ctx, http.MethodGet, manifestPath)
+		if err != nil {
+			return nil, fmt.Errorf("fetching oci manifest: %w", err)
+		}
+	}
+	if res.IsError() {
+		_ = res.Close() //nolint:errcheck
+		return nil, fmt.Errorf("fetching oci manifest: %w: %d", ErrUpstreamStatus, res.StatusCode())
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(res.Body(), &manifest); err != nil {
+		return nil, fmt.Errorf("decoding oci manifest: %w", err)
+	}
+
+	layer, err := manifest.pmtilesLayer()
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+
+	reader, err := NewHTTPRangeReader(
+		fmt.Sprintf("%s/v2/%s/blobs/%s", registry, r.repository, layer.Digest),
+		options...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reader.authenticate = func(ctx context.Context, challenge string) (string, error) {
+		token, err := ociToken(ctx, challenge, options...)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	if token != "" {
+		reader.authorization = "Bearer " + token
+	}
+
+	return reader, nil
+}
+
+// ociToken requests a pull token from the realm announced in a
+// `Bearer realm="...",service="...",scope="..."` challenge, authenticated
+// with the credentials of the options, if any.
+func ociToken(ctx context.Context, challenge string, options ...rip.Option) (string, error) {
+	if !strings.HasPrefix(challenge, ociBearerChallenge) {
+		return "", fmt.Errorf("unsupported oci auth challenge %q", challenge)
+	}
+
+	params := parseAuthParams(strings.TrimPrefix(challenge, ociBearerChallenge))
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("oci auth challenge %q is missing realm", challenge)
+	}
+
+	c, err := rip.NewClient(realm, options...)
+	if err != nil {
+		return "", err
+	}
+
+	query := rip.Query{}
+	for _, k := range []string{"service", "scope"} {
+		if v, ok := params[k]; ok {
+			query[k] = v
+		}
+	}
+
+	res, err := newRequest(c).SetQuery(query).Execute(ctx, http.MethodGet, "")
+	if err != nil {
+		return "", fmt.Errorf("fetching oci token: %w", err)
+	}
+	if res.IsError() {
+		_ = res.Close() //nolint:errcheck
+		return "", fmt.Errorf("fetching oci token: %w: %d", ErrUpstreamStatus, res.StatusCode())
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(res.Body(), &body); err != nil {
+		return "", fmt.Errorf("decoding oci token: %w", err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", errors.New("decoding oci token: response has neither token nor access_token")
+}
+
+// parseAuthParams parses the comma separated name=value auth-params of a
+// challenge, see RFC 7235 section 2.1. Values are tokens or quoted strings,
+// which may contain commas and backslash escapes. Names are lower cased.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		if i := strings.IndexByte(name, ','); i >= 0 {
+			// skip a malformed param without value.
+			s = s[i+1:]
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[name] = value.String()
+	}
+}
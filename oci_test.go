@@ -0,0 +1,203 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/rip"
+)
+
+func TestOCIRangeReader(t *testing.T) {
+	data, err := os.ReadFile(testArchive)
+	if err != nil {
+		t.Fatalf("reading testdata should not error: %s", err)
+	}
+
+	const (
+		token  = "anonymous-pull-token"
+		digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	)
+
+	var ts *httptest.Server
+	ts = httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				if r.URL.Query().Get("scope") != "repository:org/basemap:pull,push" {
+					t.Errorf("unexpected token scope: %s", r.URL.Query().Get("scope"))
+				}
+				w.Write([]byte(`{"token":"` + token + `"}`))
+				return
+			}
+
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.Header().Set(
+					"WWW-Authenticate",
+					`Bearer realm="`+ts.URL+`/token", service="registry", scope="repository:org/basemap:pull,push"`,
+				)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			switch r.URL.Path {
+			case "/v2/org/basemap/manifests/2026-10":
+				w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+				w.Write([]byte(`{
+					"mediaType": "application/vnd.oci.image.manifest.v1+json",
+					"layers": [{"mediaType": "application/vnd.pmtiles", "digest": "` + digest + `"}]
+				}`))
+			case "/v2/org/basemap/blobs/" + digest:
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer ts.Close()
+
+	transport, _ := ts.Client().Transport.(*http.Transport)
+	ref := "oci://" + strings.TrimPrefix(ts.URL, "https://") + "/org/basemap:2026-10"
+
+	reader, err := pmtilr.NewOCIRangeReader(t.Context(), ref, rip.WithTransport(transport))
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 7))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rc.Close()
+
+	result, _ := io.ReadAll(rc)
+	if string(result) != "PMTiles" {
+		t.Fatalf("expected %s, got: %s", "PMTiles", string(result))
+	}
+}
+
+func TestOCIRangeReaderTokenRefresh(t *testing.T) {
+	data, err := os.ReadFile(testArchive)
+	if err != nil {
+		t.Fatalf("reading testdata should not error: %s", err)
+	}
+
+	const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+	var (
+		mu     sync.Mutex
+		issued int
+		valid  string
+	)
+
+	var ts *httptest.Server
+	ts = httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if r.URL.Path == "/token" {
+				if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				issued++
+				valid = fmt.Sprintf("token-%d", issued)
+				w.Write([]byte(`{"token":"` + valid + `"}`))
+				return
+			}
+
+			if valid == "" || r.Header.Get("Authorization") != "Bearer "+valid {
+				w.Header().Set(
+					"WWW-Authenticate",
+					`Bearer realm="`+ts.URL+`/token",service="registry",scope="repository:org/basemap:pull"`,
+				)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			switch r.URL.Path {
+			case "/v2/org/basemap/manifests/2026-10":
+				w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+				w.Write([]byte(`{
+					"mediaType": "application/vnd.oci.image.manifest.v1+json",
+					"layers": [{"mediaType": "application/vnd.pmtiles", "digest": "` + digest + `"}]
+				}`))
+			case "/v2/org/basemap/blobs/" + digest:
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer ts.Close()
+
+	transport, _ := ts.Client().Transport.(*http.Transport)
+	ref := "oci://" + strings.TrimPrefix(ts.URL, "https://") + "/org/basemap:2026-10"
+
+	reader, err := pmtilr.NewOCIRangeReader(
+		t.Context(), ref,
+		rip.WithTransport(transport),
+		pmtilr.WithBasicAuth("user", "secret"),
+	)
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	read := func() {
+		t.Helper()
+
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 7))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer rc.Close()
+
+		result, _ := io.ReadAll(rc)
+		if string(result) != "PMTiles" {
+			t.Fatalf("expected %s, got: %s", "PMTiles", string(result))
+		}
+	}
+
+	read()
+
+	// expire the token, the reader has to fetch a new one.
+	mu.Lock()
+	valid = ""
+	mu.Unlock()
+
+	read()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if issued != 2 {
+		t.Fatalf("expected %d tokens to be issued, got: %d", 2, issued)
+	}
+}
+
+func TestOCIRangeReaderMissingToken(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				w.Write([]byte(`{"expires_in":300}`))
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+ts.URL+`/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+	defer ts.Close()
+
+	transport, _ := ts.Client().Transport.(*http.Transport)
+	ref := "oci://" + strings.TrimPrefix(ts.URL, "https://") + "/org/basemap:2026-10"
+
+	_, err := pmtilr.NewOCIRangeReader(t.Context(), ref, rip.WithTransport(transport))
+	if err == nil || !strings.Contains(err.Error(), "neither token nor access_token") {
+		t.Fatalf("expected error for a token response without token, got: %v", err)
+	}
+}
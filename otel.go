@@ -3,6 +3,10 @@ package pmtilr
 import (
 	"context"
 	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -27,12 +31,62 @@ func newInt64Counter(
 	return meter.Int64Counter(name, opts...)
 }
 
+// metricLabels holds the attributes every metric of a Source is recorded
+// with, e.g. to break down latency and hit rate per archive.
+type metricLabels struct {
+	attrs []attribute.KeyValue
+}
+
+func newMetricLabels(archive string) *metricLabels {
+	l := &metricLabels{}
+	if archive != "" {
+		l.attrs = append(l.attrs, attribute.String("archive", archiveLabel(archive)))
+	}
+	return l
+}
+
+func (l *metricLabels) add(kv ...attribute.KeyValue) {
+	l.attrs = append(l.attrs, kv...)
+}
+
+func (l *metricLabels) with(kv ...attribute.KeyValue) metric.MeasurementOption {
+	if l == nil || len(l.attrs) == 0 {
+		return metric.WithAttributes(kv...)
+	}
+	return metric.WithAttributes(append(slices.Clip(l.attrs), kv...)...)
+}
+
+// maxArchiveLabels caps the number of distinct archive label values per
+// process. Archives labeled beyond that are recorded as "other" to keep
+// metric cardinality bounded.
+const maxArchiveLabels = 100
+
+var archiveLabels = struct {
+	sync.Mutex
+	seen map[string]struct{}
+}{seen: map[string]struct{}{}}
+
+func archiveLabel(name string) string {
+	archiveLabels.Lock()
+	defer archiveLabels.Unlock()
+
+	if _, ok := archiveLabels.seen[name]; ok {
+		return name
+	}
+	if len(archiveLabels.seen) >= maxArchiveLabels {
+		return "other"
+	}
+	archiveLabels.seen[name] = struct{}{}
+	return name
+}
+
 // instrumentedSource implements the Source interface
 // and wraps a Source to collect metrics and provide tracing.
 func newInstrumentedSource(
 	source *TileSource,
 	tracer trace.Tracer,
 	meter metric.Meter,
+	labels *metricLabels,
 ) (Source, error) {
 	requestHistogramName := "pmtilr.source.tile.request.duration"
 	requestHistogram, err := newFloat64Histogram(
@@ -48,12 +102,14 @@ func newInstrumentedSource(
 		source:           source,
 		tracer:           tracer,
 		meter:            meter,
+		labels:           labels,
 		requestHistogram: requestHistogram,
 	}, nil
 }
 
 type instrumentedSource struct {
 	source *TileSource
+	labels *metricLabels
 
 	requestHistogram metric.Float64Histogram
 
@@ -72,7 +128,7 @@ func (is *instrumentedSource) Tile(ctx context.Context, z, x, y uint64) (data []
 			is.requestHistogram.Record(
 				ctx,
 				duration.Seconds(),
-				metric.WithAttributes(
+				is.labels.with(
 					attribute.KeyValue{Key: "success", Value: attribute.BoolValue(err == nil)},
 				),
 			)
@@ -88,6 +144,72 @@ func (is *instrumentedSource) Tile(ctx context.Context, z, x, y uint64) (data []
 	return data, err
 }
 
+func (is *instrumentedSource) TileByID(ctx context.Context, id TileID) ([]byte, error) {
+	z, x, y, err := id.checkedZXY()
+	if err != nil {
+		return nil, err
+	}
+	return is.Tile(ctx, z, x, y)
+}
+
+func (is *instrumentedSource) TileTo(ctx context.Context, w io.Writer, z, x, y uint64) (n int64, err error) {
+	ctx, span := is.tracer.Start(ctx, "pmtilr.tile")
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		if is.requestHistogram.Enabled(ctx) {
+			duration := time.Since(start)
+			is.requestHistogram.Record(
+				ctx,
+				duration.Seconds(),
+				is.labels.with(
+					attribute.KeyValue{Key: "success", Value: attribute.BoolValue(err == nil)},
+				),
+			)
+		}
+	}()
+
+	n, err = is.source.TileTo(ctx, w, z, x, y)
+	if err != nil {
+		span.SetStatus(codes.Error, "pmtilr.tile failed")
+		span.RecordError(err)
+	}
+	return n, err
+}
+
+func (is *instrumentedSource) Warm(ctx context.Context, z, x, y uint64) error {
+	return is.source.Warm(ctx, z, x, y)
+}
+
+func (is *instrumentedSource) Coverage(ctx context.Context, zoom uint8) (*Bitmap, error) {
+	ctx, span := is.tracer.Start(ctx, "pmtilr.coverage")
+	defer span.End()
+
+	bitmap, err := is.source.Coverage(ctx, zoom)
+	if err != nil {
+		span.SetStatus(codes.Error, "pmtilr.coverage failed")
+		span.RecordError(err)
+	}
+	return bitmap, err
+}
+
+func (is *instrumentedSource) Entries(ctx context.Context) iter.Seq2[Entry, error] {
+	return is.source.Entries(ctx)
+}
+
+func (is *instrumentedSource) EntryBytes(ctx context.Context, e Entry) ([]byte, error) {
+	return is.source.EntryBytes(ctx, e)
+}
+
+func (is *instrumentedSource) Stats() Stats {
+	return is.source.Stats()
+}
+
+func (is *instrumentedSource) Probe(ctx context.Context) error {
+	return is.source.Probe(ctx)
+}
+
 func (is *instrumentedSource) Header() HeaderV3 {
 	return is.source.Header()
 }
@@ -96,6 +218,14 @@ func (is *instrumentedSource) Meta() Metadata {
 	return is.source.Meta()
 }
 
+func (is *instrumentedSource) Snapshot(ctx context.Context, w io.Writer) error {
+	return is.source.Snapshot(ctx, w)
+}
+
+func (is *instrumentedSource) Restore(ctx context.Context, r io.Reader) error {
+	return is.source.Restore(ctx, r)
+}
+
 func (is *instrumentedSource) Close() {
 	is.source.Close()
 }
@@ -107,7 +237,8 @@ func (is *instrumentedSource) TileJSON(host string) TileJSON {
 // instrumentedCacher satisfied the Cacher interface,
 // and wraps a Cacher to collect metrics and provide tracing.
 type instrumentedCacher struct {
-	cache Cacher
+	cache  Cacher
+	labels *metricLabels
 
 	requestHistogram metric.Float64Histogram
 	cacheHitCounter  metric.Int64Counter
@@ -120,6 +251,7 @@ func newInstrumentedCacher(
 	cache Cacher,
 	tracer trace.Tracer,
 	meter metric.Meter,
+	labels *metricLabels,
 ) (*instrumentedCacher, error) {
 	requestHistogramName := "pmtilr.directory.cache.request.duration"
 	requestHistogram, err := newFloat64Histogram(
@@ -142,6 +274,7 @@ func newInstrumentedCacher(
 	}
 	return &instrumentedCacher{
 		cache:  cache,
+		labels: labels,
 		tracer: tracer,
 		meter:  meter,
 
@@ -161,7 +294,7 @@ func (ic *instrumentedCacher) Get(ctx context.Context, key string) (Directory, b
 			ic.requestHistogram.Record(
 				ctx,
 				duration.Seconds(),
-				metric.WithAttributes(
+				ic.labels.with(
 					attribute.KeyValue{Key: "operation", Value: attribute.StringValue("get")},
 				),
 			)
@@ -175,7 +308,7 @@ func (ic *instrumentedCacher) Get(ctx context.Context, key string) (Directory, b
 		ic.cacheHitCounter.Add(
 			ctx,
 			1,
-			metric.WithAttributes(
+			ic.labels.with(
 				attribute.KeyValue{Key: "cached", Value: attribute.BoolValue(cached)},
 			),
 		)
@@ -195,7 +328,7 @@ func (ic *instrumentedCacher) Set(ctx context.Context, key string, value Directo
 			ic.requestHistogram.Record(
 				ctx,
 				duration.Seconds(),
-				metric.WithAttributes(
+				ic.labels.with(
 					attribute.KeyValue{Key: "operation", Value: attribute.StringValue("set")},
 				),
 			)
@@ -217,6 +350,7 @@ func (ic *instrumentedCacher) Clear() {
 // and wraps a Repository to collect metrics and provide tracing.
 type instrumentedRepository struct {
 	repository Repository
+	labels     *metricLabels
 
 	sharedRequestCounter   metric.Int64Counter
 	sharedRequestHistogram metric.Float64Histogram
@@ -229,6 +363,7 @@ func newInstrumentedRepository(
 	repository Repository,
 	tracer trace.Tracer,
 	meter metric.Meter,
+	labels *metricLabels,
 ) (*instrumentedRepository, error) {
 	sharedRequestDurationHistogramName := "pmtilr.repository.directory.request.duration"
 	sharedRequestHistogram, err := newFloat64Histogram(
@@ -256,6 +391,7 @@ func newInstrumentedRepository(
 
 	return &instrumentedRepository{
 		repository:             repository,
+		labels:                 labels,
 		tracer:                 tracer,
 		meter:                  meter,
 		sharedRequestCounter:   sharedRequestCounter,
@@ -263,6 +399,40 @@ func newInstrumentedRepository(
 	}, nil
 }
 
+func (ir *instrumentedRepository) Snapshot(ctx context.Context, w io.Writer) error {
+	ctx, span := ir.tracer.Start(ctx, "pmtilr.tile.repository.snapshot")
+	defer span.End()
+
+	var err error
+	if r, ok := ir.repository.(Snapshotter); ok {
+		err = r.Snapshot(ctx, w)
+	} else {
+		err = fmt.Errorf("writing snapshot: %T does not support snapshots", ir.repository)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, "pmtilr.snapshot failed")
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (ir *instrumentedRepository) Restore(ctx context.Context, r io.Reader) error {
+	ctx, span := ir.tracer.Start(ctx, "pmtilr.tile.repository.restore")
+	defer span.End()
+
+	var err error
+	if repository, ok := ir.repository.(Snapshotter); ok {
+		err = repository.Restore(ctx, r)
+	} else {
+		err = fmt.Errorf("restoring snapshot: %T does not support snapshots", ir.repository)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, "pmtilr.restore failed")
+		span.RecordError(err)
+	}
+	return err
+}
+
 func (ir *instrumentedRepository) Close() {
 	ir.repository.Close()
 }
@@ -284,7 +454,7 @@ func (ir *instrumentedRepository) DirectoryAt(
 			ir.sharedRequestHistogram.Record(
 				ctx,
 				duration.Seconds(),
-				metric.WithAttributes(
+				ir.labels.with(
 					attribute.KeyValue{Key: "success", Value: attribute.BoolValue(err == nil)},
 				),
 			)
@@ -296,7 +466,7 @@ func (ir *instrumentedRepository) DirectoryAt(
 		ir.sharedRequestCounter.Add(
 			ctx,
 			1,
-			metric.WithAttributes(
+			ir.labels.with(
 				attribute.KeyValue{
 					Key:   "shared",
 					Value: attribute.BoolValue(shared),
@@ -312,3 +482,69 @@ func (ir *instrumentedRepository) DirectoryAt(
 
 	return dir, shared, err
 }
+
+// spanAttributer is implemented by RangeReaders describing the archive they
+// read from, e.g. the bucket and key of an S3 object, for the spans of a
+// TracedRangeReader.
+type spanAttributer interface {
+	spanAttributes() []attribute.KeyValue
+}
+
+// TracedRangeReader wraps a RangeReader to create a span per ReadRange with
+// the offset and length of the range, and the scheme and location of the
+// archive, so backend latency shows up in the traces of tile requests. The
+// span ends once the returned body is closed, so it covers reading it.
+//
+// Sources from NewSource trace their reader unless instrumentation is
+// disabled.
+type TracedRangeReader struct {
+	reader RangeReader
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+// NewTracedRangeReader wraps reader to trace reads with a tracer of
+// provider.
+func NewTracedRangeReader(reader RangeReader, provider trace.TracerProvider) *TracedRangeReader {
+	var attrs []attribute.KeyValue
+	if a, ok := reader.(spanAttributer); ok {
+		attrs = a.spanAttributes()
+	}
+
+	return &TracedRangeReader{
+		reader: reader,
+		tracer: provider.Tracer(instrumentationName),
+		attrs:  attrs,
+	}
+}
+
+// ReadRange reads the range within a "pmtilr.range.read" span.
+func (t *TracedRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	ctx, span := t.tracer.Start(ctx, "pmtilr.range.read", trace.WithAttributes(t.attrs...))
+	span.SetAttributes(
+		attribute.Int64("pmtilr.range.offset", int64(ranger.Offset())), //nolint:gosec
+		attribute.Int64("pmtilr.range.length", int64(ranger.Length())), //nolint:gosec
+	)
+
+	rc, err := t.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		span.SetStatus(codes.Error, "pmtilr.range.read failed")
+		span.RecordError(err)
+		span.End()
+		return nil, err
+	}
+
+	return newObservedBody(rc, func(n int64, err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, "pmtilr.range.read failed")
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.Int64("pmtilr.range.bytes", n))
+		span.End()
+	}), nil
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (t *TracedRangeReader) Unwrap() RangeReader {
+	return t.reader
+}
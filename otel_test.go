@@ -0,0 +1,182 @@
+package pmtilr_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestArchiveLabels(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		options []pmtilr.SourceOption
+		archive string
+		etag    bool
+	}{
+		{name: "unlabeled"},
+		{
+			name:    "archive",
+			options: []pmtilr.SourceOption{pmtilr.WithArchiveLabel("counties")},
+			archive: "counties",
+		},
+		{
+			name: "archive and etag",
+			options: []pmtilr.SourceOption{
+				pmtilr.WithArchiveLabel("counties"),
+				pmtilr.WithEtagLabel(),
+			},
+			archive: "counties",
+			etag:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+			source, err := pmtilr.NewSource(
+				ctx,
+				testArchive,
+				append(tt.options, pmtilr.WithMeterProvider(provider))...,
+			)
+			if err != nil {
+				t.Fatalf("creating source: %v", err)
+			}
+
+			if _, err := source.Tile(ctx, 4, 3, 5); err != nil {
+				t.Fatalf("reading tile: %v", err)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(ctx, &rm); err != nil {
+				t.Fatalf("collecting metrics: %v", err)
+			}
+
+			var points int
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					for _, set := range attributeSets(m.Data) {
+						points++
+
+						archive, ok := set.Value("archive")
+						if got := archive.AsString(); ok != (tt.archive != "") || got != tt.archive {
+							t.Errorf("%s: archive label = %q, want %q", m.Name, got, tt.archive)
+						}
+
+						etag, ok := set.Value("archive.etag")
+						if ok != tt.etag || (ok && etag.AsString() != source.Header().Etag) {
+							t.Errorf("%s: archive.etag label = %q, want present %v", m.Name, etag.AsString(), tt.etag)
+						}
+					}
+				}
+			}
+			if points == 0 {
+				t.Fatal("expected metrics to be recorded")
+			}
+		})
+	}
+}
+
+func attributeSets(data metricdata.Aggregation) []attribute.Set {
+	var sets []attribute.Set
+	switch d := data.(type) {
+	case metricdata.Histogram[float64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+	case metricdata.Sum[int64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+	}
+	return sets
+}
+
+func TestTracedRangeReader(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	source, err := pmtilr.NewSource(ctx, testArchive, pmtilr.WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+	recorder.Reset()
+
+	if _, err := source.Tile(ctx, 4, 3, 5); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+
+	var reads []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "pmtilr.range.read" {
+			reads = append(reads, span)
+		}
+	}
+	if len(reads) == 0 {
+		t.Fatal("expected pmtilr.range.read spans")
+	}
+
+	for _, span := range reads {
+		if !span.Parent().IsValid() {
+			t.Error("expected range reads to be traced within the tile request")
+		}
+
+		attrs := attribute.NewSet(span.Attributes()...)
+		if scheme, _ := attrs.Value("pmtilr.reader.scheme"); scheme.AsString() != "file" {
+			t.Errorf("expected scheme file, got %q", scheme.AsString())
+		}
+		length, _ := attrs.Value("pmtilr.range.length")
+		read, _ := attrs.Value("pmtilr.range.bytes")
+		if length.AsInt64() == 0 || read.AsInt64() != length.AsInt64() {
+			t.Errorf("expected %d bytes read, got %d", length.AsInt64(), read.AsInt64())
+		}
+	}
+}
+
+func TestTracedRangeReaderHTTPAttributes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	u, _ := url.Parse(ts.URL + "/map.pmtiles?X-Amz-Signature=secret")
+	u.User = url.UserPassword("user", "password")
+	inner, err := pmtilr.NewHTTPRangeReader(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := pmtilr.NewTracedRangeReader(inner, provider).ReadRange(context.Background(), pmtilr.NewRange(0, 4))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	rc.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	got, _ := attrs.Value("pmtilr.http.url")
+	if want := ts.URL + "/map.pmtiles"; got.AsString() != want {
+		t.Errorf("got url %q, want %q", got.AsString(), want)
+	}
+}
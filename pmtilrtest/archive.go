@@ -0,0 +1,238 @@
+// Package pmtilrtest provides utilities for testing against pmtilr without
+// shipping fixture files.
+//
+// Archives are built in memory with a fluent builder:
+//
+//	data := pmtilrtest.NewArchive().
+//		WithTile(0, 0, 0, []byte("tile")).
+//		WithCompression(pmtilr.CompressionGZIP).
+//		Bytes()
+//
+// RangeReader is a scripted pmtilr.RangeReader with programmable latency,
+// errors and per-range responses, and HeaderBytes and DirectoryBytes
+// serialize canned headers and directories.
+package pmtilrtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// defaultLeafSize is the number of entries per leaf directory. Archives with
+// up to defaultLeafSize tiles only have a root directory.
+const defaultLeafSize = 4096
+
+// Archive builds a PMTiles v3 archive in memory. The zero value is not
+// usable, use NewArchive. Methods panic on invalid input, as the builder is
+// meant for tests.
+type Archive struct {
+	tiles       map[pmtilr.TileID][]byte
+	compression pmtilr.Compression
+	tileType    pmtilr.TileType
+	metadata    any
+	bounds      [4]float64
+	leafSize    int
+}
+
+// NewArchive returns an empty, uncompressed MVT archive builder covering
+// the whole world.
+func NewArchive() *Archive {
+	return &Archive{
+		tiles:       map[pmtilr.TileID][]byte{},
+		compression: pmtilr.CompressionNone,
+		tileType:    pmtilr.TileTypeMVT,
+		metadata:    map[string]any{},
+		bounds:      [4]float64{-180, -85.0511287, 180, 85.0511287},
+		leafSize:    defaultLeafSize,
+	}
+}
+
+// WithTile adds the uncompressed tile data at z/x/y, replacing any tile
+// added before. Tile data is compressed as set by WithCompression.
+func (a *Archive) WithTile(z, x, y uint64, data []byte) *Archive {
+	id, err := pmtilr.NewTileID(z, x, y)
+	if err != nil {
+		panic(fmt.Sprintf("pmtilrtest: tile %d/%d/%d: %v", z, x, y, err))
+	}
+	a.tiles[id] = bytes.Clone(data)
+	return a
+}
+
+// WithCompression sets the compression of tiles and directories. Only
+// pmtilr.CompressionNone and pmtilr.CompressionGZIP are supported.
+func (a *Archive) WithCompression(compression pmtilr.Compression) *Archive {
+	switch compression {
+	case pmtilr.CompressionNone, pmtilr.CompressionGZIP:
+	default:
+		panic(fmt.Sprintf("pmtilrtest: unsupported compression %v", compression))
+	}
+	a.compression = compression
+	return a
+}
+
+// WithTileType sets the tile type of the archive.
+func (a *Archive) WithTileType(tileType pmtilr.TileType) *Archive {
+	a.tileType = tileType
+	return a
+}
+
+// WithMetadata sets the JSON metadata of the archive, e.g. a pmtilr.Metadata.
+func (a *Archive) WithMetadata(metadata any) *Archive {
+	a.metadata = metadata
+	return a
+}
+
+// WithBounds sets the bounds of the archive in degrees.
+func (a *Archive) WithBounds(minLon, minLat, maxLon, maxLat float64) *Archive {
+	a.bounds = [4]float64{minLon, minLat, maxLon, maxLat}
+	return a
+}
+
+// WithLeafSize sets the number of entries per leaf directory, e.g. to test
+// leaf directory lookups with few tiles.
+func (a *Archive) WithLeafSize(n int) *Archive {
+	if n < 1 {
+		panic(fmt.Sprintf("pmtilrtest: invalid leaf size %d", n))
+	}
+	a.leafSize = n
+	return a
+}
+
+// Bytes serializes the archive. Identical tiles are stored once, and runs of
+// identical consecutive tiles share a single entry.
+//
+// The layout is: header, root directory, metadata, leaf directories, tile
+// data.
+func (a *Archive) Bytes() []byte {
+	var (
+		entries  []pmtilr.Entry
+		tileData []byte
+		contents = map[string]pmtilr.Entry{}
+		minZoom  = uint8(255)
+		maxZoom  uint8
+	)
+
+	for _, id := range slices.Sorted(maps.Keys(a.tiles)) {
+		z := id.Zoom()
+		minZoom, maxZoom = min(minZoom, z), max(maxZoom, z)
+
+		data := a.compress(a.tiles[id])
+
+		if n := len(entries); n > 0 {
+			last := &entries[n-1]
+			if last.TileID+pmtilr.TileID(last.RunLength) == id && bytes.Equal(tileData[last.Offset:last.Offset+last.Length], data) {
+				last.RunLength++
+				continue
+			}
+		}
+
+		entry, ok := contents[string(data)]
+		if !ok {
+			entry = pmtilr.Entry{Offset: uint64(len(tileData)), Length: uint64(len(data))}
+			contents[string(data)] = entry
+			tileData = append(tileData, data...)
+		}
+		entries = append(entries, pmtilr.Entry{
+			TileID:    id,
+			Offset:    entry.Offset,
+			Length:    entry.Length,
+			RunLength: 1,
+		})
+	}
+	if len(entries) == 0 {
+		minZoom = 0
+	}
+
+	root, leaves := a.directories(entries)
+
+	metadata, err := json.Marshal(a.metadata)
+	if err != nil {
+		panic(fmt.Sprintf("pmtilrtest: marshaling metadata: %v", err))
+	}
+	metadata = a.compress(metadata)
+
+	var addressed uint64
+	for _, e := range entries {
+		addressed += uint64(e.RunLength)
+	}
+
+	h := pmtilr.HeaderV3{
+		SpecVersion:         3,
+		RootOffset:          pmtilr.HeaderSizeBytes,
+		RootLength:          uint64(len(root)),
+		MetadataLength:      uint64(len(metadata)),
+		LeafDirectoryLength: uint64(len(leaves)),
+		TileDataLength:      uint64(len(tileData)),
+		AddressedTilesCount: addressed,
+		TileEntriesCount:    uint64(len(entries)),
+		TileContentsCount:   uint64(len(contents)),
+		Clustered:           true,
+		InternalCompression: a.compression,
+		TileCompression:     a.compression,
+		TileType:            a.tileType,
+		MinZoom:             minZoom,
+		MaxZoom:             maxZoom,
+		MinLonE7:            e7(a.bounds[0]),
+		MinLatE7:            e7(a.bounds[1]),
+		MaxLonE7:            e7(a.bounds[2]),
+		MaxLatE7:            e7(a.bounds[3]),
+		CenterZoom:          minZoom,
+		CenterLonE7:         e7((a.bounds[0] + a.bounds[2]) / 2),
+		CenterLatE7:         e7((a.bounds[1] + a.bounds[3]) / 2),
+	}
+	h.MetadataOffset = h.RootOffset + h.RootLength
+	h.LeafDirectoryOffset = h.MetadataOffset + h.MetadataLength
+	h.TileDataOffset = h.LeafDirectoryOffset + h.LeafDirectoryLength
+
+	buf := make([]byte, 0, h.TileDataOffset+h.TileDataLength)
+	buf = append(buf, HeaderBytes(h)...)
+	buf = append(buf, root...)
+	buf = append(buf, metadata...)
+	buf = append(buf, leaves...)
+	buf = append(buf, tileData...)
+
+	return buf
+}
+
+// directories serializes the entries into a root directory and, if there
+// are more entries than the leaf size, leaf directories.
+func (a *Archive) directories(entries []pmtilr.Entry) (root, leaves []byte) {
+	if len(entries) <= a.leafSize {
+		return a.compress(DirectoryBytes(entries)), nil
+	}
+
+	var rootEntries []pmtilr.Entry
+	for chunk := range slices.Chunk(entries, a.leafSize) {
+		leaf := a.compress(DirectoryBytes(chunk))
+		rootEntries = append(rootEntries, pmtilr.Entry{
+			TileID: chunk[0].TileID,
+			Offset: uint64(len(leaves)),
+			Length: uint64(len(leaf)),
+		})
+		leaves = append(leaves, leaf...)
+	}
+
+	return a.compress(DirectoryBytes(rootEntries)), leaves
+}
+
+func (a *Archive) compress(data []byte) []byte {
+	if a.compression != pmtilr.CompressionGZIP {
+		return data
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(data) //nolint:errcheck // writes to a bytes.Buffer do not fail
+	_ = zw.Close()        //nolint:errcheck
+	return buf.Bytes()
+}
+
+func e7(v float64) int32 {
+	return int32(v * 10_000_000)
+}
@@ -0,0 +1,102 @@
+package pmtilrtest_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestArchive(t *testing.T) {
+	tiles := map[[3]uint64][]byte{
+		{0, 0, 0}: []byte("root"),
+		{1, 0, 0}: []byte("same"),
+		{1, 0, 1}: []byte("same"),
+		{1, 1, 1}: []byte("same"),
+		{1, 1, 0}: []byte("other"),
+		{3, 2, 5}: []byte("deep"),
+	}
+
+	tests := []struct {
+		name        string
+		compression pmtilr.Compression
+		leafSize    int
+	}{
+		{name: "uncompressed", compression: pmtilr.CompressionNone},
+		{name: "gzip", compression: pmtilr.CompressionGZIP},
+		{name: "leaf directories", compression: pmtilr.CompressionGZIP, leafSize: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := pmtilrtest.NewArchive().
+				WithCompression(tt.compression).
+				WithMetadata(pmtilr.Metadata{Name: "test"})
+			if tt.leafSize > 0 {
+				archive = archive.WithLeafSize(tt.leafSize)
+			}
+			for zxy, data := range tiles {
+				archive = archive.WithTile(zxy[0], zxy[1], zxy[2], data)
+			}
+
+			source, err := pmtilr.NewSource(
+				t.Context(),
+				"memory",
+				pmtilr.WithRangeReader(archive.RangeReader()),
+				pmtilr.WithDisableInstrumentation(),
+			)
+			if err != nil {
+				t.Fatalf("creating source: %v", err)
+			}
+
+			header := source.Header()
+			if header.MinZoom != 0 || header.MaxZoom != 3 {
+				t.Errorf("zoom range = %d-%d, want 0-3", header.MinZoom, header.MaxZoom)
+			}
+			if header.AddressedTilesCount != uint64(len(tiles)) {
+				t.Errorf("addressed tiles = %d, want %d", header.AddressedTilesCount, len(tiles))
+			}
+			if header.TileContentsCount != 4 {
+				t.Errorf("tile contents = %d, want 4", header.TileContentsCount)
+			}
+			if got := source.Meta().Name; got != "test" {
+				t.Errorf("metadata name = %q, want %q", got, "test")
+			}
+
+			for zxy, want := range tiles {
+				data, err := source.Tile(t.Context(), zxy[0], zxy[1], zxy[2])
+				if err != nil {
+					t.Fatalf("reading tile %v: %v", zxy, err)
+				}
+				rc, err := pmtilr.Decompress(io.NopCloser(bytes.NewReader(data)), header.TileCompression)
+				if err != nil {
+					t.Fatalf("decompressing tile %v: %v", zxy, err)
+				}
+				got, err := io.ReadAll(rc)
+				_ = rc.Close()
+				if err != nil {
+					t.Fatalf("decompressing tile %v: %v", zxy, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("tile %v = %q, want %q", zxy, got, want)
+				}
+			}
+
+			if _, err := source.Tile(t.Context(), 2, 0, 0); !errors.Is(err, pmtilr.ErrTileNotFound) {
+				t.Errorf("expected ErrTileNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestArchiveUnsupportedCompression(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	pmtilrtest.NewArchive().WithCompression(pmtilr.CompressionBrotli)
+}
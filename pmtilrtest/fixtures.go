@@ -0,0 +1,90 @@
+package pmtilrtest
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// HeaderBytes serializes h into the 127 bytes of a PMTiles v3 header. The
+// Etag is not part of the header and is ignored.
+func HeaderBytes(h pmtilr.HeaderV3) []byte {
+	buf := make([]byte, 0, pmtilr.HeaderSizeBytes)
+
+	buf = append(buf, "PMTiles"...)
+	buf = append(buf, h.SpecVersion)
+	for _, v := range []uint64{
+		h.RootOffset, h.RootLength,
+		h.MetadataOffset, h.MetadataLength,
+		h.LeafDirectoryOffset, h.LeafDirectoryLength,
+		h.TileDataOffset, h.TileDataLength,
+		h.AddressedTilesCount, h.TileEntriesCount, h.TileContentsCount,
+	} {
+		buf = binary.LittleEndian.AppendUint64(buf, v)
+	}
+
+	var clustered byte
+	if h.Clustered {
+		clustered = 1
+	}
+	buf = append(buf,
+		clustered,
+		byte(h.InternalCompression),
+		byte(h.TileCompression),
+		byte(h.TileType),
+		h.MinZoom,
+		h.MaxZoom,
+	)
+	for _, v := range []int32{h.MinLonE7, h.MinLatE7, h.MaxLonE7, h.MaxLatE7} {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v)) //nolint:gosec
+	}
+	buf = append(buf, h.CenterZoom)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(h.CenterLonE7)) //nolint:gosec
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(h.CenterLatE7)) //nolint:gosec
+
+	return buf
+}
+
+// DirectoryBytes serializes entries, sorted by TileID, into an uncompressed
+// directory: count, tile id deltas, run lengths, lengths and offsets + 1, or
+// 0 if contiguous to the previous entry. Entries with a RunLength of 0 point
+// to leaf directories.
+func DirectoryBytes(entries []pmtilr.Entry) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(entries)))
+
+	var lastID pmtilr.TileID
+	for _, e := range entries {
+		buf = binary.AppendUvarint(buf, uint64(e.TileID-lastID))
+		lastID = e.TileID
+	}
+	for _, e := range entries {
+		buf = binary.AppendUvarint(buf, uint64(e.RunLength))
+	}
+	for _, e := range entries {
+		buf = binary.AppendUvarint(buf, e.Length)
+	}
+	for i, e := range entries {
+		if i > 0 && e.Offset == entries[i-1].Offset+entries[i-1].Length {
+			buf = binary.AppendUvarint(buf, 0)
+			continue
+		}
+		buf = binary.AppendUvarint(buf, e.Offset+1)
+	}
+
+	return buf
+}
+
+// FixtureArchive returns an archive with every tile from zoom 0 up to
+// maxZoom, each holding its own "z/x/y" as data.
+func FixtureArchive(maxZoom uint8) *Archive {
+	a := NewArchive()
+	for z := range uint64(maxZoom) + 1 {
+		for x := range uint64(1) << z {
+			for y := range uint64(1) << z {
+				a.WithTile(z, x, y, fmt.Appendf(nil, "%d/%d/%d", z, x, y))
+			}
+		}
+	}
+	return a
+}
@@ -0,0 +1,181 @@
+package pmtilrtest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// Response is the scripted result of a ReadRange call.
+type Response struct {
+	Data    []byte
+	Err     error
+	Latency time.Duration
+}
+
+// RangeReader is a scripted pmtilr.RangeReader. Every call is answered by,
+// in order of precedence:
+//  1. the next response queued with WithResponses,
+//  2. the response set for the exact range with WithRange,
+//  3. the error set with WithError,
+//  4. the requested range of the backing data.
+//
+// Calls are recorded and can be inspected with Calls. A RangeReader is safe
+// for concurrent use.
+type RangeReader struct {
+	mu          sync.Mutex
+	data        []byte
+	etag        string
+	latency     time.Duration
+	err         error
+	ranges      map[pmtilr.Range]Response
+	queue       []Response
+	calls       []pmtilr.Range
+	inflight    int
+	maxInflight int
+}
+
+// NewRangeReader returns a RangeReader serving ranges of data, e.g. from
+// Archive.Bytes.
+func NewRangeReader(data []byte) *RangeReader {
+	return &RangeReader{
+		data:   data,
+		ranges: map[pmtilr.Range]Response{},
+	}
+}
+
+// WithLatency delays every call by d, unless the context is canceled first.
+func (r *RangeReader) WithLatency(d time.Duration) *RangeReader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latency = d
+	return r
+}
+
+// WithError fails every call not answered by a scripted response with err.
+// Pass nil to serve the backing data again.
+func (r *RangeReader) WithError(err error) *RangeReader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+	return r
+}
+
+// WithETag makes the reader report etag, see pmtilr.ETagger.
+func (r *RangeReader) WithETag(etag string) *RangeReader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.etag = etag
+	return r
+}
+
+// WithRange answers every call for offset and length with resp.
+func (r *RangeReader) WithRange(offset, length uint64, resp Response) *RangeReader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ranges[pmtilr.NewRange(offset, length)] = resp
+	return r
+}
+
+// WithResponses queues responses answering the next calls in order,
+// regardless of the requested range. A Response without Data and Err
+// serves the backing data after its Latency, e.g. to slow down a single
+// call.
+func (r *RangeReader) WithResponses(responses ...Response) *RangeReader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue = append(r.queue, responses...)
+	return r
+}
+
+// ETag implements pmtilr.ETagger.
+func (r *RangeReader) ETag() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.etag
+}
+
+// Calls returns the ranges requested so far.
+func (r *RangeReader) Calls() []pmtilr.Range {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// MaxConcurrency returns the maximum number of calls in flight at the same
+// time so far, e.g. to test request coalescing.
+func (r *RangeReader) MaxConcurrency() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.maxInflight
+}
+
+// Reset clears the recorded calls.
+func (r *RangeReader) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+	r.maxInflight = 0
+}
+
+// ReadRange implements pmtilr.RangeReader.
+func (r *RangeReader) ReadRange(ctx context.Context, ranger pmtilr.Ranger) (io.ReadCloser, error) {
+	rng := pmtilr.NewRange(ranger.Offset(), ranger.Length())
+	resp, scripted := r.next(rng)
+	defer r.done()
+
+	if resp.Latency > 0 {
+		timer := time.NewTimer(resp.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if scripted && resp.Data != nil {
+		return io.NopCloser(bytes.NewReader(resp.Data)), nil
+	}
+
+	return NewBytesRangeReader(r.data).ReadRange(ctx, rng)
+}
+
+// next records the call and resolves its response.
+func (r *RangeReader) next(rng pmtilr.Range) (Response, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, rng)
+	r.inflight++
+	r.maxInflight = max(r.maxInflight, r.inflight)
+
+	resp, scripted := Response{Latency: r.latency, Err: r.err}, false
+	if len(r.queue) > 0 {
+		resp, r.queue, scripted = r.queue[0], r.queue[1:], true
+	} else if ranged, ok := r.ranges[rng]; ok {
+		resp, scripted = ranged, true
+	}
+	if resp.Latency == 0 {
+		resp.Latency = r.latency
+	}
+
+	return resp, scripted
+}
+
+func (r *RangeReader) done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inflight--
+}
@@ -0,0 +1,126 @@
+package pmtilrtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestRangeReader(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		reader  func() *pmtilrtest.RangeReader
+		timeout time.Duration
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "backing data",
+			reader: func() *pmtilrtest.RangeReader { return pmtilrtest.NewRangeReader([]byte("0123456789")) },
+			want:   "234",
+		},
+		{
+			name: "error",
+			reader: func() *pmtilrtest.RangeReader {
+				return pmtilrtest.NewRangeReader([]byte("0123456789")).WithError(errBoom)
+			},
+			wantErr: errBoom,
+		},
+		{
+			name: "range",
+			reader: func() *pmtilrtest.RangeReader {
+				return pmtilrtest.NewRangeReader([]byte("0123456789")).
+					WithError(errBoom).
+					WithRange(2, 3, pmtilrtest.Response{Data: []byte("abc")})
+			},
+			want: "abc",
+		},
+		{
+			name: "queued before range",
+			reader: func() *pmtilrtest.RangeReader {
+				return pmtilrtest.NewRangeReader([]byte("0123456789")).
+					WithRange(2, 3, pmtilrtest.Response{Data: []byte("abc")}).
+					WithResponses(pmtilrtest.Response{Err: errBoom})
+			},
+			wantErr: errBoom,
+		},
+		{
+			name: "latency exceeding deadline",
+			reader: func() *pmtilrtest.RangeReader {
+				return pmtilrtest.NewRangeReader([]byte("0123456789")).WithLatency(time.Second)
+			},
+			timeout: 10 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := t.Context()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			reader := tt.reader()
+			rc, err := reader.ReadRange(ctx, pmtilr.NewRange(2, 3))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer rc.Close()
+
+			got, _ := io.ReadAll(rc)
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if calls := reader.Calls(); len(calls) != 1 || calls[0] != pmtilr.NewRange(2, 3) {
+				t.Errorf("unexpected calls %v", calls)
+			}
+		})
+	}
+}
+
+func TestRangeReaderSource(t *testing.T) {
+	reader := pmtilrtest.NewRangeReader(pmtilrtest.FixtureArchive(2).Bytes()).WithETag(`"v1"`)
+
+	source, err := pmtilr.NewSource(
+		t.Context(),
+		"memory",
+		pmtilr.WithRangeReader(reader),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+	if got := source.Header().Etag; got != `"v1"` {
+		t.Errorf("etag = %s, want %s", got, `"v1"`)
+	}
+
+	// fail the next read, i.e. the root directory lookup.
+	reader.WithResponses(pmtilrtest.Response{Err: errors.New("flaky")})
+	if _, err := source.Tile(t.Context(), 2, 1, 3); err == nil {
+		t.Fatal("expected scripted error")
+	}
+
+	data, err := source.Tile(t.Context(), 2, 1, 3)
+	if err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+	if string(data) != "2/1/3" {
+		t.Errorf("tile = %q, want %q", data, "2/1/3")
+	}
+}
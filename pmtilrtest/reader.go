@@ -0,0 +1,18 @@
+package pmtilrtest
+
+import "github.com/iwpnd/pmtilr"
+
+// BytesRangeReader is a pmtilr.RangeReader reading from an in-memory
+// archive, see pmtilr.BytesRangeReader.
+type BytesRangeReader = pmtilr.BytesRangeReader
+
+// NewBytesRangeReader returns a BytesRangeReader reading from data.
+func NewBytesRangeReader(data []byte) *BytesRangeReader {
+	return pmtilr.NewBytesRangeReader(data)
+}
+
+// RangeReader serializes the archive and returns a reader over it, to be
+// used with pmtilr.WithRangeReader.
+func (a *Archive) RangeReader() *BytesRangeReader {
+	return NewBytesRangeReader(a.Bytes())
+}
@@ -0,0 +1,191 @@
+package pmtilrtest
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// ErrNotRecorded is returned by a ReplayRangeReader for a range that is not
+// covered by the recording.
+var ErrNotRecorded = errors.New("range not recorded")
+
+// recording is the file format shared by RecordingRangeReader and
+// ReplayRangeReader.
+type recording struct {
+	ETag   string          `json:"etag,omitempty"`
+	Ranges []recordedRange `json:"ranges"`
+	index  map[[2]uint64]int
+}
+
+type recordedRange struct {
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+	Data   []byte `json:"data"`
+}
+
+// RecordingRangeReader wraps a pmtilr.RangeReader, e.g. a S3RangeReader,
+// and records every range read so it can be saved with Save and served by
+// a ReplayRangeReader without access to the backend.
+type RecordingRangeReader struct {
+	reader pmtilr.RangeReader
+	path   string
+
+	mu  sync.Mutex
+	rec recording
+}
+
+// NewRecordingRangeReader returns a RecordingRangeReader reading from
+// reader and saving the recording to path.
+func NewRecordingRangeReader(reader pmtilr.RangeReader, path string) *RecordingRangeReader {
+	r := &RecordingRangeReader{
+		reader: reader,
+		path:   path,
+		rec:    recording{index: map[[2]uint64]int{}},
+	}
+	if e, ok := pmtilr.ReaderAs[pmtilr.ETagger](reader); ok {
+		r.rec.ETag = e.ETag()
+	}
+	return r
+}
+
+// ReadRange implements pmtilr.RangeReader.
+func (r *RecordingRangeReader) ReadRange(ctx context.Context, ranger pmtilr.Ranger) (io.ReadCloser, error) {
+	rc, err := r.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("recording range: %w", err)
+	}
+
+	key := [2]uint64{ranger.Offset(), ranger.Length()}
+
+	r.mu.Lock()
+	if _, ok := r.rec.index[key]; !ok {
+		r.rec.index[key] = len(r.rec.Ranges)
+		r.rec.Ranges = append(r.rec.Ranges, recordedRange{
+			Offset: key[0],
+			Length: key[1],
+			Data:   data,
+		})
+	}
+	r.mu.Unlock()
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// ETag implements pmtilr.ETagger with the etag of the wrapped reader, if
+// any, at the time of recording.
+func (r *RecordingRangeReader) ETag() string {
+	return r.rec.ETag
+}
+
+// Save writes the recording to the path, replacing it atomically.
+func (r *RecordingRangeReader) Save() error {
+	r.mu.Lock()
+	rec := r.rec
+	rec.Ranges = slices.Clone(r.rec.Ranges)
+	r.mu.Unlock()
+
+	slices.SortFunc(rec.Ranges, func(a, b recordedRange) int {
+		if a.Offset != b.Offset {
+			return cmp.Compare(a.Offset, b.Offset)
+		}
+		return cmp.Compare(a.Length, b.Length)
+	})
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling recording: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("saving recording: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close() //nolint:errcheck
+		return fmt.Errorf("saving recording: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving recording: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("saving recording: %w", err)
+	}
+
+	return nil
+}
+
+// ReplayRangeReader serves the ranges saved by a RecordingRangeReader. A
+// range that was not recorded exactly is served from a recorded range
+// containing it, otherwise ErrNotRecorded is returned.
+type ReplayRangeReader struct {
+	rec recording
+}
+
+// NewReplayRangeReader loads the recording at path.
+func NewReplayRangeReader(path string) (*ReplayRangeReader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading recording: %w", err)
+	}
+
+	var rec recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("loading recording %s: %w", path, err)
+	}
+	rec.index = make(map[[2]uint64]int, len(rec.Ranges))
+	for i, rr := range rec.Ranges {
+		if uint64(len(rr.Data)) > rr.Length {
+			return nil, fmt.Errorf(
+				"loading recording %s: range %d+%d holds %d bytes",
+				path, rr.Offset, rr.Length, len(rr.Data),
+			)
+		}
+		rec.index[[2]uint64{rr.Offset, rr.Length}] = i
+	}
+
+	return &ReplayRangeReader{rec: rec}, nil
+}
+
+// ReadRange implements pmtilr.RangeReader.
+func (r *ReplayRangeReader) ReadRange(ctx context.Context, ranger pmtilr.Ranger) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	offset, length := ranger.Offset(), ranger.Length()
+	if i, ok := r.rec.index[[2]uint64{offset, length}]; ok {
+		return io.NopCloser(bytes.NewReader(r.rec.Ranges[i].Data)), nil
+	}
+	for _, rr := range r.rec.Ranges {
+		if offset >= rr.Offset && offset+length <= rr.Offset+uint64(len(rr.Data)) {
+			start := offset - rr.Offset
+			return io.NopCloser(bytes.NewReader(rr.Data[start : start+length])), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %d+%d", ErrNotRecorded, offset, length)
+}
+
+// ETag implements pmtilr.ETagger with the etag of the recorded backend.
+func (r *ReplayRangeReader) ETag() string {
+	return r.rec.ETag
+}
@@ -0,0 +1,74 @@
+package pmtilrtest_test
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.json")
+
+	backend := pmtilrtest.NewRangeReader(pmtilrtest.FixtureArchive(3).Bytes()).WithETag(`"v1"`)
+	recorder := pmtilrtest.NewRecordingRangeReader(backend, path)
+
+	source, err := pmtilr.NewSource(
+		t.Context(),
+		"recorded",
+		pmtilr.WithRangeReader(recorder),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+	if _, err := source.Tile(t.Context(), 3, 2, 5); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("saving recording: %v", err)
+	}
+
+	replay, err := pmtilrtest.NewReplayRangeReader(path)
+	if err != nil {
+		t.Fatalf("loading recording: %v", err)
+	}
+
+	source, err = pmtilr.NewSource(
+		t.Context(),
+		"replayed",
+		pmtilr.WithRangeReader(replay),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating replayed source: %v", err)
+	}
+	if got := source.Header().Etag; got != `"v1"` {
+		t.Errorf("etag = %s, want %s", got, `"v1"`)
+	}
+
+	data, err := source.Tile(t.Context(), 3, 2, 5)
+	if err != nil {
+		t.Fatalf("reading replayed tile: %v", err)
+	}
+	if string(data) != "3/2/5" {
+		t.Errorf("tile = %q, want %q", data, "3/2/5")
+	}
+
+	// a sub range of the recorded header.
+	rc, err := replay.ReadRange(t.Context(), pmtilr.NewRange(0, 7))
+	if err != nil {
+		t.Fatalf("reading sub range: %v", err)
+	}
+	magic, _ := io.ReadAll(rc)
+	if string(magic) != "PMTiles" {
+		t.Errorf("sub range = %q, want %q", magic, "PMTiles")
+	}
+
+	if _, err := replay.ReadRange(t.Context(), pmtilr.NewRange(1<<20, 10)); !errors.Is(err, pmtilrtest.ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package pmtilr
+
+import (
+	"bytes"
+	"io"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// poolingDisabled bypasses all internal pools, see SetBufferPooling.
+var poolingDisabled atomic.Bool
+
+// SetBufferPooling enables or disables the internal pools of directory
+// readers, (de)compressors, read and key buffers, e.g. to debug with the
+// race detector or to analyze leaks. The pools are shared by all Sources,
+// so the setting is global to the process. Pooling is enabled by default.
+func SetBufferPooling(enabled bool) {
+	poolingDisabled.Store(!enabled)
+}
+
+// pool is a typed sync.Pool that allocates fresh values and drops returned
+// ones while pooling is disabled.
+type pool[T any] struct {
+	pool  sync.Pool
+	alloc func() T
+}
+
+func newPool[T any](alloc func() T) *pool[T] {
+	p := &pool[T]{alloc: alloc}
+	p.pool.New = func() any { return alloc() }
+	return p
+}
+
+func (p *pool[T]) Get() T {
+	if poolingDisabled.Load() {
+		return p.alloc()
+	}
+	return p.pool.Get().(T) //nolint:errcheck,forcetypeassert
+}
+
+func (p *pool[T]) Put(v T) {
+	if poolingDisabled.Load() {
+		return
+	}
+	p.pool.Put(v)
+}
+
+const (
+	// minBufferClass and maxBufferClass bound the size classes of pooled
+	// buffers as powers of two, 1 KiB to 8 MiB. Larger buffers are not
+	// pooled.
+	minBufferClass = 10
+	maxBufferClass = 23
+
+	// compressionRatioHint estimates the decompressed size of compressed
+	// payloads from their length.
+	compressionRatioHint = 4
+)
+
+// bufferPools holds *bytes.Buffer by size class, so reads of similarly
+// sized payloads reuse buffers instead of growing fresh ones.
+var bufferPools = func() (pools [maxBufferClass - minBufferClass + 1]*pool[*bytes.Buffer]) {
+	for i := range pools {
+		size := 1 << (minBufferClass + i)
+		pools[i] = newPool(func() *bytes.Buffer { return bytes.NewBuffer(make([]byte, 0, size)) })
+	}
+	return pools
+}()
+
+// bufferClass returns the index of the smallest size class holding size
+// bytes, or false if size exceeds the largest class.
+func bufferClass(size uint64) (int, bool) {
+	class := max(bits.Len64(max(size, 1)-1), minBufferClass)
+	if class > maxBufferClass {
+		return 0, false
+	}
+	return class - minBufferClass, true
+}
+
+// acquireBuffer returns an empty buffer with a capacity of at least
+// sizeHint bytes, pooled by size class. Hints come from untrusted header
+// fields, so hints beyond the largest class get a buffer of that class
+// size, unpooled, which grows as bytes are actually read.
+func acquireBuffer(sizeHint uint64) *bytes.Buffer {
+	class, ok := bufferClass(sizeHint)
+	if !ok {
+		return bytes.NewBuffer(make([]byte, 0, 1<<maxBufferClass))
+	}
+	return bufferPools[class].Get()
+}
+
+// releaseBuffer returns buf to the pool of the largest size class its
+// capacity holds. Buffers that grew beyond the largest class are dropped.
+func releaseBuffer(buf *bytes.Buffer) {
+	size := buf.Cap()
+	if size < 1<<minBufferClass || size > 1<<maxBufferClass {
+		return
+	}
+	buf.Reset()
+	bufferPools[bits.Len(uint(size))-1-minBufferClass].Put(buf)
+}
+
+// decompressedSizeHint estimates the decompressed size of a payload of
+// length bytes.
+func decompressedSizeHint(length uint64, compression Compression) uint64 {
+	switch compression {
+	case CompressionNone, CompressionUnknown:
+		return length
+	default:
+		return length * compressionRatioHint
+	}
+}
+
+// readAllSized reads r to EOF into a pooled buffer pre-sized by sizeHint
+// and returns a copy of exactly the bytes read.
+func readAllSized(r io.Reader, sizeHint uint64) ([]byte, error) {
+	// ReadFrom grows buffers with less than MinRead bytes left
+	buf := acquireBuffer(sizeHint + bytes.MinRead)
+	defer releaseBuffer(buf)
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
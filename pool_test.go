@@ -0,0 +1,83 @@
+package pmtilr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPoolingDisabled(t *testing.T) {
+	t.Cleanup(func() { SetBufferPooling(true) })
+
+	p := newPool(func() *[]byte {
+		buf := make([]byte, 0, 8)
+		return &buf
+	})
+
+	tests := []struct {
+		name     string
+		disabled bool
+	}{
+		{name: "pooled"},
+		{name: "disabled", disabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetBufferPooling(!tt.disabled)
+
+			// sync.Pool may drop values at any time, so only a reused
+			// value proves pooling, and any reuse while disabled fails.
+			reused := false
+			for range 100 {
+				v := p.Get()
+				p.Put(v)
+				if p.Get() == v {
+					reused = true
+				}
+			}
+
+			if reused == tt.disabled {
+				t.Errorf("reused = %v with pooling disabled = %v", reused, tt.disabled)
+			}
+		})
+	}
+}
+
+func TestAcquireBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		hint    uint64
+		wantCap int
+	}{
+		{name: "below smallest class", hint: 10, wantCap: 1 << minBufferClass},
+		{name: "exact class", hint: 1 << 16, wantCap: 1 << 16},
+		{name: "between classes", hint: 1<<16 + 1, wantCap: 1 << 17},
+		{name: "beyond largest class", hint: 1<<maxBufferClass + 1, wantCap: 1 << maxBufferClass},
+		{name: "untrusted huge hint", hint: 1 << 50, wantCap: 1 << maxBufferClass},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := acquireBuffer(tt.hint)
+			if buf.Len() != 0 || buf.Cap() < tt.wantCap {
+				t.Errorf("expected empty buffer of %d bytes, got %d of %d", tt.wantCap, buf.Len(), buf.Cap())
+			}
+			buf.WriteString("data")
+			releaseBuffer(buf)
+		})
+	}
+}
+
+func TestReadAllSized(t *testing.T) {
+	data := bytes.Repeat([]byte("pmtiles"), 1000)
+
+	for _, hint := range []uint64{0, 100, uint64(len(data)), 1 << 20} {
+		got, err := readAllSized(bytes.NewReader(data), hint)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("hint %d: got %d bytes, want %d", hint, len(got), len(data))
+		}
+	}
+}
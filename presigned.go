@@ -0,0 +1,184 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iwpnd/rip"
+)
+
+// URLFunc mints a URL to read an archive from, e.g. a fresh presigned S3 or
+// GCS URL.
+type URLFunc = func(ctx context.Context) (string, error)
+
+// PresignedRangeReader implements RangeReader against presigned URLs that
+// expire. When the current URL is rejected, a fresh URL is minted and the
+// read is retried once, so long-running servers can keep reading archives
+// shared via presigned links.
+//
+// The validators pinned by the underlying HTTPRangeReader are carried over
+// to readers of refreshed URLs.
+type PresignedRangeReader struct {
+	mint    URLFunc
+	options []rip.Option
+
+	mu     sync.RWMutex
+	reader *HTTPRangeReader
+}
+
+// NewPresignedRangeReader mints the initial URL and returns a PresignedRangeReader.
+// Options are applied to every HTTPRangeReader created for a minted URL.
+func NewPresignedRangeReader(
+	ctx context.Context,
+	mint URLFunc,
+	options ...rip.Option,
+) (*PresignedRangeReader, error) {
+	p := &PresignedRangeReader{mint: mint, options: options}
+
+	reader, err := p.newReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.reader = reader
+
+	return p, nil
+}
+
+func (p *PresignedRangeReader) newReader(ctx context.Context) (*HTTPRangeReader, error) {
+	u, err := p.mint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("minting presigned url: %w", err)
+	}
+	return NewHTTPRangeReader(u, p.options...)
+}
+
+// ReadRange reads the range from the current URL, refreshing it once if the
+// server rejects it as expired.
+func (p *PresignedRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	p.mu.RLock()
+	reader := p.reader
+	p.mu.RUnlock()
+
+	rc, err := reader.ReadRange(ctx, ranger)
+	if !isExpiredURLError(err) {
+		return rc, err
+	}
+
+	reader, rerr := p.refresh(ctx, reader)
+	if rerr != nil {
+		return nil, errors.Join(err, rerr)
+	}
+
+	return reader.ReadRange(ctx, ranger)
+}
+
+// refresh replaces the failed reader, unless another caller already did so.
+func (p *PresignedRangeReader) refresh(ctx context.Context, failed *HTTPRangeReader) (*HTTPRangeReader, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.reader != failed {
+		return p.reader, nil
+	}
+
+	reader, err := p.newReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	failed.mu.RLock()
+	reader.etag, reader.lastModified = failed.etag, failed.lastModified
+	failed.mu.RUnlock()
+	reader.ifRange = failed.ifRange
+
+	p.reader = reader
+	// reads in flight keep their connections, only idle ones are closed.
+	_ = failed.Close() //nolint:errcheck
+
+	return reader, nil
+}
+
+// Unwrap returns the reader of the current URL, see Unwrapper.
+func (p *PresignedRangeReader) Unwrap() RangeReader {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.reader
+}
+
+// Close closes the idle connections of the reader of the current URL.
+func (p *PresignedRangeReader) Close() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.reader.Close()
+}
+
+// ErrURLExpired is returned by HTTP based RangeReaders when a presigned URL
+// is rejected after the expiry encoded in its query.
+var ErrURLExpired = errors.New("presigned url expired")
+
+// statusError returns the error for an unexpected status code, matching
+// ErrURLExpired if the URL is presigned and rejected after its expiry.
+func (h *HTTPRangeReader) statusError(statusCode int) error {
+	err := &UpstreamStatusError{StatusCode: statusCode}
+	if h.expires.IsZero() || time.Now().Before(h.expires) || !isExpiredURLError(err) {
+		return err
+	}
+	return fmt.Errorf("%w at %s: %w", ErrURLExpired, h.expires.Format(time.RFC3339), err)
+}
+
+// presignedExpiry returns the expiry encoded in the query of a presigned
+// S3 (SigV4 or SigV2), GCS or Azure SAS URL, or the zero time.
+func presignedExpiry(rawURL string) time.Time {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}
+	}
+	query := u.Query()
+
+	for _, prefix := range []string{"X-Amz-", "X-Goog-"} {
+		date, err := time.Parse("20060102T150405Z", query.Get(prefix+"Date"))
+		if err != nil {
+			continue
+		}
+		seconds, err := strconv.Atoi(query.Get(prefix + "Expires"))
+		if err != nil {
+			continue
+		}
+		return date.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if expires, err := strconv.ParseInt(query.Get("Expires"), 10, 64); err == nil {
+		return time.Unix(expires, 0)
+	}
+	if query.Has("sig") {
+		if expires, err := time.Parse(time.RFC3339, query.Get("se")); err == nil {
+			return expires
+		}
+	}
+	return time.Time{}
+}
+
+// isExpiredURLError reports whether err is the response of an object store to
+// an expired presigned URL: S3 responds with 403, GCS with 400 ExpiredToken.
+func isExpiredURLError(err error) bool {
+	var statusErr *UpstreamStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	default:
+		return false
+	}
+}
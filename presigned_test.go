@@ -0,0 +1,175 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestPresignedRangeReader(t *testing.T) {
+	var validSignature atomic.Int32
+	validSignature.Store(1)
+
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("sig") != fmt.Sprint(validSignature.Load()) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("fake"))
+		}))
+	defer ts.Close()
+
+	var minted atomic.Int32
+	mint := func(_ context.Context) (string, error) {
+		return fmt.Sprintf("%s/map.pmtiles?sig=%d", ts.URL, minted.Add(1)), nil
+	}
+
+	reader, err := pmtilr.NewPresignedRangeReader(t.Context(), mint)
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	read := func() error {
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		result, _ := io.ReadAll(rc)
+		if string(result) != "fake" {
+			t.Fatalf("expected %s, got: %s", "fake", string(result))
+		}
+		return nil
+	}
+
+	if err := read(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// presigned url expires
+	validSignature.Store(2)
+
+	if err := read(); err != nil {
+		t.Fatalf("expected url to be refreshed, got: %s", err)
+	}
+	if minted.Load() != 2 {
+		t.Fatalf("expected 2 minted urls, got: %d", minted.Load())
+	}
+
+	// refreshed url is rejected as well
+	validSignature.Store(-1)
+
+	err = read()
+	var statusErr *pmtilr.UpstreamStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 UpstreamStatusError, got: %v", err)
+	}
+}
+
+func TestHTTPRangeReaderURLExpired(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	past := time.Now().Add(-time.Hour).UTC()
+	future := time.Now().Add(time.Hour).UTC()
+
+	tests := []struct {
+		name    string
+		query   string
+		expired bool
+	}{
+		{
+			name:    "expired S3 SigV4",
+			query:   "X-Amz-Date=" + past.Format("20060102T150405Z") + "&X-Amz-Expires=60",
+			expired: true,
+		},
+		{
+			name:  "valid S3 SigV4",
+			query: "X-Amz-Date=" + future.Format("20060102T150405Z") + "&X-Amz-Expires=60",
+		},
+		{
+			name:    "expired GCS",
+			query:   "X-Goog-Date=" + past.Format("20060102T150405Z") + "&X-Goog-Expires=60",
+			expired: true,
+		},
+		{
+			name:    "expired S3 SigV2",
+			query:   fmt.Sprintf("Expires=%d&Signature=abc", past.Unix()),
+			expired: true,
+		},
+		{
+			name:    "expired Azure SAS",
+			query:   "se=" + past.Format(time.RFC3339) + "&sig=abc",
+			expired: true,
+		},
+		{
+			name:  "not presigned",
+			query: "token=abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := pmtilr.NewHTTPRangeReader(ts.URL + "/map.pmtiles?" + tt.query)
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+
+			_, err = reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+			if !errors.Is(err, pmtilr.ErrUpstreamStatus) {
+				t.Fatalf("expected ErrUpstreamStatus, got %v", err)
+			}
+			if got := errors.Is(err, pmtilr.ErrURLExpired); got != tt.expired {
+				t.Fatalf("expected ErrURLExpired %v, got %v", tt.expired, err)
+			}
+		})
+	}
+}
+
+func TestWithURLRefresh(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "2" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("fake"))
+	}))
+	defer ts.Close()
+
+	var refreshes atomic.Int32
+	reader, err := pmtilr.NewRangeReader(
+		t.Context(),
+		ts.URL+"/map.pmtiles?sig=1",
+		pmtilr.WithURLRefresh(func(context.Context) (string, error) {
+			refreshes.Add(1)
+			return ts.URL + "/map.pmtiles?sig=2", nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	defer rc.Close()
+
+	if got := refreshes.Load(); got != 1 {
+		t.Fatalf("expected 1 refresh, got %d", got)
+	}
+}
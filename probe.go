@@ -0,0 +1,46 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Prober is implemented by RangeReaders that can check that their archive
+// is reachable, e.g. for readiness checks of services that should not
+// accept traffic before the backend is. File readers stat the file, remote
+// readers issue a small request against the backend. Probe finds it
+// behind decorators, bypassing their caches.
+type Prober interface {
+	Probe(ctx context.Context) error
+}
+
+// Probe checks that the archive behind reader is reachable, with the first
+// Prober in the chain of reader, see ReaderAs, otherwise by reading the
+// first byte from the innermost reader, bypassing caches of decorators.
+func Probe(ctx context.Context, reader RangeReader) error {
+	for {
+		if p, ok := reader.(Prober); ok {
+			return p.Probe(ctx)
+		}
+		u, ok := reader.(Unwrapper)
+		if !ok {
+			return probeRead(ctx, reader)
+		}
+		reader = u.Unwrap()
+	}
+}
+
+// probeRead reads the first byte of the archive behind reader.
+func probeRead(ctx context.Context, reader RangeReader) error {
+	rc, err := reader.ReadRange(ctx, NewRange(0, 1))
+	if err != nil {
+		return fmt.Errorf("probing archive: %w", err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return fmt.Errorf("probing archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package pmtilr_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestProbe(t *testing.T) {
+	errDown := errors.New("backend down")
+	archive := pmtilrtest.FixtureArchive(2).Bytes()
+
+	path := filepath.Join(t.TempDir(), "archive.pmtiles")
+	if err := os.WriteFile(path, archive, 0o600); err != nil {
+		t.Fatalf("writing archive: %s", err)
+	}
+	removed := filepath.Join(t.TempDir(), "removed.pmtiles")
+	if err := os.WriteFile(removed, archive, 0o600); err != nil {
+		t.Fatalf("writing archive: %s", err)
+	}
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-0/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("0"))
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	newReader := func(t *testing.T, uri string) pmtilr.RangeReader {
+		t.Helper()
+		reader, err := pmtilr.NewRangeReader(t.Context(), uri)
+		if err != nil {
+			t.Fatalf("creating reader: %s", err)
+		}
+		return reader
+	}
+
+	tests := []struct {
+		name    string
+		reader  func(t *testing.T) pmtilr.RangeReader
+		wantErr bool
+	}{
+		{
+			name:   "file",
+			reader: func(t *testing.T) pmtilr.RangeReader { return newReader(t, path) },
+		},
+		{
+			name: "removed file",
+			reader: func(t *testing.T) pmtilr.RangeReader {
+				reader := newReader(t, removed)
+				os.Remove(removed)
+				return reader
+			},
+			wantErr: true,
+		},
+		{
+			name:   "http",
+			reader: func(t *testing.T) pmtilr.RangeReader { return newReader(t, up.URL) },
+		},
+		{
+			name:    "http error",
+			reader:  func(t *testing.T) pmtilr.RangeReader { return newReader(t, down.URL) },
+			wantErr: true,
+		},
+		{
+			name: "reader without prober",
+			reader: func(_ *testing.T) pmtilr.RangeReader {
+				return pmtilrtest.NewRangeReader(archive)
+			},
+		},
+		{
+			name: "reader without prober failing",
+			reader: func(_ *testing.T) pmtilr.RangeReader {
+				return pmtilrtest.NewRangeReader(archive).WithError(errDown)
+			},
+			wantErr: true,
+		},
+		{
+			name: "decorator forwards probe",
+			reader: func(t *testing.T) pmtilr.RangeReader {
+				return pmtilr.NewRetryRangeReader(newReader(t, down.URL), pmtilr.RetryPolicy{MaxAttempts: 1})
+			},
+			wantErr: true,
+		},
+		{
+			name: "fallback with reachable secondary",
+			reader: func(t *testing.T) pmtilr.RangeReader {
+				return pmtilr.NewFallbackRangeReader(newReader(t, down.URL), newReader(t, up.URL))
+			},
+		},
+		{
+			name: "fallback with unreachable readers",
+			reader: func(t *testing.T) pmtilr.RangeReader {
+				return pmtilr.NewFallbackRangeReader(newReader(t, down.URL), newReader(t, down.URL))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pmtilr.Probe(t.Context(), tt.reader(t))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProbeBypassesCaches(t *testing.T) {
+	mock := pmtilrtest.NewRangeReader(pmtilrtest.FixtureArchive(2).Bytes())
+	reader, err := pmtilr.NewBlockCacheRangeReader(mock)
+	if err != nil {
+		t.Fatalf("creating reader: %s", err)
+	}
+
+	for range 2 {
+		if err := pmtilr.Probe(t.Context(), reader); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if got := len(mock.Calls()); got != 2 {
+		t.Errorf("expected every probe to reach the backend, got %d calls", got)
+	}
+}
+
+func TestSourceProbe(t *testing.T) {
+	source, err := pmtilr.NewSource(
+		t.Context(),
+		"",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+	)
+	if err != nil {
+		t.Fatalf("creating source: %s", err)
+	}
+	prober := source.(pmtilr.Prober)
+
+	if err := prober.Probe(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	source.(interface{ Close() }).Close()
+	if err := prober.Probe(t.Context()); !errors.Is(err, pmtilr.ErrSourceClosed) {
+		t.Errorf("expected ErrSourceClosed, got %v", err)
+	}
+}
+
+func TestRefreshingSourceProbe(t *testing.T) {
+	source, err := pmtilr.NewRefreshingSource(t.Context(), testArchive, nil)
+	if err != nil {
+		t.Fatalf("creating source: %s", err)
+	}
+	defer source.Close()
+
+	if err := source.Probe(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
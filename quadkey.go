@@ -0,0 +1,58 @@
+package pmtilr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TileIDToQuadkey returns the quadkey of the tile, as used by Bing and
+// Azure Maps to address tiles. Each digit of the quadkey selects one of the
+// four children of the tile at the previous zoom, so the tile at zoom 0 has
+// the empty quadkey.
+func TileIDToQuadkey(id TileID) (string, error) {
+	if !id.Valid() {
+		return "", fmt.Errorf("tile id %d exceeds zoom limit of %d", uint64(id), MaxZ)
+	}
+
+	z, x, y := id.ZXY()
+	var b strings.Builder
+	b.Grow(int(z)) //nolint:gosec // at most MaxZ
+	for i := z; i > 0; i-- {
+		mask := uint64(1) << (i - 1)
+		digit := byte('0')
+		if x&mask != 0 {
+			digit++
+		}
+		if y&mask != 0 {
+			digit += 2
+		}
+		b.WriteByte(digit)
+	}
+	return b.String(), nil
+}
+
+// QuadkeyToTileID returns the TileID of the tile addressed by quadkey, see
+// TileIDToQuadkey.
+func QuadkeyToTileID(quadkey string) (TileID, error) {
+	if len(quadkey) > MaxZ {
+		return 0, fmt.Errorf("quadkey %q exceeds zoom limit of %d", quadkey, MaxZ)
+	}
+
+	var x, y uint64
+	for i := range len(quadkey) {
+		x, y = x<<1, y<<1
+		switch quadkey[i] {
+		case '0':
+		case '1':
+			x |= 1
+		case '2':
+			y |= 1
+		case '3':
+			x |= 1
+			y |= 1
+		default:
+			return 0, fmt.Errorf("invalid quadkey digit %q in %q", quadkey[i], quadkey)
+		}
+	}
+	return NewTileID(uint64(len(quadkey)), x, y)
+}
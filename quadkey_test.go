@@ -0,0 +1,58 @@
+package pmtilr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestQuadkey(t *testing.T) {
+	tests := []struct {
+		name    string
+		z, x, y uint64
+		quadkey string
+	}{
+		{name: "root", z: 0, x: 0, y: 0, quadkey: ""},
+		{name: "zoom 1", z: 1, x: 1, y: 1, quadkey: "3"},
+		{name: "zoom 3", z: 3, x: 3, y: 5, quadkey: "213"},
+		{name: "max zoom", z: pmtilr.MaxZ, x: 1<<pmtilr.MaxZ - 1, y: 0, quadkey: strings.Repeat("1", pmtilr.MaxZ)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := pmtilr.NewTileID(tt.z, tt.x, tt.y)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			quadkey, err := pmtilr.TileIDToQuadkey(id)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if quadkey != tt.quadkey {
+				t.Fatalf("expected quadkey %q, got: %q", tt.quadkey, quadkey)
+			}
+
+			got, err := pmtilr.QuadkeyToTileID(quadkey)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != id {
+				t.Fatalf("expected %s, got: %s", id, got)
+			}
+		})
+	}
+}
+
+func TestQuadkeyErrors(t *testing.T) {
+	if _, err := pmtilr.TileIDToQuadkey(pmtilr.TileID(1 << 62)); err == nil {
+		t.Fatal("expected error for invalid tile id")
+	}
+
+	for _, quadkey := range []string{"014", "12a", strings.Repeat("0", pmtilr.MaxZ+1)} {
+		if id, err := pmtilr.QuadkeyToTileID(quadkey); err == nil {
+			t.Fatalf("expected error for %q, got: %s", quadkey, id)
+		}
+	}
+}
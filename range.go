@@ -1,23 +1,24 @@
 package pmtilr
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"net"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cespare/xxhash/v2"
 	"github.com/iwpnd/rip"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/mmap"
 )
 
@@ -79,15 +80,28 @@ func (r Range) Length() uint64 {
 	return r[indexSize]
 }
 
-// Validate ensures that the range size is positive.
-// Returns an error if Size() == 0.
+// Validate ensures that the range size is positive and that the range ends
+// within math.MaxInt64, so it can be passed to io.ReaderAt and friends.
+// Returns an error if Size() == 0 or the range overflows.
 func (r Range) Validate() error {
 	if r.Length() == 0 {
 		return errors.New("invalid range: size must be a positive integer")
 	}
+	if r.Length() > math.MaxInt64 || r.Offset() > math.MaxInt64-r.Length() {
+		return fmt.Errorf("invalid range: %d+%d overflows int64", r.Offset(), r.Length())
+	}
 	return nil
 }
 
+// bufferLen returns length as an int to size a buffer, failing if it does
+// not fit into the address space, e.g. above 2GiB on 32-bit platforms.
+func bufferLen(length uint64) (int, error) {
+	if length > math.MaxInt {
+		return 0, fmt.Errorf("range of %d bytes exceeds the address space", length)
+	}
+	return int(length), nil
+}
+
 // NewRange constructs a Range with the given offset and size.
 func NewRange(offset, size uint64) Range {
 	return Range{offset, size}
@@ -101,26 +115,87 @@ type RangeReader interface {
 	ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error)
 }
 
+// Unwrapper is implemented by RangeReaders decorating another RangeReader,
+// e.g. with retries, caching or instrumentation. Decorators do not forward
+// the capabilities of the reader they wrap, such as Versioner, ETagger,
+// Sizer or Prober; they are looked up along the chain with ReaderAs.
+type Unwrapper interface {
+	Unwrap() RangeReader
+}
+
+// ReaderAs returns the first reader implementing T in the chain of reader
+// and the readers it wraps, see Unwrapper.
+func ReaderAs[T any](reader RangeReader) (T, bool) {
+	for reader != nil {
+		if t, ok := reader.(T); ok {
+			return t, true
+		}
+		u, ok := reader.(Unwrapper)
+		if !ok {
+			break
+		}
+		reader = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// CloseReader closes the first reader implementing io.Closer in the chain
+// of reader. Decorators holding resources of their own, e.g. caches,
+// implement io.Closer and close the reader they wrap in turn.
+func CloseReader(reader RangeReader) error {
+	if c, ok := ReaderAs[io.Closer](reader); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // NewRangeReader parses a URI and returns an appropriate RangeReader implementation.
-// Supports local file URIs ("file://") and bare paths. Other schemes are not supported.
-func NewRangeReader(ctx context.Context, uri string) (RangeReader, error) {
+// Supports local file URIs ("file://") and bare paths, "http(s)://", "webdav(s)://",
+// "s3://", members of local or remote ZIP archives ("zip:///bundle.zip!/map.pmtiles",
+// "zip+https://host/bundle.zip!/map.pmtiles") and OCI artifacts
+// ("oci://registry/repository:tag"). Other schemes are not supported.
+func NewRangeReader(ctx context.Context, uri string, options ...RangeReaderOption) (RangeReader, error) {
 	u, err := ParseURI(uri)
 	if err != nil {
 		return nil, fmt.Errorf("parsing URI %q: %w", uri, err)
 	}
 
+	cfg := &rangeReaderConfig{}
+	for _, optFn := range options {
+		optFn(cfg)
+	}
+
 	switch u.Scheme() { //nolint:exhaustive
 	case SchemeHTTP, SchemeHTTPS:
-		return NewHTTPRangeReader(u.Raw().String())
-	case SchemeFileCwd, SchemeFile:
-		return NewFileRangeReader(u.FullPath())
-	case SchemeS3:
-		client, err := createS3Client(ctx)
+		opts, err := cfg.ripOptions()
 		if err != nil {
 			return nil, err
 		}
-		bucket, key := u.Host(), u.Path()
-		return NewS3RangeReader(bucket, strings.TrimPrefix(key, "/"), client)
+		raw := *u.Raw()
+		opts = append(userinfoAuth(&raw), opts...)
+		if cfg.urlRefresh != nil {
+			return cfg.presignedRangeReader(raw.String(), opts)
+		}
+		return cfg.httpRangeReader(NewHTTPRangeReader(raw.String(), opts...))
+	case SchemeWebDAV, SchemeWebDAVS:
+		opts, err := cfg.ripOptions()
+		if err != nil {
+			return nil, err
+		}
+		return cfg.httpRangeReader(NewWebDAVRangeReader(u.Raw().String(), opts...))
+	case SchemeOCI:
+		opts, err := cfg.ripOptions()
+		if err != nil {
+			return nil, err
+		}
+		return cfg.httpRangeReader(NewOCIRangeReader(ctx, u.Raw().String(), opts...))
+	case SchemeFileCwd, SchemeFile:
+		return NewFileRangeReader(u.FullPath())
+	case SchemeZip:
+		return newZipRangeReaderFromURI(ctx, u, options...)
+	case SchemeS3:
+		return cfg.limitBandwidth(newS3RangeReaderFromURI(ctx, u, cfg))
 	}
 
 	return nil, fmt.Errorf("unsupported URI scheme %q", u.Scheme())
@@ -128,18 +203,53 @@ func NewRangeReader(ctx context.Context, uri string) (RangeReader, error) {
 
 var ErrUpstreamStatus = errors.New("unexpected http status code")
 
+// UpstreamStatusError is returned by HTTP based RangeReaders when the server
+// responds with an unexpected status code. It matches ErrUpstreamStatus.
+type UpstreamStatusError struct {
+	StatusCode int
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("%s: %d", ErrUpstreamStatus, e.StatusCode)
+}
+
+func (e *UpstreamStatusError) Unwrap() error {
+	return ErrUpstreamStatus
+}
+
 // HTTPRangeReader performs HTTP range requests against a single host
 // using a persistent rip.Client.
+//
+// The validators (ETag, Last-Modified) of the first successful response are
+// pinned and sent as If-Match/If-Unmodified-Since on every subsequent request,
+// so a republished archive surfaces as ErrArchiveChanged instead of torn
+// reads across two archive versions.
 type HTTPRangeReader struct {
-	c *rip.Client
+	c         *rip.Client
+	transport *http.Transport // pooled connections, unless set by options
+	host      string
+	expires   time.Time // expiry of a presigned URL, if any
+	ifRange   bool
+
+	// authenticate answers the WWW-Authenticate challenge of a 401 with a
+	// new Authorization header, e.g. a fresh registry token. If nil, a 401
+	// is returned as is.
+	authenticate func(ctx context.Context, challenge string) (string, error)
+
+	mu            sync.RWMutex
+	etag          string
+	lastModified  string
+	authorization string // set by authenticate, overrides default headers
 }
 
 // NewHTTPRangeReader returns an HTTPRangeReader configured for the given host.
 // A default timeout of 200ms is applied; callers may override it or supply
 // additional rip.Options which take precedence over defaults.
 func NewHTTPRangeReader(host string, options ...rip.Option) (*HTTPRangeReader, error) {
+	transport := newHTTPTransport()
 	defaultOpts := []rip.Option{
 		rip.WithTimeout(time.Second * 5),
+		rip.WithTransport(transport),
 	}
 	c, err := rip.NewClient(
 		strings.TrimSuffix(host, "/"),
@@ -150,32 +260,233 @@ func NewHTTPRangeReader(host string, options ...rip.Option) (*HTTPRangeReader, e
 	}
 
 	return &HTTPRangeReader{
-		c: c,
+		c:         c,
+		transport: transport,
+		host:      host,
+		expires:   presignedExpiry(host),
 	}, nil
 }
 
+// newHTTPTransport returns the transport of an HTTPRangeReader, matching
+// the defaults of rip.
+func newHTTPTransport() *http.Transport {
+	return &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// Close closes the idle pooled connections of the reader. Transports set
+// with rip.WithTransport or WithHTTPClient are left to their owner.
+func (h *HTTPRangeReader) Close() error {
+	if h.transport != nil {
+		h.transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// spanAttributes implements spanAttributer. Query and user info are left
+// out, as they may carry credentials, e.g. of presigned URLs.
+func (h *HTTPRangeReader) spanAttributes() []attribute.KeyValue {
+	u, err := url.Parse(h.host)
+	if err != nil {
+		return nil
+	}
+	u.RawQuery, u.User, u.Fragment = "", nil, ""
+	return []attribute.KeyValue{
+		attribute.String("pmtilr.reader.scheme", u.Scheme),
+		attribute.String("pmtilr.http.url", u.String()),
+	}
+}
+
+// ETag returns the pinned ETag of the remote archive, or an empty string
+// if no response carrying an ETag has been received yet.
+func (h *HTTPRangeReader) ETag() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.etag
+}
+
 // ReadRange fetches a byte range from the upstream host.
 // The caller is responsible for closing the returned io.ReadCloser.
 //
-// Returns an error if the request fails or the server responds with a
-// non-success status code (> 399).
+// Returns ErrArchiveChanged if the server rejects the pinned validators
+// with 412 Precondition Failed, or in If-Range mode responds with the full
+// archive, and an error if the request fails or the server responds with
+// any other non-success status code (> 399).
 func (h *HTTPRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
-	req := h.c.NR().SetHeader("Range", bytesRange(ranger.Offset(), ranger.Length()))
-	res, err := req.Execute(ctx, "GET", "")
+	var conditional bool
+	res, err := h.execute(ctx, "GET", func() *rip.Request {
+		req := newRequest(h.c).SetHeader("Range", bytesRange(ranger.Offset(), ranger.Length()))
+		conditional = h.setPreconditions(req)
+		return req
+	})
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode() == http.StatusPreconditionFailed ||
+		(h.ifRange && conditional && res.StatusCode() == http.StatusOK) {
+		_ = res.Close() //nolint:errcheck
+		return nil, fmt.Errorf("%w: etag %q no longer matches", ErrArchiveChanged, h.ETag())
+	}
 	if res.IsError() {
-		return nil, fmt.Errorf("%w: %d", ErrUpstreamStatus, res.StatusCode())
+		_ = res.Close() //nolint:errcheck
+		return nil, h.statusError(res.StatusCode())
 	}
 
+	h.pin(res.Header())
+
 	return res.RawBody(), nil
 }
 
+// Version returns the current ETag of the remote archive, or its
+// Last-Modified date if the server sends no ETag. Unlike ETag it is not
+// pinned and reflects the archive currently served.
+func (h *HTTPRangeReader) Version(ctx context.Context) (string, error) {
+	res, err := h.execute(ctx, "HEAD", func() *rip.Request { return newRequest(h.c) })
+	if err != nil {
+		return "", err
+	}
+	defer res.Close() //nolint:errcheck
+	if res.IsError() {
+		return "", h.statusError(res.StatusCode())
+	}
+
+	if etag := res.Header().Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	if modified := res.Header().Get("Last-Modified"); modified != "" {
+		return modified, nil
+	}
+	return "", errors.New("upstream sends neither ETag nor Last-Modified")
+}
+
+// Size returns the size of the remote archive from the Content-Range of a
+// single byte read, as not every server answers HEAD requests, e.g. for
+// presigned URLs. The pinned validators are sent, so the size is that of
+// the pinned archive.
+func (h *HTTPRangeReader) Size(ctx context.Context) (uint64, error) {
+	var conditional bool
+	res, err := h.execute(ctx, "GET", func() *rip.Request {
+		req := newRequest(h.c).SetHeader("Range", bytesRange(0, 1))
+		conditional = h.setPreconditions(req)
+		return req
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	switch {
+	case res.StatusCode() == http.StatusPreconditionFailed ||
+		(h.ifRange && conditional && res.StatusCode() == http.StatusOK):
+		return 0, fmt.Errorf("%w: etag %q no longer matches", ErrArchiveChanged, h.ETag())
+	case res.IsError():
+		return 0, h.statusError(res.StatusCode())
+	case res.StatusCode() == http.StatusOK:
+		// the server ignored the range and sends the whole archive.
+		size, err := strconv.ParseUint(res.Header().Get("Content-Length"), 10, 64)
+		if err != nil {
+			return 0, errors.New("upstream sends neither Content-Range nor Content-Length")
+		}
+		return size, nil
+	}
+	return contentRangeSize(res.Header().Get("Content-Range"))
+}
+
+// Probe requests the first byte of the archive with the pinned validators,
+// as Size does.
+func (h *HTTPRangeReader) Probe(ctx context.Context) error {
+	if _, err := h.Size(ctx); err != nil {
+		return fmt.Errorf("probing archive: %w", err)
+	}
+	return nil
+}
+
+// execute sends the request built by build. If the server answers 401
+// and the reader can authenticate, the challenge is answered and the request
+// is rebuilt and sent once more with the new credentials.
+func (h *HTTPRangeReader) execute(ctx context.Context, method string, build func() *rip.Request) (*rip.Response, error) {
+	res, err := h.authorize(build()).Execute(ctx, method, "")
+	if err != nil || h.authenticate == nil || res.StatusCode() != http.StatusUnauthorized {
+		return res, err
+	}
+
+	authorization, err := h.authenticate(ctx, res.Header().Get("WWW-Authenticate"))
+	_ = res.Close() //nolint:errcheck
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	h.authorization = authorization
+	h.mu.Unlock()
+
+	return h.authorize(build()).Execute(ctx, method, "")
+}
+
+// authorize replaces the Authorization header of the request with the one
+// obtained by authenticate, if any.
+func (h *HTTPRangeReader) authorize(req *rip.Request) *rip.Request {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.authorization != "" {
+		req.Header.Set("Authorization", h.authorization)
+	}
+	return req
+}
+
+// setPreconditions attaches the pinned validators to the request and reports
+// whether any were attached. Weak ETags cannot be used with If-Match or
+// If-Range, Last-Modified is used as a fallback instead.
+func (h *HTTPRangeReader) setPreconditions(req *rip.Request) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ifMatch, ifUnmodified := "If-Match", "If-Unmodified-Since"
+	if h.ifRange {
+		ifMatch, ifUnmodified = "If-Range", "If-Range"
+	}
+
+	switch {
+	case h.etag != "" && !strings.HasPrefix(h.etag, "W/"):
+		req.SetHeader(ifMatch, h.etag)
+	case h.lastModified != "":
+		req.SetHeader(ifUnmodified, h.lastModified)
+	default:
+		return false
+	}
+	return true
+}
+
+// pin captures the validators of the first response that carries any.
+func (h *HTTPRangeReader) pin(header http.Header) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.etag != "" || h.lastModified != "" {
+		return
+	}
+	h.etag = header.Get("ETag")
+	h.lastModified = header.Get("Last-Modified")
+}
+
+// unpin drops the pinned validators, so the next response pins the archive
+// currently served, e.g. after a RefreshingSource detected a new version.
+func (h *HTTPRangeReader) unpin() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.etag, h.lastModified = "", ""
+}
+
 // FileRangeReader implements RangeReader by reading from an io.ReaderAt (file).
 // It interprets Ranger.Offset() and Ranger.Size() to slice the file.
 type FileRangeReader struct {
 	file io.ReaderAt
+	path string
 }
 
 // NewFileRangeReader opens the file at the given path and returns a FileRangeReader.
@@ -185,7 +496,15 @@ func NewFileRangeReader(path string) (*FileRangeReader, error) {
 	if err != nil {
 		return nil, fmt.Errorf("FileRrangeReader opening file at path %s: %w", path, err)
 	}
-	return &FileRangeReader{file: f}, nil
+	return &FileRangeReader{file: f, path: filePath}, nil
+}
+
+// Close closes the file.
+func (f *FileRangeReader) Close() error {
+	if c, ok := f.file.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // ReadRange reads bytes from the underlying file at the specified range.
@@ -194,13 +513,51 @@ func (f *FileRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.Read
 	if err := ranger.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid ranger: %w", err)
 	}
-	return io.NopCloser(
-		io.NewSectionReader(
-			f.file, int64(ranger.Offset()), int64(ranger.Length()), //nolint:gosec
-		),
+	return newFileSection(
+		f.file, int64(ranger.Offset()), int64(ranger.Length()), //nolint:gosec
 	), nil
 }
 
+// Size returns the size of the opened file.
+func (f *FileRangeReader) Size(_ context.Context) (uint64, error) {
+	if s, ok := f.file.(interface{ Stat() (os.FileInfo, error) }); ok {
+		info, err := s.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("stat %s: %w", f.path, err)
+		}
+		return uint64(info.Size()), nil //nolint:gosec // sizes are not negative
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return 0, fmt.Errorf("stat %s: %w", f.path, err)
+	}
+	return uint64(info.Size()), nil //nolint:gosec // sizes are not negative
+}
+
+// spanAttributes implements spanAttributer.
+func (f *FileRangeReader) spanAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("pmtilr.reader.scheme", SchemeFile.String()),
+		attribute.String("pmtilr.file.path", f.path),
+	}
+}
+
+// Version returns the size and modification time of the file, which change
+// whenever the archive is replaced.
+func (f *FileRangeReader) Version(_ context.Context) (string, error) {
+	return fileVersion(f.path)
+}
+
+// Probe stats the file at its path, e.g. to detect a deleted archive or an
+// unavailable network mount.
+func (f *FileRangeReader) Probe(_ context.Context) error {
+	if _, err := os.Stat(f.path); err != nil {
+		return fmt.Errorf("probing archive: %w", err)
+	}
+	return nil
+}
+
 type MMapFileRangeReader struct {
 	file *mmap.ReaderAt
 }
@@ -225,98 +582,94 @@ func (f *MMapFileRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.
 	), nil
 }
 
-// S3Client is an interface providing methods used by the S3RangeReader.
-type S3Client interface {
-	GetObject(
-		ctx context.Context,
-		params *s3.GetObjectInput,
-		optFns ...func(*s3.Options),
-	) (*s3.GetObjectOutput, error)
-}
-
-func newDefaultS3HTTPClient() *awshttp.BuildableClient {
-	return awshttp.NewBuildableClient().
-		WithTransportOptions(func(tr *http.Transport) {
-			// from SDK default 100
-			tr.MaxIdleConns = 100
-			// from SDK default 10, to avoid tcp+tls handshake past 10concurrent requests
-			tr.MaxIdleConnsPerHost = 100
-			// from unset
-			tr.ResponseHeaderTimeout = 5 * time.Second
-			// from 10s
-			tr.TLSHandshakeTimeout = 3 * time.Second
-		}).
-		WithDialerOptions(func(d *net.Dialer) {
-			d.Timeout = 3 * time.Second // fail-fast connect
-		})
-}
-
-func createS3Client(ctx context.Context) (S3Client, error) {
-	cfg, err := config.LoadDefaultConfig(
-		ctx,
-		config.WithHTTPClient(newDefaultS3HTTPClient()),
-	)
-	if err != nil {
-		return nil, err
-	}
+// Close unmaps the file.
+func (f *MMapFileRangeReader) Close() error {
+	return f.file.Close()
+}
 
-	return s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.UsePathStyle = true
-	}), nil
+// Size returns the size of the mapped file.
+func (f *MMapFileRangeReader) Size(_ context.Context) (uint64, error) {
+	return uint64(f.file.Len()), nil //nolint:gosec // sizes are not negative
 }
 
-// S3RangeReader implements RangeReader by reading from an S3 bucket
-type S3RangeReader struct {
-	client S3Client
-	bucket string
-	key    string
+// ReaderAtRangeReader adapts any io.ReaderAt of a known size, e.g. a custom
+// FUSE mount or a decrypting reader, to a RangeReader.
+type ReaderAtRangeReader struct {
+	r    io.ReaderAt
+	size int64
 }
 
-// NewS3RangeReader creates a S3RangeReader implementing RangeReader.
-func NewS3RangeReader(bucket, key string, client S3Client) (*S3RangeReader, error) {
-	return &S3RangeReader{
-		bucket: bucket,
-		key:    key,
-		client: client,
-	}, nil
+// NewReaderAtRangeReader returns a ReaderAtRangeReader reading the first
+// size bytes of r.
+func NewReaderAtRangeReader(r io.ReaderAt, size int64) *ReaderAtRangeReader {
+	return &ReaderAtRangeReader{r: r, size: max(size, 0)}
 }
 
-// ReadRange reads bytes from the underlying S3 object at the specified range.
-// It validates the Ranger and returns a ReadCloser for streaming access.
-func (s *S3RangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+// ReadRange returns a reader over the range. Like a file, ranges beyond
+// size are truncated. The reader copies with sendfile if r is an *os.File.
+func (r *ReaderAtRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
 	if err := ranger.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid ranger: %w", err)
 	}
 
-	byteRange := bytesRange(ranger.Offset(), ranger.Length())
-	output, err := s.client.GetObject(ctx,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.bucket),
-			Key:    aws.String(s.key),
-			Range:  aws.String(byteRange),
-		},
-		disableResponseValidation,
-	)
-	if err != nil {
-		return nil, err
+	// Validate guarantees the range fits into int64.
+	offset := min(int64(ranger.Offset()), r.size)        //nolint:gosec
+	length := min(int64(ranger.Length()), r.size-offset) //nolint:gosec
+
+	return newFileSection(r.r, offset, length), nil
+}
+
+// Size returns the size r was created with.
+func (r *ReaderAtRangeReader) Size(_ context.Context) (uint64, error) {
+	return uint64(r.size), nil //nolint:gosec // clamped to be positive
+}
+
+// BytesRangeReader reads ranges of an archive held in memory, e.g. a small
+// archive embedded with go:embed, without any syscalls.
+type BytesRangeReader struct {
+	data []byte
+
+	etagOnce sync.Once
+	etag     string
+}
+
+// NewBytesRangeReader returns a BytesRangeReader reading from data. data
+// must not be modified afterwards.
+func NewBytesRangeReader(data []byte) *BytesRangeReader {
+	return &BytesRangeReader{data: data}
+}
+
+// ReadRange returns the bytes of the range. Like a file, ranges beyond the
+// end of data are truncated.
+func (b *BytesRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
 	}
 
-	return output.Body, nil
+	size := uint64(len(b.data))
+	start := min(ranger.Offset(), size)
+	end := min(start+ranger.Length(), size)
+
+	return io.NopCloser(bytes.NewReader(b.data[start:end])), nil
+}
+
+// Size returns the length of data.
+func (b *BytesRangeReader) Size(_ context.Context) (uint64, error) {
+	return uint64(len(b.data)), nil
 }
 
-// disableResponseValidation disables checksum validation on the response.  This
-// is necessary for S3 ReaderAt byte range requests as the responses to these do
-// not include checksums.  Not disabling checksums means that by default the AWS
-// SDK will log checksum failures.  We *could* disable this logging using
-// DisableLogOutputChecksumValidationSkipped but it seems cleaner to disable the
-// check full stop.
-func disableResponseValidation(o *s3.Options) {
-	o.ResponseChecksumValidation = aws.ResponseChecksumValidationUnset
+// ETag returns a hash of the data, computed on first use, so the directory
+// cache keys of the same archive are stable across processes.
+func (b *BytesRangeReader) ETag() string {
+	b.etagOnce.Do(func() {
+		b.etag = `"` + strconv.FormatUint(xxhash.Sum64(b.data), 16) + `"`
+	})
+	return b.etag
 }
 
 func bytesRange(offset, length uint64) string {
-	bufPtr, _ := keyBufPool.Get().(*[]byte) //nolint:errcheck
-	buf := (*bufPtr)[:0]                    // Reset length but keep capacity
+	bufPtr := keyBufPool.Get()
+	buf := (*bufPtr)[:0] // Reset length but keep capacity
 	defer keyBufPool.Put(bufPtr)
 
 	buf = append(buf, "bytes="...)
@@ -326,3 +679,52 @@ func bytesRange(offset, length uint64) string {
 
 	return string(buf)
 }
+
+// observedBody reports the number of bytes read from a body and the first
+// read error once it is closed, e.g. to end the span of a read.
+type observedBody struct {
+	rc   io.ReadCloser
+	done func(n int64, err error)
+	once sync.Once
+	n    int64
+	err  error
+}
+
+func newObservedBody(rc io.ReadCloser, done func(n int64, err error)) *observedBody {
+	return &observedBody{rc: rc, done: done}
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF && b.err == nil { //nolint:errorlint // io.EOF is returned unwrapped
+		b.err = err
+	}
+	return n, err
+}
+
+// WriteTo keeps the io.WriterTo of the body, e.g. sendfile of file readers.
+func (b *observedBody) WriteTo(w io.Writer) (int64, error) {
+	var (
+		n   int64
+		err error
+	)
+	if wt, ok := b.rc.(io.WriterTo); ok {
+		n, err = wt.WriteTo(w)
+	} else {
+		n, err = io.Copy(w, struct{ io.Reader }{b.rc})
+	}
+	b.n += n
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.rc.Close()
+	b.once.Do(func() {
+		b.done(b.n, b.err)
+	})
+	return err
+}
@@ -6,17 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/iwpnd/pmtilr"
 	"github.com/iwpnd/rip"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestHTTPRangeReader(t *testing.T) {
@@ -138,6 +140,92 @@ func TestHTTPRangeReader(t *testing.T) {
 	}
 }
 
+func TestHTTPRangeReaderETagPinning(t *testing.T) {
+	data := []byte("fake tile data")
+	etag := `"v1"`
+
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if match := r.Header.Get("If-Match"); match != "" && match != etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[0:4])
+		}))
+	defer ts.Close()
+
+	reader, err := pmtilr.NewHTTPRangeReader(ts.URL)
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+	if err != nil {
+		t.Fatalf("first read should not fail: %s", err)
+	}
+	rc.Close()
+
+	if reader.ETag() != `"v1"` {
+		t.Fatalf("expected pinned etag %q, got: %q", `"v1"`, reader.ETag())
+	}
+
+	// archive is republished
+	etag = `"v2"`
+
+	_, err = reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+	if !errors.Is(err, pmtilr.ErrArchiveChanged) {
+		t.Fatalf("expected ErrArchiveChanged, got: %v", err)
+	}
+}
+
+func TestHTTPRangeReaderIfRange(t *testing.T) {
+	data := []byte("fake tile data")
+	etag := `"v1"`
+
+	var ifMatch []string
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+			w.Header().Set("ETag", etag)
+			if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != etag {
+				w.WriteHeader(http.StatusOK)
+				w.Write(data)
+				return
+			}
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[0:4])
+		}))
+	defer ts.Close()
+
+	reader, err := pmtilr.NewRangeReader(t.Context(), ts.URL, pmtilr.WithIfRange())
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	for range 2 {
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+		if err != nil {
+			t.Fatalf("read should not fail: %s", err)
+		}
+		rc.Close()
+	}
+
+	// archive is republished
+	etag = `"v2"`
+
+	_, err = reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+	if !errors.Is(err, pmtilr.ErrArchiveChanged) {
+		t.Fatalf("expected ErrArchiveChanged, got: %v", err)
+	}
+	for _, match := range ifMatch {
+		if match != "" {
+			t.Errorf("expected no If-Match header in If-Range mode, got: %q", match)
+		}
+	}
+}
+
 func TestFileRangeReader(t *testing.T) {
 	testFileName := "testfile"
 	testData := []byte("This is some test data for the RangeReader implementation.")
@@ -255,129 +343,247 @@ func TestFileRangeReader(t *testing.T) {
 				t.Fatalf("expected %s, got: %s", tt.expectedData, string(result))
 			}
 		})
+
+		t.Run("bytes "+tt.name, func(t *testing.T) {
+			reader := pmtilr.NewBytesRangeReader(testData)
+
+			readCloser, err := reader.ReadRange(
+				t.Context(),
+				pmtilr.NewRange(uint64(tt.offset), uint64(tt.length)),
+			)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatal("expected error, and received error do not match")
+			}
+
+			result := []byte{}
+			if readCloser != nil {
+				defer readCloser.Close()
+				result, _ = io.ReadAll(readCloser)
+			}
+
+			if tt.expectedData != string(result) {
+				t.Fatalf("expected %s, got: %s", tt.expectedData, string(result))
+			}
+		})
 	}
 }
 
-func TestS3RangeReader(t *testing.T) {
-	bucketName := "test-bucket"
-	objectKey := "test-object"
-	testData := []byte("This is some test data for the RangeReader implementation.")
+func TestReaderAtRangeReader(t *testing.T) {
+	data := []byte("0123456789")
+	// the reader exposes only the first 8 bytes.
+	reader := pmtilr.NewReaderAtRangeReader(bytes.NewReader(data), 8)
 
 	tests := []struct {
-		name          string
-		offset        int64
-		length        int
-		expectedData  string
-		expectedError error
+		name           string
+		offset, length uint64
+		want           string
+	}{
+		{name: "within size", offset: 2, length: 3, want: "234"},
+		{name: "beyond size", offset: 6, length: 4, want: "67"},
+		{name: "past size", offset: 9, length: 1, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(tt.offset, tt.length))
+			if err != nil {
+				t.Fatalf("reading range: %v", err)
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("reading data: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBytesRangeReaderSource(t *testing.T) {
+	data, err := os.ReadFile(testArchive)
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	reader := pmtilr.NewBytesRangeReader(data)
+
+	if reader.ETag() == "" || reader.ETag() != pmtilr.NewBytesRangeReader(data).ETag() {
+		t.Errorf("expected stable etag, got %q", reader.ETag())
+	}
+
+	source, err := pmtilr.NewSource(t.Context(), "memory", pmtilr.WithRangeReader(reader))
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+	if source.Header().Etag != reader.ETag() {
+		t.Errorf("expected header etag %q, got %q", reader.ETag(), source.Header().Etag)
+	}
+	if _, err := source.Tile(t.Context(), 4, 3, 5); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+}
+
+func TestRangeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rng     pmtilr.Range
+		wantErr bool
+	}{
+		{name: "valid", rng: pmtilr.NewRange(127, 10)},
+		{name: "empty", rng: pmtilr.NewRange(0, 0), wantErr: true},
+		{name: "length overflows int64", rng: pmtilr.NewRange(0, math.MaxUint64), wantErr: true},
+		{name: "end overflows int64", rng: pmtilr.NewRange(math.MaxInt64, 1), wantErr: true},
+		{name: "end at max int64", rng: pmtilr.NewRange(math.MaxInt64-1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rng.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// closeRecorder is a RangeReader recording whether it was closed.
+type closeRecorder struct {
+	*pmtilr.BytesRangeReader
+	closed atomic.Bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestRangeReaderCloseForwarding(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap func(t *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader
 	}{
 		{
-			name:          "Read middle range",
-			offset:        5,
-			length:        10,
-			expectedData:  "is some te",
-			expectedError: nil,
+			name: "block cache",
+			wrap: func(t *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				r, err := pmtilr.NewBlockCacheRangeReader(inner)
+				if err != nil {
+					t.Fatalf("creating reader: %v", err)
+				}
+				return r
+			},
 		},
 		{
-			name:          "Read full range",
-			offset:        0,
-			length:        len(testData),
-			expectedData:  string(testData),
-			expectedError: nil,
+			name: "disk cache",
+			wrap: func(t *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				r, err := pmtilr.NewDiskCacheRangeReader(inner, t.TempDir(), 1<<20)
+				if err != nil {
+					t.Fatalf("creating reader: %v", err)
+				}
+				return r
+			},
 		},
 		{
-			name:          "Read beyond end",
-			offset:        int64(len(testData) - 5),
-			length:        50,
-			expectedData:  "tion.",
-			expectedError: nil,
+			name: "coalescing",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewCoalescingRangeReader(inner)
+			},
+		},
+		{
+			name: "concurrency limited",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewConcurrencyLimitedRangeReader(inner, 1)
+			},
+		},
+		{
+			name: "logging",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewLoggingRangeReader(inner, slog.New(slog.DiscardHandler), slog.LevelDebug)
+			},
+		},
+		{
+			name: "rate limited",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewRateLimitedRangeReader(inner)
+			},
+		},
+		{
+			name: "retry",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewRetryRangeReader(inner, pmtilr.RetryPolicy{})
+			},
+		},
+		{
+			name: "timeout",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewTimeoutRangeReader(inner, time.Second)
+			},
+		},
+		{
+			name: "traced",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewTracedRangeReader(inner, tracenoop.NewTracerProvider())
+			},
+		},
+		{
+			name: "fallback",
+			wrap: func(_ *testing.T, inner pmtilr.RangeReader) pmtilr.RangeReader {
+				return pmtilr.NewFallbackRangeReader(inner, inner)
+			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := &mockS3Client{
-				GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
-					if bucketName != aws.ToString(params.Bucket) {
-						t.Fatalf("expected: %s, got: %s", bucketName, aws.ToString(params.Bucket))
-					}
-
-					var start, end int
-					r := aws.ToString(params.Range)
-					_, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end)
-					if err != nil {
-						return nil, fmt.Errorf("invalid range header: %w", err)
-					}
-
-					dataLength := len(testData)
-					if start >= dataLength {
-						return &s3.GetObjectOutput{
-							Body: io.NopCloser(bytes.NewReader(nil)), // No data
-						}, nil
-					}
-
-					// Clamp end to test data length
-					if end >= dataLength {
-						end = dataLength - 1
-					}
-
-					if end < start {
-						return &s3.GetObjectOutput{
-							Body: io.NopCloser(bytes.NewReader(nil)),
-						}, nil
-					}
-
-					d := end - start + 1
-					buf := make([]byte, d)
-					copy(buf, testData[start:end+1])
-
-					return &s3.GetObjectOutput{
-						Body: io.NopCloser(bytes.NewReader(buf)),
-					}, nil
-				},
-			}
+			inner := &closeRecorder{BytesRangeReader: pmtilr.NewBytesRangeReader([]byte("data"))}
 
-			reader, err := pmtilr.NewS3RangeReader(bucketName, objectKey, mockClient)
-			if err != nil {
-				t.Fatal("unexpected error")
+			if err := pmtilr.CloseReader(tt.wrap(t, inner)); err != nil {
+				t.Fatalf("closing reader: %v", err)
 			}
-
-			readCloser, err := reader.ReadRange(
-				t.Context(),
-				pmtilr.NewRange(uint64(tt.offset), uint64(tt.length)),
-			)
-			if !errors.Is(err, tt.expectedError) {
-				t.Fatalf("expected error, and received error do not match")
+			if !inner.closed.Load() {
+				t.Fatal("expected the wrapped reader to be closed")
 			}
+		})
+	}
+}
 
-			result := []byte{}
-			if readCloser != nil {
-				defer readCloser.Close()
-				result, _ = io.ReadAll(readCloser)
-			}
+func TestFileRangeReaderClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.pmtiles")
+	if err := os.WriteFile(path, []byte("some archive data"), 0o600); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
 
-			if len(tt.expectedData) != len(result) {
-				t.Fatalf(
-					"expected equal length of expected data %d and got data %d",
-					len(tt.expectedData),
-					len(result),
-				)
+	tests := []struct {
+		name string
+		open func() (pmtilr.RangeReader, error)
+	}{
+		{
+			name: "file",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewFileRangeReader(path) },
+		},
+		{
+			name: "mmap",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewMMapFileRangeReader(path) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := tt.open()
+			if err != nil {
+				t.Fatalf("opening reader: %v", err)
+			}
+			if err := reader.(io.Closer).Close(); err != nil {
+				t.Fatalf("closing reader: %v", err)
 			}
 
-			if tt.expectedData != string(result) {
-				t.Fatalf("expected %s, got: %s", tt.expectedData, string(result))
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+			if err == nil {
+				_, err = io.ReadAll(rc)
+			}
+			if err == nil {
+				t.Fatal("expected reads from a closed reader to fail")
 			}
 		})
 	}
 }
-
-type mockS3Client struct {
-	GetObjectFunc func(ctx context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error)
-}
-
-func (m *mockS3Client) GetObject(
-	ctx context.Context,
-	params *s3.GetObjectInput,
-	_ ...func(*s3.Options),
-) (*s3.GetObjectOutput, error) {
-	return m.GetObjectFunc(ctx, params)
-}
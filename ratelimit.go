@@ -0,0 +1,130 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+type rateLimitConfig struct {
+	requests  *rate.Limiter
+	bytes     *rate.Limiter
+	bandwidth *rate.Limiter
+}
+
+// RateLimitOption is a functional option for configuring a
+// RateLimitedRangeReader.
+type RateLimitOption = func(config *rateLimitConfig)
+
+// WithRequestsPerSecond limits reads to perSecond, allowing bursts of up to
+// burst reads.
+func WithRequestsPerSecond(perSecond float64, burst int) RateLimitOption {
+	return func(config *rateLimitConfig) {
+		config.requests = rate.NewLimiter(rate.Limit(perSecond), max(burst, 1))
+	}
+}
+
+// WithBytesPerSecond limits the bytes requested to perSecond, allowing
+// bursts of up to burst bytes. Ranges are accounted by their requested
+// length.
+func WithBytesPerSecond(perSecond float64, burst int) RateLimitOption {
+	return func(config *rateLimitConfig) {
+		config.bytes = rate.NewLimiter(rate.Limit(perSecond), max(burst, 1))
+	}
+}
+
+// WithBandwidth paces reading the bodies of reads to perSecond bytes,
+// allowing bursts of up to burst bytes. Unlike WithBytesPerSecond, which
+// admits whole ranges, it caps the transfer rate of the backing store, e.g.
+// so background jobs don't starve interactive traffic on the same link.
+func WithBandwidth(perSecond float64, burst int) RateLimitOption {
+	return func(config *rateLimitConfig) {
+		config.bandwidth = rate.NewLimiter(rate.Limit(perSecond), max(burst, 1))
+	}
+}
+
+// RateLimitedRangeReader limits the requests and bytes per second read from
+// the backing store with token buckets, so a Source in a busy tile server
+// cannot exhaust S3 request quotas. Reads over the limit wait until they
+// are allowed or their context is done.
+type RateLimitedRangeReader struct {
+	reader RangeReader
+	cfg    rateLimitConfig
+}
+
+// NewRateLimitedRangeReader wraps reader with the configured limits.
+// Without options reads are not limited.
+func NewRateLimitedRangeReader(reader RangeReader, options ...RateLimitOption) *RateLimitedRangeReader {
+	cfg := rateLimitConfig{}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+	return &RateLimitedRangeReader{reader: reader, cfg: cfg}
+}
+
+// ReadRange waits for the limits and reads the range.
+func (r *RateLimitedRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if r.cfg.requests != nil {
+		if err := r.cfg.requests.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("waiting for request rate limit: %w", err)
+		}
+	}
+	if r.cfg.bytes != nil {
+		if err := waitBytes(ctx, r.cfg.bytes, ranger.Length()); err != nil {
+			return nil, fmt.Errorf("waiting for byte rate limit: %w", err)
+		}
+	}
+
+	rc, err := r.reader.ReadRange(ctx, ranger)
+	if err != nil || r.cfg.bandwidth == nil {
+		return rc, err
+	}
+	return &throttledBody{rc: rc, ctx: ctx, limiter: r.cfg.bandwidth}, nil
+}
+
+// waitBytes waits for n tokens in chunks of the burst size, as ranges may be
+// larger than the burst.
+func waitBytes(ctx context.Context, limiter *rate.Limiter, n uint64) error {
+	burst := uint64(limiter.Burst()) //nolint:gosec // burst is positive
+	for n > 0 {
+		chunk := min(n, burst)
+		if err := limiter.WaitN(ctx, int(chunk)); err != nil { //nolint:gosec // chunk <= burst
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (r *RateLimitedRangeReader) Unwrap() RangeReader {
+	return r.reader
+}
+
+// throttledBody paces reads of a body with a limiter.
+type throttledBody struct {
+	rc      io.ReadCloser
+	ctx     context.Context //nolint:containedctx // bounds waiting for the limiter
+	limiter *rate.Limiter
+}
+
+// Read reads at most a burst and waits for the bytes read, so the
+// transfer is paced by the backpressure of the connection.
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if burst := b.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := b.rc.Read(p)
+	if n > 0 {
+		if werr := b.limiter.WaitN(b.ctx, n); werr != nil {
+			return n, fmt.Errorf("waiting for bandwidth limit: %w", werr)
+		}
+	}
+	return n, err
+}
+
+func (b *throttledBody) Close() error {
+	return b.rc.Close()
+}
@@ -0,0 +1,93 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestRateLimitedRangeReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []pmtilr.RateLimitOption
+		ranges  []pmtilr.Range
+		minWait time.Duration
+	}{
+		{
+			name:    "unlimited",
+			ranges:  []pmtilr.Range{pmtilr.NewRange(0, 10), pmtilr.NewRange(0, 10)},
+			minWait: 0,
+		},
+		{
+			name:    "requests per second",
+			options: []pmtilr.RateLimitOption{pmtilr.WithRequestsPerSecond(20, 1)},
+			// the first request uses the burst, the others wait 50ms each.
+			ranges:  []pmtilr.Range{pmtilr.NewRange(0, 1), pmtilr.NewRange(0, 1), pmtilr.NewRange(0, 1)},
+			minWait: 90 * time.Millisecond,
+		},
+		{
+			name:    "bytes per second beyond burst",
+			options: []pmtilr.RateLimitOption{pmtilr.WithBytesPerSecond(100, 4)},
+			// 4 bytes of burst, the other 6 bytes wait 60ms.
+			ranges:  []pmtilr.Range{pmtilr.NewRange(0, 10)},
+			minWait: 50 * time.Millisecond,
+		},
+		{
+			name:    "bandwidth",
+			options: []pmtilr.RateLimitOption{pmtilr.WithBandwidth(100, 4)},
+			// the body is read in chunks of 4 bytes, the last 6 wait 60ms.
+			ranges:  []pmtilr.Range{pmtilr.NewRange(0, 10)},
+			minWait: 50 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := pmtilr.NewRateLimitedRangeReader(
+				pmtilrtest.NewRangeReader([]byte("0123456789")),
+				tt.options...,
+			)
+
+			start := time.Now()
+			for _, rng := range tt.ranges {
+				rc, err := reader.ReadRange(t.Context(), rng)
+				if err != nil {
+					t.Fatalf("reading range: %v", err)
+				}
+				data, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil || uint64(len(data)) != rng.Length() {
+					t.Fatalf("reading body: got %d bytes, %v", len(data), err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait {
+				t.Errorf("reads took %v, want at least %v", elapsed, tt.minWait)
+			}
+		})
+	}
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		mock := pmtilrtest.NewRangeReader([]byte("0123456789"))
+		reader := pmtilr.NewRateLimitedRangeReader(mock, pmtilr.WithRequestsPerSecond(0.1, 1))
+
+		if _, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 1)); err != nil {
+			t.Fatalf("reading range: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := reader.ReadRange(ctx, pmtilr.NewRange(0, 1)); err == nil {
+			t.Fatal("expected error waiting beyond the deadline")
+		} else if errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected cancellation: %v", err)
+		}
+		if got := len(mock.Calls()); got != 1 {
+			t.Errorf("got %d calls, want 1", got)
+		}
+	})
+}
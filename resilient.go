@@ -0,0 +1,174 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// fileHandle is the subset of *os.File used by ResilientFileRangeReader.
+type fileHandle interface {
+	io.ReaderAt
+	io.Closer
+	Stat() (fs.FileInfo, error)
+}
+
+// ResilientFileRangeReader implements RangeReader for archives on network
+// filesystems (NFS, SMB). Unlike FileRangeReader it detects stale file handle
+// and I/O errors after a mount blip, reopens the file and retries the read.
+//
+// The size and modification time of the file are pinned on open. If they
+// differ after reopening, the archive was replaced and ErrArchiveChanged is
+// returned instead of mixing bytes from two archive versions.
+//
+// Ranges are read eagerly into memory so that errors surface from ReadRange
+// and can be recovered, instead of from a later Read on the returned body.
+type ResilientFileRangeReader struct {
+	path string
+
+	mu      sync.RWMutex
+	file    fileHandle
+	size    int64
+	modTime time.Time
+}
+
+// NewResilientFileRangeReader opens the file at the given path and returns a
+// ResilientFileRangeReader.
+func NewResilientFileRangeReader(path string) (*ResilientFileRangeReader, error) {
+	r := &ResilientFileRangeReader{path: filepath.Clean(path)}
+
+	f, info, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	r.file, r.size, r.modTime = f, info.Size(), info.ModTime()
+
+	return r, nil
+}
+
+func (r *ResilientFileRangeReader) open() (*os.File, fs.FileInfo, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ResilientFileRangeReader opening file at path %s: %w", r.path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close() //nolint:errcheck
+		return nil, nil, fmt.Errorf("ResilientFileRangeReader stat file at path %s: %w", r.path, err)
+	}
+
+	return f, info, nil
+}
+
+// ReadRange reads bytes from the file at the specified range, reopening the
+// file once if the read fails with a stale handle or I/O error.
+func (r *ResilientFileRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	// the range is clamped to the pinned size of the file, so an oversized
+	// length does not allocate more than the file holds.
+	r.mu.RLock()
+	length := uint64(0)
+	if fileSize := uint64(r.size); ranger.Offset() < fileSize { //nolint:gosec
+		length = min(ranger.Length(), fileSize-ranger.Offset())
+	}
+	r.mu.RUnlock()
+
+	size, err := bufferLen(length)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	off := int64(ranger.Offset()) //nolint:gosec
+
+	r.mu.RLock()
+	file := r.file
+	n, err := file.ReadAt(buf, off)
+	r.mu.RUnlock()
+
+	if isStaleFileError(err) {
+		if rerr := r.reopen(file); rerr != nil {
+			return nil, errors.Join(err, rerr)
+		}
+
+		r.mu.RLock()
+		n, err = r.file.ReadAt(buf, off)
+		r.mu.RUnlock()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading file at path %s: %w", r.path, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(buf[:n])), nil
+}
+
+// reopen replaces the failed handle, unless another caller already did so.
+func (r *ResilientFileRangeReader) reopen(failed fileHandle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != failed {
+		return nil
+	}
+
+	f, info, err := r.open()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != r.size || !info.ModTime().Equal(r.modTime) {
+		_ = f.Close() //nolint:errcheck
+		return fmt.Errorf(
+			"%w: %s changed from %d bytes (%s) to %d bytes (%s)",
+			ErrArchiveChanged, r.path, r.size, r.modTime, info.Size(), info.ModTime(),
+		)
+	}
+
+	_ = r.file.Close() //nolint:errcheck
+	r.file = f
+
+	return nil
+}
+
+// Size returns the size of the file when it was opened. A replaced file
+// fails reads with ErrArchiveChanged.
+func (r *ResilientFileRangeReader) Size(_ context.Context) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return uint64(r.size), nil //nolint:gosec // sizes are not negative
+}
+
+// Close closes the underlying file.
+func (r *ResilientFileRangeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// Probe stats the file at its path, e.g. to detect a deleted archive or an
+// unavailable network mount.
+func (r *ResilientFileRangeReader) Probe(_ context.Context) error {
+	if _, err := os.Stat(r.path); err != nil {
+		return fmt.Errorf("probing archive: %w", err)
+	}
+	return nil
+}
+
+// isStaleFileError reports whether err indicates a file handle that became
+// unusable, e.g. after an NFS server restart or mount blip.
+func isStaleFileError(err error) bool {
+	return errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EIO)
+}
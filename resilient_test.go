@@ -0,0 +1,98 @@
+package pmtilr
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// staleFile fails every read like a file handle after an NFS mount blip.
+type staleFile struct{ closed bool }
+
+func (s *staleFile) ReadAt(_ []byte, _ int64) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: "stale", Err: syscall.ESTALE}
+}
+func (s *staleFile) Close() error               { s.closed = true; return nil }
+func (s *staleFile) Stat() (fs.FileInfo, error) { return nil, syscall.ESTALE }
+
+func TestResilientFileRangeReader(t *testing.T) {
+	testData := []byte("This is some test data for the RangeReader implementation.")
+
+	setupFn := func(t *testing.T) string {
+		t.Helper()
+		file := filepath.Join(t.TempDir(), "testfile")
+		if err := os.WriteFile(file, testData, 0o600); err != nil {
+			t.Fatalf("writing testdata should not error")
+		}
+		return file
+	}
+
+	t.Run("reopens stale file handle", func(t *testing.T) {
+		reader, err := NewResilientFileRangeReader(setupFn(t))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+
+		stale := &staleFile{}
+		reader.file.Close()
+		reader.file = stale
+
+		rc, err := reader.ReadRange(t.Context(), NewRange(5, 10))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result, _ := io.ReadAll(rc)
+		if string(result) != "is some te" {
+			t.Fatalf("expected %s, got: %s", "is some te", string(result))
+		}
+		if !stale.closed {
+			t.Fatal("expected stale handle to be closed")
+		}
+	})
+
+	t.Run("clamps oversized ranges to the file size", func(t *testing.T) {
+		reader, err := NewResilientFileRangeReader(setupFn(t))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+
+		rc, err := reader.ReadRange(t.Context(), NewRange(50, 1<<40))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result, _ := io.ReadAll(rc)
+		if string(result) != "ntation." {
+			t.Fatalf("expected %s, got: %s", "ntation.", string(result))
+		}
+	})
+
+	t.Run("fails if file changed while stale", func(t *testing.T) {
+		file := setupFn(t)
+		reader, err := NewResilientFileRangeReader(file)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer reader.Close()
+
+		reader.file.Close()
+		reader.file = &staleFile{}
+
+		if err := os.WriteFile(file, []byte("republished"), 0o600); err != nil {
+			t.Fatalf("writing testdata should not error")
+		}
+		future := time.Now().Add(time.Hour)
+		os.Chtimes(file, future, future)
+
+		_, err = reader.ReadRange(t.Context(), NewRange(5, 10))
+		if !errors.Is(err, ErrArchiveChanged) {
+			t.Fatalf("expected ErrArchiveChanged, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = 100 * time.Millisecond
+	defaultRetryMaxDelay    = 5 * time.Second
+)
+
+// RetryPolicy configures a RetryRangeReader. Zero fields use the defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first,
+	// defaults to 3.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled on every
+	// further retry, defaults to 100ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, defaults to 5s.
+	MaxDelay time.Duration
+	// Retryable classifies errors as transient, defaults to IsRetryable.
+	Retryable func(error) bool
+}
+
+// RetryRangeReader retries transient ReadRange failures, e.g. S3 throttling
+// or connection resets, with exponential backoff and full jitter. Failures
+// while reading the returned body are not retried.
+type RetryRangeReader struct {
+	reader RangeReader
+	policy RetryPolicy
+}
+
+// NewRetryRangeReader wraps reader to retry failed reads as per policy.
+func NewRetryRangeReader(reader RangeReader, policy RetryPolicy) *RetryRangeReader {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultRetryBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultRetryMaxDelay
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+
+	return &RetryRangeReader{reader: reader, policy: policy}
+}
+
+// ReadRange reads the range, retrying retryable errors until MaxAttempts is
+// reached. Context cancellation is never retried.
+func (r *RetryRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	var errs []error
+	for attempt := range r.policy.MaxAttempts {
+		if attempt > 0 {
+			timer := time.NewTimer(r.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, errors.Join(append(errs, ctx.Err())...)
+			case <-timer.C:
+			}
+		}
+
+		rc, err := r.reader.ReadRange(ctx, ranger)
+		if err == nil {
+			return rc, nil
+		}
+		if ctx.Err() != nil || !r.policy.Retryable(err) {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("reading range after %d attempts: %w", r.policy.MaxAttempts, errors.Join(errs...))
+}
+
+// backoff returns a random delay up to BaseDelay * 2^(attempt-1), capped
+// by MaxDelay.
+func (r *RetryRangeReader) backoff(attempt int) time.Duration {
+	ceiling := r.policy.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		ceiling = min(ceiling, r.policy.BaseDelay<<shift)
+	}
+	if ceiling <= 0 {
+		ceiling = r.policy.MaxDelay
+	}
+	return rand.N(ceiling) + 1 //nolint:gosec // jitter needs no secure randomness
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (r *RetryRangeReader) Unwrap() RangeReader {
+	return r.reader
+}
+
+// IsRetryable reports whether err is transient: network timeouts and
+// resets, HTTP 408, 429 and 5xx responses and S3 throttling errors.
+// ErrArchiveChanged and ErrTileNotFound are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrArchiveChanged) || errors.Is(err, ErrTileNotFound) ||
+		errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	// AWS SDK errors, matched by behavior to not depend on the SDK.
+	var codeErr interface{ ErrorCode() string }
+	if errors.As(err, &codeErr) {
+		switch codeErr.ErrorCode() {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestTimeout",
+			"RequestTimeTooSkewed", "InternalError", "ServiceUnavailable":
+			return true
+		}
+	}
+	var httpErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &httpErr) {
+		return retryableStatus(httpErr.HTTPStatusCode())
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return code >= http.StatusInternalServerError && code != http.StatusNotImplemented
+}
@@ -0,0 +1,119 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestRetryRangeReader(t *testing.T) {
+	unavailable := &pmtilr.UpstreamStatusError{StatusCode: 503}
+	notFound := &pmtilr.UpstreamStatusError{StatusCode: 404}
+
+	tests := []struct {
+		name      string
+		responses []pmtilrtest.Response
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name:      "succeeds after transient failures",
+			responses: []pmtilrtest.Response{{Err: unavailable}, {Err: syscall.ECONNRESET}},
+			wantCalls: 3,
+		},
+		{
+			name:      "does not retry permanent failures",
+			responses: []pmtilrtest.Response{{Err: notFound}},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "gives up after max attempts",
+			responses: []pmtilrtest.Response{{Err: unavailable}, {Err: unavailable}, {Err: unavailable}},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pmtilrtest.NewRangeReader([]byte("0123456789")).WithResponses(tt.responses...)
+			reader := pmtilr.NewRetryRangeReader(mock, pmtilr.RetryPolicy{
+				BaseDelay: time.Millisecond,
+				MaxDelay:  time.Millisecond,
+			})
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 3))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				defer rc.Close()
+				data, _ := io.ReadAll(rc)
+				if string(data) != "234" {
+					t.Errorf("got %q, want %q", data, "234")
+				}
+			}
+			if got := len(mock.Calls()); got != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("stops backing off when canceled", func(t *testing.T) {
+		mock := pmtilrtest.NewRangeReader(nil).WithError(unavailable)
+		reader := pmtilr.NewRetryRangeReader(mock, pmtilr.RetryPolicy{
+			MaxAttempts: 10,
+			BaseDelay:   time.Hour,
+			MaxDelay:    time.Hour,
+		})
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := reader.ReadRange(ctx, pmtilr.NewRange(0, 1)); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if got := len(mock.Calls()); got != 1 {
+			t.Errorf("got %d calls, want 1", got)
+		}
+	})
+}
+
+type codeError string
+
+func (e codeError) Error() string     { return string(e) }
+func (e codeError) ErrorCode() string { return string(e) }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &pmtilr.UpstreamStatusError{StatusCode: 429}, want: true},
+		{err: &pmtilr.UpstreamStatusError{StatusCode: 502}, want: true},
+		{err: &pmtilr.UpstreamStatusError{StatusCode: 403}, want: false},
+		{err: fmt.Errorf("get object: %w", codeError("SlowDown")), want: true},
+		{err: codeError("NoSuchKey"), want: false},
+		{err: syscall.ECONNRESET, want: true},
+		{err: io.ErrUnexpectedEOF, want: true},
+		{err: pmtilr.ErrArchiveChanged, want: false},
+		{err: context.Canceled, want: false},
+		{err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := pmtilr.IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,504 @@
+//go:build !pmtilr_nos3
+
+package pmtilr
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// s3Config configures the S3RangeReader created by NewRangeReader.
+type s3Config struct {
+	client        S3Client
+	readerOptions []S3RangeReaderOption
+	anonymous     bool
+	awsConfig     *aws.Config
+}
+
+// WithAWSConfig creates the client for an "s3://" Source URI from awsConfig,
+// e.g. one with a custom retryer, IMDS settings or FIPS and dual-stack
+// endpoints, instead of loading the default AWS config. URI parameters
+// override its region and credentials. Its HTTP client is kept unless
+// WithHTTPClient is set, otherwise the HTTP options apply.
+func WithAWSConfig(awsConfig aws.Config) SourceOption {
+	return func(config *sourceConfig) {
+		config.readerOpts = append(config.readerOpts, func(config *rangeReaderConfig) {
+			config.s3.awsConfig = &awsConfig
+		})
+	}
+}
+
+// WithS3Client sets the client used for "s3://" URIs, e.g. one with custom
+// credentials, retries or endpoint resolution. The client is used as is and
+// neither the HTTP options of NewRangeReader nor URI parameters apply to
+// it. By default a client is created from the default AWS config.
+func WithS3Client(client S3Client) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.s3.client = client
+	}
+}
+
+// WithAnonymousS3 sends unsigned requests for "s3://" URIs, so public
+// buckets, e.g. the Overture Maps mirrors, can be read on machines without
+// AWS credentials or profile, as the AWS config is not loaded. The region
+// defaults to "us-east-1", set the region of the bucket with the "region"
+// URI parameter. It does not apply to a client set with WithS3Client.
+func WithAnonymousS3() RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.s3.anonymous = true
+	}
+}
+
+func newS3RangeReaderFromURI(ctx context.Context, u *URI, cfg *rangeReaderConfig) (RangeReader, error) {
+	params, err := parseS3URIParams(u.Query())
+	if err != nil {
+		return nil, err
+	}
+	if user := u.User(); user != nil {
+		params.client = true
+		params.accessKeyID = user.Username()
+		params.secretAccessKey, _ = user.Password()
+	}
+	client, err := createS3Client(ctx, cfg, params)
+	if err != nil {
+		return nil, err
+	}
+
+	options := cfg.s3.readerOptions
+	if params.versionID != "" {
+		options = append(slices.Clip(options), WithS3VersionID(params.versionID))
+	}
+	bucket, key := u.Host(), u.Path()
+	return NewS3RangeReader(bucket, strings.TrimPrefix(key, "/"), client, options...)
+}
+
+// s3URIParams configures the client and reader for an "s3://" URI from its
+// query, e.g.
+// "s3://bucket/key?region=eu-central-1&endpoint=https://minio.local&pathstyle=true",
+// and static credentials from its user info, e.g.
+// "s3://AKIA...:secret@bucket/key", with the secret percent-encoded.
+type s3URIParams struct {
+	// client reports whether client parameters are set.
+	client          bool
+	region          string
+	endpoint        string
+	pathStyle       bool
+	versionID       string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func parseS3URIParams(query url.Values) (s3URIParams, error) {
+	params := s3URIParams{pathStyle: true}
+	for name, values := range query {
+		value := values[len(values)-1]
+		params.client = params.client || name != "versionId"
+		switch name {
+		case "versionId":
+			params.versionID = value
+		case "region":
+			params.region = value
+		case "endpoint":
+			if err := validateS3Endpoint(value); err != nil {
+				return params, err
+			}
+			params.endpoint = value
+		case "pathstyle":
+			pathStyle, err := strconv.ParseBool(value)
+			if err != nil {
+				return params, fmt.Errorf("invalid s3 pathstyle %q: %w", value, err)
+			}
+			params.pathStyle = pathStyle
+		default:
+			return params, fmt.Errorf("unsupported s3 URI parameter %q", name)
+		}
+	}
+	return params, nil
+}
+
+// S3Client is an interface providing methods used by the S3RangeReader.
+type S3Client interface {
+	GetObject(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+}
+
+func newDefaultS3HTTPClient(cfg *rangeReaderConfig) *awshttp.BuildableClient {
+	return awshttp.NewBuildableClient().
+		WithTransportOptions(func(tr *http.Transport) {
+			// from SDK default 100
+			tr.MaxIdleConns = 100
+			// from SDK default 10, to avoid tcp+tls handshake past 10concurrent requests
+			tr.MaxIdleConnsPerHost = 100
+			// from unset
+			tr.ResponseHeaderTimeout = 5 * time.Second
+			// from 10s
+			tr.TLSHandshakeTimeout = 3 * time.Second
+
+			cfg.applyTransportOptions(tr)
+		}).
+		WithDialerOptions(func(d *net.Dialer) {
+			d.Timeout = 3 * time.Second // fail-fast connect
+		})
+}
+
+func validateS3Endpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	return nil
+}
+
+func createS3Client(ctx context.Context, cfg *rangeReaderConfig, params s3URIParams) (S3Client, error) {
+	if cfg.s3.client != nil {
+		if params.client {
+			return nil, errors.New("s3 URI parameters cannot be applied to a client set with WithS3Client")
+		}
+		return cfg.s3.client, nil
+	}
+
+	if params.accessKeyID == "" && cfg.s3.anonymous {
+		return newAnonymousS3Client(cfg, params), nil
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, cfg, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = params.pathStyle
+		if params.endpoint != "" {
+			o.BaseEndpoint = aws.String(params.endpoint)
+		}
+	}), nil
+}
+
+// loadAWSConfig returns the config set with WithAWSConfig or loads the
+// default AWS config, applying the client parameters of the URI.
+func loadAWSConfig(ctx context.Context, cfg *rangeReaderConfig, params s3URIParams) (aws.Config, error) {
+	var creds aws.CredentialsProvider
+	if params.accessKeyID != "" {
+		creds = credentials.NewStaticCredentialsProvider(params.accessKeyID, params.secretAccessKey, "")
+	}
+
+	if cfg.s3.awsConfig != nil {
+		awsCfg := cfg.s3.awsConfig.Copy()
+		switch {
+		case cfg.httpClient != nil:
+			awsCfg.HTTPClient = cfg.httpClient
+		case awsCfg.HTTPClient == nil:
+			awsCfg.HTTPClient = newDefaultS3HTTPClient(cfg)
+		}
+		if params.region != "" {
+			awsCfg.Region = params.region
+		}
+		if creds != nil {
+			awsCfg.Credentials = creds
+		}
+		return awsCfg, nil
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithHTTPClient(newDefaultS3HTTPClient(cfg)),
+	}
+	if cfg.httpClient != nil {
+		loadOptions[0] = config.WithHTTPClient(cfg.httpClient)
+	}
+	if params.region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(params.region))
+	}
+	if creds != nil {
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(creds))
+	}
+	return config.LoadDefaultConfig(ctx, loadOptions...)
+}
+
+// newAnonymousS3Client returns a client sending unsigned requests. The AWS
+// config is not loaded, so no profile or credentials are required.
+func newAnonymousS3Client(cfg *rangeReaderConfig, params s3URIParams) *s3.Client {
+	var httpClient s3.HTTPClient = newDefaultS3HTTPClient(cfg)
+	if cfg.httpClient != nil {
+		httpClient = cfg.httpClient
+	}
+
+	region := params.region
+	if region == "" {
+		region = defaultS3EndpointRegion
+	}
+
+	options := s3.Options{
+		Region:       region,
+		Credentials:  aws.AnonymousCredentials{},
+		HTTPClient:   httpClient,
+		UsePathStyle: params.pathStyle,
+	}
+	if params.endpoint != "" {
+		options.BaseEndpoint = aws.String(params.endpoint)
+	}
+	return s3.New(options)
+}
+
+// SSECustomerKey is the key material of an object stored with server-side
+// encryption with customer-provided keys (SSE-C), as sent to S3.
+type SSECustomerKey struct {
+	// Algorithm is the encryption algorithm, "AES256".
+	Algorithm string
+	// Key is the base64-encoded key.
+	Key string
+	// KeyMD5 is the base64-encoded MD5 digest of the key.
+	KeyMD5 string
+}
+
+// NewSSECustomerKey returns the SSECustomerKey of a raw 256-bit AES key.
+func NewSSECustomerKey(key []byte) SSECustomerKey {
+	sum := md5.Sum(key) //nolint:gosec // the digest S3 requires
+	return SSECustomerKey{
+		Algorithm: string(types.ServerSideEncryptionAes256),
+		Key:       base64.StdEncoding.EncodeToString(key),
+		KeyMD5:    base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+type s3ReaderConfig struct {
+	sseCustomerKey *SSECustomerKey
+	versionID      string
+}
+
+// S3RangeReaderOption is a functional option for configuring an
+// S3RangeReader.
+type S3RangeReaderOption = func(config *s3ReaderConfig)
+
+// WithSSECustomerKey reads an object encrypted with a customer-provided key.
+// The key is sent with every request.
+func WithSSECustomerKey(key SSECustomerKey) S3RangeReaderOption {
+	return func(config *s3ReaderConfig) {
+		config.sseCustomerKey = &key
+	}
+}
+
+// WithS3VersionID pins the reader to a version of the object, so a
+// long-running server keeps serving a consistent archive after the object
+// is overwritten. For URIs use the "versionId" query parameter.
+func WithS3VersionID(versionID string) S3RangeReaderOption {
+	return func(config *s3ReaderConfig) {
+		config.versionID = versionID
+	}
+}
+
+// WithS3RangeReaderOptions configures the S3RangeReader created for "s3://"
+// URIs.
+func WithS3RangeReaderOptions(options ...S3RangeReaderOption) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.s3.readerOptions = append(config.s3.readerOptions, options...)
+	}
+}
+
+// S3RangeReader implements RangeReader by reading from an S3 bucket.
+//
+// The ETag of the first response is pinned and sent as If-Match on every
+// subsequent request, so an overwritten object surfaces as
+// ErrArchiveChanged instead of torn reads across two archive versions.
+type S3RangeReader struct {
+	client S3Client
+	bucket string
+	key    string
+	cfg    s3ReaderConfig
+
+	mu   sync.RWMutex
+	etag string
+}
+
+// NewS3RangeReader creates a S3RangeReader implementing RangeReader.
+func NewS3RangeReader(
+	bucket, key string,
+	client S3Client,
+	options ...S3RangeReaderOption,
+) (*S3RangeReader, error) {
+	cfg := s3ReaderConfig{}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+
+	return &S3RangeReader{
+		bucket: bucket,
+		key:    key,
+		client: client,
+		cfg:    cfg,
+	}, nil
+}
+
+// input returns the GetObjectInput for a byte range.
+func (s *S3RangeReader) input(byteRange string) *s3.GetObjectInput {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+		Range:  aws.String(byteRange),
+	}
+	if s.cfg.versionID != "" {
+		input.VersionId = aws.String(s.cfg.versionID)
+	}
+	if k := s.cfg.sseCustomerKey; k != nil {
+		input.SSECustomerAlgorithm = aws.String(k.Algorithm)
+		input.SSECustomerKey = aws.String(k.Key)
+		input.SSECustomerKeyMD5 = aws.String(k.KeyMD5)
+	}
+	return input
+}
+
+// ReadRange reads bytes from the underlying S3 object at the specified range.
+// It validates the Ranger and returns a ReadCloser for streaming access.
+func (s *S3RangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	input := s.input(bytesRange(ranger.Offset(), ranger.Length()))
+	etag := s.ETag()
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+
+	output, err := s.client.GetObject(ctx, input, disableResponseValidation)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return nil, fmt.Errorf("%w: etag %q no longer matches", ErrArchiveChanged, etag)
+		}
+		return nil, err
+	}
+
+	s.pin(aws.ToString(output.ETag))
+
+	return output.Body, nil
+}
+
+// Size returns the size of the object from the ContentRange of a single
+// byte read, of the pinned ETag if any.
+func (s *S3RangeReader) Size(ctx context.Context) (uint64, error) {
+	input := s.input(bytesRange(0, 1))
+	etag := s.ETag()
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+
+	output, err := s.client.GetObject(ctx, input, disableResponseValidation)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return 0, fmt.Errorf("%w: etag %q no longer matches", ErrArchiveChanged, etag)
+		}
+		return 0, err
+	}
+	_ = output.Body.Close() //nolint:errcheck
+
+	if output.ContentRange == nil && output.ContentLength != nil {
+		return uint64(*output.ContentLength), nil //nolint:gosec // sizes are not negative
+	}
+	return contentRangeSize(aws.ToString(output.ContentRange))
+}
+
+// Probe requests the first byte of the object with the pinned ETag, as
+// Size does.
+func (s *S3RangeReader) Probe(ctx context.Context) error {
+	if _, err := s.Size(ctx); err != nil {
+		return fmt.Errorf("probing archive: %w", err)
+	}
+	return nil
+}
+
+// spanAttributes implements spanAttributer.
+func (s *S3RangeReader) spanAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("pmtilr.reader.scheme", SchemeS3.String()),
+		attribute.String("pmtilr.s3.bucket", s.bucket),
+		attribute.String("pmtilr.s3.key", s.key),
+	}
+}
+
+// ETag returns the pinned ETag of the object, or an empty string if no
+// response carrying an ETag has been received yet.
+func (s *S3RangeReader) ETag() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.etag
+}
+
+// pin captures the ETag of the first response that carries one.
+func (s *S3RangeReader) pin(etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.etag == "" {
+		s.etag = etag
+	}
+}
+
+// unpin drops the pinned ETag, so the next response pins the object
+// currently stored. A pinned object version is kept.
+func (s *S3RangeReader) unpin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.etag = ""
+}
+
+// isPreconditionFailed reports whether S3 rejected the If-Match header.
+func isPreconditionFailed(err error) bool {
+	var codeErr interface{ ErrorCode() string }
+	if errors.As(err, &codeErr) && codeErr.ErrorCode() == "PreconditionFailed" {
+		return true
+	}
+	var httpErr interface{ HTTPStatusCode() int }
+	return errors.As(err, &httpErr) && httpErr.HTTPStatusCode() == http.StatusPreconditionFailed
+}
+
+// Version returns the current ETag of the S3 object, or of the pinned
+// object version, or its last modification time if no ETag is set. Unlike
+// ETag it is not pinned and reflects the object currently stored.
+func (s *S3RangeReader) Version(ctx context.Context) (string, error) {
+	output, err := s.client.GetObject(ctx, s.input(bytesRange(0, 1)), disableResponseValidation)
+	if err != nil {
+		return "", err
+	}
+	_ = output.Body.Close() //nolint:errcheck
+
+	if etag := aws.ToString(output.ETag); etag != "" {
+		return etag, nil
+	}
+	if output.LastModified != nil {
+		return output.LastModified.UTC().Format(time.RFC3339Nano), nil
+	}
+	return "", errors.New("object has neither ETag nor LastModified")
+}
+
+// disableResponseValidation disables checksum validation on the response.  This
+// is necessary for S3 ReaderAt byte range requests as the responses to these do
+// not include checksums.  Not disabling checksums means that by default the AWS
+// SDK will log checksum failures.  We *could* disable this logging using
+// DisableLogOutputChecksumValidationSkipped but it seems cleaner to disable the
+// check full stop.
+func disableResponseValidation(o *s3.Options) {
+	o.ResponseChecksumValidation = aws.ResponseChecksumValidationUnset
+}
@@ -0,0 +1,18 @@
+//go:build pmtilr_nos3
+
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+)
+
+// s3Config is empty as S3 support is excluded from builds with the
+// pmtilr_nos3 tag.
+type s3Config struct{}
+
+// newS3RangeReaderFromURI fails as S3 support is excluded from builds with
+// the pmtilr_nos3 tag.
+func newS3RangeReaderFromURI(_ context.Context, u *URI, _ *rangeReaderConfig) (RangeReader, error) {
+	return nil, fmt.Errorf("unsupported URI scheme %q: built with pmtilr_nos3", u.Scheme())
+}
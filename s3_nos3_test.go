@@ -0,0 +1,15 @@
+//go:build pmtilr_nos3
+
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestS3Excluded(t *testing.T) {
+	if _, err := pmtilr.NewRangeReader(t.Context(), "s3://bucket/map.pmtiles"); err == nil {
+		t.Fatal("expected s3 URIs to fail with pmtilr_nos3")
+	}
+}
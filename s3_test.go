@@ -0,0 +1,369 @@
+//go:build !pmtilr_nos3
+
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestS3RangeReader(t *testing.T) {
+	bucketName := "test-bucket"
+	objectKey := "test-object"
+	testData := []byte("This is some test data for the RangeReader implementation.")
+
+	tests := []struct {
+		name          string
+		offset        int64
+		length        int
+		expectedData  string
+		expectedError error
+	}{
+		{
+			name:          "Read middle range",
+			offset:        5,
+			length:        10,
+			expectedData:  "is some te",
+			expectedError: nil,
+		},
+		{
+			name:          "Read full range",
+			offset:        0,
+			length:        len(testData),
+			expectedData:  string(testData),
+			expectedError: nil,
+		},
+		{
+			name:          "Read beyond end",
+			offset:        int64(len(testData) - 5),
+			length:        50,
+			expectedData:  "tion.",
+			expectedError: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &mockS3Client{
+				GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+					if bucketName != aws.ToString(params.Bucket) {
+						t.Fatalf("expected: %s, got: %s", bucketName, aws.ToString(params.Bucket))
+					}
+
+					var start, end int
+					r := aws.ToString(params.Range)
+					_, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end)
+					if err != nil {
+						return nil, fmt.Errorf("invalid range header: %w", err)
+					}
+
+					dataLength := len(testData)
+					if start >= dataLength {
+						return &s3.GetObjectOutput{
+							Body: io.NopCloser(bytes.NewReader(nil)), // No data
+						}, nil
+					}
+
+					// Clamp end to test data length
+					if end >= dataLength {
+						end = dataLength - 1
+					}
+
+					if end < start {
+						return &s3.GetObjectOutput{
+							Body: io.NopCloser(bytes.NewReader(nil)),
+						}, nil
+					}
+
+					d := end - start + 1
+					buf := make([]byte, d)
+					copy(buf, testData[start:end+1])
+
+					return &s3.GetObjectOutput{
+						Body: io.NopCloser(bytes.NewReader(buf)),
+					}, nil
+				},
+			}
+
+			reader, err := pmtilr.NewS3RangeReader(bucketName, objectKey, mockClient)
+			if err != nil {
+				t.Fatal("unexpected error")
+			}
+
+			readCloser, err := reader.ReadRange(
+				t.Context(),
+				pmtilr.NewRange(uint64(tt.offset), uint64(tt.length)),
+			)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error, and received error do not match")
+			}
+
+			result := []byte{}
+			if readCloser != nil {
+				defer readCloser.Close()
+				result, _ = io.ReadAll(readCloser)
+			}
+
+			if len(tt.expectedData) != len(result) {
+				t.Fatalf(
+					"expected equal length of expected data %d and got data %d",
+					len(tt.expectedData),
+					len(result),
+				)
+			}
+
+			if tt.expectedData != string(result) {
+				t.Fatalf("expected %s, got: %s", tt.expectedData, string(result))
+			}
+		})
+	}
+}
+
+type mockS3Client struct {
+	GetObjectFunc func(ctx context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error)
+}
+
+func (m *mockS3Client) GetObject(
+	ctx context.Context,
+	params *s3.GetObjectInput,
+	_ ...func(*s3.Options),
+) (*s3.GetObjectOutput, error) {
+	return m.GetObjectFunc(ctx, params)
+}
+
+func TestWithS3Client(t *testing.T) {
+	var bucket, key string
+	client := &mockS3Client{
+		GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+			bucket, key = aws.ToString(params.Bucket), aws.ToString(params.Key)
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("data")))}, nil
+		},
+	}
+
+	reader, err := pmtilr.NewRangeReader(t.Context(), "s3://bucket/path/map.pmtiles", pmtilr.WithS3Client(client))
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	defer rc.Close()
+
+	if bucket != "bucket" || key != "path/map.pmtiles" {
+		t.Errorf("expected the custom client to read bucket/path/map.pmtiles, got %s/%s", bucket, key)
+	}
+}
+
+func TestS3RangeReaderSSECustomerKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	sse := pmtilr.NewSSECustomerKey(key)
+	if sse.Algorithm != "AES256" || sse.Key != "QkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI=" {
+		t.Errorf("unexpected key material %+v", sse)
+	}
+
+	var inputs []*s3.GetObjectInput
+	client := &mockS3Client{
+		GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+			inputs = append(inputs, params)
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(bytes.NewReader([]byte("d"))),
+				ETag: aws.String(`"v1"`),
+			}, nil
+		},
+	}
+
+	reader, err := pmtilr.NewRangeReader(t.Context(), "s3://bucket/map.pmtiles",
+		pmtilr.WithS3Client(client),
+		pmtilr.WithS3RangeReaderOptions(pmtilr.WithSSECustomerKey(sse)),
+	)
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 1))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	rc.Close()
+	if _, err := reader.(pmtilr.Versioner).Version(t.Context()); err != nil {
+		t.Fatalf("reading version: %v", err)
+	}
+
+	for _, input := range inputs {
+		if aws.ToString(input.SSECustomerAlgorithm) != sse.Algorithm ||
+			aws.ToString(input.SSECustomerKey) != sse.Key ||
+			aws.ToString(input.SSECustomerKeyMD5) != sse.KeyMD5 {
+			t.Errorf("expected SSE-C key material on every request, got %+v", input)
+		}
+	}
+	if len(inputs) != 2 {
+		t.Errorf("expected 2 requests, got %d", len(inputs))
+	}
+}
+
+func TestS3RangeReaderVersionID(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		options []pmtilr.RangeReaderOption
+	}{
+		{
+			name:    "option",
+			uri:     "s3://bucket/map.pmtiles",
+			options: []pmtilr.RangeReaderOption{pmtilr.WithS3RangeReaderOptions(pmtilr.WithS3VersionID("v-1"))},
+		},
+		{
+			name: "URI parameter",
+			uri:  "s3://bucket/map.pmtiles?versionId=v-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var versionIDs []string
+			client := &mockS3Client{
+				GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+					versionIDs = append(versionIDs, aws.ToString(params.VersionId))
+					return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("d")))}, nil
+				},
+			}
+
+			reader, err := pmtilr.NewRangeReader(t.Context(), tt.uri, append(tt.options, pmtilr.WithS3Client(client))...)
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 1))
+			if err != nil {
+				t.Fatalf("reading range: %v", err)
+			}
+			rc.Close()
+
+			if len(versionIDs) != 1 || versionIDs[0] != "v-1" {
+				t.Errorf("expected reads of version v-1, got %v", versionIDs)
+			}
+		})
+	}
+}
+
+type preconditionFailedError struct{}
+
+func (preconditionFailedError) Error() string       { return "PreconditionFailed" }
+func (preconditionFailedError) ErrorCode() string   { return "PreconditionFailed" }
+func (preconditionFailedError) HTTPStatusCode() int { return 412 }
+
+func TestS3RangeReaderIfMatch(t *testing.T) {
+	current := `"v1"`
+	var ifMatch []string
+	client := &mockS3Client{
+		GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+			ifMatch = append(ifMatch, aws.ToString(params.IfMatch))
+			if params.IfMatch != nil && *params.IfMatch != current {
+				return nil, preconditionFailedError{}
+			}
+			return &s3.GetObjectOutput{
+				Body: io.NopCloser(bytes.NewReader([]byte("d"))),
+				ETag: aws.String(current),
+			}, nil
+		},
+	}
+
+	reader, err := pmtilr.NewS3RangeReader("bucket", "map.pmtiles", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.ETag() != "" {
+		t.Errorf("expected no etag before the first read, got %q", reader.ETag())
+	}
+
+	for range 2 {
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 1))
+		if err != nil {
+			t.Fatalf("reading range: %v", err)
+		}
+		rc.Close()
+	}
+	if reader.ETag() != `"v1"` {
+		t.Errorf("expected pinned etag %q, got %q", `"v1"`, reader.ETag())
+	}
+
+	current = `"v2"`
+	if _, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 1)); !errors.Is(err, pmtilr.ErrArchiveChanged) {
+		t.Errorf("expected ErrArchiveChanged, got %v", err)
+	}
+	if version, err := reader.Version(t.Context()); err != nil || version != `"v2"` {
+		t.Errorf("expected current version %q, got %q, %v", `"v2"`, version, err)
+	}
+
+	want := []string{"", `"v1"`, `"v1"`, ""}
+	if fmt.Sprint(ifMatch) != fmt.Sprint(want) {
+		t.Errorf("got If-Match %q, want %q", ifMatch, want)
+	}
+}
+
+func TestS3RangeReaderSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   *s3.GetObjectOutput
+		err      error
+		expected uint64
+		wantErr  error
+	}{
+		{
+			name:     "content range",
+			output:   &s3.GetObjectOutput{ContentRange: aws.String("bytes 0-0/4096"), ContentLength: aws.Int64(1)},
+			expected: 4096,
+		},
+		{
+			name:     "content length only",
+			output:   &s3.GetObjectOutput{ContentLength: aws.Int64(12)},
+			expected: 12,
+		},
+		{
+			name:    "etag changed",
+			err:     preconditionFailedError{},
+			wantErr: pmtilr.ErrArchiveChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input *s3.GetObjectInput
+			client := &mockS3Client{
+				GetObjectFunc: func(_ context.Context, params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+					input = params
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					tt.output.Body = io.NopCloser(bytes.NewReader([]byte{0}))
+					return tt.output, nil
+				},
+			}
+
+			reader, err := pmtilr.NewS3RangeReader("bucket", "key", client)
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+
+			size, err := reader.Size(t.Context())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if size != tt.expected {
+				t.Fatalf("expected size %d, got %d", tt.expected, size)
+			}
+			if got := aws.ToString(input.Range); got != "bytes=0-0" {
+				t.Fatalf("expected a single byte range, got %q", got)
+			}
+		})
+	}
+}
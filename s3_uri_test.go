@@ -0,0 +1,183 @@
+//go:build !pmtilr_nos3
+
+package pmtilr
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestS3URIParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       string
+		region    string
+		endpoint  string
+		pathStyle bool
+		wantErr   bool
+	}{
+		{
+			name:      "defaults",
+			uri:       "s3://bucket/map.pmtiles",
+			pathStyle: true,
+		},
+		{
+			name:      "all parameters",
+			uri:       "s3://bucket/map.pmtiles?region=eu-central-1&endpoint=https://minio.local&pathstyle=false",
+			region:    "eu-central-1",
+			endpoint:  "https://minio.local",
+			pathStyle: false,
+		},
+		{
+			name:    "invalid endpoint",
+			uri:     "s3://bucket/map.pmtiles?endpoint=minio.local",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pathstyle",
+			uri:     "s3://bucket/map.pmtiles?pathstyle=maybe",
+			wantErr: true,
+		},
+		{
+			name:    "unknown parameter",
+			uri:     "s3://bucket/map.pmtiles?regoin=eu-central-1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params, err := parseS3URIParams(u.Query())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsing parameters: %v", err)
+			}
+
+			client, err := createS3Client(t.Context(), &rangeReaderConfig{}, params)
+			if err != nil {
+				t.Fatalf("creating client: %v", err)
+			}
+			options := client.(*s3.Client).Options()
+
+			if tt.region != "" && options.Region != tt.region {
+				t.Errorf("expected region %q, got %q", tt.region, options.Region)
+			}
+			if got := aws.ToString(options.BaseEndpoint); got != tt.endpoint {
+				t.Errorf("expected endpoint %q, got %q", tt.endpoint, got)
+			}
+			if options.UsePathStyle != tt.pathStyle {
+				t.Errorf("expected path style %t, got %t", tt.pathStyle, options.UsePathStyle)
+			}
+		})
+	}
+}
+
+func TestS3URIParamsWithClient(t *testing.T) {
+	cfg := &rangeReaderConfig{s3: s3Config{client: &s3.Client{}}}
+
+	params, err := parseS3URIParams(url.Values{"region": {"eu-central-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createS3Client(t.Context(), cfg, params); err == nil {
+		t.Error("expected error applying URI parameters to a custom client")
+	}
+
+	params, err = parseS3URIParams(url.Values{"versionId": {"3HL4kqtJlcpXroDTDmJ"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.versionID != "3HL4kqtJlcpXroDTDmJ" {
+		t.Errorf("expected version id, got %q", params.versionID)
+	}
+	if _, err := createS3Client(t.Context(), cfg, params); err != nil {
+		t.Errorf("expected version ids to apply to custom clients: %v", err)
+	}
+}
+
+func TestS3URICredentials(t *testing.T) {
+	u, err := ParseURI("s3://AKIAEXAMPLE:se%2Fcret@bucket/map.pmtiles?region=eu-central-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := newS3RangeReaderFromURI(t.Context(), u, &rangeReaderConfig{})
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+	s3Reader := reader.(*S3RangeReader)
+	if s3Reader.bucket != "bucket" {
+		t.Errorf("expected bucket %q, got %q", "bucket", s3Reader.bucket)
+	}
+
+	creds, err := s3Reader.client.(*s3.Client).Options().Credentials.Retrieve(t.Context())
+	if err != nil {
+		t.Fatalf("retrieving credentials: %v", err)
+	}
+	if creds.AccessKeyID != "AKIAEXAMPLE" || creds.SecretAccessKey != "se/cret" {
+		t.Errorf("expected credentials from user info, got %q:%q", creds.AccessKeyID, creds.SecretAccessKey)
+	}
+
+	cfg := &rangeReaderConfig{s3: s3Config{client: &s3.Client{}}}
+	if _, err := newS3RangeReaderFromURI(t.Context(), u, cfg); err == nil {
+		t.Error("expected error applying URI credentials to a custom client")
+	}
+}
+
+func TestWithAWSConfig(t *testing.T) {
+	// a missing profile fails loading the AWS config.
+	t.Setenv("AWS_PROFILE", "pmtilr-missing-profile")
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		region string
+	}{
+		{name: "config region", region: "eu-west-1"},
+		{name: "uri region", query: url.Values{"region": {"eu-central-1"}}, region: "eu-central-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &sourceConfig{}
+			WithAWSConfig(aws.Config{Region: "eu-west-1", RetryMaxAttempts: 7})(source)
+			cfg := &rangeReaderConfig{}
+			for _, optFn := range source.readerOpts {
+				optFn(cfg)
+			}
+
+			params, err := parseS3URIParams(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := createS3Client(t.Context(), cfg, params)
+			if err != nil {
+				t.Fatalf("creating client: %v", err)
+			}
+			options := client.(*s3.Client).Options()
+
+			if options.Region != tt.region {
+				t.Errorf("expected region %q, got %q", tt.region, options.Region)
+			}
+			if options.RetryMaxAttempts != 7 {
+				t.Errorf("expected retry attempts of the config, got %d", options.RetryMaxAttempts)
+			}
+			if options.HTTPClient == nil {
+				t.Error("expected the default HTTP client")
+			}
+		})
+	}
+}
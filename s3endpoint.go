@@ -0,0 +1,79 @@
+//go:build !pmtilr_nos3
+
+package pmtilr
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const defaultS3EndpointRegion = "us-east-1"
+
+// S3Credentials are the static credentials of an S3-compatible store.
+// Without an AccessKeyID requests are sent anonymously.
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Region is the signing region, defaults to "us-east-1", which MinIO
+	// and Cloudflare R2 accept.
+	Region string
+}
+
+// NewS3RangeReaderWithEndpoint creates a S3RangeReader for an S3-compatible
+// store, e.g. MinIO or Cloudflare R2, at endpoint, without loading the AWS
+// config. Requests use path-style addressing and unsigned payloads, and
+// checksums are only sent and validated where required, which those stores
+// do not all support.
+//
+// The HTTP options, e.g. WithRootCAs or WithClientCertificates for custom
+// TLS settings, and WithS3RangeReaderOptions apply, WithS3Client does not.
+func NewS3RangeReaderWithEndpoint(
+	bucket, key, endpoint string,
+	creds S3Credentials,
+	options ...RangeReaderOption,
+) (*S3RangeReader, error) {
+	cfg := &rangeReaderConfig{}
+	for _, optFn := range options {
+		optFn(cfg)
+	}
+
+	if err := validateS3Endpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	var httpClient s3.HTTPClient = newDefaultS3HTTPClient(cfg)
+	if cfg.httpClient != nil {
+		httpClient = cfg.httpClient
+	}
+
+	var provider aws.CredentialsProvider = aws.AnonymousCredentials{}
+	if creds.AccessKeyID != "" {
+		provider = credentials.NewStaticCredentialsProvider(
+			creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken,
+		)
+	}
+
+	region := creds.Region
+	if region == "" {
+		region = defaultS3EndpointRegion
+	}
+
+	client := s3.New(s3.Options{
+		Region:                     region,
+		BaseEndpoint:               aws.String(endpoint),
+		UsePathStyle:               true,
+		Credentials:                provider,
+		HTTPClient:                 httpClient,
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+		APIOptions: []func(*middleware.Stack) error{
+			v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware,
+		},
+	})
+
+	return NewS3RangeReader(bucket, key, client, cfg.s3.readerOptions...)
+}
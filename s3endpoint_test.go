@@ -0,0 +1,133 @@
+//go:build !pmtilr_nos3
+
+package pmtilr_test
+
+import (
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestS3RangeReaderWithEndpoint(t *testing.T) {
+	data := []byte("0123456789")
+
+	var requests []*http.Request
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.URL.Path != "/bucket/tiles/map.pmtiles" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Range", "bytes 2-4/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[2:5])
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	tests := []struct {
+		name     string
+		endpoint string
+		creds    pmtilr.S3Credentials
+		signed   bool
+		wantErr  bool
+	}{
+		{
+			name:     "static credentials",
+			endpoint: server.URL,
+			creds:    pmtilr.S3Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"},
+			signed:   true,
+		},
+		{
+			name:     "anonymous",
+			endpoint: server.URL,
+		},
+		{
+			name:     "invalid endpoint",
+			endpoint: "minio.local:9000",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+
+			reader, err := pmtilr.NewS3RangeReaderWithEndpoint(
+				"bucket", "tiles/map.pmtiles", tt.endpoint, tt.creds, pmtilr.WithRootCAs(pool),
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 3))
+			if err != nil {
+				t.Fatalf("reading range: %v", err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(got) != "234" {
+				t.Errorf("got %q, want %q", got, "234")
+			}
+
+			if len(requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(requests))
+			}
+			r := requests[0]
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "AWS4-HMAC-SHA256") != tt.signed {
+				t.Errorf("unexpected authorization %q", auth)
+			}
+			if tt.signed && r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+				t.Errorf("expected unsigned payload, got %q", r.Header.Get("X-Amz-Content-Sha256"))
+			}
+		})
+	}
+}
+
+func TestWithAnonymousS3(t *testing.T) {
+	// a missing profile fails loading the AWS config.
+	t.Setenv("AWS_PROFILE", "pmtilr-missing-profile")
+
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Range", "bytes 0-3/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("0123"))
+	}))
+	defer server.Close()
+
+	uri := "s3://bucket/tiles/map.pmtiles?endpoint=" + server.URL
+	if _, err := pmtilr.NewRangeReader(t.Context(), uri); err == nil {
+		t.Fatal("expected error loading the missing profile")
+	}
+
+	reader, err := pmtilr.NewRangeReader(t.Context(), uri, pmtilr.WithAnonymousS3())
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	rc.Close()
+
+	if len(auth) != 1 || auth[0] != "" {
+		t.Errorf("expected 1 unsigned request, got authorization %q", auth)
+	}
+}
@@ -0,0 +1,169 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+type sampleConfig struct {
+	zooms   *ZoomRange
+	perZoom bool
+	rand    *rand.Rand
+}
+
+// SampleOption configures Sample.
+type SampleOption = func(*sampleConfig)
+
+// WithSampleZoomRange restricts sampling to zooms. Defaults to the archive's
+// MinZoom to MaxZoom.
+func WithSampleZoomRange(zooms ZoomRange) SampleOption {
+	return func(cfg *sampleConfig) {
+		cfg.zooms = &zooms
+	}
+}
+
+// WithSamplePerZoom samples n tiles from every zoom level instead of n tiles
+// in total.
+func WithSamplePerZoom() SampleOption {
+	return func(cfg *sampleConfig) {
+		cfg.perZoom = true
+	}
+}
+
+// WithSampleRand sets the source of randomness, e.g. a seeded *rand.Rand for
+// reproducible regression tests.
+func WithSampleRand(r *rand.Rand) SampleOption {
+	return func(cfg *sampleConfig) {
+		cfg.rand = r
+	}
+}
+
+// Sample returns up to n uniformly sampled z/x/y of tiles present in source,
+// without replacement, ordered by zoom and tile ID. Sampling reads the
+// directories but no tile data.
+func Sample(
+	ctx context.Context,
+	source Source,
+	n int,
+	options ...SampleOption,
+) ([][3]uint64, error) {
+	header := source.Header()
+	zooms := NewZoomRange(uint64(header.MinZoom), uint64(header.MaxZoom))
+	cfg := sampleConfig{zooms: &zooms}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	if cfg.rand == nil {
+		cfg.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())) //nolint:gosec
+	}
+	if err := cfg.zooms.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.zooms.MaxZoom() > MaxZ {
+		return nil, fmt.Errorf("zoom %d exceeds limit of %d", cfg.zooms.MaxZoom(), MaxZ)
+	}
+
+	// only the coverage of one zoom is held at a time. Sampling across
+	// zooms counts the tiles of every zoom first and computes the coverage
+	// of zooms holding sampled ranks a second time, which the directory
+	// cache keeps cheap.
+	var tiles [][3]uint64
+	if cfg.perZoom {
+		for z := cfg.zooms.MinZoom(); z <= cfg.zooms.MaxZoom(); z++ {
+			bitmap, err := sourceCoverage(ctx, source, uint8(z)) //nolint:gosec
+			if err != nil {
+				return nil, err
+			}
+			tiles = append(tiles, bitmap.selectRanks(sampleRanks(cfg.rand, bitmap.Count(), n))...)
+		}
+		return tiles, nil
+	}
+
+	var (
+		counts []uint64
+		total  uint64
+	)
+	for z := cfg.zooms.MinZoom(); z <= cfg.zooms.MaxZoom(); z++ {
+		bitmap, err := sourceCoverage(ctx, source, uint8(z)) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, bitmap.Count())
+		total += bitmap.Count()
+	}
+
+	var offset uint64
+	ranks := sampleRanks(cfg.rand, total, n)
+	for i, count := range counts {
+		k := 0
+		for k < len(ranks) && ranks[k] < offset+count {
+			ranks[k] -= offset
+			k++
+		}
+		if k > 0 {
+			z := cfg.zooms.MinZoom() + uint64(i)
+			bitmap, err := sourceCoverage(ctx, source, uint8(z)) //nolint:gosec
+			if err != nil {
+				return nil, err
+			}
+			tiles = append(tiles, bitmap.selectRanks(ranks[:k])...)
+		}
+		ranks = ranks[k:]
+		offset += count
+	}
+
+	return tiles, nil
+}
+
+// sampleRanks returns min(n, total) distinct sorted ranks in [0, total)
+// using Floyd's algorithm.
+func sampleRanks(r *rand.Rand, total uint64, n int) []uint64 {
+	if n <= 0 || total == 0 {
+		return nil
+	}
+
+	k := min(uint64(n), total)
+	seen := make(map[uint64]struct{}, k)
+	for j := total - k; j < total; j++ {
+		t := r.Uint64N(j + 1)
+		if _, ok := seen[t]; ok {
+			t = j
+		}
+		seen[t] = struct{}{}
+	}
+
+	ranks := make([]uint64, 0, k)
+	for rank := range seen {
+		ranks = append(ranks, rank)
+	}
+	slices.Sort(ranks)
+	return ranks
+}
+
+// selectRanks returns the z/x/y of the present tiles with the given
+// ascending ranks in Hilbert order. Ranks beyond Count are skipped.
+func (b *Bitmap) selectRanks(ranks []uint64) [][3]uint64 {
+	tiles := make([][3]uint64, 0, len(ranks))
+	base := zoomBaseTileID(b.zoom)
+
+	var offset uint64
+	runs := b.runs
+	for _, rank := range ranks {
+		for len(runs) > 0 && rank >= offset+runs[0].to-runs[0].from {
+			offset += runs[0].to - runs[0].from
+			runs = runs[1:]
+		}
+		if len(runs) == 0 {
+			break
+		}
+
+		zxy, err := FastZXYfromHilbertTileID(base + runs[0].from + rank - offset)
+		if err != nil {
+			break
+		}
+		tiles = append(tiles, zxy)
+	}
+	return tiles
+}
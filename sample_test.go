@@ -0,0 +1,99 @@
+package pmtilr_test
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestSample(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	seeded := func() pmtilr.SampleOption {
+		return pmtilr.WithSampleRand(rand.New(rand.NewPCG(1, 2)))
+	}
+
+	tiles, err := pmtilr.Sample(t.Context(), src, 25, seeded())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tiles) != 25 {
+		t.Fatalf("expected 25 tiles, got: %d", len(tiles))
+	}
+
+	seen := map[[3]uint64]bool{}
+	for _, zxy := range tiles {
+		if seen[zxy] {
+			t.Fatalf("expected distinct tiles, got %v twice", zxy)
+		}
+		seen[zxy] = true
+
+		if _, err := src.Tile(t.Context(), zxy[0], zxy[1], zxy[2]); err != nil {
+			t.Fatalf("expected sampled tile %v to exist: %s", zxy, err)
+		}
+	}
+
+	again, err := pmtilr.Sample(t.Context(), src, 25, seeded())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(tiles, again) {
+		t.Fatal("expected seeded samples to be reproducible")
+	}
+
+	perZoom, err := pmtilr.Sample(
+		t.Context(), src, 1000,
+		pmtilr.WithSamplePerZoom(),
+		pmtilr.WithSampleZoomRange(pmtilr.NewZoomRange(0, 2)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var expected int
+	for z := range uint8(3) {
+		coverage, err := src.(pmtilr.Coverager).Coverage(t.Context(), z)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected += int(coverage.Count())
+	}
+	if len(perZoom) != expected {
+		t.Fatalf("expected all %d tiles of z0-2, got: %d", expected, len(perZoom))
+	}
+}
+
+func TestSampleHighZoom(t *testing.T) {
+	src, err := pmtilr.NewSource(
+		t.Context(), "",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	tiles, err := pmtilr.Sample(
+		t.Context(), src, 100,
+		pmtilr.WithSampleZoomRange(pmtilr.NewZoomRange(0, pmtilr.MaxZ)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tiles) != 21 {
+		t.Fatalf("expected all 21 tiles of z0-2, got: %d", len(tiles))
+	}
+	for i := 1; i < len(tiles); i++ {
+		prev, _ := pmtilr.NewTileID(tiles[i-1][0], tiles[i-1][1], tiles[i-1][2])
+		id, _ := pmtilr.NewTileID(tiles[i][0], tiles[i][1], tiles[i][2])
+		if id <= prev {
+			t.Fatalf("expected tiles ordered by tile id, got %v after %v", tiles[i], tiles[i-1])
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	DefaultSegmentThreshold = 8 << 20
+	DefaultSegments         = 4
+)
+
+type segmentConfig struct {
+	threshold uint64
+	segments  int
+}
+
+// SegmentOption is a functional option for configuring a
+// SegmentedRangeReader.
+type SegmentOption = func(config *segmentConfig)
+
+// WithSegmentThreshold splits ranges longer than threshold bytes, defaults
+// to 8 MiB.
+func WithSegmentThreshold(threshold uint64) SegmentOption {
+	return func(config *segmentConfig) {
+		config.threshold = threshold
+	}
+}
+
+// WithSegments sets the number of parallel reads a range is split into,
+// defaults to 4.
+func WithSegments(segments int) SegmentOption {
+	return func(config *segmentConfig) {
+		config.segments = max(segments, 1)
+	}
+}
+
+// SegmentedRangeReader splits large ranges, e.g. multi-MB leaf directories
+// or raster tiles, into parallel reads of the inner reader and reassembles
+// them, so transfers on high-latency links are not bound by the throughput
+// of a single connection. Ranges up to the threshold are passed through.
+//
+// Split ranges are buffered in memory before they are returned. If a read
+// fails, the other reads are cancelled and the error is returned. Segments
+// other than the last must hold exactly the requested bytes, otherwise the
+// read fails with ErrShortRead. Servers may reject segments starting past
+// the end of the archive, so if the inner reader implements Sizer, split
+// ranges are truncated to the archive size, which is looked up once per
+// ETag and again after ErrArchiveChanged.
+type SegmentedRangeReader struct {
+	reader RangeReader
+	cfg    segmentConfig
+
+	mu    sync.Mutex
+	size  uint64
+	sized bool
+	etag  string // ETag of the archive the size was looked up for
+}
+
+// NewSegmentedRangeReader wraps reader to read large ranges in segments.
+func NewSegmentedRangeReader(reader RangeReader, options ...SegmentOption) *SegmentedRangeReader {
+	cfg := segmentConfig{
+		threshold: DefaultSegmentThreshold,
+		segments:  DefaultSegments,
+	}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+	return &SegmentedRangeReader{reader: reader, cfg: cfg}
+}
+
+// ReadRange reads the range, in parallel segments if it is longer than the
+// threshold. Like the inner reader, ranges past the end of the archive are
+// truncated.
+func (s *SegmentedRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if ranger.Length() <= s.cfg.threshold || s.cfg.segments < 2 {
+		return s.reader.ReadRange(ctx, ranger)
+	}
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+	if _, err := bufferLen(ranger.Length()); err != nil {
+		return nil, err
+	}
+
+	ranger = s.truncate(ctx, ranger)
+	if ranger.Length() == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := s.split(ranger)
+	parts := make([][]byte, len(segments))
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, segment := range segments {
+		wg.Go(func() {
+			data, err := readSegment(ctx, s.reader, segment)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			parts[i] = data
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		if errors.Is(firstErr, ErrArchiveChanged) {
+			s.resetSize()
+		}
+		return nil, firstErr
+	}
+	if err := checkSegments(segments, parts); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(bytes.Join(parts, nil))), nil
+}
+
+// truncate truncates the range to the archive size if the inner reader
+// reports it.
+func (s *SegmentedRangeReader) truncate(ctx context.Context, ranger Ranger) Range {
+	rng := NewRange(ranger.Offset(), ranger.Length())
+	size, ok := s.archiveSize(ctx)
+	if !ok {
+		return rng
+	}
+	if rng.Offset() >= size {
+		return NewRange(rng.Offset(), 0)
+	}
+	return NewRange(rng.Offset(), min(rng.Length(), size-rng.Offset()))
+}
+
+// archiveSize returns the size of the archive, looking it up if it is not
+// known for the current ETag of the inner reader.
+func (s *SegmentedRangeReader) archiveSize(ctx context.Context) (uint64, bool) {
+	etag := readerETag(s.reader)
+
+	s.mu.Lock()
+	size, sized := s.size, s.sized && s.etag == etag
+	s.mu.Unlock()
+	if sized {
+		return size, true
+	}
+
+	size, err := readerSize(ctx, s.reader)
+	if err != nil {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	s.size, s.sized, s.etag = size, true, etag
+	s.mu.Unlock()
+	return size, true
+}
+
+// resetSize drops the size of a replaced archive.
+func (s *SegmentedRangeReader) resetSize() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sized = false
+}
+
+// split divides the range into segments of equal length, the last one may
+// be shorter.
+func (s *SegmentedRangeReader) split(ranger Ranger) []Range {
+	n := uint64(s.cfg.segments) //nolint:gosec // segments is positive
+	size := (ranger.Length() + n - 1) / n
+
+	segments := make([]Range, 0, n)
+	end := ranger.Offset() + ranger.Length()
+	for offset := ranger.Offset(); offset < end; offset += size {
+		segments = append(segments, NewRange(offset, min(size, end-offset)))
+	}
+	return segments
+}
+
+// readSegment reads a segment, which may be truncated at the end of the
+// archive. Bodies longer than the segment, e.g. the whole archive sent by a
+// server ignoring the range, fail with ErrShortRead.
+func readSegment(ctx context.Context, reader RangeReader, segment Range) ([]byte, error) {
+	rc, err := reader.ReadRange(ctx, segment)
+	if err != nil {
+		return nil, fmt.Errorf("reading segment %d+%d: %w", segment.Offset(), segment.Length(), err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	var buf bytes.Buffer
+	// the length is bounded by bufferLen of the range.
+	buf.Grow(int(segment.Length())) //nolint:gosec
+
+	if _, err := buf.ReadFrom(io.LimitReader(rc, int64(segment.Length())+1)); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("reading segment %d+%d: %w", segment.Offset(), segment.Length(), err)
+	}
+
+	if got := uint64(buf.Len()); got > segment.Length() {
+		return nil, segmentLengthError(segment, got)
+	}
+	return buf.Bytes(), nil
+}
+
+// checkSegments checks that the parts read for segments are contiguous: a
+// segment may only be shorter than requested at the end of the archive, so
+// all segments after it must be empty.
+func checkSegments(segments []Range, parts [][]byte) error {
+	for i, segment := range segments {
+		got := uint64(len(parts[i]))
+		if got == segment.Length() {
+			continue
+		}
+		for j := i + 1; j < len(segments); j++ {
+			if len(parts[j]) > 0 {
+				return segmentLengthError(segment, got)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func segmentLengthError(segment Range, got uint64) error {
+	return fmt.Errorf(
+		"reading segment %d+%d: %w",
+		segment.Offset(), segment.Length(),
+		&ShortReadError{Offset: segment.Offset(), Expected: segment.Length(), Got: got},
+	)
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (s *SegmentedRangeReader) Unwrap() RangeReader {
+	return s.reader
+}
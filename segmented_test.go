@@ -0,0 +1,203 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestSegmentedRangeReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	tests := []struct {
+		name          string
+		rng           pmtilr.Range
+		sized         bool
+		expectedData  []byte
+		expectedCalls []pmtilr.Range
+	}{
+		{
+			name:          "short range is passed through",
+			rng:           pmtilr.NewRange(10, 20),
+			expectedData:  data[10:30],
+			expectedCalls: []pmtilr.Range{{10, 20}},
+		},
+		{
+			name:          "long range is split",
+			rng:           pmtilr.NewRange(10, 50),
+			expectedData:  data[10:60],
+			expectedCalls: []pmtilr.Range{{10, 13}, {23, 13}, {36, 13}, {49, 11}},
+		},
+		{
+			name:          "range past the end is truncated to the size",
+			rng:           pmtilr.NewRange(60, 80),
+			sized:         true,
+			expectedData:  data[60:],
+			expectedCalls: []pmtilr.Range{{60, 10}, {70, 10}, {80, 10}, {90, 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pmtilrtest.NewRangeReader(data).WithLatency(5 * time.Millisecond)
+			var inner pmtilr.RangeReader = mock
+			if tt.sized {
+				inner = &sizedRangeReader{RangeReader: mock, size: uint64(len(data))}
+			}
+			reader := pmtilr.NewSegmentedRangeReader(inner, pmtilr.WithSegmentThreshold(32), pmtilr.WithSegments(4))
+
+			rc, err := reader.ReadRange(t.Context(), tt.rng)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(got, tt.expectedData) {
+				t.Errorf("expected %q, got %q", tt.expectedData, got)
+			}
+
+			calls := mock.Calls()
+			slices.SortFunc(calls, func(a, b pmtilr.Range) int { return int(a.Offset()) - int(b.Offset()) })
+			if !slices.Equal(calls, tt.expectedCalls) {
+				t.Errorf("expected calls %v, got %v", tt.expectedCalls, calls)
+			}
+			if len(calls) > 1 && mock.MaxConcurrency() != len(calls) {
+				t.Errorf("expected %d parallel calls, got %d", len(calls), mock.MaxConcurrency())
+			}
+		})
+	}
+}
+
+func TestSegmentedRangeReaderError(t *testing.T) {
+	errBackend := errors.New("backend down")
+	mock := pmtilrtest.NewRangeReader(make([]byte, 100)).
+		WithLatency(time.Second).
+		WithRange(25, 25, pmtilrtest.Response{Err: errBackend, Latency: time.Millisecond})
+	reader := pmtilr.NewSegmentedRangeReader(mock, pmtilr.WithSegmentThreshold(32), pmtilr.WithSegments(4))
+
+	start := time.Now()
+	if _, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 100)); !errors.Is(err, errBackend) {
+		t.Fatalf("expected backend error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the other segments to be cancelled, took %s", elapsed)
+	}
+}
+
+func TestSegmentedRangeReaderSegmentLength(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	tests := []struct {
+		name    string
+		resp    pmtilrtest.Response
+		wantErr error
+	}{
+		{
+			name:    "short segment",
+			resp:    pmtilrtest.Response{Data: data[25:40]},
+			wantErr: pmtilr.ErrShortRead,
+		},
+		{
+			name:    "full body instead of the segment",
+			resp:    pmtilrtest.Response{Data: data},
+			wantErr: pmtilr.ErrShortRead,
+		},
+		{
+			name: "exact segment",
+			resp: pmtilrtest.Response{Data: data[25:50]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := pmtilrtest.NewRangeReader(data).WithRange(25, 25, tt.resp)
+			reader := pmtilr.NewSegmentedRangeReader(mock, pmtilr.WithSegmentThreshold(32), pmtilr.WithSegments(4))
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 100))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+
+			got, _ := io.ReadAll(rc)
+			if !bytes.Equal(got, data) {
+				t.Errorf("expected %q, got %q", data, got)
+			}
+		})
+	}
+}
+
+func TestSegmentedRangeReaderReplacedArchive(t *testing.T) {
+	inner := &replaceableRangeReader{data: bytes.Repeat([]byte("a"), 50), etag: `"v1"`}
+	reader := pmtilr.NewSegmentedRangeReader(inner, pmtilr.WithSegmentThreshold(32), pmtilr.WithSegments(4))
+
+	read := func() []byte {
+		t.Helper()
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 100))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer rc.Close()
+		got, _ := io.ReadAll(rc)
+		return got
+	}
+
+	if got := read(); len(got) != 50 {
+		t.Fatalf("expected 50 bytes, got %d", len(got))
+	}
+
+	inner.replace(bytes.Repeat([]byte("b"), 100), `"v2"`)
+	if got := read(); len(got) != 100 {
+		t.Fatalf("expected the size of the replaced archive, got %d bytes", len(got))
+	}
+}
+
+// replaceableRangeReader is an archive that can be republished with a new
+// ETag.
+type replaceableRangeReader struct {
+	mu   sync.Mutex
+	data []byte
+	etag string
+}
+
+func (r *replaceableRangeReader) replace(data []byte, etag string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data, r.etag = data, etag
+}
+
+func (r *replaceableRangeReader) ReadRange(_ context.Context, ranger pmtilr.Ranger) (io.ReadCloser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	size := uint64(len(r.data))
+	start := min(ranger.Offset(), size)
+	end := min(start+ranger.Length(), size)
+	return io.NopCloser(bytes.NewReader(r.data[start:end])), nil
+}
+
+func (r *replaceableRangeReader) Size(_ context.Context) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return uint64(len(r.data)), nil
+}
+
+func (r *replaceableRangeReader) ETag() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.etag
+}
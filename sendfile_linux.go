@@ -0,0 +1,69 @@
+//go:build linux
+
+package pmtilr
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxSendfileSize is the largest chunk passed to a single sendfile call.
+const maxSendfileSize = 1 << 30
+
+// sendfile copies length bytes at offset of src to dst with sendfile(2),
+// leaving the file offset of src untouched. handled is false if nothing was
+// written and the copy must fall back to user space.
+func sendfile(dst syscall.Conn, src *os.File, offset, length int64) (written int64, handled bool, err error) {
+	dc, err := dst.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	sc, err := src.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+
+	var werr error
+	cerr := sc.Control(func(sfd uintptr) {
+		err = dc.Write(func(dfd uintptr) bool {
+			for written < length {
+				n, err := unix.Sendfile(int(dfd), int(sfd), &offset, int(min(length-written, maxSendfileSize)))
+				if n > 0 {
+					written += int64(n)
+				}
+				switch {
+				case errors.Is(err, unix.EAGAIN):
+					// wait for dst to become writable.
+					return false
+				case errors.Is(err, unix.EINTR):
+					continue
+				case err != nil:
+					werr = err
+					return true
+				case n == 0:
+					// src ended early.
+					return true
+				}
+			}
+			return true
+		})
+	})
+
+	switch {
+	case cerr != nil:
+		return written, written > 0, cerr
+	case err != nil:
+		return written, written > 0 || werr == nil, err
+	case werr != nil:
+		if written == 0 && (errors.Is(werr, unix.EINVAL) || errors.Is(werr, unix.ENOSYS)) {
+			// sendfile not supported for this pair of descriptors.
+			return 0, false, nil
+		}
+		return written, true, werr
+	}
+
+	return written, true, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package pmtilr
+
+import (
+	"os"
+	"syscall"
+)
+
+// sendfile is only supported on linux, copies fall back to user space.
+func sendfile(_ syscall.Conn, _ *os.File, _, _ int64) (int64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,118 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+var ErrShortRead = errors.New("unexpected response length")
+
+// ShortReadError is returned by the bodies of a ValidatingRangeReader when
+// the backend returns more or fewer bytes than requested. It matches
+// ErrShortRead.
+type ShortReadError struct {
+	Offset   uint64
+	Expected uint64
+	Got      uint64
+}
+
+func (e *ShortReadError) Error() string {
+	return fmt.Sprintf(
+		"%s: got %d of %d bytes at offset %d",
+		ErrShortRead, e.Got, e.Expected, e.Offset,
+	)
+}
+
+func (e *ShortReadError) Unwrap() error {
+	return ErrShortRead
+}
+
+// ValidatingRangeReader wraps a RangeReader to verify that every body holds
+// exactly the requested number of bytes, so truncated responses, e.g. of a
+// reset S3 connection, fail with ErrShortRead instead of surfacing as
+// directory or decompression errors.
+//
+// Reads past the end of the archive are truncated by most readers. If a
+// body ends early, the size of the archive is looked up once with Sizer to
+// tell the end of the archive from a truncated response; without it, any
+// body ending early is a short read.
+type ValidatingRangeReader struct {
+	reader RangeReader
+
+	size  atomic.Uint64
+	sized atomic.Bool
+}
+
+// NewValidatingRangeReader wraps reader to validate the length of bodies.
+func NewValidatingRangeReader(reader RangeReader) *ValidatingRangeReader {
+	return &ValidatingRangeReader{reader: reader}
+}
+
+// ReadRange reads the range and returns a body failing with a
+// ShortReadError if its length does not match.
+func (v *ValidatingRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	rc, err := v.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		return nil, err
+	}
+	return &validatingBody{
+		rc:       rc,
+		ctx:      ctx,
+		reader:   v,
+		offset:   ranger.Offset(),
+		expected: ranger.Length(),
+	}, nil
+}
+
+// atEnd reports whether offset is the end of the archive.
+func (v *ValidatingRangeReader) atEnd(ctx context.Context, offset uint64) bool {
+	if !v.sized.Load() {
+		size, err := readerSize(ctx, v.reader)
+		if err != nil {
+			return false
+		}
+		v.size.Store(size)
+		v.sized.Store(true)
+	}
+	return offset == v.size.Load()
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (v *ValidatingRangeReader) Unwrap() RangeReader {
+	return v.reader
+}
+
+// validatingBody counts the bytes of a body and fails at its end if they
+// differ from the requested length.
+type validatingBody struct {
+	rc       io.ReadCloser
+	ctx      context.Context //nolint:containedctx // bounds the size lookup
+	reader   *ValidatingRangeReader
+	offset   uint64
+	expected uint64
+	n        uint64
+}
+
+func (b *validatingBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	b.n += uint64(n) //nolint:gosec // n is not negative
+
+	if b.n > b.expected {
+		return n, b.err()
+	}
+	if errors.Is(err, io.EOF) && b.n < b.expected && !b.reader.atEnd(b.ctx, b.offset+b.n) {
+		return n, b.err()
+	}
+	return n, err
+}
+
+func (b *validatingBody) err() error {
+	return &ShortReadError{Offset: b.offset, Expected: b.expected, Got: b.n}
+}
+
+func (b *validatingBody) Close() error {
+	return b.rc.Close()
+}
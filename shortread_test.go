@@ -0,0 +1,102 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestValidatingRangeReader(t *testing.T) {
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name    string
+		reader  pmtilr.RangeReader
+		ranger  pmtilr.Range
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "exact length",
+			reader: pmtilrtest.NewRangeReader(data),
+			ranger: pmtilr.NewRange(2, 4),
+			want:   "2345",
+		},
+		{
+			name: "truncated body",
+			reader: pmtilrtest.NewRangeReader(data).
+				WithResponses(pmtilrtest.Response{Data: []byte("23")}),
+			ranger:  pmtilr.NewRange(2, 4),
+			wantErr: pmtilr.ErrShortRead,
+		},
+		{
+			name: "overlong body",
+			reader: pmtilrtest.NewRangeReader(data).
+				WithResponses(pmtilrtest.Response{Data: []byte("234567")}),
+			ranger:  pmtilr.NewRange(2, 4),
+			wantErr: pmtilr.ErrShortRead,
+		},
+		{
+			name:   "clamped at the end of the archive",
+			reader: pmtilr.NewBytesRangeReader(data),
+			ranger: pmtilr.NewRange(8, 4),
+			want:   "89",
+		},
+		{
+			name: "truncated before the end of the archive",
+			reader: &sizedRangeReader{
+				RangeReader: pmtilrtest.NewRangeReader(data).
+					WithResponses(pmtilrtest.Response{Data: []byte("8")}),
+				size: uint64(len(data)),
+			},
+			ranger:  pmtilr.NewRange(8, 4),
+			wantErr: pmtilr.ErrShortRead,
+		},
+		{
+			name: "clamped without Sizer",
+			reader: pmtilrtest.NewRangeReader(data).
+				WithResponses(pmtilrtest.Response{Data: []byte("89")}),
+			ranger:  pmtilr.NewRange(8, 4),
+			wantErr: pmtilr.ErrShortRead,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := pmtilr.NewValidatingRangeReader(tt.reader)
+
+			rc, err := reader.ReadRange(t.Context(), tt.ranger)
+			if err != nil {
+				t.Fatalf("reading range: %v", err)
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && string(got) != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+
+			var shortErr *pmtilr.ShortReadError
+			if tt.wantErr != nil && (!errors.As(err, &shortErr) || shortErr.Offset != tt.ranger.Offset()) {
+				t.Fatalf("expected a ShortReadError at offset %d, got %v", tt.ranger.Offset(), err)
+			}
+		})
+	}
+}
+
+// sizedRangeReader reports a fixed archive size for a RangeReader.
+type sizedRangeReader struct {
+	pmtilr.RangeReader
+	size uint64
+}
+
+func (s *sizedRangeReader) Size(_ context.Context) (uint64, error) {
+	return s.size, nil
+}
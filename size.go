@@ -0,0 +1,67 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sizer is implemented by RangeReaders that know the size of the archive
+// they read from, e.g. to validate the offsets of a header against the
+// real object length. File, S3 and HTTP readers implement it.
+type Sizer interface {
+	Size(ctx context.Context) (uint64, error)
+}
+
+// ErrArchiveTruncated is returned by HeaderV3.ValidateSize if a section of
+// the archive extends beyond its size.
+var ErrArchiveTruncated = errors.New("archive truncated")
+
+// readerSize returns the size of the archive of reader with the first
+// Sizer in its chain, see ReaderAs.
+func readerSize(ctx context.Context, reader RangeReader) (uint64, error) {
+	s, ok := ReaderAs[Sizer](reader)
+	if !ok {
+		return 0, fmt.Errorf("%T does not report archive sizes", reader)
+	}
+	return s.Size(ctx)
+}
+
+// ValidateSize checks that the sections of the archive lie within size
+// bytes. Trailing data after the last section is allowed by the spec.
+func (h HeaderV3) ValidateSize(size uint64) error {
+	sections := []struct {
+		name           string
+		offset, length uint64
+	}{
+		{"root directory", h.RootOffset, h.RootLength},
+		{"metadata", h.MetadataOffset, h.MetadataLength},
+		{"leaf directories", h.LeafDirectoryOffset, h.LeafDirectoryLength},
+		{"tile data", h.TileDataOffset, h.TileDataLength},
+	}
+	for _, s := range sections {
+		if s.offset > size || s.length > size-s.offset {
+			return fmt.Errorf(
+				"%w: %s at %d+%d beyond archive size %d",
+				ErrArchiveTruncated, s.name, s.offset, s.length, size,
+			)
+		}
+	}
+	return nil
+}
+
+// contentRangeSize returns the complete length of a Content-Range header,
+// e.g. 1024 for "bytes 0-0/1024".
+func contentRangeSize(contentRange string) (uint64, error) {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok || !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	size, err := strconv.ParseUint(total, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range %q: unknown size", contentRange)
+	}
+	return size, nil
+}
@@ -0,0 +1,126 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestSizer(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).Bytes()
+
+	path := filepath.Join(t.TempDir(), "archive.pmtiles")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
+
+	ranged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(ranged.Close)
+
+	unranged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(unranged.Close)
+
+	tests := []struct {
+		name string
+		open func() (pmtilr.RangeReader, error)
+	}{
+		{
+			name: "file",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewFileRangeReader(path) },
+		},
+		{
+			name: "resilient file",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewResilientFileRangeReader(path) },
+		},
+		{
+			name: "bytes",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewBytesRangeReader(data), nil },
+		},
+		{
+			name: "http with Content-Range",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewHTTPRangeReader(ranged.URL) },
+		},
+		{
+			name: "http without range support",
+			open: func() (pmtilr.RangeReader, error) { return pmtilr.NewHTTPRangeReader(unranged.URL) },
+		},
+		{
+			name: "decorated",
+			open: func() (pmtilr.RangeReader, error) {
+				return pmtilr.NewConcurrencyLimitedRangeReader(pmtilr.NewBytesRangeReader(data), 1), nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := tt.open()
+			if err != nil {
+				t.Fatalf("opening reader: %v", err)
+			}
+
+			sizer, ok := pmtilr.ReaderAs[pmtilr.Sizer](reader)
+			if !ok {
+				t.Fatalf("%T does not implement Sizer", reader)
+			}
+			size, err := sizer.Size(t.Context())
+			if err != nil {
+				t.Fatalf("size: %v", err)
+			}
+			if size != uint64(len(data)) {
+				t.Fatalf("expected size %d, got %d", len(data), size)
+			}
+		})
+	}
+}
+
+func TestSizerUnsupported(t *testing.T) {
+	reader := pmtilr.NewConcurrencyLimitedRangeReader(pmtilrtest.NewRangeReader(nil), 1)
+
+	if _, ok := pmtilr.ReaderAs[pmtilr.Sizer](reader); ok {
+		t.Fatal("expected no Sizer for a reader without size")
+	}
+}
+
+func TestHeaderValidateSize(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(2).Bytes()
+
+	header, err := pmtilr.NewHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("reading header: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		size    uint64
+		wantErr error
+	}{
+		{name: "complete", size: uint64(len(data))},
+		{name: "trailing data", size: uint64(len(data)) + 1024},
+		{name: "truncated", size: uint64(len(data)) - 1, wantErr: pmtilr.ErrArchiveTruncated},
+		{name: "header only", size: pmtilr.HeaderSizeBytes, wantErr: pmtilr.ErrArchiveTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := header.ValidateSize(tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
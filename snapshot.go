@@ -0,0 +1,141 @@
+package pmtilr
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+)
+
+// Snapshotter is implemented by Repositories and Sources that can persist
+// their directory cache, e.g. to hand a warmed cache to the next instance
+// of a blue/green deploy instead of cold-starting against remote storage.
+// Sources from NewSource implement it and fail unless their Repository
+// implements it too, as DirectoryRepository does.
+//
+// Directories are cached by archive etag, so a snapshot only helps instances
+// reading an archive whose RangeReader reports a stable ETag, e.g. HTTP or
+// S3.
+type Snapshotter interface {
+	// Snapshot writes the cached directories to w.
+	Snapshot(ctx context.Context, w io.Writer) error
+	// Restore adds the directories of a snapshot to the cache. Nothing is
+	// added unless the whole snapshot is valid.
+	Restore(ctx context.Context, r io.Reader) error
+}
+
+// IterableCacher is implemented by Cachers that can enumerate their entries,
+// which Snapshot requires. OtterCache implements it.
+type IterableCacher interface {
+	All(ctx context.Context) iter.Seq2[string, Directory]
+}
+
+// ErrInvalidSnapshot is returned by Restore for corrupted or truncated
+// snapshots.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// snapshotMagic starts every snapshot and carries the layout version. It is
+// followed by length-prefixed Directory.MarshalBinary records, a 0 length
+// terminator and the CRC-32 of all preceding bytes.
+const snapshotMagic = "PMTSNAP1"
+
+func writeSnapshot(ctx context.Context, cache Cacher, w io.Writer) error {
+	if ic, ok := cache.(*instrumentedCacher); ok {
+		cache = ic.cache
+	}
+	iterable, ok := cache.(IterableCacher)
+	if !ok {
+		return fmt.Errorf("writing snapshot: %T cannot enumerate its entries", cache)
+	}
+
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	for key, dir := range iterable.All(ctx) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dir.key = key
+		data, err := dir.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+
+		n := binary.PutUvarint(lenBuf[:], uint64(len(data)))
+		if _, err := bw.Write(lenBuf[:n]); err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+		if _, err := bw.Write(data); err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+	}
+
+	if err := bw.WriteByte(0); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	if _, err := w.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32())); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return nil
+}
+
+func readSnapshot(ctx context.Context, cache Cacher, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	if len(data) < len(snapshotMagic)+1+crc32.Size || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: missing header", ErrInvalidSnapshot)
+	}
+	body, sum := data[:len(data)-crc32.Size], data[len(data)-crc32.Size:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	body = body[len(snapshotMagic):]
+
+	var dirs []Directory
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		size, n := binary.Uvarint(body)
+		if n <= 0 || size > uint64(len(body[n:])) {
+			return fmt.Errorf("%w: truncated record", ErrInvalidSnapshot)
+		}
+		body = body[n:]
+		if size == 0 {
+			break
+		}
+
+		var dir Directory
+		if err := dir.UnmarshalBinary(body[:size]); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+		}
+		body = body[size:]
+		dirs = append(dirs, dir)
+	}
+	if len(body) > 0 {
+		return fmt.Errorf("%w: trailing data", ErrInvalidSnapshot)
+	}
+
+	for _, dir := range dirs {
+		cache.Set(ctx, dir.key, dir)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	data := pmtilrtest.FixtureArchive(3).WithLeafSize(4).Bytes()
+	tiles := [][3]uint64{{0, 0, 0}, {1, 1, 0}, {2, 3, 3}, {3, 5, 2}}
+
+	newSource := func(t *testing.T, reader pmtilr.RangeReader) pmtilr.Source {
+		t.Helper()
+		source, err := pmtilr.NewSource(t.Context(), "memory", pmtilr.WithRangeReader(reader))
+		if err != nil {
+			t.Fatalf("creating source: %v", err)
+		}
+		return source
+	}
+
+	// directory cache keys contain the archive etag, so it must be stable.
+	warm := newSource(t, pmtilrtest.NewRangeReader(data).WithETag("v1"))
+	for _, zxy := range tiles {
+		if _, err := warm.Tile(t.Context(), zxy[0], zxy[1], zxy[2]); err != nil {
+			t.Fatalf("reading tile %v: %v", zxy, err)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	if err := warm.(pmtilr.Snapshotter).Snapshot(t.Context(), &snapshot); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+
+	t.Run("restored directories are not read again", func(t *testing.T) {
+		reader := pmtilrtest.NewRangeReader(data).WithETag("v1")
+		cold := newSource(t, reader)
+		if err := cold.(pmtilr.Snapshotter).Restore(t.Context(), bytes.NewReader(snapshot.Bytes())); err != nil {
+			t.Fatalf("restoring snapshot: %v", err)
+		}
+		reader.Reset()
+
+		for _, zxy := range tiles {
+			if _, err := cold.Tile(t.Context(), zxy[0], zxy[1], zxy[2]); err != nil {
+				t.Fatalf("reading tile %v: %v", zxy, err)
+			}
+		}
+		// only the tile data is read.
+		if got := len(reader.Calls()); got != len(tiles) {
+			t.Errorf("got %d reads, want %d", got, len(tiles))
+		}
+	})
+
+	t.Run("corrupted snapshot restores nothing", func(t *testing.T) {
+		corrupted := bytes.Clone(snapshot.Bytes())
+		corrupted[len(corrupted)/2] ^= 0xff
+
+		reader := pmtilrtest.NewRangeReader(data).WithETag("v1")
+		cold := newSource(t, reader)
+		err := cold.(pmtilr.Snapshotter).Restore(t.Context(), bytes.NewReader(corrupted))
+		if !errors.Is(err, pmtilr.ErrInvalidSnapshot) {
+			t.Fatalf("expected ErrInvalidSnapshot, got %v", err)
+		}
+		reader.Reset()
+
+		if _, err := cold.Tile(t.Context(), 3, 5, 2); err != nil {
+			t.Fatalf("reading tile: %v", err)
+		}
+		if got := len(reader.Calls()); got <= 1 {
+			t.Errorf("expected directory reads, got %d reads", got)
+		}
+	})
+
+	t.Run("cacher without enumeration", func(t *testing.T) {
+		source, err := pmtilr.NewSource(
+			t.Context(),
+			"memory",
+			pmtilr.WithRangeReader(pmtilrtest.NewBytesRangeReader(data)),
+			pmtilr.WithCacher(mapCacher{}),
+		)
+		if err != nil {
+			t.Fatalf("creating source: %v", err)
+		}
+		if err := source.(pmtilr.Snapshotter).Snapshot(t.Context(), &bytes.Buffer{}); err == nil {
+			t.Error("expected error for cacher without enumeration")
+		}
+	})
+}
+
+type mapCacher map[string]pmtilr.Directory
+
+func (c mapCacher) Get(_ context.Context, key string) (pmtilr.Directory, bool) {
+	d, ok := c[key]
+	return d, ok
+}
+
+func (c mapCacher) Set(_ context.Context, key string, value pmtilr.Directory) bool {
+	c[key] = value
+	return true
+}
+
+func (c mapCacher) Close() {}
+func (c mapCacher) Clear() {}
@@ -1,12 +1,16 @@
 package pmtilr
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"iter"
 	"sync"
 
 	singleflight "github.com/iwpnd/singleflightx"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -16,20 +20,30 @@ const (
 )
 
 // keyBufPool provides a shared buffer pool with 64-byte pre-allocated buffers
-var keyBufPool = sync.Pool{
-	New: func() any {
-		buf := make([]byte, 0, 64) // Pre-allocate 64 bytes capacity, sufficient for key pattern
-		return &buf
-	},
-}
+var keyBufPool = newPool(func() *[]byte {
+	buf := make([]byte, 0, 64) // Pre-allocate 64 bytes capacity, sufficient for key pattern
+	return &buf
+})
 
 type sourceConfig struct {
 	reader     RangeReader
+	readerOpts []RangeReaderOption
 	cacher     Cacher
 	decompress DecompressFunc
+	maxDecomp  uint64
+	tileDecomp bool
+	sniff      bool
+	transform  TileTransform
+	overzoom   OverzoomFunc
+	maxZoom    uint8
+	hooks      []FetchHook
+	keyFunc    KeyFunc
 	sfxshards  uint64
 	withOtel   bool
 
+	archiveLabel string
+	etagLabel    bool
+
 	tracerProvider trace.TracerProvider
 	meterProvider  metric.MeterProvider
 }
@@ -44,6 +58,71 @@ func WithDecompressFunc(decompressFn DecompressFunc) SourceOption {
 	}
 }
 
+// WithMaxDecompressedSize limits the decompressed size of directories,
+// metadata and tiles decompressed with WithTileDecompression to maxBytes,
+// defaults to DefaultMaxDecompressedSize. Larger payloads fail with a
+// DecompressedSizeError instead of allocating unbounded memory. Zero
+// disables the limit. It applies to decompression functions set with
+// WithDecompressFunc as well.
+func WithMaxDecompressedSize(maxBytes uint64) SourceOption {
+	return func(config *sourceConfig) {
+		config.maxDecomp = maxBytes
+	}
+}
+
+// WithTileDecompression decompresses tiles according to the header's
+// TileCompression before they are returned, for consumers that want raw
+// MVT or PNG bytes. Header then reports CompressionNone as TileCompression.
+// Tile transforms and overzooming still operate on the compressed tiles.
+func WithTileDecompression() SourceOption {
+	return func(config *sourceConfig) {
+		config.tileDecomp = true
+	}
+}
+
+// WithCompressionSniffing detects the compression of directories, metadata
+// and tiles decompressed with WithTileDecompression by their gzip or zstd
+// magic number if the header declares an unknown compression, see
+// SniffingDecompressFunc. Some older tooling writes incorrect compression
+// bytes, which otherwise yields garbage entries.
+func WithCompressionSniffing() SourceOption {
+	return func(config *sourceConfig) {
+		config.sniff = true
+	}
+}
+
+// TileTransform post-processes the tile bytes returned by Source.Tile, e.g.
+// to filter vector tile layers per request. data is compressed as per
+// header.TileCompression and the result must be compressed the same way.
+type TileTransform = func(
+	ctx context.Context, header HeaderV3, z, x, y uint64, data []byte,
+) ([]byte, error)
+
+// WithTileTransform sets a TileTransform applied to every tile read from
+// the Source.
+func WithTileTransform(transform TileTransform) SourceOption {
+	return func(config *sourceConfig) {
+		config.transform = transform
+	}
+}
+
+// OverzoomFunc derives a tile dz zoom levels below the archive's MaxZoom
+// from its parent tile at MaxZoom. (dx, dy) is the offset of the requested
+// tile within the parent, in units of the requested tile. parent and the
+// result are compressed as per header.TileCompression.
+type OverzoomFunc = func(
+	ctx context.Context, header HeaderV3, parent []byte, dz uint8, dx, dy uint64,
+) ([]byte, error)
+
+// WithOverzoom serves tiles beyond the archive's MaxZoom up to maxZoom by
+// deriving them from the parent tile at MaxZoom with fn.
+func WithOverzoom(maxZoom uint8, fn OverzoomFunc) SourceOption {
+	return func(config *sourceConfig) {
+		config.maxZoom = maxZoom
+		config.overzoom = fn
+	}
+}
+
 // WithCacher sets a custom in directory cache on the Source.
 func WithCacher(cacher Cacher) SourceOption {
 	return func(config *sourceConfig) {
@@ -51,13 +130,31 @@ func WithCacher(cacher Cacher) SourceOption {
 	}
 }
 
-// WithRangeReader sets a custom RangeReader on the Source.
+// WithKeyFunc sets the KeyFunc deriving directory cache keys, defaults to
+// HashKey. Use ConcatKey for exact keys.
+func WithKeyFunc(keyFunc KeyFunc) SourceOption {
+	return func(config *sourceConfig) {
+		config.keyFunc = keyFunc
+	}
+}
+
+// WithRangeReader sets a custom RangeReader on the Source. The reader stays
+// owned by the caller and is not closed with the Source.
 func WithRangeReader(reader RangeReader) SourceOption {
 	return func(config *sourceConfig) {
 		config.reader = reader
 	}
 }
 
+// WithRangeReaderOptions configures the default RangeReader created from the
+// Source URI, e.g. to set a custom http.Client, proxy or TLS settings.
+// It has no effect if a RangeReader is set with WithRangeReader.
+func WithRangeReaderOptions(options ...RangeReaderOption) SourceOption {
+	return func(config *sourceConfig) {
+		config.readerOpts = append(config.readerOpts, options...)
+	}
+}
+
 // WithSingleFlightShardCount change the number of singleflight shards from default 3.
 func WithSingleFlightShardCount(shards uint64) SourceOption {
 	return func(config *sourceConfig) {
@@ -86,6 +183,25 @@ func WithDisableInstrumentation() SourceOption {
 	}
 }
 
+// WithArchiveLabel records all metrics of the Source with an "archive"
+// attribute, so dashboards can break down latency and hit rate per archive
+// in a multi-archive deployment. At most 100 distinct names are used per
+// process, further archives are recorded as "other".
+func WithArchiveLabel(name string) SourceOption {
+	return func(config *sourceConfig) {
+		config.archiveLabel = name
+	}
+}
+
+// WithEtagLabel additionally records all metrics of the Source with an
+// "archive.etag" attribute. Every new archive version adds a new series,
+// so only enable it if archives are replaced rarely.
+func WithEtagLabel() SourceOption {
+	return func(config *sourceConfig) {
+		config.etagLabel = true
+	}
+}
+
 type Source interface {
 	Tile(ctx context.Context, z, x, y uint64) ([]byte, error)
 	Header() HeaderV3
@@ -101,6 +217,18 @@ type TileSource struct {
 	meta       *Metadata      // Metadata for tile index and offsets
 	repository Repository     // Repository for actual tile reads
 	decompress DecompressFunc // Function handling decompression on the archive
+	transform  TileTransform  // Optional post-processing of tile bytes
+	tileDecomp bool           // Decompress tiles before returning them
+	overzoom   OverzoomFunc   // Optional derivation of tiles beyond MaxZoom
+	maxZoom    uint8          // Max zoom served when overzooming
+	hooks      []FetchHook    // Invoked after each tile read from the archive
+	stats      *sourceStats   // Directory lookup statistics
+
+	lifetime context.Context    // Canceled once the source is closed
+	close    context.CancelFunc // Cancels lifetime
+	reads    sync.RWMutex       // Held by reads, delays closing the reader
+	owned    bool               // Whether the reader is closed with the source
+	closed   sync.Once
 }
 
 // NewSource initializes a Source, optionally applying SourceConfigOptions,
@@ -110,17 +238,20 @@ func NewSource( //nolint:cyclop
 	ctx context.Context,
 	uri string,
 	options ...SourceOption,
-) (Source, error) {
+) (_ Source, err error) {
 	// Create Source with defaults
 	s := &TileSource{
 		header: &HeaderV3{},
 		meta:   &Metadata{},
+		stats:  &sourceStats{},
 	}
+	s.lifetime, s.close = context.WithCancel(context.Background())
 
 	cfg := &sourceConfig{
 		tracerProvider: otel.GetTracerProvider(),
 		meterProvider:  otel.GetMeterProvider(),
 		withOtel:       true,
+		maxDecomp:      DefaultMaxDecompressedSize,
 	}
 
 	// apply user options
@@ -130,6 +261,7 @@ func NewSource( //nolint:cyclop
 
 	tracer := cfg.tracerProvider.Tracer(instrumentationName)
 	meter := cfg.meterProvider.Meter(instrumentationName)
+	labels := newMetricLabels(cfg.archiveLabel)
 
 	if cfg.cacher == nil {
 		cache, err := NewOtterCache()
@@ -146,11 +278,21 @@ func NewSource( //nolint:cyclop
 	s.reader = cfg.reader
 	// Initialize default reader unless configured.
 	if s.reader == nil {
-		reader, err := NewRangeReader(ctx, uri)
+		reader, err := NewRangeReader(ctx, uri, cfg.readerOpts...)
 		if err != nil {
 			return nil, err
 		}
 		s.reader = reader
+		s.owned = true
+		defer func() {
+			if err != nil {
+				_ = CloseReader(reader) //nolint:errcheck
+			}
+		}()
+	}
+
+	if cfg.withOtel {
+		s.reader = NewTracedRangeReader(s.reader, cfg.tracerProvider)
 	}
 
 	sg := singleflight.NewShardedGroup[string, Directory](
@@ -159,32 +301,45 @@ func NewSource( //nolint:cyclop
 
 	cache := cfg.cacher
 	if cfg.withOtel {
-		c, err := newInstrumentedCacher(cache, tracer, meter)
+		c, err := newInstrumentedCacher(cache, tracer, meter, labels)
 		if err != nil {
 			return nil, fmt.Errorf("creating source: %w", err)
 		}
 		cache = c
 	}
 
-	repository, err := NewDirectoryRepository(cache, sg)
+	var repoOpts []RepositoryOption
+	if cfg.keyFunc != nil {
+		repoOpts = append(repoOpts, WithRepositoryKeyFunc(cfg.keyFunc))
+	}
+	repository, err := NewDirectoryRepository(cache, sg, repoOpts...)
 	if err != nil {
 		return nil, err
 	}
 	s.repository = repository
 
 	if cfg.withOtel {
-		r, err := newInstrumentedRepository(repository, tracer, meter)
+		r, err := newInstrumentedRepository(repository, tracer, meter, labels)
 		if err != nil {
 			return nil, err
 		}
 		s.repository = r
 	}
 
+	s.transform = cfg.transform
+	s.tileDecomp = cfg.tileDecomp
+	s.overzoom = cfg.overzoom
+	s.maxZoom = cfg.maxZoom
+	s.hooks = cfg.hooks
 	s.decompress = cfg.decompress
 	// Initialize default decompress function unless configured.
 	if s.decompress == nil {
-		s.decompress = Decompress
+		s.decompress = decompress
+	}
+	if cfg.sniff {
+		s.decompress = SniffingDecompressFunc(s.decompress)
 	}
+	s.decompress = LimitDecompressFunc(s.decompress, cfg.maxDecomp)
 
 	if err := s.header.ReadFrom(ctx, s.reader); err != nil {
 		return nil, err
@@ -195,7 +350,12 @@ func NewSource( //nolint:cyclop
 	}
 
 	if cfg.withOtel {
-		return newInstrumentedSource(s, tracer, meter)
+		if cfg.etagLabel {
+			// metrics are only recorded once the source is returned, so the
+			// labels can still be extended with the archive etag.
+			labels.add(attribute.String("archive.etag", s.header.Etag))
+		}
+		return newInstrumentedSource(s, tracer, meter, labels)
 	}
 
 	return s, nil
@@ -203,31 +363,175 @@ func NewSource( //nolint:cyclop
 
 // Tile returns the raw tile bytes for the specified z, x, y.
 func (s *TileSource) Tile(ctx context.Context, z, x, y uint64) ([]byte, error) {
+	maxZoom := uint64(s.header.MaxZoom)
+	if s.overzoom != nil {
+		maxZoom = max(maxZoom, uint64(s.maxZoom))
+	}
+
 	// NOTE: maybe validate zxy against header.bounds
-	if z < uint64(s.header.MinZoom) || z > uint64(s.header.MaxZoom) {
+	if z < uint64(s.header.MinZoom) || z > maxZoom {
 		return []byte{}, fmt.Errorf(
 			"invalid zoom: %d for allowed range of %d to %d",
 			z,
 			s.header.MinZoom,
-			s.header.MaxZoom,
+			maxZoom,
 		)
 	}
 
-	entry, err := TileEntry(ctx, s.repository, s.Header(), s.reader, s.decompress, z, x, y)
+	var (
+		data []byte
+		err  error
+	)
+	if z > uint64(s.header.MaxZoom) {
+		data, err = s.overzoomTile(ctx, z, x, y)
+	} else {
+		data, err = s.readTile(ctx, z, x, y)
+	}
+	if err == nil && s.transform != nil {
+		data, err = s.transform(ctx, *s.header, z, x, y, data)
+	}
+	if err != nil || !s.tileDecomp {
+		return data, err
+	}
+
+	return s.decompressTile(data)
+}
+
+// TileByID returns the raw tile bytes for id, see Tile.
+func (s *TileSource) TileByID(ctx context.Context, id TileID) ([]byte, error) {
+	z, x, y, err := id.checkedZXY()
+	if err != nil {
+		return nil, err
+	}
+	return s.Tile(ctx, z, x, y)
+}
+
+// decompressTile decompresses tile data according to the TileCompression of
+// the archive.
+func (s *TileSource) decompressTile(data []byte) ([]byte, error) {
+	if s.header.TileCompression == CompressionNone {
+		return data, nil
+	}
+
+	rc, err := s.decompress(io.NopCloser(bytes.NewReader(data)), s.header.TileCompression)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing tile: %w", err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	raw, err := readAllSized(rc, decompressedSizeHint(uint64(len(data)), s.header.TileCompression))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing tile: %w", err)
+	}
+	return raw, nil
+}
+
+// readTile reads the tile bytes for z, x, y from the archive.
+func (s *TileSource) readTile(ctx context.Context, z, x, y uint64) ([]byte, error) {
+	entry, data, err := s.readEntry(ctx, z, x, y)
 	if err != nil {
 		return nil, err
 	}
 
-	return entry.ReadTileBytes(
+	// hooks run outside of the read, they may warm tiles or close the source.
+	s.fetched(ctx, z, x, y, entry)
+	return data, nil
+}
+
+// readEntry resolves and reads the tile for z, x, y.
+func (s *TileSource) readEntry(ctx context.Context, z, x, y uint64) (*Entry, []byte, error) {
+	if err := s.acquire(); err != nil {
+		return nil, nil, err
+	}
+	defer s.release()
+
+	entry, err := tileEntry(ctx, s.repository, s.Header(), s.reader, s.decompress, s.stats, z, x, y)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := entry.ReadTileBytes(
 		ctx,
 		s.reader,
 		s.header.TileDataOffset,
 	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, data, nil
 }
 
-// Header returns a copy of the current header.
+// overzoomTile derives the tile for z, x, y beyond MaxZoom from its parent.
+func (s *TileSource) overzoomTile(ctx context.Context, z, x, y uint64) ([]byte, error) {
+	dz := z - uint64(s.header.MaxZoom)
+	px, py := x>>dz, y>>dz
+
+	parent, err := s.readTile(ctx, uint64(s.header.MaxZoom), px, py)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.overzoom(ctx, *s.header, parent, uint8(dz), x-px<<dz, y-py<<dz) //nolint:gosec
+}
+
+// Coverage returns a Bitmap of the tiles present in the archive at zoom,
+// see Coverager.
+func (s *TileSource) Coverage(ctx context.Context, zoom uint8) (*Bitmap, error) {
+	return Coverage(ctx, s.repository, *s.header, s.reader, s.decompress, zoom)
+}
+
+// Entries yields every tile Entry of the archive in ascending TileID order,
+// see EntryReader.
+func (s *TileSource) Entries(ctx context.Context) iter.Seq2[Entry, error] {
+	return TileEntries(ctx, s.repository, *s.header, s.reader, s.decompress)
+}
+
+// EntryBytes reads the tile bytes of an Entry yielded by Entries, shared by
+// all tiles of its run, without resolving it in the directories again, see
+// EntryReader. With WithTileDecompression the bytes are decompressed. Tile
+// transforms, overzooming and fetch hooks are not applied.
+func (s *TileSource) EntryBytes(ctx context.Context, e Entry) ([]byte, error) {
+	if e.IsDirectory() {
+		return nil, fmt.Errorf("reading entry: tile id %s is a leaf directory", e.TileID)
+	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	data, err := e.ReadTileBytes(ctx, s.reader, s.header.TileDataOffset)
+	if err != nil || !s.tileDecomp {
+		return data, err
+	}
+	return s.decompressTile(data)
+}
+
+// Stats returns the directory lookup statistics of tiles read so far, see
+// StatsReporter.
+func (s *TileSource) Stats() Stats {
+	return s.stats.snapshot()
+}
+
+// Probe checks that the archive of the source is reachable, see Prober,
+// e.g. for the readiness check of a tile server.
+func (s *TileSource) Probe(ctx context.Context) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+
+	return Probe(ctx, s.reader)
+}
+
+// Header returns a copy of the current header. With WithTileDecompression,
+// TileCompression is CompressionNone.
 func (s *TileSource) Header() HeaderV3 {
-	return *s.header
+	header := *s.header
+	if s.tileDecomp {
+		header.TileCompression = CompressionNone
+	}
+	return header
 }
 
 // Meta returns a copy of the current metadata.
@@ -235,9 +539,59 @@ func (s *TileSource) Meta() Metadata {
 	return *s.meta
 }
 
-// Close the source and its dependencies.
+// Close the source and its dependencies, and stop background work such as
+// warming. Tile reads in flight are completed before the RangeReader is
+// closed, unless it was set with WithRangeReader. Reads from a closed source
+// fail with ErrSourceClosed. Close may be called more than once.
 func (s *TileSource) Close() {
-	s.repository.Close()
+	s.closed.Do(func() {
+		s.close()
+
+		s.reads.Lock()
+		defer s.reads.Unlock()
+
+		s.repository.Close()
+		if s.owned {
+			_ = CloseReader(s.reader) //nolint:errcheck
+		}
+	})
+}
+
+// Snapshot writes the cached directories of the source to w, see
+// Snapshotter.
+func (s *TileSource) Snapshot(ctx context.Context, w io.Writer) error {
+	r, ok := s.repository.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("writing snapshot: %T does not support snapshots", s.repository)
+	}
+	return r.Snapshot(ctx, w)
+}
+
+// Restore adds the directories of a snapshot to the directory cache of the
+// source, see Snapshotter. Directories of other archive versions are
+// restored as well, but never read.
+func (s *TileSource) Restore(ctx context.Context, r io.Reader) error {
+	repository, ok := s.repository.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("restoring snapshot: %T does not support snapshots", s.repository)
+	}
+	return repository.Restore(ctx, r)
+}
+
+// acquire holds off closing the reader until release is called. It returns
+// ErrSourceClosed once the source is closed.
+func (s *TileSource) acquire() error {
+	s.reads.RLock()
+	if s.lifetime.Err() != nil {
+		s.reads.RUnlock()
+		return ErrSourceClosed
+	}
+	return nil
+}
+
+// release ends a read started with acquire.
+func (s *TileSource) release() {
+	s.reads.RUnlock()
 }
 
 type TileJSON struct {
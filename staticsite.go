@@ -0,0 +1,181 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// staticSiteHost is the host passed to TileJSON, yielding tile URLs relative
+// to tilejson.json.
+const staticSiteHost = "."
+
+var staticSiteTemplate = template.Must(template.New("index.html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<link rel="stylesheet" href="https://unpkg.com/maplibre-gl@5/dist/maplibre-gl.css">
+<script src="https://unpkg.com/maplibre-gl@5/dist/maplibre-gl.js"></script>
+<style>body { margin: 0; } #map { position: absolute; inset: 0; }</style>
+</head>
+<body>
+<div id="map"></div>
+<script>
+const base = new URL(".", window.location.href).href;
+const style = {{ .Style }};
+for (const source of Object.values(style.sources)) {
+  source.tiles = source.tiles.map((tile) => new URL(tile, base).href);
+}
+new maplibregl.Map({ container: "map", style: style, center: {{ .Center }}, zoom: {{ .Zoom }}, hash: true });
+</script>
+</body>
+</html>
+`))
+
+// staticSiteColors are cycled through for the layers of vector archives.
+var staticSiteColors = []string{
+	"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#9a6324",
+}
+
+// ExportStaticSite writes the tiles of source to dir as a z/x/y tile tree
+// together with a tilejson.json and a minimal MapLibre index.html, so the
+// directory can be published to any static host. Tiles are written
+// decompressed. Export options such as WithCheckpoint apply.
+func ExportStaticSite(ctx context.Context, source Source, dir string, options ...ExportOption) error {
+	header := source.Header()
+	ext := header.TileType.Ext()
+
+	writer := TileWriterFunc(func(_ context.Context, z, x, y uint64, data []byte) error {
+		rc, err := Decompress(io.NopCloser(bytes.NewReader(data)), header.TileCompression)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		path := filepath.Join(
+			dir,
+			strconv.FormatUint(z, 10),
+			strconv.FormatUint(x, 10),
+			strconv.FormatUint(y, 10)+ext,
+		)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gosec
+			return err
+		}
+
+		f, err := os.Create(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, rc); err != nil {
+			_ = f.Close() //nolint:errcheck
+			return err
+		}
+		return f.Close()
+	})
+
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec
+		return fmt.Errorf("creating static site: %w", err)
+	}
+	if err := Export(ctx, source, writer, options...); err != nil {
+		return fmt.Errorf("exporting static site: %w", err)
+	}
+
+	tj, err := json.MarshalIndent(source.TileJSON(staticSiteHost), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding tilejson: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tilejson.json"), tj, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("writing tilejson: %w", err)
+	}
+
+	index, err := renderStaticSiteIndex(source)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), index, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("writing index.html: %w", err)
+	}
+
+	return nil
+}
+
+// renderStaticSiteIndex renders an index.html showing the archive with a
+// generated MapLibre style.
+func renderStaticSiteIndex(source Source) ([]byte, error) {
+	header := source.Header()
+	meta := source.Meta()
+
+	tileURL := fmt.Sprintf("{z}/{x}/{y}%s", header.TileType.Ext())
+	src := map[string]any{
+		"tiles":   []string{tileURL},
+		"minzoom": header.MinZoom,
+		"maxzoom": header.MaxZoom,
+	}
+	if meta.Attribution != "" {
+		src["attribution"] = meta.Attribution
+	}
+
+	var layers []map[string]any
+	if header.TileType.IsVector() {
+		src["type"] = "vector"
+		for i, l := range meta.VectorLayers {
+			color := staticSiteColors[i%len(staticSiteColors)]
+			layers = append(layers,
+				map[string]any{
+					"id": l.ID + "-fill", "type": "fill", "source": "archive", "source-layer": l.ID,
+					"filter": []any{"==", []any{"geometry-type"}, "Polygon"},
+					"paint":  map[string]any{"fill-color": color, "fill-opacity": 0.2},
+				},
+				map[string]any{
+					"id": l.ID + "-line", "type": "line", "source": "archive", "source-layer": l.ID,
+					"paint": map[string]any{"line-color": color, "line-width": 1},
+				},
+				map[string]any{
+					"id": l.ID + "-circle", "type": "circle", "source": "archive", "source-layer": l.ID,
+					"filter": []any{"==", []any{"geometry-type"}, "Point"},
+					"paint":  map[string]any{"circle-color": color, "circle-radius": 3},
+				},
+			)
+		}
+	} else {
+		src["type"] = "raster"
+		src["tileSize"] = 256
+		layers = append(layers, map[string]any{"id": "archive", "type": "raster", "source": "archive"})
+	}
+
+	style, err := json.Marshal(map[string]any{
+		"version": 8,
+		"sources": map[string]any{"archive": src},
+		"layers":  layers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding style: %w", err)
+	}
+
+	title := meta.Name
+	if title == "" {
+		title = "pmtilr"
+	}
+
+	center := fmt.Sprintf("[%d, %d]", header.CenterLonE7, header.CenterLatE7)
+
+	var buf bytes.Buffer
+	err = staticSiteTemplate.Execute(&buf, map[string]any{
+		"Title":  title,
+		"Style":  template.JS(style),  //nolint:gosec // marshaled by encoding/json
+		"Center": template.JS(center), //nolint:gosec // formatted integers
+		"Zoom":   header.CenterZoom,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rendering index.html: %w", err)
+	}
+	return buf.Bytes(), nil
+}
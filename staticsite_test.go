@@ -0,0 +1,61 @@
+package pmtilr_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/mvt"
+)
+
+func TestExportStaticSite(t *testing.T) {
+	src, err := pmtilr.NewSource(t.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+
+	dir := t.TempDir()
+	err = pmtilr.ExportStaticSite(
+		t.Context(), src, dir, pmtilr.WithExportZoomRange(pmtilr.NewZoomRange(0, 2)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "0", "0", "0.mvt"))
+	if err != nil {
+		t.Fatalf("expected tile 0/0/0: %s", err)
+	}
+	if _, err := mvt.Decode(data); err != nil {
+		t.Fatalf("expected decompressed vector tile: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "3")); !os.IsNotExist(err) {
+		t.Fatalf("expected no tiles beyond zoom 2, got: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "tilejson.json"))
+	if err != nil {
+		t.Fatalf("expected tilejson.json: %s", err)
+	}
+	var tj pmtilr.TileJSON
+	if err := json.Unmarshal(raw, &tj); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tj.Tiles) != 1 || tj.Tiles[0] != "./{z}/{x}/{y}.mvt" {
+		t.Fatalf("expected relative tile url, got: %v", tj.Tiles)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("expected index.html: %s", err)
+	}
+	layer := src.Meta().VectorLayers[0].ID
+	for _, want := range []string{"maplibregl.Map", `"source-layer":"` + layer + `"`, "{z}/{x}/{y}.mvt"} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("expected index.html to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package pmtilr
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// leafSizeBounds are the upper bounds in bytes of the leaf directory size
+// buckets of Stats.
+var leafSizeBounds = [...]uint64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, math.MaxUint64}
+
+// Stats holds the directory lookup statistics of a Source, e.g. to decide
+// whether archives need a bigger root directory or the directory cache is
+// sized adequately.
+type Stats struct {
+	// LookupsByDepth counts the tile lookups by the number of directories
+	// traversed to resolve them: index 0 resolved in the root directory,
+	// index 1 in a first level leaf directory and so on.
+	LookupsByDepth [directoryMaxDepth]uint64 `json:"lookups_by_depth"`
+	// LeafDirectorySizes is a histogram of the (compressed) byte sizes of
+	// the leaf directories traversed by lookups.
+	LeafDirectorySizes []SizeBucket `json:"leaf_directory_sizes"`
+}
+
+// SizeBucket counts the sizes up to and including UpperBound, and above the
+// UpperBound of the previous bucket.
+type SizeBucket struct {
+	UpperBound uint64 `json:"upper_bound"`
+	Count      uint64 `json:"count"`
+}
+
+// StatsReporter is implemented by Sources that record directory lookup
+// statistics, such as TileSource.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// sourceStats collects Stats with atomic counters.
+type sourceStats struct {
+	depths    [directoryMaxDepth]atomic.Uint64
+	leafSizes [len(leafSizeBounds)]atomic.Uint64
+}
+
+// observeLookup records a lookup resolved after traversing depth
+// directories.
+func (s *sourceStats) observeLookup(depth int) {
+	if s == nil || depth < 1 || depth > len(s.depths) {
+		return
+	}
+	s.depths[depth-1].Add(1)
+}
+
+// observeLeaf records a traversed leaf directory of size bytes.
+func (s *sourceStats) observeLeaf(size uint64) {
+	if s == nil {
+		return
+	}
+	for i, bound := range leafSizeBounds {
+		if size <= bound {
+			s.leafSizes[i].Add(1)
+			return
+		}
+	}
+}
+
+func (s *sourceStats) snapshot() Stats {
+	var stats Stats
+	for i := range s.depths {
+		stats.LookupsByDepth[i] = s.depths[i].Load()
+	}
+	stats.LeafDirectorySizes = make([]SizeBucket, len(leafSizeBounds))
+	for i, bound := range leafSizeBounds {
+		stats.LeafDirectorySizes[i] = SizeBucket{UpperBound: bound, Count: s.leafSizes[i].Load()}
+	}
+	return stats
+}
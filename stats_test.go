@@ -0,0 +1,64 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		archive    *pmtilrtest.Archive
+		wantDepths [3]uint64
+		wantLeaves uint64
+	}{
+		{
+			name:       "root directory",
+			archive:    pmtilrtest.FixtureArchive(2),
+			wantDepths: [3]uint64{3, 0, 0},
+		},
+		{
+			name:       "leaf directories",
+			archive:    pmtilrtest.FixtureArchive(2).WithLeafSize(4),
+			wantDepths: [3]uint64{0, 3, 0},
+			wantLeaves: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := pmtilr.NewSource(
+				t.Context(),
+				"memory",
+				pmtilr.WithRangeReader(tt.archive.RangeReader()),
+			)
+			if err != nil {
+				t.Fatalf("creating source: %v", err)
+			}
+
+			for _, zxy := range [][3]uint64{{0, 0, 0}, {1, 1, 0}, {2, 3, 3}} {
+				if _, err := source.Tile(t.Context(), zxy[0], zxy[1], zxy[2]); err != nil {
+					t.Fatalf("reading tile %v: %v", zxy, err)
+				}
+			}
+
+			stats := source.(pmtilr.StatsReporter).Stats()
+			if stats.LookupsByDepth != tt.wantDepths {
+				t.Errorf("lookups by depth = %v, want %v", stats.LookupsByDepth, tt.wantDepths)
+			}
+
+			var leaves uint64
+			for _, bucket := range stats.LeafDirectorySizes {
+				leaves += bucket.Count
+			}
+			if leaves != tt.wantLeaves {
+				t.Errorf("leaf directories = %d, want %d", leaves, tt.wantLeaves)
+			}
+			if tt.wantLeaves > 0 && stats.LeafDirectorySizes[0].Count != tt.wantLeaves {
+				t.Errorf("expected all leaf directories in the smallest bucket, got %v", stats.LeafDirectorySizes)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+type tieredConfig struct {
+	blockOptions []BlockCacheOption
+	diskDir      string
+	diskMaxBytes uint64
+	diskOptions  []DiskCacheOption
+}
+
+// TieredOption is a functional option for configuring a TieredRangeReader.
+type TieredOption = func(config *tieredConfig)
+
+// WithTieredBlockCache configures the memory tier, see
+// NewBlockCacheRangeReader.
+func WithTieredBlockCache(options ...BlockCacheOption) TieredOption {
+	return func(config *tieredConfig) {
+		config.blockOptions = append(config.blockOptions, options...)
+	}
+}
+
+// WithTieredDiskCache adds a disk tier caching up to maxBytes in dir, see
+// NewDiskCacheRangeReader. Without it, memory misses are read from the
+// remote reader.
+func WithTieredDiskCache(dir string, maxBytes uint64, options ...DiskCacheOption) TieredOption {
+	return func(config *tieredConfig) {
+		config.diskDir = dir
+		config.diskMaxBytes = maxBytes
+		config.diskOptions = append(config.diskOptions, options...)
+	}
+}
+
+// TieredStats holds the reads of a TieredRangeReader by tier. Memory
+// misses are read from the disk tier in blocks, disk misses from the
+// remote reader, so DiskReads-RemoteReads are the disk hits.
+type TieredStats struct {
+	// Reads counts the ranges read from the TieredRangeReader.
+	Reads uint64 `json:"reads"`
+	// DiskReads and DiskBytes count the block reads of the disk tier.
+	DiskReads uint64 `json:"disk_reads"`
+	DiskBytes uint64 `json:"disk_bytes"`
+	// RemoteReads and RemoteBytes count the reads of the remote reader.
+	RemoteReads uint64 `json:"remote_reads"`
+	RemoteBytes uint64 `json:"remote_bytes"`
+}
+
+// TieredRangeReader composes a BlockCacheRangeReader in memory, an optional
+// DiskCacheRangeReader and a remote RangeReader, e.g. for edge deployments
+// that should serve hot ranges from memory, warm ranges from local disk
+// across restarts and fetch only cold ranges from S3.
+type TieredRangeReader struct {
+	remote RangeReader
+	memory *BlockCacheRangeReader
+
+	reads      atomic.Uint64
+	diskTier   *tierCounter
+	remoteTier *tierCounter
+}
+
+// NewTieredRangeReader creates a TieredRangeReader caching reads of remote.
+func NewTieredRangeReader(remote RangeReader, options ...TieredOption) (*TieredRangeReader, error) {
+	cfg := tieredConfig{}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+
+	t := &TieredRangeReader{
+		remote:     remote,
+		remoteTier: &tierCounter{reader: remote},
+	}
+
+	var next RangeReader = t.remoteTier
+	if cfg.diskDir != "" {
+		disk, err := NewDiskCacheRangeReader(next, cfg.diskDir, cfg.diskMaxBytes, cfg.diskOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("creating disk tier: %w", err)
+		}
+		t.diskTier = &tierCounter{reader: disk}
+		next = t.diskTier
+	}
+
+	memory, err := NewBlockCacheRangeReader(next, cfg.blockOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("creating memory tier: %w", err)
+	}
+	t.memory = memory
+
+	return t, nil
+}
+
+// ReadRange reads the range from the fastest tier holding it.
+func (t *TieredRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	t.reads.Add(1)
+	return t.memory.ReadRange(ctx, ranger)
+}
+
+// Stats returns the reads by tier so far.
+func (t *TieredRangeReader) Stats() TieredStats {
+	stats := TieredStats{
+		Reads:       t.reads.Load(),
+		RemoteReads: t.remoteTier.reads.Load(),
+		RemoteBytes: t.remoteTier.bytes.Load(),
+	}
+	if t.diskTier != nil {
+		stats.DiskReads = t.diskTier.reads.Load()
+		stats.DiskBytes = t.diskTier.bytes.Load()
+	}
+	return stats
+}
+
+// Unwrap returns the remote reader, see Unwrapper.
+func (t *TieredRangeReader) Unwrap() RangeReader {
+	return t.remote
+}
+
+// Close drops the memory tier, closes the files of the disk tier and the
+// remote reader if it implements io.Closer.
+func (t *TieredRangeReader) Close() error {
+	return t.memory.Close()
+}
+
+// tierCounter counts the reads and bytes reaching a tier. It unwraps to the
+// reader of the tier, whose ETag keys the caches of the tiers above.
+type tierCounter struct {
+	reader RangeReader
+	reads  atomic.Uint64
+	bytes  atomic.Uint64
+}
+
+func (c *tierCounter) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	c.reads.Add(1)
+	rc, err := c.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		return nil, err
+	}
+	return newObservedBody(rc, func(n int64, _ error) {
+		c.bytes.Add(uint64(n)) //nolint:gosec // n is not negative
+	}), nil
+}
+
+func (c *tierCounter) Unwrap() RangeReader {
+	return c.reader
+}
@@ -0,0 +1,81 @@
+package pmtilr_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestTieredRangeReader(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	dir := t.TempDir()
+
+	read := func(t *testing.T, reader *pmtilr.TieredRangeReader, offset, length uint64) string {
+		t.Helper()
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(offset, length))
+		if err != nil {
+			t.Fatalf("reading range: %v", err)
+		}
+		defer rc.Close()
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("reading body: %v", err)
+		}
+		return string(got)
+	}
+
+	newReader := func(t *testing.T, options ...pmtilr.TieredOption) *pmtilr.TieredRangeReader {
+		t.Helper()
+		reader, err := pmtilr.NewTieredRangeReader(
+			pmtilr.NewBytesRangeReader(data),
+			append([]pmtilr.TieredOption{pmtilr.WithTieredBlockCache(pmtilr.WithBlockSize(8))}, options...)...,
+		)
+		if err != nil {
+			t.Fatalf("creating reader: %v", err)
+		}
+		t.Cleanup(func() { _ = reader.Close() })
+		return reader
+	}
+
+	t.Run("cold then hot", func(t *testing.T) {
+		reader := newReader(t, pmtilr.WithTieredDiskCache(dir, 1<<20))
+
+		for range 2 {
+			if got := read(t, reader, 2, 4); got != "2345" {
+				t.Fatalf("expected %q, got %q", "2345", got)
+			}
+		}
+
+		want := pmtilr.TieredStats{Reads: 2, DiskReads: 1, DiskBytes: 8, RemoteReads: 1, RemoteBytes: 8}
+		if got := reader.Stats(); got != want {
+			t.Fatalf("expected stats %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("warm after restart", func(t *testing.T) {
+		reader := newReader(t, pmtilr.WithTieredDiskCache(dir, 1<<20))
+
+		if got := read(t, reader, 2, 4); got != "2345" {
+			t.Fatalf("expected %q, got %q", "2345", got)
+		}
+
+		want := pmtilr.TieredStats{Reads: 1, DiskReads: 1, DiskBytes: 8}
+		if got := reader.Stats(); got != want {
+			t.Fatalf("expected stats %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("without disk tier", func(t *testing.T) {
+		reader := newReader(t)
+
+		if got := read(t, reader, 30, 10); got != "uvwxyz" {
+			t.Fatalf("expected %q, got %q", "uvwxyz", got)
+		}
+
+		want := pmtilr.TieredStats{Reads: 1, RemoteReads: 1, RemoteBytes: 12}
+		if got := reader.Stats(); got != want {
+			t.Fatalf("expected stats %+v, got %+v", want, got)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package pmtilr
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type TileType uint8
@@ -39,6 +40,36 @@ func (c TileType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+// ParseTileType parses the name of a tile type as returned by String,
+// case-insensitively. "jpg" is accepted for TileTypeJPEG.
+func ParseTileType(s string) (TileType, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "jpg" {
+		return TileTypeJPEG, nil
+	}
+	for t, str := range tileTypeOptions {
+		if str == name {
+			return t, nil
+		}
+	}
+	return TileTypeUnknown, fmt.Errorf("unknown tile type %q", s)
+}
+
+// UnmarshalJSON unmarshals a TileType from a JSON string (e.g. "mvt"), the
+// inverse of MarshalJSON.
+func (t *TileType) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("unmarshaling tile type: %w", err)
+	}
+	parsed, err := ParseTileType(str)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
 func (t TileType) Ext() string {
 	return fmt.Sprintf(".%s", tileTypeOptions[t])
 }
@@ -62,6 +93,22 @@ func (t TileType) ToContentType() (string, bool) {
 	}
 }
 
+// ContentType returns the registered MIME type of the tile type, e.g.
+// application/vnd.mapbox-vector-tile for MVT, or application/octet-stream
+// if unknown. Unlike ToContentType, which returns the application/x-protobuf
+// most tile clients expect for MVT, it is meant for content negotiation.
+func (t TileType) ContentType() string {
+	switch t {
+	case TileTypeMVT:
+		return "application/vnd.mapbox-vector-tile"
+	default:
+		if ct, ok := t.ToContentType(); ok {
+			return ct
+		}
+		return "application/octet-stream"
+	}
+}
+
 func (t TileType) IsVector() bool {
 	return t == TileTypeMVT || t == TileTypeMLT
 }
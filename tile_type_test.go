@@ -0,0 +1,89 @@
+package pmtilr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTileType(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    TileType
+		expectError bool
+	}{
+		{input: "mvt", expected: TileTypeMVT},
+		{input: " PNG ", expected: TileTypePNG},
+		{input: "jpeg", expected: TileTypeJPEG},
+		{input: "jpg", expected: TileTypeJPEG},
+		{input: "webp", expected: TileTypeWebp},
+		{input: "avif", expected: TileTypeAvif},
+		{input: "mlt", expected: TileTypeMLT},
+		{input: "unknown", expected: TileTypeUnknown},
+		{input: "gif", expectError: true},
+		{input: "", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseTileType(tc.input)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("got %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestTileTypeJSONRoundTrip(t *testing.T) {
+	for tt := range tileTypeOptions {
+		t.Run(tt.String(), func(t *testing.T) {
+			data, err := json.Marshal(tt)
+			if err != nil {
+				t.Fatalf("marshaling: %v", err)
+			}
+			var got TileType
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshaling %s: %v", data, err)
+			}
+			if got != tt {
+				t.Errorf("got %v, want %v", got, tt)
+			}
+		})
+	}
+
+	var tt TileType
+	if err := json.Unmarshal([]byte(`1`), &tt); err == nil {
+		t.Error("expected error for non-string JSON")
+	}
+}
+
+func TestTileTypeContentType(t *testing.T) {
+	tests := []struct {
+		tileType TileType
+		expected string
+	}{
+		{tileType: TileTypeMVT, expected: "application/vnd.mapbox-vector-tile"},
+		{tileType: TileTypeMLT, expected: "application/vnd.maplibre-vector-tile"},
+		{tileType: TileTypePNG, expected: "image/png"},
+		{tileType: TileTypeJPEG, expected: "image/jpeg"},
+		{tileType: TileTypeWebp, expected: "image/webp"},
+		{tileType: TileTypeAvif, expected: "image/avif"},
+		{tileType: TileTypeUnknown, expected: "application/octet-stream"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tileType.String(), func(t *testing.T) {
+			if got := tc.tileType.ContentType(); got != tc.expected {
+				t.Errorf("got %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
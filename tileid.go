@@ -0,0 +1,84 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+)
+
+// TileID is the position of a tile on the Hilbert curve of a PMTiles
+// archive. Use it instead of bare uint64s, which are easy to mix up with
+// offsets and lengths.
+type TileID uint64
+
+// NewTileID returns the TileID of the tile at z/x/y.
+func NewTileID(z, x, y uint64) (TileID, error) {
+	if z > MaxZ {
+		return 0, fmt.Errorf("zoom %d exceeds limit of %d", z, MaxZ)
+	}
+	id, err := FastZXYToHilbertTileID(z, x, y)
+	return TileID(id), err
+}
+
+// maxTileID is the first tile id beyond MaxZ.
+const maxTileID TileID = (1<<(2*(MaxZ+1)) - 1) / 3
+
+// Valid reports whether the id addresses a tile up to MaxZ.
+func (id TileID) Valid() bool {
+	return id < maxTileID
+}
+
+// Zoom returns the zoom level of the tile, see Valid.
+func (id TileID) Zoom() uint8 {
+	return uint8(ZoomFromHilbertTileID(uint64(id))) //nolint:gosec // at most 31
+}
+
+// ZXY returns the coordinates of the tile. Invalid ids yield 0/0/0.
+func (id TileID) ZXY() (z, x, y uint64) {
+	if !id.Valid() {
+		return 0, 0, 0
+	}
+	zxy, err := FastZXYfromHilbertTileID(uint64(id))
+	if err != nil {
+		return 0, 0, 0
+	}
+	return zxy[0], zxy[1], zxy[2]
+}
+
+// String returns the coordinates of the tile as "z/x/y".
+func (id TileID) String() string {
+	if !id.Valid() {
+		return fmt.Sprintf("invalid(%d)", uint64(id))
+	}
+	z, x, y := id.ZXY()
+	return fmt.Sprintf("%d/%d/%d", z, x, y)
+}
+
+// checkedZXY returns the coordinates of the tile, failing for invalid ids.
+func (id TileID) checkedZXY() (z, x, y uint64, err error) {
+	if !id.Valid() {
+		return 0, 0, 0, fmt.Errorf("invalid tile id %d beyond zoom %d", uint64(id), MaxZ)
+	}
+	z, x, y = id.ZXY()
+	return z, x, y, nil
+}
+
+// TileIDSource is implemented by Sources that look up tiles by TileID, such
+// as TileSource.
+type TileIDSource interface {
+	// TileByID returns the tile bytes for id.
+	TileByID(ctx context.Context, id TileID) ([]byte, error)
+}
+
+// TileByID returns the tile bytes for id of source. Sources not
+// implementing TileIDSource fall back to Source.Tile.
+func TileByID(ctx context.Context, source Source, id TileID) ([]byte, error) {
+	if s, ok := source.(TileIDSource); ok {
+		return s.TileByID(ctx, id)
+	}
+
+	z, x, y, err := id.checkedZXY()
+	if err != nil {
+		return nil, err
+	}
+	return source.Tile(ctx, z, x, y)
+}
@@ -0,0 +1,113 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestTileID(t *testing.T) {
+	tests := []struct {
+		name      string
+		z, x, y   uint64
+		wantID    pmtilr.TileID
+		wantError bool
+	}{
+		{name: "root", z: 0, x: 0, y: 0, wantID: 0},
+		{name: "zoom 1", z: 1, x: 1, y: 0, wantID: 4},
+		{name: "zoom 2", z: 2, x: 1, y: 3, wantID: 11},
+		{name: "max zoom", z: pmtilr.MaxZ, x: 1<<pmtilr.MaxZ - 1, y: 0},
+		{name: "beyond max zoom", z: pmtilr.MaxZ + 1, wantError: true},
+		{name: "outside zoom", z: 1, x: 2, y: 0, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := pmtilr.NewTileID(tt.z, tt.x, tt.y)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got %d", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.wantID != 0 && id != tt.wantID {
+				t.Errorf("expected id %d, got %d", tt.wantID, id)
+			}
+
+			if !id.Valid() {
+				t.Errorf("expected %d to be valid", id)
+			}
+			if got := id.Zoom(); uint64(got) != tt.z {
+				t.Errorf("expected zoom %d, got %d", tt.z, got)
+			}
+			if z, x, y := id.ZXY(); z != tt.z || x != tt.x || y != tt.y {
+				t.Errorf("expected %d/%d/%d, got %d/%d/%d", tt.z, tt.x, tt.y, z, x, y)
+			}
+		})
+	}
+}
+
+func TestTileIDString(t *testing.T) {
+	id, _ := pmtilr.NewTileID(14, 8943, 5372)
+	if got := id.String(); got != "14/8943/5372" {
+		t.Errorf("expected 14/8943/5372, got %s", got)
+	}
+
+	invalid := pmtilr.TileID(1<<63 - 1)
+	if invalid.Valid() {
+		t.Errorf("expected %d to be invalid", uint64(invalid))
+	}
+	if got := invalid.String(); got != "invalid(9223372036854775807)" {
+		t.Errorf("unexpected string %s", got)
+	}
+	if z, x, y := invalid.ZXY(); z != 0 || x != 0 || y != 0 {
+		t.Errorf("expected 0/0/0, got %d/%d/%d", z, x, y)
+	}
+}
+
+func TestTileIDValid(t *testing.T) {
+	// the Hilbert curve of a zoom level ends at x = 2^z-1, y = 0
+	last, _ := pmtilr.NewTileID(pmtilr.MaxZ, 1<<pmtilr.MaxZ-1, 0)
+	for _, tt := range []struct {
+		id   pmtilr.TileID
+		want bool
+	}{
+		{id: 0, want: true},
+		{id: last, want: true},
+		{id: last + 1},
+		{id: 1 << 62},
+		{id: 1<<64 - 1},
+	} {
+		if got := tt.id.Valid(); got != tt.want {
+			t.Errorf("expected %d valid %t, got %t", uint64(tt.id), tt.want, got)
+		}
+	}
+}
+
+func TestTileByID(t *testing.T) {
+	archive := pmtilrtest.FixtureArchive(2)
+	source, err := pmtilr.NewSource(t.Context(), "", pmtilr.WithRangeReader(archive.RangeReader()))
+	if err != nil {
+		t.Fatalf("creating source: %s", err)
+	}
+	if _, ok := source.(pmtilr.TileIDSource); !ok {
+		t.Fatalf("expected %T to implement TileIDSource", source)
+	}
+
+	id, _ := pmtilr.NewTileID(2, 1, 3)
+	data, err := pmtilr.TileByID(t.Context(), source, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(data) != "2/1/3" {
+		t.Errorf("got tile %q, want %q", data, "2/1/3")
+	}
+
+	if _, err := pmtilr.TileByID(t.Context(), source, pmtilr.TileID(1<<63-1)); err == nil {
+		t.Error("expected error for an invalid tile id")
+	}
+}
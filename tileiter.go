@@ -0,0 +1,56 @@
+package pmtilr
+
+import "iter"
+
+type tileIDsConfig struct {
+	bounds *Bounds
+}
+
+// TileIDsOption is a functional option for configuring IterTileIDs.
+type TileIDsOption = func(config *tileIDsConfig)
+
+// WithTileIDsBounds limits IterTileIDs to the tiles intersecting bounds.
+func WithTileIDsBounds(bounds Bounds) TileIDsOption {
+	return func(config *tileIDsConfig) {
+		config.bounds = &bounds
+	}
+}
+
+// IterTileIDs yields the ids of all tiles from minZoom to maxZoom in
+// Hilbert order, the order of the tiles in the archive, e.g. to warm caches
+// or export an archive in sequential reads. Zooms beyond MaxZ are skipped,
+// as are all tiles if the bounds of WithTileIDsBounds are invalid.
+func IterTileIDs(minZoom, maxZoom uint8, options ...TileIDsOption) iter.Seq[TileID] {
+	cfg := tileIDsConfig{}
+	for _, optFn := range options {
+		optFn(&cfg)
+	}
+
+	return func(yield func(TileID) bool) {
+		if cfg.bounds != nil && cfg.bounds.Validate() != nil {
+			return
+		}
+
+		for zoom := uint64(minZoom); zoom <= uint64(min(maxZoom, MaxZ)); zoom++ {
+			z := uint8(zoom) //nolint:gosec // at most MaxZ
+
+			if cfg.bounds == nil {
+				first := zoomBaseTileID(z)
+				for id := first; id < zoomBaseTileID(z+1); id++ {
+					if !yield(TileID(id)) {
+						return
+					}
+				}
+				continue
+			}
+
+			minX, minY, maxX, maxY := cfg.bounds.TileRange(z)
+			r := TileRange{Zoom: z, MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}
+			for id := range r.TileIDs() {
+				if !yield(id) {
+					return
+				}
+			}
+		}
+	}
+}
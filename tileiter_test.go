@@ -0,0 +1,84 @@
+package pmtilr_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestIterTileIDs(t *testing.T) {
+	berlin := pmtilr.NewBounds(13.3, 52.4, 13.5, 52.6)
+
+	tests := []struct {
+		name     string
+		minZoom  uint8
+		maxZoom  uint8
+		options  []pmtilr.TileIDsOption
+		expected func(z, x, y uint64) bool
+		zooms    [2]uint64
+	}{
+		{
+			name: "all tiles", minZoom: 0, maxZoom: 3, zooms: [2]uint64{0, 3},
+			expected: func(_, _, _ uint64) bool { return true },
+		},
+		{
+			name: "single zoom", minZoom: 2, maxZoom: 2, zooms: [2]uint64{2, 2},
+			expected: func(_, _, _ uint64) bool { return true },
+		},
+		{
+			name: "bounds", minZoom: 4, maxZoom: 8, zooms: [2]uint64{4, 8},
+			options: []pmtilr.TileIDsOption{pmtilr.WithTileIDsBounds(berlin)},
+			expected: func(z, x, y uint64) bool {
+				minX, minY, maxX, maxY := berlin.TileRange(uint8(z))
+				return x >= minX && x <= maxX && y >= minY && y <= maxY
+			},
+		},
+		{
+			name: "inverted zooms", minZoom: 3, maxZoom: 2, zooms: [2]uint64{1, 0},
+		},
+		{
+			name: "invalid bounds", minZoom: 0, maxZoom: 2, zooms: [2]uint64{1, 0},
+			options: []pmtilr.TileIDsOption{pmtilr.WithTileIDsBounds(pmtilr.NewBounds(10, 0, 0, 10))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected []pmtilr.TileID
+			for z := tt.zooms[0]; z <= tt.zooms[1]; z++ {
+				for x := range uint64(1) << z {
+					for y := range uint64(1) << z {
+						if !tt.expected(z, x, y) {
+							continue
+						}
+						id, err := pmtilr.NewTileID(z, x, y)
+						if err != nil {
+							t.Fatalf("unexpected error: %s", err)
+						}
+						expected = append(expected, id)
+					}
+				}
+			}
+			slices.Sort(expected)
+
+			got := slices.Collect(pmtilr.IterTileIDs(tt.minZoom, tt.maxZoom, tt.options...))
+			if !slices.Equal(got, expected) {
+				t.Fatalf("expected %v, got: %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestIterTileIDsStops(t *testing.T) {
+	var got []pmtilr.TileID
+	for id := range pmtilr.IterTileIDs(0, pmtilr.MaxZ) {
+		if len(got) == 3 {
+			break
+		}
+		got = append(got, id)
+	}
+	if expected := []pmtilr.TileID{0, 1, 2}; !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got: %v", expected, got)
+	}
+}
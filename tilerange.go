@@ -0,0 +1,94 @@
+package pmtilr
+
+import (
+	"fmt"
+	"iter"
+)
+
+// TileRange is the inclusive x/y extent of tiles at a zoom level.
+type TileRange struct {
+	Zoom uint8
+	MinX uint64
+	MinY uint64
+	MaxX uint64
+	MaxY uint64
+}
+
+// TileRangeForBounds returns the range of tiles at zoom intersecting the
+// WGS84 bounding box, e.g. to export, prefetch or query the tiles of a
+// region. Coordinates beyond the web mercator extent are clamped.
+func TileRangeForBounds(minLon, minLat, maxLon, maxLat float64, zoom uint8) (TileRange, error) {
+	bounds := NewBounds(minLon, minLat, maxLon, maxLat)
+	if err := bounds.Validate(); err != nil {
+		return TileRange{}, err
+	}
+	if zoom > MaxZ {
+		return TileRange{}, fmt.Errorf("zoom %d exceeds limit of %d", zoom, MaxZ)
+	}
+
+	minX, minY, maxX, maxY := bounds.TileRange(zoom)
+	return TileRange{Zoom: zoom, MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}, nil
+}
+
+// Count returns the number of tiles in the range.
+func (r TileRange) Count() uint64 {
+	if r.MinX > r.MaxX || r.MinY > r.MaxY {
+		return 0
+	}
+	return (r.MaxX - r.MinX + 1) * (r.MaxY - r.MinY + 1)
+}
+
+// Contains reports whether the tile at x/y lies in the range.
+func (r TileRange) Contains(x, y uint64) bool {
+	return x >= r.MinX && x <= r.MaxX && y >= r.MinY && y <= r.MaxY
+}
+
+// TileIDs yields the ids of the tiles in the range in ascending order, the
+// order of the tiles in the archive. It descends the Hilbert curve from
+// zoom 0 and skips quadrants outside the range, so a small range at a high
+// zoom is cheap to walk.
+func (r TileRange) TileIDs() iter.Seq[TileID] {
+	return func(yield func(TileID) bool) {
+		base := zoomBaseTileID(r.Zoom)
+
+		// walk visits the quadrant with the relative Hilbert index t at
+		// zoom, which covers the relative ids t<<2d to (t+1)<<2d-1 at
+		// r.Zoom, where d is the difference in zoom.
+		var walk func(zoom uint8, t uint64) bool
+		walk = func(zoom uint8, t uint64) bool {
+			zxy, err := FastZXYfromHilbertTileID(zoomBaseTileID(zoom) + t)
+			if err != nil {
+				return false
+			}
+
+			d := r.Zoom - zoom
+			minX, minY := zxy[1]<<d, zxy[2]<<d
+			maxX, maxY := minX+(1<<d)-1, minY+(1<<d)-1
+			if maxX < r.MinX || minX > r.MaxX || maxY < r.MinY || minY > r.MaxY {
+				return true
+			}
+
+			if minX >= r.MinX && maxX <= r.MaxX && minY >= r.MinY && maxY <= r.MaxY {
+				first := base + t<<(2*d)
+				for id := first; id < first+1<<(2*d); id++ {
+					if !yield(TileID(id)) {
+						return false
+					}
+				}
+				return true
+			}
+
+			for child := range uint64(4) {
+				if !walk(zoom+1, t<<2+child) {
+					return false
+				}
+			}
+			return true
+		}
+
+		if r.Zoom > MaxZ || r.MinX > r.MaxX || r.MinY > r.MaxY {
+			return
+		}
+		walk(0, 0)
+	}
+}
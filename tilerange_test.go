@@ -0,0 +1,84 @@
+package pmtilr_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestTileRangeForBounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		bounds    [4]float64
+		zoom      uint8
+		expected  pmtilr.TileRange
+		count     uint64
+		wantError bool
+	}{
+		{name: "world z0", bounds: [4]float64{-180, -90, 180, 90}, zoom: 0, expected: pmtilr.TileRange{}, count: 1},
+		{name: "world z3", bounds: [4]float64{-180, -90, 180, 90}, zoom: 3, expected: pmtilr.TileRange{Zoom: 3, MaxX: 7, MaxY: 7}, count: 64},
+		{name: "berlin z10", bounds: [4]float64{13.3, 52.4, 13.5, 52.6}, zoom: 10, expected: pmtilr.TileRange{Zoom: 10, MinX: 549, MinY: 335, MaxX: 550, MaxY: 336}, count: 4},
+		{name: "inverted", bounds: [4]float64{10, 0, 0, 10}, zoom: 2, wantError: true},
+		{name: "beyond max zoom", bounds: [4]float64{0, 0, 1, 1}, zoom: pmtilr.MaxZ + 1, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := pmtilr.TileRangeForBounds(tt.bounds[0], tt.bounds[1], tt.bounds[2], tt.bounds[3], tt.zoom)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if r != tt.expected {
+				t.Fatalf("expected %+v, got: %+v", tt.expected, r)
+			}
+			if got := r.Count(); got != tt.count {
+				t.Fatalf("expected count %d, got: %d", tt.count, got)
+			}
+		})
+	}
+}
+
+func TestTileRangeTileIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		r    pmtilr.TileRange
+	}{
+		{name: "root", r: pmtilr.TileRange{}},
+		{name: "whole zoom", r: pmtilr.TileRange{Zoom: 3, MaxX: 7, MaxY: 7}},
+		{name: "single tile", r: pmtilr.TileRange{Zoom: 4, MinX: 5, MinY: 9, MaxX: 5, MaxY: 9}},
+		{name: "unaligned", r: pmtilr.TileRange{Zoom: 5, MinX: 3, MinY: 7, MaxX: 20, MaxY: 12}},
+		{name: "max zoom", r: pmtilr.TileRange{Zoom: pmtilr.MaxZ, MinX: 1<<25 - 2, MinY: 1<<24 + 3, MaxX: 1<<25 + 1, MaxY: 1<<24 + 5}},
+		{name: "empty", r: pmtilr.TileRange{Zoom: 2, MinX: 2, MaxX: 1, MaxY: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected []pmtilr.TileID
+			for x := tt.r.MinX; x <= tt.r.MaxX; x++ {
+				for y := tt.r.MinY; y <= tt.r.MaxY; y++ {
+					id, err := pmtilr.NewTileID(uint64(tt.r.Zoom), x, y)
+					if err != nil {
+						t.Fatalf("unexpected error: %s", err)
+					}
+					expected = append(expected, id)
+				}
+			}
+			slices.Sort(expected)
+
+			got := slices.Collect(tt.r.TileIDs())
+			if !slices.Equal(got, expected) {
+				t.Fatalf("expected %v, got: %v", expected, got)
+			}
+			if uint64(len(got)) != tt.r.Count() {
+				t.Fatalf("expected count %d, got: %d", len(got), tt.r.Count())
+			}
+		})
+	}
+}
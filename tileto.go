@@ -0,0 +1,153 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// TileWriterTo is implemented by Sources that stream a tile to a writer
+// without buffering it in memory.
+type TileWriterTo interface {
+	// TileTo writes the tile bytes for z, x, y to w and returns the number
+	// of bytes written.
+	TileTo(ctx context.Context, w io.Writer, z, x, y uint64) (int64, error)
+}
+
+// TileTo writes the tile bytes for z, x, y of source to w. Sources
+// implementing TileWriterTo stream the tile, e.g. with sendfile from a
+// local archive to a socket, others fall back to Source.Tile.
+func TileTo(ctx context.Context, source Source, w io.Writer, z, x, y uint64) (int64, error) {
+	if s, ok := source.(TileWriterTo); ok {
+		return s.TileTo(ctx, w, z, x, y)
+	}
+
+	data, err := source.Tile(ctx, z, x, y)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// TileTo streams the tile bytes for z, x, y from the RangeReader to w. Tiles
+// that are overzoomed, transformed or decompressed are buffered as with Tile.
+func (s *TileSource) TileTo(ctx context.Context, w io.Writer, z, x, y uint64) (int64, error) {
+	if s.transform != nil || s.tileDecomp || z > uint64(s.header.MaxZoom) || z < uint64(s.header.MinZoom) {
+		data, err := s.Tile(ctx, z, x, y)
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(data)
+		return int64(n), err
+	}
+
+	entry, n, err := s.copyEntry(ctx, w, z, x, y)
+	if err != nil {
+		return n, err
+	}
+
+	s.fetched(ctx, z, x, y, entry)
+	return n, nil
+}
+
+// copyEntry resolves the tile for z, x, y and copies it to w.
+func (s *TileSource) copyEntry(ctx context.Context, w io.Writer, z, x, y uint64) (*Entry, int64, error) {
+	if err := s.acquire(); err != nil {
+		return nil, 0, err
+	}
+	defer s.release()
+
+	entry, err := tileEntry(ctx, s.repository, *s.header, s.reader, s.decompress, s.stats, z, x, y)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rc, err := s.reader.ReadRange(ctx, NewRange(s.header.TileDataOffset+entry.Offset, entry.Length))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	n, err := io.Copy(w, rc)
+	if err != nil {
+		return nil, n, fmt.Errorf("writing tile: %w", err)
+	}
+	if uint64(n) != entry.Length { //nolint:gosec
+		return nil, n, fmt.Errorf("writing tile: %w", io.ErrUnexpectedEOF)
+	}
+
+	return entry, n, nil
+}
+
+// TileTo streams the tile of the current Source to w. If the archive changed
+// underneath the current Source before anything was written, it is
+// refreshed and the read retried once.
+func (rs *RefreshingSource) TileTo(ctx context.Context, w io.Writer, z, x, y uint64) (int64, error) {
+	tileTo := func(src Source) (int64, error) { return TileTo(ctx, src, w, z, x, y) }
+	current, n, err := withCurrent(rs, tileTo)
+	if n > 0 || !errors.Is(err, ErrArchiveChanged) {
+		return n, err
+	}
+
+	if rerr := rs.refreshStale(ctx, current); rerr != nil {
+		return 0, errors.Join(err, rerr)
+	}
+	_, n, err = withCurrent(rs, tileTo)
+	return n, err
+}
+
+// copyBufPool holds the buffers of fileSection copies without sendfile.
+var copyBufPool = newPool(func() *[]byte {
+	buf := make([]byte, 32*1024)
+	return &buf
+})
+
+// fileSection is the io.ReadCloser returned by FileRangeReader. It
+// implements io.WriterTo to copy with sendfile to sockets and files where
+// supported, without passing the bytes through user space.
+type fileSection struct {
+	*io.SectionReader
+	file   io.ReaderAt
+	offset int64
+}
+
+func newFileSection(file io.ReaderAt, offset, length int64) *fileSection {
+	return &fileSection{
+		SectionReader: io.NewSectionReader(file, offset, length),
+		file:          file,
+		offset:        offset,
+	}
+}
+
+// Close is a no-op, the file is owned by the FileRangeReader.
+func (s *fileSection) Close() error {
+	return nil
+}
+
+// WriteTo implements io.WriterTo.
+func (s *fileSection) WriteTo(w io.Writer) (int64, error) {
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	dst, isConn := w.(syscall.Conn)
+	src, isFile := s.file.(*os.File)
+	if isConn && isFile && s.Size() > pos {
+		n, handled, err := sendfile(dst, src, s.offset+pos, s.Size()-pos)
+		if handled {
+			if _, serr := s.Seek(n, io.SeekCurrent); serr != nil && err == nil {
+				err = serr
+			}
+			return n, err
+		}
+	}
+
+	buf := copyBufPool.Get()
+	defer copyBufPool.Put(buf)
+	return io.CopyBuffer(w, s.SectionReader, *buf)
+}
@@ -0,0 +1,164 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+// bufferedSource hides the TileWriterTo implementation of a Source.
+type bufferedSource struct {
+	pmtilr.Source
+}
+
+func TestTileTo(t *testing.T) {
+	ctx := t.Context()
+
+	source, err := pmtilr.NewSource(ctx, testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+	want, err := source.Tile(ctx, 4, 3, 5)
+	if err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		source pmtilr.Source
+		writer func(t *testing.T) (io.Writer, func() []byte)
+	}{
+		{
+			name:   "buffer",
+			source: source,
+			writer: func(_ *testing.T) (io.Writer, func() []byte) {
+				var buf bytes.Buffer
+				return &buf, buf.Bytes
+			},
+		},
+		{
+			name:   "file",
+			source: source,
+			writer: func(t *testing.T) (io.Writer, func() []byte) {
+				path := filepath.Join(t.TempDir(), "tile")
+				f, err := os.Create(path)
+				if err != nil {
+					t.Fatalf("creating file: %v", err)
+				}
+				t.Cleanup(func() { _ = f.Close() })
+				return f, func() []byte {
+					data, _ := os.ReadFile(path)
+					return data
+				}
+			},
+		},
+		{
+			name:   "tcp",
+			source: source,
+			writer: tcpWriter,
+		},
+		{
+			name:   "fallback",
+			source: bufferedSource{source},
+			writer: func(_ *testing.T) (io.Writer, func() []byte) {
+				var buf bytes.Buffer
+				return &buf, buf.Bytes
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, written := tt.writer(t)
+
+			n, err := pmtilr.TileTo(ctx, tt.source, w, 4, 3, 5)
+			if err != nil {
+				t.Fatalf("writing tile: %v", err)
+			}
+			if n != int64(len(want)) {
+				t.Errorf("wrote %d bytes, want %d", n, len(want))
+			}
+			if got := written(); !bytes.Equal(got, want) {
+				t.Errorf("written tile differs from Tile: %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if _, err := pmtilr.TileTo(ctx, source, &buf, 7, 0, 0); err == nil {
+		t.Error("expected error for missing tile")
+	}
+}
+
+// tcpWriter returns the client side of a loopback TCP connection and a
+// func returning everything received on the server side.
+func tcpWriter(t *testing.T) (io.Writer, func() []byte) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, func() []byte {
+		_ = conn.(*net.TCPConn).CloseWrite()
+		return <-received
+	}
+}
+
+func BenchmarkTileTo(b *testing.B) {
+	source, err := pmtilr.NewSource(b.Context(), testArchive, pmtilr.WithDisableInstrumentation())
+	if err != nil {
+		b.Fatalf("creating source: %v", err)
+	}
+
+	f, err := os.Create(filepath.Join(b.TempDir(), "tiles"))
+	if err != nil {
+		b.Fatalf("creating file: %v", err)
+	}
+	defer f.Close()
+
+	b.Run("TileTo", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := pmtilr.TileTo(b.Context(), source, f, 4, 3, 5); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Tile", func(b *testing.B) {
+		for b.Loop() {
+			data, err := source.Tile(b.Context(), 4, 3, 5)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := f.Write(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
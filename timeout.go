@@ -0,0 +1,90 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TimeoutRangeReader enforces a deadline on every read, covering both the
+// ReadRange call and reading the returned body, so a stuck backend request
+// cannot stall a tile request indefinitely, regardless of the deadline of
+// the caller's context.
+//
+// Reads exceeding the timeout fail with context.DeadlineExceeded, which
+// IsRetryable classifies as transient, so it composes with
+// NewRetryRangeReader.
+type TimeoutRangeReader struct {
+	reader  RangeReader
+	timeout time.Duration
+}
+
+// NewTimeoutRangeReader wraps reader to fail reads taking longer than
+// timeout.
+func NewTimeoutRangeReader(reader RangeReader, timeout time.Duration) *TimeoutRangeReader {
+	return &TimeoutRangeReader{reader: reader, timeout: timeout}
+}
+
+// ReadRange reads the range within the timeout. The body must be read and
+// closed within the same timeout.
+func (t *TimeoutRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+
+	rc, err := t.reader.ReadRange(ctx, ranger)
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("reading range: %w", ctx.Err())
+		}
+		return nil, err
+	}
+
+	body := &timeoutBody{rc: rc, ctx: ctx, cancel: cancel}
+	// closing the body unblocks readers not observing the context.
+	body.stop = context.AfterFunc(ctx, func() { _ = body.closeOnce() }) //nolint:errcheck
+	return body, nil
+}
+
+// Unwrap returns the wrapped reader, see Unwrapper.
+func (t *TimeoutRangeReader) Unwrap() RangeReader {
+	return t.reader
+}
+
+// timeoutBody is a body bound to the deadline of its read.
+type timeoutBody struct {
+	rc     io.ReadCloser
+	ctx    context.Context //nolint:containedctx // the deadline of the read
+	cancel context.CancelFunc
+	stop   func() bool
+
+	once sync.Once
+	err  error
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("reading range: %w", err)
+	}
+
+	n, err := b.rc.Read(p)
+	if err != nil && err != io.EOF && b.ctx.Err() != nil { //nolint:errorlint // io.EOF is returned unwrapped
+		return n, fmt.Errorf("reading range: %w", b.ctx.Err())
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	b.stop()
+	err := b.closeOnce()
+	b.cancel()
+	return err
+}
+
+func (b *timeoutBody) closeOnce() error {
+	b.once.Do(func() {
+		b.err = b.rc.Close()
+	})
+	return b.err
+}
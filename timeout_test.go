@@ -0,0 +1,65 @@
+package pmtilr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+// stuckBodyReader returns bodies that block until closed.
+type stuckBodyReader struct{}
+
+func (stuckBodyReader) ReadRange(context.Context, pmtilr.Ranger) (io.ReadCloser, error) {
+	pr, _ := io.Pipe()
+	return pr, nil
+}
+
+func TestTimeoutRangeReader(t *testing.T) {
+	t.Run("within timeout", func(t *testing.T) {
+		reader := pmtilr.NewTimeoutRangeReader(pmtilrtest.NewRangeReader([]byte("0123456789")), time.Second)
+
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 3))
+		if err != nil {
+			t.Fatalf("reading range: %v", err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		if err != nil || string(data) != "234" {
+			t.Errorf("got %q, %v, want %q", data, err, "234")
+		}
+	})
+
+	t.Run("stuck call", func(t *testing.T) {
+		mock := pmtilrtest.NewRangeReader([]byte("0123456789")).WithLatency(time.Hour)
+		reader := pmtilr.NewTimeoutRangeReader(mock, 10*time.Millisecond)
+
+		_, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 3))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("stuck body", func(t *testing.T) {
+		reader := pmtilr.NewTimeoutRangeReader(stuckBodyReader{}, 10*time.Millisecond)
+
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(2, 3))
+		if err != nil {
+			t.Fatalf("reading range: %v", err)
+		}
+		defer rc.Close()
+
+		_, err = io.ReadAll(rc)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if !pmtilr.IsRetryable(err) {
+			t.Error("expected timeouts to be retryable")
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package pmtilr
+
+import "fmt"
+
+// FlipY converts the y coordinate of a tile at zoom z between the TMS
+// scheme, which counts rows from the south, and the XYZ scheme of PMTiles,
+// which counts them from the north, e.g. to serve clients such as GeoServer
+// that request TMS tiles. The conversion is its own inverse.
+func FlipY(z, y uint64) (uint64, error) {
+	if z > MaxZ {
+		return 0, fmt.Errorf("zoom %d exceeds limit of %d", z, MaxZ)
+	}
+	if y >= 1<<z {
+		return 0, fmt.Errorf("tile coordinate y %d outside of bounds for zoom %d", y, z)
+	}
+	return 1<<z - 1 - y, nil
+}
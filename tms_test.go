@@ -0,0 +1,46 @@
+package pmtilr_test
+
+import (
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+func TestFlipY(t *testing.T) {
+	tests := []struct {
+		name      string
+		z, y      uint64
+		expected  uint64
+		wantError bool
+	}{
+		{name: "root", z: 0, y: 0, expected: 0},
+		{name: "north", z: 2, y: 0, expected: 3},
+		{name: "south", z: 2, y: 3, expected: 0},
+		{name: "berlin z10", z: 10, y: 335, expected: 688},
+		{name: "outside zoom", z: 2, y: 4, wantError: true},
+		{name: "beyond max zoom", z: pmtilr.MaxZ + 1, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pmtilr.FlipY(tt.z, tt.y)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %d, got: %d", tt.expected, got)
+			}
+
+			back, err := pmtilr.FlipY(tt.z, got)
+			if err != nil || back != tt.y {
+				t.Fatalf("expected flipping back to yield %d, got: %d, %v", tt.y, back, err)
+			}
+		})
+	}
+}
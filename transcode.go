@@ -0,0 +1,186 @@
+package pmtilr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+)
+
+const defaultJPEGQuality = jpeg.DefaultQuality
+
+type transcoderConfig struct {
+	jpegQuality    int
+	pngCompression png.CompressionLevel
+}
+
+// TranscoderOption configures a Transcoder.
+type TranscoderOption = func(*transcoderConfig)
+
+// WithJPEGQuality sets the JPEG encoding quality (1-100). Lower values trade
+// image quality for smaller tiles, e.g. for low-bandwidth clients.
+func WithJPEGQuality(quality int) TranscoderOption {
+	return func(cfg *transcoderConfig) {
+		cfg.jpegQuality = min(max(quality, 1), 100)
+	}
+}
+
+// WithPNGCompression sets the PNG compression level.
+func WithPNGCompression(level png.CompressionLevel) TranscoderOption {
+	return func(cfg *transcoderConfig) {
+		cfg.pngCompression = level
+	}
+}
+
+// pngBufferPool implements png.EncoderBufferPool on top of sync.Pool.
+type pngBufferPool struct{ pool sync.Pool }
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer) //nolint:errcheck
+	return b
+}
+
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) { p.pool.Put(b) }
+
+// Transcoder re-encodes raster tiles between formats. Encoders and output
+// buffers are pooled, so a single Transcoder is safe for concurrent use and
+// should be shared.
+type Transcoder struct {
+	cfg     transcoderConfig
+	png     *png.Encoder
+	buffers sync.Pool
+}
+
+// NewTranscoder returns a Transcoder. PNG and JPEG are supported as target
+// formats; PNG, JPEG and WebP are supported as sources.
+func NewTranscoder(options ...TranscoderOption) *Transcoder {
+	cfg := transcoderConfig{
+		jpegQuality:    defaultJPEGQuality,
+		pngCompression: png.DefaultCompression,
+	}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	return &Transcoder{
+		cfg: cfg,
+		png: &png.Encoder{
+			CompressionLevel: cfg.pngCompression,
+			BufferPool:       &pngBufferPool{},
+		},
+		buffers: sync.Pool{New: func() any { return new(bytes.Buffer) }},
+	}
+}
+
+// Transcode decodes an uncompressed raster tile of type from and re-encodes
+// it as to. Tiles that already match a lossless target are returned as is;
+// JPEG to JPEG is re-encoded to apply the configured quality.
+func (t *Transcoder) Transcode(data []byte, from, to TileType) ([]byte, error) {
+	if from == to && to != TileTypeJPEG {
+		return data, nil
+	}
+	if to != TileTypePNG && to != TileTypeJPEG {
+		return nil, fmt.Errorf("%w: cannot encode %s", ErrUnsupportedImageType, to)
+	}
+
+	img, err := DecodeImage(data, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Encode(img, to)
+}
+
+// Encode encodes img as a tile of type to.
+func (t *Transcoder) Encode(img image.Image, to TileType) ([]byte, error) {
+	buf, _ := t.buffers.Get().(*bytes.Buffer) //nolint:errcheck
+	buf.Reset()
+	defer t.buffers.Put(buf)
+
+	var err error
+	switch to {
+	case TileTypePNG:
+		err = t.png.Encode(buf, img)
+	case TileTypeJPEG:
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: t.cfg.jpegQuality})
+	default:
+		return nil, fmt.Errorf("%w: cannot encode %s", ErrUnsupportedImageType, to)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s tile: %w", to, err)
+	}
+
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// TranscodingSource wraps a raster Source and serves its tiles re-encoded
+// as a different TileType. Tiles are returned uncompressed.
+type TranscodingSource struct {
+	Source
+	to         TileType
+	transcoder *Transcoder
+}
+
+// NewTranscodingSource returns a Source serving the tiles of source as to,
+// using transcoder for the re-encoding.
+func NewTranscodingSource(
+	source Source,
+	to TileType,
+	transcoder *Transcoder,
+) (*TranscodingSource, error) {
+	if source.Header().TileType.IsVector() {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImageType, source.Header().TileType)
+	}
+	if to != TileTypePNG && to != TileTypeJPEG {
+		return nil, fmt.Errorf("%w: cannot encode %s", ErrUnsupportedImageType, to)
+	}
+	return &TranscodingSource{Source: source, to: to, transcoder: transcoder}, nil
+}
+
+// Tile returns the tile at z/x/y re-encoded as the target TileType.
+func (s *TranscodingSource) Tile(ctx context.Context, z, x, y uint64) ([]byte, error) {
+	header := s.Source.Header()
+
+	data, err := s.Source.Tile(ctx, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := Decompress(io.NopCloser(bytes.NewReader(data)), header.TileCompression)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading tile: %w", err)
+	}
+
+	return s.transcoder.Transcode(raw, header.TileType, s.to)
+}
+
+// Header returns the wrapped header with TileType and TileCompression
+// reflecting the transcoded output.
+func (s *TranscodingSource) Header() HeaderV3 {
+	header := s.Source.Header()
+	header.TileType = s.to
+	header.TileCompression = CompressionNone
+	return header
+}
+
+// TileJSON returns the wrapped TileJSON with tile URLs using the extension
+// of the target TileType.
+func (s *TranscodingSource) TileJSON(host string) TileJSON {
+	tj := s.Source.TileJSON(host)
+	ext := s.Source.Header().TileType.Ext()
+	for i, tile := range tj.Tiles {
+		tj.Tiles[i] = strings.TrimSuffix(tile, ext) + s.to.Ext()
+	}
+	return tj
+}
@@ -0,0 +1,117 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+type rasterSource struct {
+	data []byte
+}
+
+func (s rasterSource) Tile(context.Context, uint64, uint64, uint64) ([]byte, error) {
+	return s.data, nil
+}
+
+func (s rasterSource) Header() pmtilr.HeaderV3 {
+	return pmtilr.HeaderV3{TileType: pmtilr.TileTypePNG, TileCompression: pmtilr.CompressionNone}
+}
+
+func (s rasterSource) Meta() pmtilr.Metadata { return pmtilr.Metadata{} }
+
+func (s rasterSource) TileJSON(host string) pmtilr.TileJSON {
+	return pmtilr.TileJSON{Tiles: []string{host + "/{z}/{x}/{y}.png"}}
+}
+
+func noisyPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := range 64 {
+		for y := range 64 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8(x ^ y), A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding png: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestTranscoder(t *testing.T) {
+	data := noisyPNG(t)
+
+	high := pmtilr.NewTranscoder(pmtilr.WithJPEGQuality(95))
+	low := pmtilr.NewTranscoder(pmtilr.WithJPEGQuality(10))
+
+	highJPEG, err := high.Transcode(data, pmtilr.TileTypePNG, pmtilr.TileTypeJPEG)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lowJPEG, err := low.Transcode(data, pmtilr.TileTypePNG, pmtilr.TileTypeJPEG)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(lowJPEG) >= len(highJPEG) {
+		t.Fatalf("expected low quality jpeg (%d) to be smaller than high quality (%d)", len(lowJPEG), len(highJPEG))
+	}
+
+	img, err := pmtilr.DecodeImage(lowJPEG, pmtilr.TileTypeJPEG)
+	if err != nil {
+		t.Fatalf("transcoded tile should decode: %s", err)
+	}
+	if img.Bounds().Dx() != 64 {
+		t.Fatalf("expected width 64, got: %d", img.Bounds().Dx())
+	}
+
+	roundTrip, err := high.Transcode(highJPEG, pmtilr.TileTypeJPEG, pmtilr.TileTypePNG)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(roundTrip)); err != nil {
+		t.Fatalf("expected valid png: %s", err)
+	}
+
+	same, err := high.Transcode(data, pmtilr.TileTypePNG, pmtilr.TileTypePNG)
+	if err != nil || !bytes.Equal(same, data) {
+		t.Fatalf("expected png to png to pass through, err: %v", err)
+	}
+
+	_, err = high.Transcode(data, pmtilr.TileTypePNG, pmtilr.TileTypeWebp)
+	if !errors.Is(err, pmtilr.ErrUnsupportedImageType) {
+		t.Fatalf("expected ErrUnsupportedImageType, got: %v", err)
+	}
+}
+
+func TestTranscodingSource(t *testing.T) {
+	src, err := pmtilr.NewTranscodingSource(
+		rasterSource{data: noisyPNG(t)}, pmtilr.TileTypeJPEG, pmtilr.NewTranscoder(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if src.Header().TileType != pmtilr.TileTypeJPEG {
+		t.Fatalf("expected header tile type jpeg, got: %s", src.Header().TileType)
+	}
+	if got := src.TileJSON("http://localhost").Tiles[0]; got != "http://localhost/{z}/{x}/{y}.jpeg" {
+		t.Fatalf("unexpected tile url: %s", got)
+	}
+
+	tile, err := src.Tile(t.Context(), 0, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := pmtilr.DecodeImage(tile, pmtilr.TileTypeJPEG); err != nil {
+		t.Fatalf("expected jpeg tile: %s", err)
+	}
+}
@@ -0,0 +1,209 @@
+package pmtilr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/iwpnd/rip"
+)
+
+type rangeReaderConfig struct {
+	httpClient   *http.Client
+	proxy        func(*http.Request) (*url.URL, error)
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	s3           s3Config
+	ifRange      bool
+	bandwidth    float64
+	urlRefresh   URLFunc
+
+	// transport is cloned by ripOptions and owned by the created reader.
+	transport *http.Transport
+}
+
+// RangeReaderOption is a functional option for configuring the RangeReader
+// created by NewRangeReader.
+type RangeReaderOption = func(config *rangeReaderConfig)
+
+// WithHTTPClient sets the *http.Client used by HTTP based backends (http(s),
+// webdav(s), oci, s3). HTTPRangeReader is built on rip and adopts the
+// client's Timeout and Transport. Proxy and TLS options can only be
+// combined with a client whose Transport is an *http.Transport.
+func WithHTTPClient(client *http.Client) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.httpClient = client
+	}
+}
+
+// WithProxy routes all requests of HTTP based backends through the given proxy.
+func WithProxy(proxyURL *url.URL) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithRootCAs sets the certificate authorities used to verify servers of
+// HTTP based backends, e.g. a corporate CA bundle.
+func WithRootCAs(pool *x509.CertPool) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.rootCAs = pool
+	}
+}
+
+// WithClientCertificates sets the certificates presented to servers of HTTP
+// based backends that require mutual TLS.
+func WithClientCertificates(certs ...tls.Certificate) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.certificates = certs
+	}
+}
+
+// WithIfRange sends the pinned validators of HTTP based readers as If-Range
+// instead of If-Match/If-Unmodified-Since. Servers and CDNs answer a
+// changed archive with the full body instead of the range, which is
+// discarded and reported as ErrArchiveChanged. Use it for servers ignoring
+// If-Match; a RefreshingSource then reloads the header and directories.
+func WithIfRange() RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.ifRange = true
+	}
+}
+
+// WithBandwidthLimit caps the transfer rate of remote readers (http(s),
+// webdav(s), oci, s3) to bytesPerSecond, e.g. for a Source used by
+// background jobs such as warming or exports, so they don't starve
+// interactive tile traffic on the same link. See WithBandwidth.
+func WithBandwidthLimit(bytesPerSecond float64) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.bandwidth = bytesPerSecond
+	}
+}
+
+// WithURLRefresh reads http(s) URIs, e.g. presigned S3 or GCS URLs, with a
+// PresignedRangeReader that calls refresh for a fresh URL once the current
+// one is rejected as expired.
+func WithURLRefresh(refresh URLFunc) RangeReaderOption {
+	return func(config *rangeReaderConfig) {
+		config.urlRefresh = refresh
+	}
+}
+
+// httpRangeReader applies the reader options not handled by rip.
+func (c *rangeReaderConfig) httpRangeReader(r *HTTPRangeReader, err error) (RangeReader, error) {
+	if err != nil {
+		return nil, err
+	}
+	c.configure(r)
+	return c.limitBandwidth(r, nil)
+}
+
+// presignedRangeReader reads uri with a PresignedRangeReader refreshing it
+// with WithURLRefresh.
+func (c *rangeReaderConfig) presignedRangeReader(uri string, options []rip.Option) (RangeReader, error) {
+	r, err := NewHTTPRangeReader(uri, options...)
+	if err != nil {
+		return nil, err
+	}
+	c.configure(r)
+	return c.limitBandwidth(&PresignedRangeReader{mint: c.urlRefresh, options: options, reader: r}, nil)
+}
+
+// configure applies the reader options not handled by rip to r.
+func (c *rangeReaderConfig) configure(r *HTTPRangeReader) {
+	r.ifRange = c.ifRange
+	if c.transport != nil {
+		r.transport = c.transport
+	}
+}
+
+// limitBandwidth wraps remote readers if WithBandwidthLimit is set. The
+// burst is a tenth of a second of transfer, at least 4 KiB.
+func (c *rangeReaderConfig) limitBandwidth(r RangeReader, err error) (RangeReader, error) {
+	if err != nil || c.bandwidth <= 0 {
+		return r, err
+	}
+	burst := max(int(c.bandwidth/10), 4<<10)
+	return NewRateLimitedRangeReader(r, WithBandwidth(c.bandwidth, burst)), nil
+}
+
+// hasTransportOptions reports whether proxy or TLS settings are configured.
+func (c *rangeReaderConfig) hasTransportOptions() bool {
+	return c.proxy != nil || c.rootCAs != nil || len(c.certificates) > 0
+}
+
+// applyTransportOptions applies proxy and TLS settings to tr.
+func (c *rangeReaderConfig) applyTransportOptions(tr *http.Transport) {
+	if c.proxy != nil {
+		tr.Proxy = c.proxy
+	}
+
+	if c.rootCAs == nil && len(c.certificates) == 0 {
+		return
+	}
+
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+	}
+	if c.rootCAs != nil {
+		tr.TLSClientConfig.RootCAs = c.rootCAs
+	}
+	if len(c.certificates) > 0 {
+		tr.TLSClientConfig.Certificates = c.certificates
+	}
+}
+
+// roundTripperTransport returns an *http.Transport, as required by rip,
+// that hands all http and https requests to rt.
+func roundTripperTransport(rt http.RoundTripper) *http.Transport {
+	tr := &http.Transport{}
+	tr.RegisterProtocol("http", rt)
+	tr.RegisterProtocol("https", rt)
+	return tr
+}
+
+// ripOptions translates the configuration into options for the rip.Client
+// used by HTTPRangeReader.
+func (c *rangeReaderConfig) ripOptions() ([]rip.Option, error) {
+	var opts []rip.Option
+
+	var tr *http.Transport
+	if c.httpClient != nil {
+		if c.httpClient.Timeout > 0 {
+			opts = append(opts, rip.WithTimeout(c.httpClient.Timeout))
+		}
+
+		switch t := c.httpClient.Transport.(type) {
+		case nil:
+		case *http.Transport:
+			tr = t
+		default:
+			if c.hasTransportOptions() {
+				return nil, fmt.Errorf(
+					"proxy and TLS options cannot be applied to http client transport %T, expected *http.Transport",
+					t,
+				)
+			}
+			tr = roundTripperTransport(t)
+		}
+	}
+
+	if c.hasTransportOptions() {
+		if tr == nil {
+			tr, _ = http.DefaultTransport.(*http.Transport) //nolint:errcheck
+		}
+		tr = tr.Clone()
+		c.applyTransportOptions(tr)
+		c.transport = tr
+	}
+
+	if tr != nil {
+		opts = append(opts, rip.WithTransport(tr))
+	}
+
+	return opts, nil
+}
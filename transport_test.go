@@ -0,0 +1,163 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestRangeReaderTransportOptions(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("fake"))
+		}))
+	defer tlsServer.Close()
+
+	proxy := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Host != "archive.invalid" {
+				t.Errorf("expected proxied request for archive.invalid, got: %s", r.URL.Host)
+			}
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("fake"))
+		}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(tlsServer.Certificate())
+
+	tests := []struct {
+		name      string
+		uri       string
+		options   []pmtilr.RangeReaderOption
+		expectErr bool
+	}{
+		{
+			name:      "fails for unknown certificate authority",
+			uri:       tlsServer.URL,
+			expectErr: true,
+		},
+		{
+			name:    "custom root CAs",
+			uri:     tlsServer.URL,
+			options: []pmtilr.RangeReaderOption{pmtilr.WithRootCAs(pool)},
+		},
+		{
+			name:    "custom http client",
+			uri:     tlsServer.URL,
+			options: []pmtilr.RangeReaderOption{pmtilr.WithHTTPClient(tlsServer.Client())},
+		},
+		{
+			name:    "proxy",
+			uri:     "http://archive.invalid/map.pmtiles",
+			options: []pmtilr.RangeReaderOption{pmtilr.WithProxy(proxyURL)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := pmtilr.NewRangeReader(t.Context(), tt.uri, tt.options...)
+			if err != nil {
+				t.Fatalf("creating reader should not fail: %s", err)
+			}
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer rc.Close()
+
+			result, _ := io.ReadAll(rc)
+			if string(result) != "fake" {
+				t.Fatalf("expected %s, got: %s", "fake", string(result))
+			}
+		})
+	}
+
+	t.Run("custom round tripper", func(t *testing.T) {
+		var calls atomic.Int32
+		client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return tlsServer.Client().Transport.RoundTrip(r)
+		})}
+
+		reader, err := pmtilr.NewRangeReader(t.Context(), tlsServer.URL, pmtilr.WithHTTPClient(client))
+		if err != nil {
+			t.Fatalf("creating reader should not fail: %s", err)
+		}
+
+		rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer rc.Close()
+
+		result, _ := io.ReadAll(rc)
+		if string(result) != "fake" {
+			t.Fatalf("expected %s, got: %s", "fake", string(result))
+		}
+		if calls.Load() == 0 {
+			t.Fatal("expected request through the client transport")
+		}
+	})
+
+	t.Run("fails for TLS options with custom round tripper", func(t *testing.T) {
+		client := &http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}
+
+		_, err := pmtilr.NewRangeReader(
+			t.Context(), tlsServer.URL, pmtilr.WithHTTPClient(client), pmtilr.WithRootCAs(pool),
+		)
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
+
+func TestWithBandwidthLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 8<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	reader, err := pmtilr.NewRangeReader(t.Context(), server.URL, pmtilr.WithBandwidthLimit(40<<10))
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	start := time.Now()
+	rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, uint64(len(data))))
+	if err != nil {
+		t.Fatalf("reading range: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	if err != nil || len(got) != len(data) {
+		t.Fatalf("reading body: got %d bytes, %v", len(got), err)
+	}
+	// 4 KiB of burst, the other 4 KiB take 100ms at 40 KiB/s.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("read took %v, want at least 80ms", elapsed)
+	}
+}
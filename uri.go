@@ -16,6 +16,10 @@ const (
 	SchemeHTTP
 	SchemeHTTPS
 	SchemeFileCwd
+	SchemeWebDAV
+	SchemeWebDAVS
+	SchemeZip
+	SchemeOCI
 )
 
 var _ fmt.Stringer = SchemeUnknown
@@ -27,6 +31,10 @@ var schemeStrings = map[Scheme]string{
 	SchemeHTTPS:   "https",
 	SchemeUnknown: "unknown",
 	SchemeFileCwd: "",
+	SchemeWebDAV:  "webdav",
+	SchemeWebDAVS: "webdavs",
+	SchemeZip:     "zip",
+	SchemeOCI:     "oci",
 }
 
 func (s Scheme) String() string {
@@ -62,6 +70,30 @@ func (u *URI) Raw() *url.URL {
 	return u.raw
 }
 
+// Query returns the parsed query parameters, which configure some schemes,
+// e.g. the region and endpoint of "s3://" URIs.
+func (u *URI) Query() url.Values {
+	return u.raw.Query()
+}
+
+// Fragment returns the unescaped fragment, e.g. the member of a "zip://"
+// URI. Fragments are never sent to remote backends.
+func (u *URI) Fragment() string {
+	return u.raw.Fragment
+}
+
+// User returns the user info of the URI, or nil if there is none.
+func (u *URI) User() *url.Userinfo {
+	return u.raw.User
+}
+
+// withoutFragment returns the URI as a string without its fragment.
+func (u *URI) withoutFragment() string {
+	raw := *u.raw
+	raw.Fragment, raw.RawFragment = "", ""
+	return raw.String()
+}
+
 func newURI(u *url.URL, scheme Scheme) *URI {
 	p := filepath.FromSlash(filepath.Join(u.Host, u.Path))
 	return &URI{
@@ -94,7 +126,19 @@ func ParseURI(raw string) (*URI, error) {
 		return newURI(u, SchemeFile), nil
 	case SchemeS3.String():
 		return newURI(u, SchemeS3), nil
+	case SchemeWebDAV.String():
+		return newURI(u, SchemeWebDAV), nil
+	case SchemeWebDAVS.String():
+		return newURI(u, SchemeWebDAVS), nil
+	case SchemeZip.String():
+		return newURI(u, SchemeZip), nil
+	case SchemeOCI.String():
+		return newURI(u, SchemeOCI), nil
 	default:
+		// members of remote ZIP archives, e.g. "zip+https://host/bundle.zip!/map.pmtiles"
+		if strings.HasPrefix(scheme, SchemeZip.String()+"+") {
+			return newURI(u, SchemeZip), nil
+		}
 		return nil, fmt.Errorf("unsupported URI scheme %q", u.Scheme)
 	}
 }
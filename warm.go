@@ -0,0 +1,253 @@
+package pmtilr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TileFetch describes a tile successfully read from the archive.
+type TileFetch struct {
+	Z, X, Y uint64
+	TileID  TileID // Hilbert tile id of Z, X, Y
+	Entry   Entry  // Directory entry the tile resolved to
+}
+
+// Warmer reads tiles without returning them, to populate the directory cache
+// and caching RangeReaders such as DiskCacheRangeReader.
+type Warmer interface {
+	Warm(ctx context.Context, z, x, y uint64) error
+}
+
+// FetchHook is invoked after each tile successfully read from the archive.
+// It runs on the request path, so slow work must be done asynchronously.
+// Tiles read with warmer do not invoke hooks.
+type FetchHook = func(ctx context.Context, warmer Warmer, fetch TileFetch)
+
+// WithFetchHook adds a FetchHook invoked after each tile read from the
+// archive, e.g. NewChildWarmer.
+func WithFetchHook(hook FetchHook) SourceOption {
+	return func(config *sourceConfig) {
+		config.hooks = append(config.hooks, hook)
+	}
+}
+
+// Warm resolves and reads the tile at z, x, y, discarding its bytes. It
+// neither invokes hooks nor records Stats, and is canceled when the source
+// is closed.
+func (s *TileSource) Warm(ctx context.Context, z, x, y uint64) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.lifetime, cancel)
+	defer stop()
+
+	if z < uint64(s.header.MinZoom) || z > uint64(s.header.MaxZoom) {
+		return fmt.Errorf(
+			"invalid zoom: %d for allowed range of %d to %d",
+			z,
+			s.header.MinZoom,
+			s.header.MaxZoom,
+		)
+	}
+
+	entry, err := tileEntry(ctx, s.repository, *s.header, s.reader, s.decompress, nil, z, x, y)
+	if err != nil {
+		return err
+	}
+
+	rc, err := s.reader.ReadRange(ctx, NewRange(s.header.TileDataOffset+entry.Offset, entry.Length))
+	if err != nil {
+		return err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return fmt.Errorf("warming tile: %w", err)
+	}
+	return nil
+}
+
+// fetched invokes the hooks of the source for a tile read from the archive.
+func (s *TileSource) fetched(ctx context.Context, z, x, y uint64, entry *Entry) {
+	if len(s.hooks) == 0 {
+		return
+	}
+
+	tileID, err := NewTileID(z, x, y)
+	if err != nil {
+		return
+	}
+
+	fetch := TileFetch{Z: z, X: x, Y: y, TileID: tileID, Entry: *entry}
+	for _, hook := range s.hooks {
+		hook(ctx, s, fetch)
+	}
+}
+
+const (
+	defaultWarmHistory     = 1024
+	defaultWarmConcurrency = 4
+	defaultWarmTimeout     = 10 * time.Second
+)
+
+type childWarmerConfig struct {
+	history     int
+	concurrency int
+	timeout     time.Duration
+}
+
+// ChildWarmerOption is a functional option for configuring NewChildWarmer.
+type ChildWarmerOption = func(config *childWarmerConfig)
+
+// WithWarmHistory sets the number of recently fetched tiles kept to detect
+// zoom-ins, defaults to 1024.
+func WithWarmHistory(n int) ChildWarmerOption {
+	return func(config *childWarmerConfig) {
+		config.history = n
+	}
+}
+
+// WithWarmConcurrency sets the maximum number of tiles warmed at the same
+// time, defaults to 4. Zoom-ins detected while all slots are busy are not
+// warmed.
+func WithWarmConcurrency(n int) ChildWarmerOption {
+	return func(config *childWarmerConfig) {
+		config.concurrency = n
+	}
+}
+
+// WithWarmTimeout sets the timeout for warming the children of a tile,
+// defaults to 10s.
+func WithWarmTimeout(d time.Duration) ChildWarmerOption {
+	return func(config *childWarmerConfig) {
+		config.timeout = d
+	}
+}
+
+// NewChildWarmer returns a FetchHook that warms the four child tiles of a
+// tile once a zoom-in is detected, i.e. the tile was fetched after its
+// parent. Map clients zoom in far more often than out, so the children are
+// likely fetched next.
+//
+// Children are warmed in the background with Warmer.Warm, so they are
+// served from the directory cache and, if configured, a caching RangeReader.
+func NewChildWarmer(options ...ChildWarmerOption) FetchHook {
+	cfg := &childWarmerConfig{
+		history:     defaultWarmHistory,
+		concurrency: defaultWarmConcurrency,
+		timeout:     defaultWarmTimeout,
+	}
+	for _, optFn := range options {
+		optFn(cfg)
+	}
+
+	w := &childWarmer{
+		timeout: cfg.timeout,
+		fetched: newRecentSet(max(cfg.history, 1)),
+		warmed:  newRecentSet(max(cfg.history, 1)),
+		sem:     make(chan struct{}, max(cfg.concurrency, 1)),
+	}
+	return w.hook
+}
+
+type childWarmer struct {
+	timeout time.Duration
+	fetched *recentSet // tiles fetched recently
+	warmed  *recentSet // tiles whose children were warmed recently
+	sem     chan struct{}
+}
+
+func (w *childWarmer) hook(ctx context.Context, warmer Warmer, fetch TileFetch) {
+	w.fetched.add(fetch.TileID)
+	if fetch.Z == 0 {
+		return
+	}
+
+	parent, err := NewTileID(fetch.Z-1, fetch.X/2, fetch.Y/2)
+	if err != nil || !w.fetched.contains(parent) {
+		return
+	}
+	if !w.warmed.add(fetch.TileID) {
+		return
+	}
+
+	select {
+	case w.sem <- struct{}{}:
+	default:
+		return
+	}
+
+	// the request context ends with the request, warming must outlive it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.timeout)
+	go func() {
+		defer func() { <-w.sem }()
+		defer cancel()
+
+		z := fetch.Z + 1
+		for dy := range uint64(2) {
+			for dx := range uint64(2) {
+				// children may be missing or beyond MaxZoom, warming is best effort.
+				_ = warmer.Warm(ctx, z, fetch.X*2+dx, fetch.Y*2+dy) //nolint:errcheck
+			}
+		}
+	}()
+}
+
+// recentSet is a fixed size set of tile ids evicting the oldest id first.
+type recentSet struct {
+	mu   sync.Mutex
+	ring []TileID
+	next int
+	ids  map[TileID]struct{}
+}
+
+func newRecentSet(size int) *recentSet {
+	return &recentSet{
+		ring: make([]TileID, 0, size),
+		ids:  make(map[TileID]struct{}, size),
+	}
+}
+
+// add adds id to the set and reports whether it was not contained before.
+func (r *recentSet) add(id TileID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ids[id]; ok {
+		return false
+	}
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, id)
+	} else {
+		delete(r.ids, r.ring[r.next])
+		r.ring[r.next] = id
+		r.next = (r.next + 1) % len(r.ring)
+	}
+	r.ids[id] = struct{}{}
+	return true
+}
+
+func (r *recentSet) contains(id TileID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.ids[id]
+	return ok
+}
+
+// items returns the ids of the set, oldest first.
+func (r *recentSet) items() []TileID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	items := make([]TileID, 0, len(r.ring))
+	items = append(items, r.ring[r.next:]...)
+	return append(items, r.ring[:r.next]...)
+}
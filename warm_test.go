@@ -0,0 +1,120 @@
+package pmtilr_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+func TestFetchHook(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches []pmtilr.TileFetch
+	)
+	hook := func(_ context.Context, _ pmtilr.Warmer, fetch pmtilr.TileFetch) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches = append(fetches, fetch)
+	}
+
+	source, err := pmtilr.NewSource(
+		t.Context(),
+		"memory",
+		pmtilr.WithRangeReader(pmtilrtest.FixtureArchive(2).RangeReader()),
+		pmtilr.WithFetchHook(hook),
+	)
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+
+	if _, err := source.Tile(t.Context(), 2, 1, 3); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+	if _, err := pmtilr.TileTo(t.Context(), source, io.Discard, 1, 1, 0); err != nil {
+		t.Fatalf("writing tile: %v", err)
+	}
+	if _, err := source.Tile(t.Context(), 3, 0, 0); err == nil {
+		t.Fatal("expected error reading tile beyond max zoom")
+	}
+
+	if len(fetches) != 2 {
+		t.Fatalf("got %d fetches, want 2", len(fetches))
+	}
+	for i, want := range [][3]uint64{{2, 1, 3}, {1, 1, 0}} {
+		got := fetches[i]
+		if [3]uint64{got.Z, got.X, got.Y} != want {
+			t.Errorf("fetch %d = %d/%d/%d, want %v", i, got.Z, got.X, got.Y, want)
+		}
+		wantID, _ := pmtilr.NewTileID(want[0], want[1], want[2])
+		if got.TileID != wantID {
+			t.Errorf("fetch %d tile id = %d, want %d", i, got.TileID, wantID)
+		}
+		if got.Entry.Length == 0 {
+			t.Errorf("fetch %d has no entry", i)
+		}
+	}
+}
+
+func TestChildWarmer(t *testing.T) {
+	reader := pmtilrtest.NewRangeReader(pmtilrtest.FixtureArchive(3).Bytes())
+	source, err := pmtilr.NewSource(
+		t.Context(),
+		"memory",
+		pmtilr.WithRangeReader(reader),
+		pmtilr.WithFetchHook(pmtilr.NewChildWarmer()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source: %v", err)
+	}
+
+	// no parent fetched before, so neither tile is a zoom-in.
+	for _, zxy := range [][3]uint64{{2, 3, 3}, {0, 0, 0}} {
+		if _, err := source.Tile(t.Context(), zxy[0], zxy[1], zxy[2]); err != nil {
+			t.Fatalf("reading tile %v: %v", zxy, err)
+		}
+	}
+	reader.Reset()
+
+	// zooming in from 0/0/0 warms the children of 1/0/0.
+	if _, err := source.Tile(t.Context(), 1, 0, 0); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+	waitForCalls(t, reader, 5)
+
+	// a repeated zoom-in does not warm again.
+	if _, err := source.Tile(t.Context(), 1, 0, 0); err != nil {
+		t.Fatalf("reading tile: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := len(reader.Calls()); got != 6 {
+		t.Errorf("got %d reads, want 6", got)
+	}
+
+	for _, zxy := range [][3]uint64{{2, 0, 0}, {2, 1, 0}, {2, 0, 1}, {2, 1, 1}} {
+		data, err := source.Tile(t.Context(), zxy[0], zxy[1], zxy[2])
+		if err != nil {
+			t.Fatalf("reading tile %v: %v", zxy, err)
+		}
+		if len(data) == 0 {
+			t.Errorf("tile %v is empty", zxy)
+		}
+	}
+}
+
+func waitForCalls(t *testing.T, reader *pmtilrtest.RangeReader, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for len(reader.Calls()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d reads, want %d", len(reader.Calls()), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
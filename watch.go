@@ -0,0 +1,566 @@
+package pmtilr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 30 * time.Second
+
+	// maxRevalidateBackoff bounds the backoff between retries of a failed
+	// revalidation.
+	maxRevalidateBackoff = 10 * time.Minute
+)
+
+// Versioner is implemented by RangeReaders that can report the current
+// version of their archive, e.g. its ETag or modification time. The version
+// is an opaque token that changes whenever the archive is replaced.
+type Versioner interface {
+	Version(ctx context.Context) (string, error)
+}
+
+// fileVersion returns the size and modification time of the file at path.
+func fileVersion(path string) (string, error) {
+	info, err := os.Stat(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(info.Size(), 10) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 10), nil
+}
+
+type watchConfig struct {
+	interval time.Duration
+	onError  func(error)
+	baseline string
+	grace    time.Duration
+}
+
+// WatchOption configures a Watcher.
+type WatchOption = func(*watchConfig)
+
+// WithWatchInterval sets the polling interval. Defaults to 30s.
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.interval = interval
+	}
+}
+
+// WithWatchErrorHandler sets a handler for polling errors. Polling
+// continues after errors; by default they are ignored.
+func WithWatchErrorHandler(fn func(error)) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.onError = fn
+	}
+}
+
+// WithWatchBaseline sets the version the first poll is compared against,
+// instead of the version reported when Run starts.
+func WithWatchBaseline(version string) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.baseline = version
+	}
+}
+
+// WithStaleWhileRevalidate makes a RefreshingSource keep serving the
+// previous Source for up to grace after a change is detected, while the new
+// Source is loaded and warmed in the background with the tiles requested
+// most recently, then switch atomically. Without it, the new Source is
+// switched in once its header and root directory are loaded. Reads of an
+// archive that changed underneath the previous Source still refresh it
+// right away. It has no effect on a Watcher.
+func WithStaleWhileRevalidate(grace time.Duration) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.grace = grace
+	}
+}
+
+// Watcher polls the version of an archive and invokes a callback when it
+// changes.
+type Watcher struct {
+	versioner Versioner
+	onChange  func(ctx context.Context, previous, current string)
+	cfg       watchConfig
+
+	// onUnchanged is called after polls that saw no change.
+	onUnchanged func(ctx context.Context)
+}
+
+// NewWatcher returns a Watcher polling versioner. onChange is called with
+// the previous and current version whenever they differ.
+func NewWatcher(
+	versioner Versioner,
+	onChange func(ctx context.Context, previous, current string),
+	options ...WatchOption,
+) *Watcher {
+	cfg := watchConfig{interval: defaultWatchInterval, onError: func(error) {}}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	return &Watcher{versioner: versioner, onChange: onChange, cfg: cfg}
+}
+
+// Run polls until ctx is done. It returns an error if the initial version
+// cannot be determined, and ctx.Err() once ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	previous := w.cfg.baseline
+	if previous == "" {
+		v, err := w.versioner.Version(ctx)
+		if err != nil {
+			return fmt.Errorf("watching archive: %w", err)
+		}
+		previous = v
+	}
+
+	ticker := time.NewTicker(w.cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := w.versioner.Version(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.cfg.onError(fmt.Errorf("watching archive: %w", err))
+			}
+			continue
+		}
+		if current != previous {
+			w.onChange(ctx, previous, current)
+			previous = current
+		} else if w.onUnchanged != nil {
+			w.onUnchanged(ctx)
+		}
+	}
+}
+
+// sourceCloser is implemented by Sources holding resources.
+type sourceCloser interface {
+	Close()
+}
+
+// unpinner is implemented by RangeReaders pinning the validators of the
+// first response, see HTTPRangeReader.
+type unpinner interface {
+	unpin()
+}
+
+// RefreshingSource is a Source that watches its archive and transparently
+// replaces itself with a freshly loaded Source, including a new header,
+// metadata and directory cache partition, when the archive is republished.
+type RefreshingSource struct {
+	uri     string
+	options []SourceOption
+
+	current atomic.Pointer[Source]
+	mu      sync.Mutex // serializes refreshes
+
+	// grace bounds warming a new Source with the recent tiles in
+	// stale-while-revalidate mode, recent is nil otherwise.
+	grace  time.Duration
+	recent *recentSet
+
+	// reader is polled for versions, closed with the source if it was
+	// created from uri.
+	reader RangeReader
+	// shared is the reader set with WithRangeReader, used by every Source
+	// and unpinned before a new one is loaded.
+	shared RangeReader
+
+	// retry records a failed revalidation of the watcher, which is retried
+	// on later polls starting with a backoff of the polling interval. It is
+	// only accessed by the watcher goroutine.
+	retry    revalidateRetry
+	interval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// revalidateRetry schedules the retry of a failed revalidation of stale.
+type revalidateRetry struct {
+	stale   *Source
+	at      time.Time
+	backoff time.Duration
+}
+
+// NewRefreshingSource loads the Source for uri and refreshes it whenever
+// the version of the archive changes. The RangeReader for uri must
+// implement Versioner, which HTTP, S3 and file readers do. The watcher runs
+// until Close is called.
+func NewRefreshingSource(
+	ctx context.Context,
+	uri string,
+	watchOptions []WatchOption,
+	options ...SourceOption,
+) (_ *RefreshingSource, err error) {
+	cfg := &sourceConfig{}
+	for _, opt := range options {
+		opt(cfg)
+	}
+
+	reader, owned := cfg.reader, RangeReader(nil)
+	if reader == nil {
+		r, err := NewRangeReader(ctx, uri, cfg.readerOpts...)
+		if err != nil {
+			return nil, err
+		}
+		reader, owned = r, r
+		defer func() {
+			if err != nil {
+				_ = CloseReader(r) //nolint:errcheck
+			}
+		}()
+	}
+	versioner, ok := ReaderAs[Versioner](reader)
+	if !ok {
+		return nil, fmt.Errorf("refreshing source: %T does not report archive versions", reader)
+	}
+
+	// capture the version before loading, so a change in between is seen
+	version, err := versioner.Version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("refreshing source: %w", err)
+	}
+
+	src, err := NewSource(ctx, uri, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	watchCfg := watchConfig{}
+	for _, opt := range watchOptions {
+		opt(&watchCfg)
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	rs := &RefreshingSource{
+		uri:     uri,
+		options: options,
+		reader:  owned,
+		shared:  cfg.reader,
+		grace:   watchCfg.grace,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	if rs.grace > 0 {
+		rs.recent = newRecentSet(defaultWarmHistory)
+	}
+	rs.current.Store(&src)
+
+	watcher := NewWatcher(versioner, func(ctx context.Context, _, _ string) {
+		rs.onChange(ctx)
+	}, append([]WatchOption{WithWatchBaseline(version)}, watchOptions...)...)
+	watcher.onUnchanged = rs.retryRevalidate
+	rs.interval = watcher.cfg.interval
+
+	go func() {
+		defer close(rs.done)
+		_ = watcher.Run(watchCtx) //nolint:errcheck
+	}()
+
+	return rs, nil
+}
+
+// Refresh reloads the Source and swaps it in. The previous Source is closed.
+func (rs *RefreshingSource) Refresh(ctx context.Context) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.reload(ctx)
+}
+
+// revalidate refreshes the Source after a change was detected. In
+// stale-while-revalidate mode the new Source is loaded and warmed without
+// holding mu, so reads keep being served by, and can refresh, the previous
+// Source meanwhile. It is only swapped in if the previous Source was not
+// replaced in the meantime.
+func (rs *RefreshingSource) revalidate(ctx context.Context) error {
+	if rs.recent == nil {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+
+		return rs.reload(ctx)
+	}
+
+	stale := rs.current.Load()
+	src, err := rs.newSource(ctx)
+	if err != nil {
+		return err
+	}
+	rs.warm(ctx, src)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.current.Load() != stale {
+		if c, ok := src.(sourceCloser); ok {
+			c.Close()
+		}
+		return nil
+	}
+	rs.swap(src)
+	return nil
+}
+
+// onChange revalidates the Source after the watcher detected a change. If
+// that fails, it is retried on the next poll, see retryRevalidate.
+func (rs *RefreshingSource) onChange(ctx context.Context) {
+	stale := rs.current.Load()
+	if err := rs.revalidate(ctx); err != nil {
+		rs.retry = revalidateRetry{stale: stale, at: time.Now(), backoff: rs.interval}
+		return
+	}
+	rs.retry = revalidateRetry{}
+}
+
+// retryRevalidate retries a failed revalidation once its backoff elapsed,
+// doubling the backoff after every failure. The retry is dropped once the
+// stale Source was replaced, e.g. by a read that saw the archive change.
+func (rs *RefreshingSource) retryRevalidate(ctx context.Context) {
+	r := &rs.retry
+	if r.stale == nil || time.Now().Before(r.at) {
+		return
+	}
+	if rs.current.Load() != r.stale {
+		*r = revalidateRetry{}
+		return
+	}
+
+	if err := rs.revalidate(ctx); err != nil {
+		r.at = time.Now().Add(r.backoff)
+		r.backoff = min(2*r.backoff, maxRevalidateBackoff)
+		return
+	}
+	*r = revalidateRetry{}
+}
+
+// reload loads the new Source and swaps it in. Callers must hold mu.
+func (rs *RefreshingSource) reload(ctx context.Context) error {
+	src, err := rs.newSource(ctx)
+	if err != nil {
+		return err
+	}
+	rs.swap(src)
+	return nil
+}
+
+// newSource loads a Source of the archive currently served. A reader set
+// with WithRangeReader is shared with the previous Source, so its pinned
+// validators are dropped first; reads of the previous Source are no longer
+// checked against its archive version until it is swapped out.
+func (rs *RefreshingSource) newSource(ctx context.Context) (Source, error) {
+	if u, ok := ReaderAs[unpinner](rs.shared); ok {
+		u.unpin()
+	}
+	src, err := NewSource(ctx, rs.uri, rs.options...)
+	if err != nil {
+		return nil, fmt.Errorf("refreshing source: %w", err)
+	}
+	return src, nil
+}
+
+// swap replaces the current Source with src and closes the previous one.
+// Reads that raced with the swap and fail with ErrSourceClosed are retried
+// on src, see current. Callers must hold mu.
+func (rs *RefreshingSource) swap(src Source) {
+	previous := rs.current.Swap(&src)
+	if c, ok := (*previous).(sourceCloser); ok {
+		c.Close()
+	}
+}
+
+// warm reads the recently requested tiles from src for up to the grace
+// period, loading the leaf directories they need. Tiles missing in the new
+// archive are skipped.
+func (rs *RefreshingSource) warm(ctx context.Context, src Source) {
+	warmer, ok := src.(Warmer)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rs.grace)
+	defer cancel()
+
+	for _, id := range slices.Backward(rs.recent.items()) {
+		if ctx.Err() != nil {
+			return
+		}
+		z, x, y := id.ZXY()
+		_ = warmer.Warm(ctx, z, x, y) //nolint:errcheck // best effort
+	}
+}
+
+func (rs *RefreshingSource) source() Source {
+	return *rs.current.Load()
+}
+
+// withCurrent calls fn with the current Source. If the Source was swapped
+// out and closed before fn read from it, fn is called again with the
+// Source that replaced it. It returns the Source of the last call.
+func withCurrent[T any](rs *RefreshingSource, fn func(Source) (T, error)) (*Source, T, error) {
+	for {
+		current := rs.current.Load()
+		v, err := fn(*current)
+		if errors.Is(err, ErrSourceClosed) && rs.current.Load() != current {
+			continue
+		}
+		return current, v, err
+	}
+}
+
+// Tile returns the tile bytes for z, x, y. If the archive changed
+// underneath the current Source, it is refreshed and the read retried once.
+func (rs *RefreshingSource) Tile(ctx context.Context, z, x, y uint64) ([]byte, error) {
+	if rs.recent != nil {
+		if id, err := NewTileID(z, x, y); err == nil {
+			rs.recent.add(id)
+		}
+	}
+
+	tile := func(src Source) ([]byte, error) { return src.Tile(ctx, z, x, y) }
+	current, data, err := withCurrent(rs, tile)
+	if !errors.Is(err, ErrArchiveChanged) {
+		return data, err
+	}
+
+	if rerr := rs.refreshStale(ctx, current); rerr != nil {
+		return nil, errors.Join(err, rerr)
+	}
+	_, data, err = withCurrent(rs, tile)
+	return data, err
+}
+
+// TileByID returns the tile bytes for id, see Tile.
+func (rs *RefreshingSource) TileByID(ctx context.Context, id TileID) ([]byte, error) {
+	z, x, y, err := id.checkedZXY()
+	if err != nil {
+		return nil, err
+	}
+	return rs.Tile(ctx, z, x, y)
+}
+
+// refreshStale refreshes the Source unless stale has already been replaced,
+// e.g. by a concurrent read or the watcher.
+func (rs *RefreshingSource) refreshStale(ctx context.Context, stale *Source) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.current.Load() != stale {
+		return nil
+	}
+	return rs.reload(ctx)
+}
+
+// Stats returns the statistics of the current Source, which start over
+// with every refresh, see StatsReporter.
+func (rs *RefreshingSource) Stats() Stats {
+	if s, ok := rs.source().(StatsReporter); ok {
+		return s.Stats()
+	}
+	return Stats{}
+}
+
+// Probe checks that the archive of the current Source is reachable, see
+// Prober.
+func (rs *RefreshingSource) Probe(ctx context.Context) error {
+	_, _, err := withCurrent(rs, func(src Source) (struct{}, error) {
+		p, ok := src.(Prober)
+		if !ok {
+			return struct{}{}, fmt.Errorf("probing source: %T does not support probes", src)
+		}
+		return struct{}{}, p.Probe(ctx)
+	})
+	return err
+}
+
+// Header returns the header of the current Source.
+func (rs *RefreshingSource) Header() HeaderV3 {
+	return rs.source().Header()
+}
+
+// Meta returns the metadata of the current Source.
+func (rs *RefreshingSource) Meta() Metadata {
+	return rs.source().Meta()
+}
+
+// TileJSON returns the TileJSON of the current Source.
+func (rs *RefreshingSource) TileJSON(host string) TileJSON {
+	return rs.source().TileJSON(host)
+}
+
+// Coverage returns the coverage of the current Source, see Coverager.
+func (rs *RefreshingSource) Coverage(ctx context.Context, zoom uint8) (*Bitmap, error) {
+	_, bitmap, err := withCurrent(rs, func(src Source) (*Bitmap, error) {
+		return sourceCoverage(ctx, src, zoom)
+	})
+	return bitmap, err
+}
+
+// Entries yields the entries of the current Source. RefreshingSource does
+// not implement EntryReader, as the Source may be replaced between reading
+// an entry and its bytes, so Export, NewHashIndex and DiffArchives require
+// the Source of a single archive version.
+func (rs *RefreshingSource) Entries(ctx context.Context) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		er, err := sourceEntryReader(rs.source())
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+		for e, err := range er.Entries(ctx) {
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+}
+
+// Snapshot writes the cached directories of the current Source to w, see
+// Snapshotter.
+func (rs *RefreshingSource) Snapshot(ctx context.Context, w io.Writer) error {
+	s, ok := rs.source().(Snapshotter)
+	if !ok {
+		return fmt.Errorf("writing snapshot: %T does not support snapshots", rs.source())
+	}
+	return s.Snapshot(ctx, w)
+}
+
+// Restore adds the directories of a snapshot to the directory cache of the
+// current Source, see Snapshotter.
+func (rs *RefreshingSource) Restore(ctx context.Context, r io.Reader) error {
+	s, ok := rs.source().(Snapshotter)
+	if !ok {
+		return fmt.Errorf("restoring snapshot: %T does not support snapshots", rs.source())
+	}
+	return s.Restore(ctx, r)
+}
+
+// Close stops watching and closes the current Source and the reader polled
+// for versions, unless it was set with WithRangeReader.
+func (rs *RefreshingSource) Close() {
+	rs.cancel()
+	<-rs.done
+
+	if c, ok := rs.source().(sourceCloser); ok {
+		c.Close()
+	}
+	if rs.reader != nil {
+		_ = CloseReader(rs.reader) //nolint:errcheck
+	}
+}
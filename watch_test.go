@@ -0,0 +1,498 @@
+package pmtilr_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/pmtilr/pmtilrtest"
+)
+
+type versionFunc func(ctx context.Context) (string, error)
+
+func (f versionFunc) Version(ctx context.Context) (string, error) { return f(ctx) }
+
+func TestWatcher(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		version = "v1"
+	)
+	versioner := versionFunc(func(context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return version, nil
+	})
+
+	changes := make(chan [2]string, 1)
+	w := pmtilr.NewWatcher(versioner, func(_ context.Context, previous, current string) {
+		changes <- [2]string{previous, current}
+	}, pmtilr.WithWatchInterval(5*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	version = "v2"
+	mu.Unlock()
+
+	select {
+	case change := <-changes:
+		if change != [2]string{"v1", "v2"} {
+			t.Fatalf("unexpected change: %v", change)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected change to be observed")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestHTTPRangeReaderVersion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected HEAD request, got: %s", r.Method)
+		}
+		w.Header().Set("ETag", `"abc"`)
+	}))
+	defer ts.Close()
+
+	reader, err := pmtilr.NewHTTPRangeReader(ts.URL)
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	version, err := reader.Version(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if version != `"abc"` {
+		t.Fatalf("expected version %q, got: %q", `"abc"`, version)
+	}
+}
+
+func TestRefreshingSource(t *testing.T) {
+	data, err := os.ReadFile(testArchive)
+	if err != nil {
+		t.Fatalf("reading archive: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "archive.pmtiles")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("writing archive: %s", err)
+	}
+
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		path,
+		[]pmtilr.WatchOption{pmtilr.WithWatchInterval(5 * time.Millisecond)},
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	etag := src.Header().Etag
+
+	// republish the archive
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("touching archive: %s", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for src.Header().Etag == etag {
+		if time.Now().After(deadline) {
+			t.Fatal("expected source to be refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := src.Tile(t.Context(), 4, 3, 5); err != nil {
+		t.Fatalf("refreshed source should serve tiles: %s", err)
+	}
+}
+
+func TestRefreshingSourceIfRange(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		etag    = `"v1"`
+		archive = pmtilrtest.FixtureArchive(2).Bytes()
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tag, data := etag, archive
+		mu.Unlock()
+
+		// ServeContent answers a stale If-Range with the full body.
+		w.Header().Set("ETag", tag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		ts.URL,
+		[]pmtilr.WatchOption{pmtilr.WithWatchInterval(time.Hour)},
+		pmtilr.WithRangeReaderOptions(pmtilr.WithIfRange()),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Tile(t.Context(), 2, 1, 1); err != nil {
+		t.Fatalf("reading tile: %s", err)
+	}
+
+	// republish a different archive
+	mu.Lock()
+	etag, archive = `"v2"`, pmtilrtest.FixtureArchive(3).Bytes()
+	mu.Unlock()
+
+	data, err := src.Tile(t.Context(), 2, 1, 1)
+	if err != nil {
+		t.Fatalf("refreshed source should serve tiles: %s", err)
+	}
+	if string(data) != "2/1/1" {
+		t.Errorf("got tile %q, want %q", data, "2/1/1")
+	}
+	if src.Header().Etag != `"v2"` {
+		t.Errorf("expected refreshed etag %q, got %q", `"v2"`, src.Header().Etag)
+	}
+}
+
+func TestRefreshingSourceRetriesFailedRevalidation(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		etag     = `"v1"`
+		archive  = pmtilrtest.FixtureArchive(2).Bytes()
+		failures int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tag, data := etag, archive
+		fail := r.Method == http.MethodGet && failures > 0
+		if fail {
+			failures--
+		}
+		mu.Unlock()
+
+		if fail {
+			http.Error(w, "unavailable", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", tag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		ts.URL,
+		[]pmtilr.WatchOption{pmtilr.WithWatchInterval(5 * time.Millisecond)},
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	// republish the archive, the first loads of it fail.
+	mu.Lock()
+	etag, archive, failures = `"v2"`, pmtilrtest.FixtureArchive(3).Bytes(), 3
+	mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for src.Header().Etag != `"v2"` {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the failed revalidation to be retried")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRefreshingSourceStaleWhileRevalidate(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		etag    = `"v1"`
+		archive = pmtilrtest.FixtureArchive(3).WithLeafSize(4).Bytes()
+		ranges  int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tag, data := etag, archive
+		if r.Header.Get("Range") != "" {
+			ranges++
+		}
+		mu.Unlock()
+
+		w.Header().Set("ETag", tag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		ts.URL,
+		[]pmtilr.WatchOption{
+			pmtilr.WithWatchInterval(5 * time.Millisecond),
+			pmtilr.WithStaleWhileRevalidate(time.Second),
+		},
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Tile(t.Context(), 3, 5, 2); err != nil {
+		t.Fatalf("reading tile: %s", err)
+	}
+
+	// republish the archive with a different directory layout
+	mu.Lock()
+	etag, archive = `"v2"`, pmtilrtest.FixtureArchive(3).WithLeafSize(2).Bytes()
+	mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for src.Header().Etag != `"v2"` {
+		if time.Now().After(deadline) {
+			t.Fatal("expected source to be refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	ranges = 0
+	mu.Unlock()
+
+	data, err := src.Tile(t.Context(), 3, 5, 2)
+	if err != nil {
+		t.Fatalf("refreshed source should serve tiles: %s", err)
+	}
+	if string(data) != "3/5/2" {
+		t.Errorf("got tile %q, want %q", data, "3/5/2")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if ranges != 1 {
+		t.Errorf("expected the leaf directories of the recent tile to be warmed, got %d range requests", ranges)
+	}
+}
+
+func TestRefreshingSourceStaleReadDuringRevalidate(t *testing.T) {
+	v1 := pmtilrtest.FixtureArchive(3).WithLeafSize(4).Bytes()
+	v2 := pmtilrtest.FixtureArchive(3).WithLeafSize(2).Bytes()
+	header, err := pmtilr.NewHeader(bytes.NewReader(v2))
+	if err != nil {
+		t.Fatalf("reading header: %s", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		etag     = `"v1"`
+		archive  = v1
+		stalled  = make(chan struct{})
+		release  = make(chan struct{})
+		stallOne sync.Once
+	)
+	defer close(release)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tag, data := etag, archive
+		mu.Unlock()
+
+		// stall the first leaf directory read of the new archive, which is
+		// the warm-up of the revalidating Source.
+		var offset uint64
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset)
+		if tag == `"v2"` && offset >= header.LeafDirectoryOffset &&
+			offset < header.LeafDirectoryOffset+header.LeafDirectoryLength {
+			stall := false
+			stallOne.Do(func() { stall = true })
+			if stall {
+				close(stalled)
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+				return
+			}
+		}
+
+		w.Header().Set("ETag", tag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	grace := 5 * time.Second
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		ts.URL,
+		[]pmtilr.WatchOption{
+			pmtilr.WithWatchInterval(5 * time.Millisecond),
+			pmtilr.WithStaleWhileRevalidate(grace),
+		},
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Tile(t.Context(), 3, 5, 2); err != nil {
+		t.Fatalf("reading tile: %s", err)
+	}
+
+	mu.Lock()
+	etag, archive = `"v2"`, v2
+	mu.Unlock()
+
+	select {
+	case <-stalled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the new source to be warmed")
+	}
+
+	// the pinned reader of the previous Source fails with
+	// ErrArchiveChanged, which refreshes it without waiting for the warm-up.
+	start := time.Now()
+	data, err := src.Tile(t.Context(), 3, 5, 2)
+	if err != nil {
+		t.Fatalf("stale read should refresh the source: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed >= grace/2 {
+		t.Fatalf("expected the stale read not to wait for the warm-up, took %s", elapsed)
+	}
+	if string(data) != "3/5/2" {
+		t.Errorf("got tile %q, want %q", data, "3/5/2")
+	}
+}
+
+func TestRefreshingSourceSharedRangeReader(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		etag    = `"v1"`
+		archive = pmtilrtest.FixtureArchive(2).Bytes()
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tag, data := etag, archive
+		mu.Unlock()
+
+		w.Header().Set("ETag", tag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	reader, err := pmtilr.NewHTTPRangeReader(ts.URL)
+	if err != nil {
+		t.Fatalf("creating reader should not fail: %s", err)
+	}
+
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		ts.URL,
+		[]pmtilr.WatchOption{pmtilr.WithWatchInterval(time.Hour)},
+		pmtilr.WithRangeReader(reader),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	// republish a different archive
+	mu.Lock()
+	etag, archive = `"v2"`, pmtilrtest.FixtureArchive(3).Bytes()
+	mu.Unlock()
+
+	if err := src.Refresh(t.Context()); err != nil {
+		t.Fatalf("refreshing the shared reader should not fail: %s", err)
+	}
+	if src.Header().Etag != `"v2"` {
+		t.Errorf("expected refreshed etag %q, got %q", `"v2"`, src.Header().Etag)
+	}
+	data, err := src.Tile(t.Context(), 3, 5, 2)
+	if err != nil {
+		t.Fatalf("refreshed source should serve tiles: %s", err)
+	}
+	if string(data) != "3/5/2" {
+		t.Errorf("got tile %q, want %q", data, "3/5/2")
+	}
+}
+
+func TestRefreshingSourceReadsDuringRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.pmtiles")
+	if err := os.WriteFile(path, pmtilrtest.FixtureArchive(2).Bytes(), 0o600); err != nil {
+		t.Fatalf("writing archive: %s", err)
+	}
+
+	src, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		path,
+		[]pmtilr.WatchOption{pmtilr.WithWatchInterval(time.Hour)},
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err != nil {
+		t.Fatalf("creating source should not fail: %s", err)
+	}
+	defer src.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				// reads racing with a refresh must not see the closed Source.
+				if _, err := src.Tile(ctx, 2, 1, 3); err != nil && ctx.Err() == nil {
+					t.Errorf("unexpected error during refresh: %s", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for range 100 {
+		if err := src.Refresh(t.Context()); err != nil {
+			t.Errorf("refreshing source: %s", err)
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestRefreshingSourceRequiresVersioner(t *testing.T) {
+	// decorators unwrap to the mock reader, which does not report versions.
+	reader := pmtilr.NewRetryRangeReader(
+		pmtilrtest.NewRangeReader(pmtilrtest.FixtureArchive(2).Bytes()),
+		pmtilr.RetryPolicy{},
+	)
+
+	_, err := pmtilr.NewRefreshingSource(
+		t.Context(),
+		"",
+		nil,
+		pmtilr.WithRangeReader(reader),
+		pmtilr.WithDisableInstrumentation(),
+	)
+	if err == nil {
+		t.Fatal("expected error for a reader without versions")
+	}
+}
@@ -0,0 +1,133 @@
+package pmtilr_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iwpnd/pmtilr"
+	"github.com/iwpnd/rip"
+)
+
+func TestWebDAVRangeReader(t *testing.T) {
+	tests := []struct {
+		name         string
+		uri          func(host string) string
+		options      []rip.Option
+		expectedAuth string
+		expectedKey  string
+	}{
+		{
+			name: "basic auth from uri",
+			uri: func(host string) string {
+				return "webdav://alice:secret@" + host + "/remote.php/dav/files/map.pmtiles"
+			},
+			expectedAuth: "Basic YWxpY2U6c2VjcmV0",
+		},
+		{
+			name: "bearer token from uri",
+			uri: func(host string) string {
+				return "webdav://t0k3n@" + host + "/remote.php/dav/files/map.pmtiles"
+			},
+			expectedAuth: "Bearer t0k3n",
+		},
+		{
+			name: "bearer token option",
+			uri: func(host string) string {
+				return "webdav://" + host + "/remote.php/dav/files/map.pmtiles"
+			},
+			options:      []rip.Option{pmtilr.WithBearerToken("t0k3n")},
+			expectedAuth: "Bearer t0k3n",
+		},
+		{
+			name: "auth options keep default headers",
+			uri: func(host string) string {
+				return "webdav://" + host + "/remote.php/dav/files/map.pmtiles"
+			},
+			options: []rip.Option{
+				rip.WithDefaultHeaders(rip.Header{"X-Api-Key": "key"}),
+				pmtilr.WithBasicAuth("alice", "secret"),
+			},
+			expectedAuth: "Basic YWxpY2U6c2VjcmV0",
+			expectedKey:  "key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path != "/remote.php/dav/files/map.pmtiles" {
+						t.Errorf("unexpected path: %s", r.URL.Path)
+					}
+					if got := r.Header.Get("Authorization"); got != tt.expectedAuth {
+						t.Errorf("expected Authorization %q, got: %q", tt.expectedAuth, got)
+					}
+					if got := r.Header.Get("X-Api-Key"); got != tt.expectedKey {
+						t.Errorf("expected X-Api-Key %q, got: %q", tt.expectedKey, got)
+					}
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write([]byte("fake"))
+				}))
+			defer ts.Close()
+
+			host := strings.TrimPrefix(ts.URL, "http://")
+
+			reader, err := pmtilr.NewWebDAVRangeReader(tt.uri(host), tt.options...)
+			if err != nil {
+				t.Fatalf("creating reader should not fail: %s", err)
+			}
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer rc.Close()
+
+			result, _ := io.ReadAll(rc)
+			if string(result) != "fake" {
+				t.Fatalf("expected %s, got: %s", "fake", string(result))
+			}
+		})
+	}
+}
+
+func TestNewRangeReaderUserinfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		userinfo     string
+		expectedAuth string
+	}{
+		{name: "basic auth", userinfo: "alice:secret@", expectedAuth: "Basic YWxpY2U6c2VjcmV0"},
+		{name: "bearer token", userinfo: "t0k3n@", expectedAuth: "Bearer t0k3n"},
+		{name: "no credentials", userinfo: "", expectedAuth: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if got := r.Header.Get("Authorization"); got != tt.expectedAuth {
+						t.Errorf("expected Authorization %q, got: %q", tt.expectedAuth, got)
+					}
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write([]byte("fake"))
+				}))
+			defer ts.Close()
+
+			uri := "http://" + tt.userinfo + strings.TrimPrefix(ts.URL, "http://") + "/map.pmtiles"
+			reader, err := pmtilr.NewRangeReader(t.Context(), uri)
+			if err != nil {
+				t.Fatalf("creating reader should not fail: %s", err)
+			}
+
+			rc, err := reader.ReadRange(t.Context(), pmtilr.NewRange(0, 4))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			rc.Close()
+		})
+	}
+}
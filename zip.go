@@ -0,0 +1,207 @@
+package pmtilr
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// zipMemberSeparator separates the path of the ZIP archive from the member
+// path in a "zip://" URI, e.g. "zip:///data/bundle.zip!/tiles/map.pmtiles".
+// The member may also be set as fragment, e.g.
+// "zip:///data/bundle.zip#tiles/map.pmtiles".
+const zipMemberSeparator = "!"
+
+// ZipRangeReader implements RangeReader for a single stored (uncompressed)
+// member of a ZIP archive. The central directory is read once on creation,
+// afterwards every range is translated into a range read into the member
+// data of the underlying archive.
+type ZipRangeReader struct {
+	reader RangeReader
+	offset uint64
+	size   uint64
+}
+
+// NewZipRangeReader reads the central directory of the ZIP archive of the given
+// size behind reader and returns a ZipRangeReader for the named member.
+//
+// Returns an error if the member does not exist or is compressed; PMTiles
+// archives must be stored in the ZIP without compression to be range-readable.
+func NewZipRangeReader(
+	ctx context.Context,
+	reader RangeReader,
+	size uint64,
+	member string,
+) (*ZipRangeReader, error) {
+	zr, err := zip.NewReader(&rangeReaderAt{ctx: ctx, reader: reader}, int64(size)) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("reading zip central directory: %w", err)
+	}
+
+	member = strings.TrimPrefix(member, "/")
+	for _, f := range zr.File {
+		if f.Name != member {
+			continue
+		}
+
+		if f.Method != zip.Store {
+			return nil, fmt.Errorf(
+				"zip member %q is compressed (method %d), only stored members are supported",
+				member, f.Method,
+			)
+		}
+
+		offset, err := f.DataOffset()
+		if err != nil {
+			return nil, fmt.Errorf("resolving zip member %q offset: %w", member, err)
+		}
+
+		return &ZipRangeReader{
+			reader: reader,
+			offset: uint64(offset), //nolint:gosec
+			size:   f.UncompressedSize64,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("zip member %q not found", member)
+}
+
+// newZipRangeReaderFromURI opens the ZIP archive referenced by a "zip://"
+// URI and returns a ZipRangeReader for the member behind the "!" or in the
+// fragment. Remote archives are referenced by the URI of the archive
+// prefixed with "zip+", e.g. "zip+s3://bucket/bundle.zip!/map.pmtiles", and
+// read with the RangeReader for that URI, which must implement Sizer.
+func newZipRangeReaderFromURI(
+	ctx context.Context,
+	u *URI,
+	options ...RangeReaderOption,
+) (*ZipRangeReader, error) {
+	if scheme, ok := strings.CutPrefix(u.Raw().Scheme, SchemeZip.String()+"+"); ok {
+		return newRemoteZipRangeReader(ctx, scheme, u, options...)
+	}
+
+	archive, member, ok := u.FullPath(), u.Fragment(), true
+	if member == "" {
+		archive, member, ok = strings.Cut(u.FullPath(), zipMemberSeparator)
+	}
+	if !ok || member == "" {
+		return nil, fmt.Errorf("zip URI %q is missing a member path after %q", u.Raw(), zipMemberSeparator)
+	}
+
+	info, err := os.Stat(archive)
+	if err != nil {
+		return nil, fmt.Errorf("stat zip archive %s: %w", archive, err)
+	}
+
+	reader, err := NewFileRangeReader(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := NewZipRangeReader(ctx, reader, uint64(info.Size()), filepath.ToSlash(member)) //nolint:gosec
+	if err != nil {
+		_ = reader.Close() //nolint:errcheck
+		return nil, err
+	}
+	return zr, nil
+}
+
+// newRemoteZipRangeReader reads the member of the ZIP archive at the URI of
+// u with the "zip+" prefix removed. The member is separated at the last
+// "!", so it may follow a query, e.g. of a presigned URL, or set as
+// fragment.
+func newRemoteZipRangeReader(
+	ctx context.Context,
+	scheme string,
+	u *URI,
+	options ...RangeReaderOption,
+) (*ZipRangeReader, error) {
+	_, rest, _ := strings.Cut(u.withoutFragment(), ":")
+	archive, member := scheme+":"+rest, u.Fragment()
+	if member == "" {
+		i := strings.LastIndex(rest, zipMemberSeparator)
+		if i < 0 || i == len(rest)-1 {
+			return nil, fmt.Errorf("zip URI %q is missing a member path after %q", u.Raw(), zipMemberSeparator)
+		}
+		archive, member = scheme+":"+rest[:i], rest[i+1:]
+	}
+
+	reader, err := NewRangeReader(ctx, archive, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := readerSize(ctx, reader)
+	if err != nil {
+		_ = CloseReader(reader) //nolint:errcheck
+		return nil, fmt.Errorf("reading zip archive size: %w", err)
+	}
+
+	zr, err := NewZipRangeReader(ctx, reader, size, member)
+	if err != nil {
+		_ = CloseReader(reader) //nolint:errcheck
+		return nil, err
+	}
+	return zr, nil
+}
+
+// Size returns the size of the ZIP member, see Sizer.
+func (z *ZipRangeReader) Size(_ context.Context) (uint64, error) {
+	return z.size, nil
+}
+
+// ReadRange reads bytes from the ZIP member at the specified range. Like
+// io.SectionReader, reads past the end of the member are truncated.
+func (z *ZipRangeReader) ReadRange(ctx context.Context, ranger Ranger) (io.ReadCloser, error) {
+	if err := ranger.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranger: %w", err)
+	}
+
+	if ranger.Offset() >= z.size {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	length := min(ranger.Length(), z.size-ranger.Offset())
+
+	return z.reader.ReadRange(ctx, NewRange(z.offset+ranger.Offset(), length))
+}
+
+// Unwrap returns the reader of the ZIP archive, see Unwrapper.
+func (z *ZipRangeReader) Unwrap() RangeReader {
+	return z.reader
+}
+
+// rangeReaderAt adapts a RangeReader to io.ReaderAt for consumers of the
+// standard library that expect random access, e.g. archive/zip.
+type rangeReaderAt struct {
+	ctx    context.Context //nolint:containedctx
+	reader RangeReader
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	rc, err := r.reader.ReadRange(r.ctx, NewRange(uint64(off), uint64(len(p))))
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	n, err = io.ReadFull(rc, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+
+	return n, err
+}
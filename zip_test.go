@@ -0,0 +1,150 @@
+package pmtilr_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iwpnd/pmtilr"
+)
+
+const testArchive = "testdata/cb_2018_us_county_500k.pmtiles"
+
+func writeZipBundle(t *testing.T, method uint16) string {
+	t.Helper()
+
+	data, err := os.ReadFile(testArchive)
+	if err != nil {
+		t.Fatalf("reading testdata should not error: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating zip should not error: %s", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	readme, _ := zw.Create("README.md")
+	readme.Write([]byte("offline bundle"))
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "tiles/map.pmtiles", Method: method})
+	if err != nil {
+		t.Fatalf("creating zip member should not error: %s", err)
+	}
+	w.Write(data)
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing zip should not error: %s", err)
+	}
+
+	return path
+}
+
+func TestZipRangeReader(t *testing.T) {
+	t.Run("reads tiles from stored member", func(t *testing.T) {
+		bundle := writeZipBundle(t, zip.Store)
+
+		direct, err := pmtilr.NewSource(
+			t.Context(), testArchive, pmtilr.WithDisableInstrumentation(),
+		)
+		if err != nil {
+			t.Fatalf("creating source should not fail: %s", err)
+		}
+
+		zipped, err := pmtilr.NewSource(
+			t.Context(), "zip://"+bundle+"!/tiles/map.pmtiles", pmtilr.WithDisableInstrumentation(),
+		)
+		if err != nil {
+			t.Fatalf("creating zip source should not fail: %s", err)
+		}
+
+		want, err := direct.Tile(t.Context(), 4, 3, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got, err := zipped.Tile(t.Context(), 4, 3, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !bytes.Equal(want, got) {
+			t.Fatalf("expected tile of %d bytes, got %d bytes", len(want), len(got))
+		}
+	})
+
+	t.Run("reads member from fragment", func(t *testing.T) {
+		bundle := writeZipBundle(t, zip.Store)
+
+		if _, err := pmtilr.NewRangeReader(t.Context(), "zip://"+bundle+"#tiles/map.pmtiles"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("fails for compressed member", func(t *testing.T) {
+		bundle := writeZipBundle(t, zip.Deflate)
+
+		_, err := pmtilr.NewRangeReader(t.Context(), "zip://"+bundle+"!/tiles/map.pmtiles")
+		if err == nil {
+			t.Fatal("expected error for compressed member")
+		}
+	})
+
+	t.Run("fails for missing member", func(t *testing.T) {
+		bundle := writeZipBundle(t, zip.Store)
+
+		_, err := pmtilr.NewRangeReader(t.Context(), "zip://"+bundle+"!/tiles/missing.pmtiles")
+		if err == nil {
+			t.Fatal("expected error for missing member")
+		}
+	})
+}
+
+func TestRemoteZipRangeReader(t *testing.T) {
+	bundle, err := os.ReadFile(writeZipBundle(t, zip.Store))
+	if err != nil {
+		t.Fatalf("reading bundle should not error: %s", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(bundle))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{name: "member", uri: "zip+" + server.URL + "/bundle.zip!/tiles/map.pmtiles"},
+		{name: "member after query", uri: "zip+" + server.URL + "/bundle.zip?sig=abc!/tiles/map.pmtiles"},
+		{name: "member in fragment", uri: "zip+" + server.URL + "/bundle.zip?sig=abc#tiles/map.pmtiles"},
+		{name: "missing member", uri: "zip+" + server.URL + "/bundle.zip!/tiles/missing.pmtiles", wantErr: true},
+		{name: "missing member path", uri: "zip+" + server.URL + "/bundle.zip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := pmtilr.NewSource(t.Context(), tt.uri, pmtilr.WithDisableInstrumentation())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("creating zip source should not fail: %s", err)
+			}
+
+			if _, err := src.Tile(t.Context(), 4, 3, 5); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}